@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+// grantPermission handles PUT requests to the /v1/admin/permissions endpoint.
+// It grants a permission to the user with the given email. Granting a
+// permission that the user already has isn't an error. See changePermission
+// for details of the request and response.
+func (app *APIApplication) grantPermission(w http.ResponseWriter, r *http.Request) {
+	app.changePermission(w, r, app.Models.Permissions.AddForUser)
+}
+
+// revokePermission handles DELETE requests to the /v1/admin/permissions
+// endpoint. It revokes a permission from the user with the given email.
+// Revoking a permission that the user doesn't have isn't an error. See
+// changePermission for details of the request and response.
+func (app *APIApplication) revokePermission(w http.ResponseWriter, r *http.Request) {
+	app.changePermission(w, r, app.Models.Permissions.RemoveForUser)
+}
+
+// changePermission reads the email of a user and a permission code from the
+// request body, and applies change to them. The body has the following form:
+//
+//	{ "email": "alice@example.com", "permission": "todos:write" }
+//
+// A failedValidationResponse is sent if the email is invalid, or if the
+// permission isn't one of data.PermissionCodes, and a notFoundResponse if
+// there is no user with the email. On success, a 200 OK response is sent with
+// the user's permissions after the change:
+//
+//	{ "email": "alice@example.com", "permissions": ["todos:read", "todos:write"] }
+func (app *APIApplication) changePermission(w http.ResponseWriter, r *http.Request, change func(userID int64, permissions ...data.PermissionCode) error) {
+	var input struct {
+		Email      string              `json:"email"`
+		Permission data.PermissionCode `json:"permission"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateEmail(v, input.Email)
+	v.CheckRule(validator.PermittedValue(input.Permission, data.PermissionCodes...),
+		"permission", validator.RuleNotAllowed, "must be todos:read, todos:write, or admin")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	user, err := app.Models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = change(user.ID, input.Permission)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	permissions, err := app.Models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if permissions == nil {
+		permissions = data.Permissions{}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"email": user.Email, "permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
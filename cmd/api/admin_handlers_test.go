@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/data"
+	"github.com/lib/pq"
+)
+
+// expectPermissions expects the query for the user's permissions, returning
+// the given codes.
+func expectPermissions(mock sqlmock.Sqlmock, userID int64, codes ...string) {
+	rows := sqlmock.NewRows([]string{"code"})
+	for _, code := range codes {
+		rows.AddRow(code)
+	}
+	mock.ExpectQuery("SELECT permissions.code FROM permissions").
+		WithArgs(userID).
+		WillReturnRows(rows)
+}
+
+func TestChangePermission(t *testing.T) {
+	// alice is the user whose permissions are changed.
+	const aliceID = 2
+	aliceRow := func() *sqlmock.Rows {
+		return sqlmock.NewRows(userColumns).
+			AddRow(aliceID, time.Now(), "alice", "alice@example.com", []byte("hash"), true, 1)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		setup  func(mock sqlmock.Sqlmock)
+		status int
+		want   string // The permissions in a successful response, or the error.
+	}{
+		{
+			name:   "Grant",
+			method: http.MethodPut,
+			body:   `{"email": "alice@example.com", "permission": "todos:write"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectPermissions(mock, testUser.ID, "admin")
+				mock.ExpectQuery("SELECT (.+) FROM users where email = \\$1").
+					WithArgs("alice@example.com").
+					WillReturnRows(aliceRow())
+				mock.ExpectExec("INSERT INTO users_permissions").
+					WithArgs(aliceID, pq.Array([]data.PermissionCode{data.TodosWrite})).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectPermissions(mock, aliceID, "todos:read", "todos:write")
+			},
+			status: http.StatusOK,
+			want:   "todos:read,todos:write",
+		},
+		{
+			name:   "Revoke",
+			method: http.MethodDelete,
+			body:   `{"email": "alice@example.com", "permission": "todos:write"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectPermissions(mock, testUser.ID, "admin")
+				mock.ExpectQuery("SELECT (.+) FROM users where email = \\$1").
+					WithArgs("alice@example.com").
+					WillReturnRows(aliceRow())
+				mock.ExpectExec("DELETE FROM users_permissions").
+					WithArgs(aliceID, pq.Array([]data.PermissionCode{data.TodosWrite})).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectPermissions(mock, aliceID, "todos:read")
+			},
+			status: http.StatusOK,
+			want:   "todos:read",
+		},
+		{
+			name:   "Revoke last permission",
+			method: http.MethodDelete,
+			body:   `{"email": "alice@example.com", "permission": "todos:read"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectPermissions(mock, testUser.ID, "admin")
+				mock.ExpectQuery("SELECT (.+) FROM users where email = \\$1").
+					WillReturnRows(aliceRow())
+				mock.ExpectExec("DELETE FROM users_permissions").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectPermissions(mock, aliceID)
+			},
+			status: http.StatusOK,
+			want:   "",
+		},
+		{
+			name:   "Not an admin",
+			method: http.MethodPut,
+			body:   `{"email": "alice@example.com", "permission": "admin"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectPermissions(mock, testUser.ID, "todos:read", "todos:write")
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "Unknown permission",
+			method: http.MethodPut,
+			body:   `{"email": "alice@example.com", "permission": "todos:delete"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectPermissions(mock, testUser.ID, "admin")
+			},
+			status: http.StatusUnprocessableEntity,
+			want:   "must be todos:read, todos:write, or admin",
+		},
+		{
+			name:   "Unknown user",
+			method: http.MethodPut,
+			body:   `{"email": "bob@example.com", "permission": "todos:read"}`,
+			setup: func(mock sqlmock.Sqlmock) {
+				expectPermissions(mock, testUser.ID, "admin")
+				mock.ExpectQuery("SELECT (.+) FROM users where email = \\$1").
+					WithArgs("bob@example.com").
+					WillReturnRows(sqlmock.NewRows(userColumns))
+			},
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+			tt.setup(mock)
+
+			handler := app.requirePermission(data.Admin, app.grantPermission)
+			if tt.method == http.MethodDelete {
+				handler = app.requirePermission(data.Admin, app.revokePermission)
+			}
+
+			r := newTestRequest(tt.method, "/v1/admin/permissions", strings.NewReader(tt.body), nil)
+			rr := httptest.NewRecorder()
+
+			handler(rr, r)
+
+			assert.Equal(t, rr.Code, tt.status)
+			if rr.Code == http.StatusOK {
+				var response struct {
+					Email       string   `json:"email"`
+					Permissions []string `json:"permissions"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, response.Email, "alice@example.com")
+				assert.Equal(t, response.Permissions != nil, true)
+				assert.Equal(t, strings.Join(response.Permissions, ","), tt.want)
+			} else {
+				assert.StringContains(t, rr.Body.String(), tt.want)
+			}
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
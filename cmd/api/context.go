@@ -14,6 +14,7 @@ type contextKey string
 var (
 	userContextKey    = contextKey("user")
 	requestContextKey = contextKey("requestContext")
+	routeContextKey   = contextKey("route")
 )
 
 // The contextSetUser method accepts a request and a user struct as arguments,
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// poolHealth is a summary of the connection pool's health, derived from two
+// consecutive samples of db.Stats().
+type poolHealth struct {
+	// WaitCountDelta is the number of connections that had to be waited for
+	// since the previous sample.
+	WaitCountDelta int64 `json:"wait_count_delta"`
+
+	// Saturated is true if any connections had to be waited for since the
+	// previous sample, or if every allowed connection is in use.
+	Saturated bool `json:"saturated"`
+}
+
+// poolMonitor computes poolHealth from successive samples of db.Stats(). The
+// zero value is ready to use.
+type poolMonitor struct {
+	mu            sync.Mutex
+	lastWaitCount int64
+}
+
+// sample compares the stats with the previous sample and returns the pool's
+// health. The first sample is compared against zero.
+func (pm *poolMonitor) sample(stats sql.DBStats) poolHealth {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	// WaitCount only increases, unless the pool is replaced. In that case,
+	// count all of the new pool's waits.
+	delta := stats.WaitCount - pm.lastWaitCount
+	if delta < 0 {
+		delta = stats.WaitCount
+	}
+	pm.lastWaitCount = stats.WaitCount
+
+	exhausted := stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections
+
+	return poolHealth{
+		WaitCountDelta: delta,
+		Saturated:      delta > 0 || exhausted,
+	}
+}
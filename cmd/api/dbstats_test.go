@@ -0,0 +1,56 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestPoolMonitor(t *testing.T) {
+	var pm poolMonitor
+
+	samples := []struct {
+		name     string
+		stats    sql.DBStats
+		expected poolHealth
+	}{
+		{
+			name:     "Idle pool",
+			stats:    sql.DBStats{MaxOpenConnections: 25, InUse: 2},
+			expected: poolHealth{},
+		},
+		{
+			name:     "New waits",
+			stats:    sql.DBStats{MaxOpenConnections: 25, InUse: 10, WaitCount: 4},
+			expected: poolHealth{WaitCountDelta: 4, Saturated: true},
+		},
+		{
+			name:     "No new waits",
+			stats:    sql.DBStats{MaxOpenConnections: 25, InUse: 10, WaitCount: 4},
+			expected: poolHealth{},
+		},
+		{
+			name:     "Every connection in use",
+			stats:    sql.DBStats{MaxOpenConnections: 25, InUse: 25, WaitCount: 4},
+			expected: poolHealth{Saturated: true},
+		},
+		{
+			name:     "Unlimited connections",
+			stats:    sql.DBStats{MaxOpenConnections: 0, InUse: 100, WaitCount: 4},
+			expected: poolHealth{},
+		},
+		{
+			name:     "Pool replaced",
+			stats:    sql.DBStats{MaxOpenConnections: 25, InUse: 1, WaitCount: 1},
+			expected: poolHealth{WaitCountDelta: 1, Saturated: true},
+		},
+	}
+
+	// Samples are taken in order, so each is compared with the last.
+	for _, s := range samples {
+		t.Run(s.name, func(t *testing.T) {
+			assert.Equal(t, pm.sample(s.stats), s.expected)
+		})
+	}
+}
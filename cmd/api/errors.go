@@ -1,8 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/data"
 )
 
 // logError logs an error message, as well as the request method and URL.
@@ -47,9 +52,24 @@ func (app *APIApplication) errorResponse(w http.ResponseWriter, r *http.Request,
 // The serverErrorResponse helper logs an unexpected error at runtime.
 // It logs the detailed error message, and uses app.errorResponse to send a 500
 // Internal Server Error with a generic error message to the client.
+//
+// If the error is a data.ErrCircuitOpen, the database is assumed to be down,
+// and a 503 Service Unavailable is sent instead, so that clients know to try
+// again later.
 func (app *APIApplication) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err.Error())
 
+	if errors.Is(err, data.ErrCircuitOpen) {
+		cooldown := data.DefaultBreakerCooldown
+		if todos, ok := app.Models.Todos.(data.TodoModel); ok {
+			cooldown = todos.Breaker.Cooldown()
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(cooldown.Seconds())))
+		msg := "the database is temporarily unavailable, please try again later"
+		app.errorResponse(w, r, http.StatusServiceUnavailable, msg)
+		return
+	}
+
 	msg := "the server encountered a problem and couldn't process your request"
 	app.errorResponse(w, r, http.StatusInternalServerError, msg)
 }
@@ -61,8 +81,35 @@ func (app *APIApplication) notFoundResponse(w http.ResponseWriter, r *http.Reque
 	app.errorResponse(w, r, http.StatusNotFound, msg)
 }
 
+// routeNotFoundResponse sends a JSON response with a 404 status code when no
+// route matches the request. Unlike notFoundResponse, the response includes
+// the requested path, and a hint for finding the API, since the path may have
+// a typo or be missing the version prefix:
+//
+//	{
+//	    "error": "the requested resource cannot be found",
+//	    "path": "/todos",
+//	    "hint": "all endpoints begin with /v1, such as GET /v1/healthcheck"
+//	}
+func (app *APIApplication) routeNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	msg := "the requested resource cannot be found"
+	app.logError(r, msg)
+
+	env := envelope{
+		"error": msg,
+		"path":  r.URL.Path,
+		"hint":  "all endpoints begin with /v1, such as GET /v1/healthcheck",
+	}
+	err := app.writeJSON(w, http.StatusNotFound, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 // methodNotAllowedResponse sends a JSON response with a 405 status code, and
-// logs it using app.errorResponse().
+// logs it using app.errorResponse(). When it is used as the router's
+// MethodNotAllowed handler, httprouter has already set the Allow header to the
+// methods that the path does allow.
 func (app *APIApplication) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	msg := fmt.Sprintf("the %s method is not allowed for this resource", r.Method)
 	app.errorResponse(w, r, http.StatusMethodNotAllowed, msg)
@@ -75,9 +122,44 @@ func (app *APIApplication) badRequestResponse(w http.ResponseWriter, r *http.Req
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
 
-// failedValidationResponse sends a JSON response with a 422 status code, and logs it using app.errorResponse(). It accepts a map of errors and their messages and sends them in the response.
-func (app *APIApplication) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+// fieldError is the message and rule code of a validation error for a single
+// field.
+type fieldError struct {
+	Message string `json:"message"`
+	Rule    string `json:"rule"`
+}
+
+// failedValidationResponse sends a JSON response with a 422 status code, and
+// logs it using app.errorResponse(). The response contains the message and
+// rule code of each of the validator's errors:
+//
+//	{ "error": { "text": { "message": "must be provided", "rule": "required" } } }
+//
+// If the request's X-Error-Format header is "flat", only the messages are
+// sent, as they were before rule codes were added:
+//
+//	{ "error": { "text": "must be provided" } }
+func (app *APIApplication) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	w.Header().Add("Vary", "X-Error-Format")
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, app.validationErrors(r, v))
+}
+
+// validationErrors returns the validator's errors in the format requested by
+// the X-Error-Format header. See failedValidationResponse.
+func (app *APIApplication) validationErrors(r *http.Request, v *validator.Validator) any {
+	if r.Header.Get("X-Error-Format") == "flat" {
+		return v.Errors
+	}
+
+	fieldErrors := make(map[string]fieldError, len(v.Errors))
+	for key, message := range v.Errors {
+		rule := v.Rules[key]
+		if rule == "" {
+			rule = validator.RuleInvalid
+		}
+		fieldErrors[key] = fieldError{Message: message, Rule: rule}
+	}
+	return fieldErrors
 }
 
 // editConflictResponse sends a JSON response with a 409 status code and a
@@ -95,6 +177,25 @@ func (app *APIApplication) rateLimitExceededReponse(w http.ResponseWriter, r *ht
 	app.errorResponse(w, r, http.StatusTooManyRequests, msg)
 }
 
+// duplicateTodoResponse sends a JSON response with a 409 status code, and the
+// ID of the existing todo that the new todo duplicates.
+func (app *APIApplication) duplicateTodoResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	msg := "an active todo with the same text already exists"
+	app.logError(r, msg)
+
+	err := app.writeJSON(w, http.StatusConflict, envelope{"error": msg, "id": id}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// unsupportedMediaTypeResponse sends a JSON response with a 415 status code,
+// and a message naming the media type that the endpoint accepts.
+func (app *APIApplication) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, mediaType string) {
+	msg := fmt.Sprintf("Content-Type must be %s", mediaType)
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, msg)
+}
+
 // notFoundResponse sends JSON response with a 404 status code, and logs it
 // using app.errorResponse().
 func (app *APIApplication) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+func TestServerErrorResponseCircuitOpen(t *testing.T) {
+	app, _ := newTestApplication(t)
+	todos := app.Models.Todos.(data.TodoModel)
+	todos.Breaker = data.NewBreaker(1, 42*time.Second)
+	app.Models.Todos = todos
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/todos", nil)
+	app.serverErrorResponse(rr, r, data.ErrCircuitOpen)
+
+	// Clients are told to wait for the breaker's cooldown.
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "42")
+
+	rr = httptest.NewRecorder()
+	app.serverErrorResponse(rr, r, errors.New("boom"))
+	assert.Equal(t, rr.Code, http.StatusInternalServerError)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+// escalationPageSize is the number of overdue todos that escalatePriorities
+// retrieves at a time, so that a large backlog isn't loaded all at once.
+var escalationPageSize = 500
+
+// runEscalation escalates the priority of overdue todos every
+// app.Config.Escalation.Interval, until the server begins shutting down. It
+// is intended to be launched with app.background. See data.Escalations for
+// which todos are escalated.
+func (app *APIApplication) runEscalation() {
+	interval := app.Config.Escalation.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	app.Logger.Info("priority escalation enabled", "interval", interval.String())
+
+	for {
+		app.escalatePriorities(app.Models.Clock.Now())
+
+		select {
+		case <-app.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// escalatePriorities escalates the priority of each of the overdue todos that
+// data.Escalations selects at the time now. Each change is logged, and the
+// owner of each changed todo is sent a todosChanged event. Errors are logged,
+// and don't stop the remaining todos from being escalated.
+func (app *APIApplication) escalatePriorities(now time.Time) {
+	ctx := context.Background()
+	changed := make(map[int64]bool)
+
+	// The overdue todos are retrieved a page at a time, in order of ID.
+	var afterID int64
+	for {
+		todos, err := app.Models.Todos.GetOverdue(ctx, now, afterID, escalationPageSize)
+		if err != nil {
+			app.Logger.Error("failed to get overdue todos", "error", err)
+			break
+		}
+
+		for _, e := range data.Escalations(todos, now) {
+			err := app.Models.Todos.Escalate(ctx, e, now)
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				// The todo was changed since it was retrieved, so it is skipped
+				// until the next run.
+				continue
+			case err != nil:
+				app.Logger.Error("failed to escalate todo priority", "todo_id", e.TodoID, "user_id", e.UserID, "error", err)
+				continue
+			}
+
+			app.Logger.Info("escalated todo priority", "todo_id", e.TodoID, "user_id", e.UserID, "from", e.From, "to", e.To)
+			changed[e.UserID] = true
+		}
+
+		if len(todos) < escalationPageSize {
+			break
+		}
+		afterID = todos[len(todos)-1].ID
+	}
+
+	for userID := range changed {
+		app.todoEvents.publish(userID, todoEvent{Type: todosChanged})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+func TestEscalatePrioritiesPages(t *testing.T) {
+	app, _ := newTestApplication(t)
+	todos := data.NewMemoryTodoModel()
+	app.Models.Todos = todos
+
+	// The overdue todos span several pages.
+	pageSize := escalationPageSize
+	escalationPageSize = 2
+	t.Cleanup(func() { escalationPageSize = pageSize })
+
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	due := now.AddDate(0, 0, -2)
+	notDue := now.AddDate(0, 0, 1)
+	insert := []*data.Todo{
+		{UserID: 1, Text: "pay rent", Priority: "B", DueDate: &due},
+		{UserID: 2, Text: "call mom", Priority: "C", DueDate: &due},
+		{UserID: 1, Text: "file taxes", Priority: "D", DueDate: &notDue},
+		{UserID: 1, Text: "renew passport", Priority: "E", DueDate: &due},
+		{UserID: 2, Text: "buy milk", Priority: "F", DueDate: &due},
+		{UserID: 1, Text: "water plants", Priority: "A", DueDate: &due},
+	}
+	assert.IsNil(t, todos.InsertMany(context.Background(), insert))
+
+	app.escalatePriorities(now)
+
+	want := []string{"A", "B", "D", "D", "E", "A"}
+	for i, todo := range insert {
+		got, err := todos.GetTodoIfOwned(context.Background(), todo.ID, todo.UserID)
+		assert.IsNil(t, err)
+		assert.Equal(t, got.Priority, want[i])
+	}
+}
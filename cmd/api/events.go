@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+// Types of todoEvent.
+const (
+	todoCreated = "created"
+	todoUpdated = "updated"
+	todoDeleted = "deleted"
+
+	// todosChanged is sent after an operation that changes several todos at
+	// once. Subscribers should fetch the user's todos again.
+	todosChanged = "changed"
+)
+
+// todoEvent describes a change to one or more of a user's todos. Todo is set
+// for created and updated events, and ID for deleted events.
+type todoEvent struct {
+	Type string     `json:"type"`
+	ID   int64      `json:"id,omitempty"`
+	Todo *data.Todo `json:"todo,omitempty"`
+}
+
+// todoEventBufferSize is the number of events that can be queued for each
+// subscriber. If a subscriber falls this far behind, further events are
+// dropped until it catches up.
+const todoEventBufferSize = 16
+
+// todoBroker is an in-process publish/subscribe broker for todoEvents. Each
+// event is published to a single user, and is only sent to that user's
+// subscribers.
+type todoBroker struct {
+	mu     sync.Mutex
+	subs   map[int64]map[chan todoEvent]struct{}
+	closed bool
+}
+
+func newTodoBroker() *todoBroker {
+	return &todoBroker{subs: make(map[int64]map[chan todoEvent]struct{})}
+}
+
+// subscribe returns a channel that receives the user's events, and a function
+// that must be called to unsubscribe. The channel is closed when the
+// subscription ends, either by unsubscribing or by closing the broker.
+func (b *todoBroker) subscribe(userID int64) (<-chan todoEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan todoEvent, todoEventBufferSize)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan todoEvent]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subs[userID][ch]; ok {
+			delete(b.subs[userID], ch)
+			if len(b.subs[userID]) == 0 {
+				delete(b.subs, userID)
+			}
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// publish sends the event to each of the user's subscribers. It never blocks.
+// If a subscriber's buffer is full, the event is dropped for that subscriber.
+func (b *todoBroker) publish(userID int64, event todoEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// close ends every subscription, and prevents new ones. It is called when the
+// server shuts down, so that open streams don't delay the shutdown.
+func (b *todoBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for userID, chans := range b.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(b.subs, userID)
+	}
+	b.closed = true
+}
+
+// streamHeartbeatInterval is how often a comment is sent on idle streams, to
+// keep the connection from being closed by proxies.
+const streamHeartbeatInterval = 15 * time.Second
+
+// streamTodos handles GET requests to the /v1/stream/todos endpoint. It
+// responds with a stream of Server-Sent Events, sending an event each time one
+// of the user's todos is created, updated, or deleted. The event's name is its
+// type, and its data is the JSON encoded todoEvent:
+//
+//	event: updated
+//	data: {"type":"updated","todo":{"id":1, ...}}
+//
+// The stream stays open until the client disconnects or the server shuts
+// down.
+func (app *APIApplication) streamTodos(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	// The server's write timeout would otherwise end the stream.
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	events, unsubscribe := app.todoEvents.subscribe(contextGet[*data.User](r, userContextKey).ID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// send writes the string to the stream and flushes it to the client.
+	send := func(s string) bool {
+		if _, err := fmt.Fprint(w, s); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !send(": connected\n\n") {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !send(": ping\n\n") {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			js, err := json.Marshal(event)
+			if err != nil {
+				app.logError(r, err.Error())
+				return
+			}
+			if !send(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, js)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+// readEvent reads lines from the stream until the end of the next event, and
+// returns its name and data. Comments are skipped.
+func readEvent(t *testing.T, scanner *bufio.Scanner) (string, string) {
+	t.Helper()
+
+	var name, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	t.Fatal("stream ended before an event was received", scanner.Err())
+	return "", ""
+}
+
+func TestStreamTodos(t *testing.T) {
+	app, mock := newTestApplication(t)
+
+	mock.ExpectQuery("INSERT INTO todos").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(7, time.Now(), 1, nil))
+
+	// The handlers are called with testUser in the request context, bypassing
+	// authentication.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = app.contextSetUser(r, testUser)
+		switch r.Method {
+		case http.MethodGet:
+			app.streamTodos(w, r)
+		case http.MethodPost:
+			app.createTodo(w, r)
+		}
+	}))
+	defer ts.Close()
+	defer app.todoEvents.close()
+
+	stream, err := ts.Client().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+
+	assert.Equal(t, stream.StatusCode, http.StatusOK)
+	assert.Equal(t, stream.Header.Get("Content-Type"), "text/event-stream")
+
+	// Wait until the stream is subscribed before creating the todo.
+	scanner := bufio.NewScanner(stream.Body)
+	if !scanner.Scan() || scanner.Text() != ": connected" {
+		t.Fatal("expected a connected comment", scanner.Err())
+	}
+
+	rs, err := ts.Client().Post(ts.URL, "application/json", strings.NewReader(`{"text": "call mom"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	assert.Equal(t, rs.StatusCode, http.StatusCreated)
+
+	name, data := readEvent(t, scanner)
+	assert.Equal(t, name, todoCreated)
+
+	var event todoEvent
+	err = json.Unmarshal([]byte(data), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, event.Type, todoCreated)
+	assert.Equal(t, event.Todo.ID, 7)
+	assert.Equal(t, event.Todo.Text, "call mom")
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
+
+func TestTodoBroker(t *testing.T) {
+	b := newTodoBroker()
+
+	mine, unsubscribe := b.subscribe(1)
+	other, _ := b.subscribe(2)
+
+	// Events are only sent to the user's own subscribers.
+	b.publish(1, todoEvent{Type: todoDeleted, ID: 3})
+	assert.Equal(t, (<-mine).ID, 3)
+	assert.Equal(t, len(other), 0)
+
+	// Publishing never blocks, even if a subscriber isn't reading.
+	for i := 0; i < todoEventBufferSize+1; i++ {
+		b.publish(1, todoEvent{Type: todoDeleted, ID: int64(i)})
+	}
+	assert.Equal(t, len(mine), todoEventBufferSize)
+
+	// Unsubscribing closes the channel.
+	unsubscribe()
+	for range mine {
+	}
+
+	// Closing the broker ends the remaining subscriptions.
+	b.close()
+	_, ok := <-other
+	assert.Equal(t, ok, false)
+}
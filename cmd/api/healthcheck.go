@@ -1,33 +1,57 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/data"
+	"github.com/kvnloughead/godo/internal/vcs"
 )
 
 // healthcheck handles GET requests to the /v1/healthcheck endpoint.
 // It responds with the current status of the application and some system info,
-// including the environment and version. This endpoint can be used as a service
-// health check to monitor the application's status and gather basic system
-// information.
+// including the environment, version, and build info. This endpoint can be
+// used as a service health check to monitor the application's status and
+// gather basic system information.
 //
 // Responds with a JSON object in the following format:
 //
 //	{
 //	  "status": "available",
+//	  "mailer": <mailer_status>,
 //	  "system_info": {
-//				"environment": <app_environment>,
-//				"version":     <app_version>,
+//				"environment":    <app_environment>,
+//				"version":        <app_version>,
+//				"uptime_seconds": <seconds_since_start>,
+//				"go_version":     <go_version>,
+//				"revision":       <vcs_revision>,
+//				"vcs_time":       <vcs_time>,
+//				"modified":       <vcs_modified>,
 //	  }
 //	}
 //
+// The mailer status is the result of the SMTP connectivity check made at
+// startup: "unchecked", "available", "unavailable", or "disabled". See
+// checkMailer.
+//
 // If the app is unable to construct the response a 500 Internal Server Error
 // is sent with no body.
 func (app *APIApplication) healthcheck(w http.ResponseWriter, r *http.Request) {
+	vcsInfo := vcs.ReadInfo()
+
 	env := envelope{
 		"status": "available",
-		"system_info": map[string]string{
-			"environment": app.Config.Env,
-			"version":     version,
+		"mailer": app.mailerStatus.Load(),
+		"system_info": map[string]any{
+			"environment":    app.Config.Env,
+			"version":        version,
+			"uptime_seconds": int64(time.Since(app.startTime).Seconds()),
+			"go_version":     runtime.Version(),
+			"revision":       vcsInfo.Revision,
+			"vcs_time":       vcsInfo.Time,
+			"modified":       vcsInfo.Modified,
 		},
 	}
 
@@ -37,3 +61,102 @@ func (app *APIApplication) healthcheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// livez handles GET requests to the /v1/livez endpoint. It always responds
+// with a 200 OK, indicating that the process is up.
+//
+//	{ "status": "alive" }
+func (app *APIApplication) livez(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"status": "alive"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readyz handles GET requests to the /v1/readyz endpoint. It responds with a
+// 200 OK if the database can be pinged, the database circuit breaker isn't
+// open, and the server isn't shutting down. If DB.FailReadyzWhenSaturated is
+// set, the connection pool must also not be saturated. See poolMonitor.
+// Otherwise, it responds with a 503 Service Unavailable and the reason.
+//
+//	{ "status": "ready" }
+//	{ "status": "unavailable", "reason": "shutting down" }
+func (app *APIApplication) readyz(w http.ResponseWriter, r *http.Request) {
+	unavailable := func(reason string) {
+		env := envelope{"status": "unavailable", "reason": reason}
+		err := app.writeJSON(w, http.StatusServiceUnavailable, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+
+	if app.shuttingDown.Load() {
+		unavailable("shutting down")
+		return
+	}
+
+	if app.DB == nil {
+		unavailable("database unavailable")
+		return
+	}
+
+	// While the circuit breaker is open, queries fail without being attempted,
+	// so the server can't serve requests even if the ping succeeds. Only the
+	// Postgres todo store has a breaker.
+	if todos, ok := app.Models.Todos.(data.TodoModel); ok && todos.Breaker.State() == data.BreakerOpen {
+		unavailable("database circuit open")
+		return
+	}
+
+	if app.Config.DB.FailReadyzWhenSaturated && app.readyzPool.sample(app.DB.Stats()).Saturated {
+		unavailable("database pool saturated")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := app.DB.PingContext(ctx); err != nil {
+		app.Logger.Error("readiness check failed", "error", err)
+		unavailable("database unavailable")
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"status": "ready"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Mailer statuses reported by the healthcheck.
+const (
+	mailerUnchecked   = "unchecked"
+	mailerAvailable   = "available"
+	mailerUnavailable = "unavailable"
+	mailerDisabled    = "disabled"
+)
+
+// checkMailer checks that the SMTP server can be connected to, and records
+// the result in app.mailerStatus. If the check fails, a warning is logged,
+// since emails such as activation tokens won't be delivered. If emails are
+// disabled, no check is made.
+func (app *APIApplication) checkMailer() {
+	if app.Mailer.Disabled() {
+		app.mailerStatus.Store(mailerDisabled)
+		app.Logger.Info("emails are disabled, and will be logged instead of sent")
+		return
+	}
+
+	err := app.Mailer.Check()
+	if err != nil {
+		app.mailerStatus.Store(mailerUnavailable)
+		app.Logger.Warn("SMTP check failed, emails will not be delivered",
+			"host", app.Config.SMTP.Host,
+			"port", app.Config.SMTP.Port,
+			"error", err)
+		return
+	}
+
+	app.mailerStatus.Store(mailerAvailable)
+	app.Logger.Info("SMTP check succeeded", "host", app.Config.SMTP.Host, "port", app.Config.SMTP.Port)
+}
@@ -1,24 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"testing"
 
+	"github.com/go-mail/mail/v2"
 	"github.com/kvnloughead/godo/internal/assert"
 	"github.com/kvnloughead/godo/internal/injector"
+	"github.com/kvnloughead/godo/internal/mailer"
 )
 
 func TestHealthcheck(t *testing.T) {
 
-	baseApp := injector.NewApplication(
+	baseApp, err := injector.NewApplication(
 		injector.Config{Env: "testing"},
 		slog.New(slog.NewTextHandler(io.Discard, nil)),
 		nil,
 	)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	app := NewAPIApplication(baseApp)
 
@@ -34,9 +42,15 @@ func TestHealthcheck(t *testing.T) {
 
 	var response struct {
 		Status     string `json:"status"`
+		Mailer     string `json:"mailer"`
 		SystemInfo struct {
-			Environment string `json:"environment"`
-			Version     string `json:"version"`
+			Environment   string  `json:"environment"`
+			Version       string  `json:"version"`
+			UptimeSeconds *int64  `json:"uptime_seconds"`
+			GoVersion     string  `json:"go_version"`
+			Revision      *string `json:"revision"`
+			VCSTime       *string `json:"vcs_time"`
+			Modified      *bool   `json:"modified"`
 		} `json:"system_info"`
 	}
 
@@ -47,6 +61,133 @@ func TestHealthcheck(t *testing.T) {
 	}
 
 	assert.Equal(t, response.Status, "available")
+	assert.Equal(t, response.Mailer, mailerUnchecked)
 	assert.Equal(t, response.SystemInfo.Environment, "testing")
 	assert.Equal(t, response.SystemInfo.Version, version)
+	assert.Equal(t, response.SystemInfo.GoVersion, runtime.Version())
+
+	// The build info fields must be present, though they may be empty in tests.
+	assert.Equal(t, response.SystemInfo.UptimeSeconds != nil, true)
+	assert.Equal(t, *response.SystemInfo.UptimeSeconds >= 0, true)
+	assert.Equal(t, response.SystemInfo.Revision != nil, true)
+	assert.Equal(t, response.SystemInfo.VCSTime != nil, true)
+	assert.Equal(t, response.SystemInfo.Modified != nil, true)
+}
+
+func TestLivez(t *testing.T) {
+	app, _ := newTestApplication(t)
+	app.shuttingDown.Store(true)
+
+	rr := httptest.NewRecorder()
+	app.livez(rr, httptest.NewRequest(http.MethodGet, "/v1/livez", nil))
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
+
+func TestReadyz(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	readyz := func() (int, string) {
+		rr := httptest.NewRecorder()
+		app.readyz(rr, httptest.NewRequest(http.MethodGet, "/v1/readyz", nil))
+
+		var response struct {
+			Status string `json:"status"`
+			Reason string `json:"reason"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rr.Code, response.Reason
+	}
+
+	code, _ := readyz()
+	assert.Equal(t, code, http.StatusOK)
+
+	// While every allowed connection is in use, the check only fails if
+	// FailReadyzWhenSaturated is set.
+	app.DB.SetMaxOpenConns(1)
+	conn, err := app.DB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Config.DB.FailReadyzWhenSaturated = true
+	code, reason := readyz()
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, reason, "database pool saturated")
+
+	conn.Close()
+	code, _ = readyz()
+	assert.Equal(t, code, http.StatusOK)
+
+	// Once the shutdown flag is set, the check fails.
+	app.shuttingDown.Store(true)
+	code, reason = readyz()
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, reason, "shutting down")
+}
+
+// fakeDialer is a mailer.Dialer that doesn't connect to an SMTP server. Dial
+// returns err, or a connection that discards messages if err is nil.
+type fakeDialer struct {
+	err error
+}
+
+func (d fakeDialer) Dial() (mail.SendCloser, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return fakeSendCloser{}, nil
+}
+
+func (d fakeDialer) DialAndSend(m ...*mail.Message) error {
+	return d.err
+}
+
+type fakeSendCloser struct{}
+
+func (fakeSendCloser) Send(from string, to []string, msg io.WriterTo) error { return nil }
+func (fakeSendCloser) Close() error                                         { return nil }
+
+func TestCheckMailer(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		disabled bool
+		status   string
+	}{
+		{name: "Success", err: nil, status: mailerAvailable},
+		{name: "Failure", err: errors.New("connection refused"), status: mailerUnavailable},
+		{name: "Disabled", err: errors.New("connection refused"), disabled: true, status: mailerDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			m, err := mailer.NewWithDialer(fakeDialer{err: tt.err}, "Godo <no-reply@example.com>")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.disabled {
+				m = m.Disable(app.Logger)
+			}
+			app.Mailer = m
+
+			app.checkMailer()
+
+			rr := httptest.NewRecorder()
+			app.healthcheck(rr, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
+
+			var response struct {
+				Mailer string `json:"mailer"`
+			}
+			err = json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.Equal(t, response.Mailer, tt.status)
+		})
+	}
 }
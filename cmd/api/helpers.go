@@ -7,8 +7,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	validator "github.com/kvnloughead/godo/internal"
@@ -41,9 +43,121 @@ func (app *APIApplication) writeJSON(w http.ResponseWriter, status int, data env
 	return nil
 }
 
+// writeText sends a plain text response with the "Content-type: text/plain"
+// header and the supplied status code. A newline is appended to the text.
+func (app *APIApplication) writeText(w http.ResponseWriter, status int, text string, headers http.Header) error {
+	for k, v := range headers {
+		w.Header()[k] = v
+	}
+
+	w.Header().Set("Content-type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := w.Write([]byte(text + "\n"))
+
+	return err
+}
+
+// acceptsPlainText returns true if the request's Accept header prefers
+// text/plain over application/json. The media type with the higher q-value
+// wins, and if they are equal, the one listed first wins. A q-value of 0 means
+// the media type isn't acceptable. If neither is acceptable, JSON is assumed.
+func (app *APIApplication) acceptsPlainText(r *http.Request) bool {
+	// The q-value and position of each media type, or 0 and -1 if it isn't
+	// listed.
+	textQ, jsonQ := 0.0, 0.0
+	textPos, jsonPos := -1, -1
+
+	for i, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(mediaRange, ";")
+		q, ok := acceptQuality(params)
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(mediaType) {
+		case "text/plain":
+			if textPos == -1 {
+				textQ, textPos = q, i
+			}
+		case "application/json":
+			if jsonPos == -1 {
+				jsonQ, jsonPos = q, i
+			}
+		}
+	}
+
+	if textQ == 0 {
+		return false
+	}
+	return textQ > jsonQ || (textQ == jsonQ && textPos < jsonPos)
+}
+
+// acceptQuality returns the q-value in the parameters of a media range in an
+// Accept header, such as "charset=utf-8;q=0.5". It is 1 if there is no q
+// parameter. If the q-value isn't a number from 0 to 1, ok is false.
+func acceptQuality(params string) (q float64, ok bool) {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0, false
+		}
+		return q, true
+	}
+	return 1, true
+}
+
+// absoluteURL returns an absolute URL for the path, using the scheme and host
+// that the request was made to. If the request has X-Forwarded-Proto or
+// X-Forwarded-Host headers, and the origin they describe is one of the trusted
+// origins in app.Config.Cors.TrustedOrigins, that origin is used instead, so
+// that URLs are correct for clients behind a trusted proxy.
+//
+// If the request has no host, the path is returned as-is.
+func (app *APIApplication) absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	fwdProto := r.Header.Get("X-Forwarded-Proto")
+	fwdHost := r.Header.Get("X-Forwarded-Host")
+	if fwdProto != "" || fwdHost != "" {
+		// Only the first value is used if a header contains a list.
+		fwdProto, _, _ = strings.Cut(fwdProto, ",")
+		fwdHost, _, _ = strings.Cut(fwdHost, ",")
+
+		origin := url.URL{Scheme: scheme, Host: host}
+		if p := strings.TrimSpace(fwdProto); p != "" {
+			origin.Scheme = p
+		}
+		if h := strings.TrimSpace(fwdHost); h != "" {
+			origin.Host = h
+		}
+
+		if slices.Contains(app.Config.Cors.TrustedOrigins, origin.String()) {
+			scheme, host = origin.Scheme, origin.Host
+		}
+	}
+
+	if host == "" {
+		return path
+	}
+
+	return (&url.URL{Scheme: scheme, Host: host, Path: path}).String()
+}
+
+// errEmptyBody is returned by readJSON and readText if the request body is
+// empty, or only contains whitespace.
+var errEmptyBody = errors.New("request body must not be empty")
+
 // readJSON decodes a requests body to the target destination. If the target destination is not a
 // non-nil pointer, panic will ensue. Only a single JSON value per request is
-// accepted.
+// accepted, and bodies may not exceed app.Config.MaxRequestBody bytes.
 //
 // The following errors are caught and responded to specifically.
 //
@@ -58,8 +172,8 @@ func (app *APIApplication) writeJSON(w http.ResponseWriter, status int, data env
 //     *json.UnmarshalTypeError. In these cases we return a message indicating
 //     the offending field, if possible. Otherwise, a generic message.
 //
-//  4. An empty body results in an io.EOF error, which are caught and responded
-//     to appropriately.
+//  4. An empty body results in an io.EOF error, for which errEmptyBody is
+//     returned.
 //
 //  5. If an unknown field is in the request body, an error will be returned.
 //
@@ -70,8 +184,8 @@ func (app *APIApplication) writeJSON(w http.ResponseWriter, status int, data env
 //
 // All other errors are returned as-is.
 func (app *APIApplication) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
-	// Restrict size of request bodyy to 1MB.
-	maxBytes := 1_048_576
+	// Restrict size of request body to the configured limit.
+	maxBytes := app.Config.MaxRequestBody
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
 
 	dec := json.NewDecoder(r.Body)
@@ -98,7 +212,7 @@ func (app *APIApplication) readJSON(w http.ResponseWriter, r *http.Request, dst
 			return fmt.Errorf("body contains JSON of an incorrect type (at character %d)", unmarshallTypeError.Offset)
 
 		case errors.Is(err, io.EOF):
-			return errors.New("request body must not be empty")
+			return errEmptyBody
 
 		// It is necessary to check the error for the prefix currently, but there
 		// is an open issue to make this a separate error in the future.
@@ -128,6 +242,28 @@ func (app *APIApplication) readJSON(w http.ResponseWriter, r *http.Request, dst
 	return nil
 }
 
+// readText reads a plain text request body. Like readJSON, bodies may not
+// exceed app.Config.MaxRequestBody bytes, and an empty body is an error.
+func (app *APIApplication) readText(w http.ResponseWriter, r *http.Request) (string, error) {
+	maxBytes := app.Config.MaxRequestBody
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return "", fmt.Errorf("body must not exceed %d bytes", maxBytesError.Limit)
+		}
+		return "", err
+	}
+
+	if strings.TrimSpace(string(body)) == "" {
+		return "", errEmptyBody
+	}
+
+	return string(body), nil
+}
+
 // envelope is a type used for wrapping JSON responses to ensure a consistent
 // response structure. It is a map with string keys and values of any type.
 //
@@ -184,7 +320,7 @@ func (app *APIApplication) readQueryInt(qs url.Values, key string, defaultValue
 
 	i, err := strconv.Atoi(s)
 	if err != nil {
-		v.AddError(key, "must be an integer value")
+		v.AddRuleError(key, validator.RuleFormat, "must be an integer value")
 		return defaultValue
 	}
 
@@ -204,13 +340,75 @@ func (app *APIApplication) readQueryBool(qs url.Values, key string, defaultValue
 
 	b, err := strconv.ParseBool(s)
 	if err != nil {
-		v.AddError(key, "must be a boolean value")
+		v.AddRuleError(key, validator.RuleFormat, "must be a boolean value")
 		return defaultValue
 	}
 
 	return b
 }
 
+// readQueryDate reads a date valued field, in the format 2006-01-02, from the
+// query string argument. If the field is empty, the zero time is returned. If
+// the field can't be parsed, the zero time is returned, and an error is added
+// to the validator instance.
+func (app *APIApplication) readQueryDate(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		v.AddRuleError(key, validator.RuleFormat, "must be a date in the format YYYY-MM-DD")
+		return time.Time{}
+	}
+
+	return t
+}
+
+// readQueryTime reads a date valued field from the query string argument,
+// either in the format 2006-01-02, or as an RFC 3339 timestamp, such as
+// 2006-01-02T15:04:05Z, for clients that need more precision than a day. If
+// the field is empty, the zero time is returned. If the field can't be parsed,
+// the zero time is returned, and an error is added to the validator instance.
+func (app *APIApplication) readQueryTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		v.AddRuleError(key, validator.RuleFormat, "must be a date in the format YYYY-MM-DD, or an RFC 3339 timestamp")
+		return time.Time{}
+	}
+
+	return t
+}
+
+// readDate parses a date in the format YYYY-MM-DD, such as a todo's due date
+// from a request body. If s is empty, nil is returned. If it can't be parsed,
+// nil is returned, and an error is added to the validator instance.
+func (app *APIApplication) readDate(key, s string, v *validator.Validator) *time.Time {
+	if s == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		v.AddRuleError(key, validator.RuleFormat, "must be a date in the format YYYY-MM-DD")
+		return nil
+	}
+
+	return &t
+}
+
 // The background method launches a background goroutine. This goroutine
 // recovers from panics, logging the resulting errors with app.Logger, and
 // calls the function argument.
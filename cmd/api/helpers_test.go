@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestReadJSONMaxRequestBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr string
+	}{
+		{name: "Under limit", size: 64},
+		{name: "Over limit", size: 200, wantErr: "body must not exceed 128 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			app.Config.MaxRequestBody = 128
+
+			// The text field is padded so that the body is exactly tt.size bytes.
+			prefix, suffix := `{"text": "`, `"}`
+			body := prefix + strings.Repeat("a", tt.size-len(prefix)-len(suffix)) + suffix
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/todos", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			var input struct {
+				Text string `json:"text"`
+			}
+			err := app.readJSON(rr, r, &input)
+
+			if tt.wantErr == "" {
+				assert.IsNil(t, err)
+				assert.Equal(t, len(input.Text), tt.size-len(prefix)-len(suffix))
+			} else {
+				assert.Equal(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"log/slog"
 	"os"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/kvnloughead/godo/internal/injector"
+	"github.com/kvnloughead/godo/internal/mailer"
 	"github.com/kvnloughead/godo/internal/vcs"
 	_ "github.com/lib/pq"
 )
@@ -20,27 +22,99 @@ var (
 	version = vcs.Version()
 )
 
+// commandUsage describes the subcommands of godo-api.
+const commandUsage = `usage: godo-api [flags] [command]
+
+Without a command, the server is started.
+
+Commands:
+  migrate <up|down [N]|version>     Apply or revert migrations.
+  seed --count N --user <email>     Generate todos for development.`
+
 // APIApplication is an instance of injector.Application. It injects
 // dependencies and stores API specific methods.
 type APIApplication struct {
 	*injector.Application
+
+	// shuttingDown is set when a graceful shutdown begins. Once it is set, the
+	// readiness check fails.
+	shuttingDown atomic.Bool
+
+	// startTime is the time the application was created, used to report uptime.
+	startTime time.Time
+
+	// mailerStatus is the result of the most recent SMTP connectivity check,
+	// reported by the healthcheck. See checkMailer.
+	mailerStatus atomic.Value
+
+	// todoEvents publishes changes to each user's todos to their open streams.
+	// See streamTodos.
+	todoEvents *todoBroker
+
+	// readyzPool monitors the connection pool for the readiness check, when
+	// DB.FailReadyzWhenSaturated is set. It is separate from the monitor for
+	// /debug/vars, so that each sees every wait.
+	readyzPool poolMonitor
+
+	// shutdown is closed when the server begins shutting down, to stop
+	// long-running background jobs such as runEscalation.
+	shutdown chan struct{}
 }
 
 func NewAPIApplication(app *injector.Application) *APIApplication {
-	return &APIApplication{Application: app}
+	apiApp := &APIApplication{Application: app, startTime: time.Now(), todoEvents: newTodoBroker(), shutdown: make(chan struct{})}
+	apiApp.mailerStatus.Store(mailerUnchecked)
+	return apiApp
 }
 
 func main() {
-	// Parse CLI flags into config struct (to be added to dependencies).
-	var cfg = injector.LoadConfig()
-
+	// These flags must be defined before LoadConfig parses the command line.
 	displayVersion := flag.Bool("version", false, "Display version and exit")
+	checkSMTP := flag.Bool("check-smtp", false, "Check the SMTP configuration and exit")
+	printConfig := flag.Bool("print-config", false, "Print the configuration, with secrets redacted, and exit")
 
-	flag.Parse()
+	// Parse CLI flags into config struct (to be added to dependencies).
+	var cfg = injector.LoadConfig()
 
 	// If -version flag is set, display version and exit.
 	if *displayVersion {
+		vcsInfo := vcs.ReadInfo()
 		fmt.Printf("Version:\t%s\n", version)
+		fmt.Printf("Revision:\t%s\n", vcsInfo.Revision)
+		fmt.Printf("VCS time:\t%s\n", vcsInfo.Time)
+		fmt.Printf("Modified:\t%t\n", vcsInfo.Modified)
+		os.Exit(0)
+	}
+
+	// If -print-config flag is set, print the resolved configuration and exit.
+	if *printConfig {
+		if err := injector.PrintConfig(os.Stdout, cfg); err != nil {
+			fmt.Printf("Failed to print config: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Settings that can't be used stop the server, rather than being replaced
+	// by defaults.
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// If -check-smtp flag is set, check the connection to the SMTP server and
+	// exit. The exit status is 1 if the check fails.
+	if *checkSMTP {
+		m, err := mailer.New(cfg.MailerOptions())
+		if err != nil {
+			fmt.Printf("Invalid mailer settings: %v\n", err)
+			os.Exit(1)
+		}
+		if err := m.Check(); err != nil {
+			fmt.Printf("SMTP check failed for %s:%d: %v\n", cfg.SMTP.Host, cfg.SMTP.Port, err)
+			os.Exit(1)
+		}
+		fmt.Printf("SMTP check succeeded for %s:%d\n", cfg.SMTP.Host, cfg.SMTP.Port)
 		os.Exit(0)
 	}
 
@@ -56,12 +130,64 @@ func main() {
 	defer db.Close()
 	logger.Info("database connection pool established")
 
+	// Arguments after the flags are a subcommand, which runs instead of the
+	// server. The migrate subcommand applies or reverts migrations, and the
+	// seed subcommand, which runs once the application is created, generates
+	// todos for development.
+	args := flag.Args()
+	if len(args) > 0 && args[0] != "migrate" && args[0] != "seed" {
+		fmt.Printf("Unknown command: %s\n%s\n", args[0], commandUsage)
+		os.Exit(2)
+	}
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrateCommand(context.Background(), db, logger, args[1:]); err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// If -db-migrate is set, apply pending migrations before serving.
+	if cfg.DB.Migrate {
+		migrator, err := newMigrator(db)
+		if err == nil {
+			err = migrateUp(context.Background(), migrator, logger)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
 	// Set additional debug variables, accessible at GET /debug/vars.
 	setDebugVars(db)
 
-	baseApp := injector.NewApplication(cfg, logger, db)
+	// Create the application. This fails if the email templates are invalid.
+	baseApp, err := injector.NewApplication(cfg, logger, db)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
 	app := NewAPIApplication(baseApp)
 
+	if len(args) > 0 && args[0] == "seed" {
+		if err := app.runSeedCommand(context.Background(), args[1:], os.Stdout); err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Check the SMTP connection in the background, so that startup isn't
+	// blocked. Failures are logged, but emails will still be attempted.
+	app.background(func() {
+		app.checkMailer()
+	})
+
+	if app.Config.Escalation.Enabled {
+		app.background(app.runEscalation)
+	}
+
 	err = app.serve()
 	if err != nil {
 		logger.Error(err.Error())
@@ -103,6 +229,10 @@ func openDB(cfg injector.Config) (*sql.DB, error) {
 //   - timestamp: a Unix timestamp
 //   - gouroutines: the number of current goroutines running
 //   - database: the result of db.Stats()
+//   - db_pool: the connection pool's health, computed by a poolMonitor. The
+//     wait count delta is the number of waits since /debug/vars was last
+//     read, and saturated is true if there were any, or if every allowed
+//     connection is in use.
 func setDebugVars(db *sql.DB) {
 	expvar.NewString("version").Set(version)
 	expvar.Publish("timestamp", expvar.Func(func() any {
@@ -114,4 +244,9 @@ func setDebugVars(db *sql.DB) {
 	expvar.Publish("database", expvar.Func(func() any {
 		return db.Stats()
 	}))
+
+	var pool poolMonitor
+	expvar.Publish("db_pool", expvar.Func(func() any {
+		return pool.sample(db.Stats())
+	}))
 }
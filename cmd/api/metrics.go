@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// latencyBuckets are the upper bounds, in milliseconds, of the buckets in a
+// latencyHistogram. Durations above the last bound are counted in a final
+// "+Inf" bucket.
+var latencyBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// latencyHistogram records the distribution of request latencies. It
+// implements the expvar.Var interface, so it can be published at /debug/vars.
+type latencyHistogram struct {
+	mu     sync.Mutex
+	counts []int64 // One count per bucket, plus one for "+Inf".
+	count  int64
+	sumMs  float64
+}
+
+// newLatencyHistogram returns an empty latencyHistogram.
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+// observe adds a duration to the histogram.
+func (h *latencyHistogram) observe(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := 0
+	for i < len(latencyBuckets) && ms > latencyBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.count++
+	h.sumMs += ms
+}
+
+// percentile returns an estimate of the pth percentile, in milliseconds. The
+// estimate is the upper bound of the bucket containing the percentile, so it
+// overestimates by at most one bucket. If the percentile falls in the "+Inf"
+// bucket, the last bound is returned. The caller must hold h.mu.
+func (h *latencyHistogram) percentile(p float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+	var seen int64
+	for i, c := range h.counts[:len(latencyBuckets)] {
+		seen += c
+		if seen >= rank {
+			return latencyBuckets[i]
+		}
+	}
+	return latencyBuckets[len(latencyBuckets)-1]
+}
+
+// String returns the histogram as a JSON object, in the following format:
+//
+//	{
+//	  "count": 3,
+//	  "sum_ms": 21.4,
+//	  "buckets": {"5ms": 1, "10ms": 1, "25ms": 1, ..., "+Inf": 0},
+//	  "p50_ms": 10,
+//	  "p90_ms": 25,
+//	  "p99_ms": 25
+//	}
+func (h *latencyHistogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make(map[string]int64, len(h.counts))
+	for i, bound := range latencyBuckets {
+		buckets[fmt.Sprintf("%gms", bound)] = h.counts[i]
+	}
+	buckets["+Inf"] = h.counts[len(latencyBuckets)]
+
+	js, _ := json.Marshal(map[string]any{
+		"count":   h.count,
+		"sum_ms":  h.sumMs,
+		"buckets": buckets,
+		"p50_ms":  h.percentile(50),
+		"p90_ms":  h.percentile(90),
+		"p99_ms":  h.percentile(99),
+	})
+	return string(js)
+}
+
+// unmatchedRoute is the route label for requests that don't match a route.
+// It doesn't include the method, since that is chosen by the client and would
+// let it create any number of histograms.
+const unmatchedRoute = "unmatched"
+
+// A routeLabel records which route handled a request, such as
+// "GET /v1/todos/:id". The metrics middleware adds one to the request context
+// and handlers registered with handle set it. It is atomic because the timeout
+// middleware can return while the handler is still running.
+type routeLabel struct {
+	atomic.Pointer[string]
+}
+
+// String returns the label, or unmatchedRoute if no route has set it.
+func (l *routeLabel) String() string {
+	if s := l.Load(); s != nil {
+		return *s
+	}
+	return unmatchedRoute
+}
+
+// handle registers the handler for the method and pattern. Requests it serves
+// are labeled with the method and the pattern, rather than the path, which
+// keeps the number of distinct routes in the metrics small.
+func handle(router *httprouter.Router, method, pattern string, handler http.HandlerFunc) {
+	label := method + " " + pattern
+	router.HandlerFunc(method, pattern, func(w http.ResponseWriter, r *http.Request) {
+		if l, ok := r.Context().Value(routeContextKey).(*routeLabel); ok {
+			l.Store(&label)
+		}
+		handler(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	for _, ms := range []int{1, 2, 3, 4, 7, 8, 20, 40, 90, 3000} {
+		h.observe(time.Duration(ms) * time.Millisecond)
+	}
+
+	var summary struct {
+		Count   int64            `json:"count"`
+		Buckets map[string]int64 `json:"buckets"`
+		P50     float64          `json:"p50_ms"`
+		P90     float64          `json:"p90_ms"`
+		P99     float64          `json:"p99_ms"`
+	}
+	err := json.Unmarshal([]byte(h.String()), &summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, summary.Count, 10)
+	assert.Equal(t, summary.Buckets["5ms"], 4)
+	assert.Equal(t, summary.Buckets["10ms"], 2)
+	assert.Equal(t, summary.Buckets["5000ms"], 1)
+	assert.Equal(t, summary.P50, 10)
+	assert.Equal(t, summary.P90, 100)
+	assert.Equal(t, summary.P99, 5000)
+}
+
+func TestMetricsRouteLatency(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	router := httprouter.New()
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	handle(router, http.MethodGet, "/test/widgets/:id", noop)
+	handle(router, http.MethodGet, "/test/gadgets", noop)
+
+	handler := app.metrics(router)
+	for _, path := range []string{"/test/widgets/1", "/test/widgets/2", "/test/gadgets"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BOGUS", "/test/nope", nil))
+
+	routeLatency := expvar.Get("route_latency").(*expvar.Map)
+
+	count := func(route string) int64 {
+		v := routeLatency.Get(route)
+		if v == nil {
+			t.Fatalf("no histogram for %q", route)
+		}
+		var summary struct {
+			Count int64 `json:"count"`
+		}
+		err := json.Unmarshal([]byte(v.String()), &summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return summary.Count
+	}
+
+	// Requests for different IDs share a histogram.
+	assert.Equal(t, count("GET /test/widgets/:id"), 2)
+	assert.Equal(t, count("GET /test/gadgets"), 1)
+	assert.Equal(t, routeLatency.Get("GET /test/widgets/1") == nil, true)
+
+	// Unmatched requests share one histogram, whatever their method.
+	assert.Equal(t, count("unmatched") >= 1, true)
+	assert.Equal(t, routeLatency.Get("BOGUS unmatched") == nil, true)
+}
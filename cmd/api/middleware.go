@@ -1,21 +1,27 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/subtle"
 	"errors"
 	"expvar"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	validator "github.com/kvnloughead/godo/internal"
 	"github.com/kvnloughead/godo/internal/data"
+	"github.com/kvnloughead/godo/internal/injector"
 
 	"github.com/google/uuid"
-	"github.com/tomasen/realip"
 	"golang.org/x/time/rate"
 )
 
@@ -39,14 +45,52 @@ func (app *APIApplication) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// expvarInt returns the published expvar.Int with the given name, creating it
+// if necessary. This allows middleware that publishes metrics to be created
+// more than once, as happens in tests.
+func expvarInt(name string) *expvar.Int {
+	if v, ok := expvar.Get(name).(*expvar.Int); ok {
+		return v
+	}
+	return expvar.NewInt(name)
+}
+
+// expvarMap returns the published expvar.Map with the given name, creating it
+// if necessary.
+func expvarMap(name string) *expvar.Map {
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return v
+	}
+	return expvar.NewMap(name)
+}
+
+// retryAfter returns the number of whole seconds until the limiter will have a
+// token available, rounded up. It is always at least 1.
+func retryAfter(limiter *rate.Limiter, now time.Time) int {
+	missing := 1 - limiter.TokensAt(now)
+	if missing <= 0 || limiter.Limit() <= 0 {
+		return 1
+	}
+	return max(int(math.Ceil(missing/float64(limiter.Limit()))), 1)
+}
+
 // rateLimit is a middleware that limits the number of requests to an average of
-// 2 per second per IP address, with bursts of up to 4 seconds.
+// app.Config.Limiter.RPS per second per client IP address, with bursts of up to
+// app.Config.Limiter.Burst requests.
 //
-// If an X-Forwarded-For or X-Real-IP header is found, the IP is taken from
-// there. Otherwise it is taken from r.RemoteAddr.
+// The client's IP is found by clientIP. It is taken from r.RemoteAddr, unless
+// the request comes from one of the proxies in
+// app.Config.Limiter.TrustedProxyCIDRs (-limiter-trusted-proxy-cidrs), in
+// which case it is taken from the X-Forwarded-For or X-Real-IP header. Headers
+// from other addresses are ignored, so that they can't be spoofed.
 //
 // If the limit is exceeded, a 429 Too Many Request response is sent to the
-// client.
+// client, with a Retry-After header containing the number of seconds until
+// the client's next request will be allowed.
+//
+// Requests from the CIDRs in app.Config.Limiter.ExemptCIDRs are never limited.
+// The same client IP is checked against them. Exempt requests are still
+// authenticated as usual.
 func (app *APIApplication) rateLimit(next http.Handler) http.Handler {
 	// Struct client contains data corresponding to a client IP. It has a rate
 	// limiter property, and a lastSeen property used to remove unused clients
@@ -61,8 +105,8 @@ func (app *APIApplication) rateLimit(next http.Handler) http.Handler {
 		clients = make(map[string]*client)
 
 		// Metrics
-		rateLimitExceeded = expvar.NewInt("rate_limit_exceeded_total")
-		currentClients    = expvar.NewInt("rate_limit_current_clients")
+		rateLimitExceeded = expvarInt("rate_limit_exceeded_total")
+		currentClients    = expvarInt("rate_limit_current_clients")
 	)
 
 	// Start background goroutine to remove old entries from the clients map.
@@ -91,11 +135,15 @@ func (app *APIApplication) rateLimit(next http.Handler) http.Handler {
 		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
 	}
 
+	// Requests from exempt CIDRs skip the limiter entirely.
+	exempt := app.parseCIDRs("rate limiter exempt", app.Config.Limiter.ExemptCIDRs)
+	trustedProxies := app.parseCIDRs("rate limiter trusted proxy", app.Config.Limiter.TrustedProxyCIDRs)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.Config.Limiter.Enabled {
-			// Get IP address. If an X-Forwarded-For or X-Real-IP header is found, the
-			// IP is taken from there. Otherwise it is taken from r.RemoteAddr.
-			ip := realip.FromRequest(r)
+		// The same IP is used for the exemption and the limiter, so that a
+		// client behind a trusted proxy can't be exempted by the proxy's IP.
+		ip := clientIP(r, trustedProxies)
+		if app.Config.Limiter.Enabled && !ipIn(ip, exempt) {
 			mu.Lock()
 
 			// If no limiter exists for current IP, add it to the map of clients.
@@ -112,8 +160,10 @@ func (app *APIApplication) rateLimit(next http.Handler) http.Handler {
 			// If the client's limiter doesn't allow the request, increment the
 			// rateLimitExceeded counter and send a 429 response.
 			if !clients[ip].limiter.Allow() {
+				wait := retryAfter(clients[ip].limiter, time.Now())
 				mu.Unlock()
 				addRateLimitHeaders(w, 0) // 0 remaining tokens
+				w.Header().Set("Retry-After", strconv.Itoa(wait))
 				rateLimitExceeded.Add(1)
 				app.Logger.Info("rate limit exceeded",
 					"ip", ip,
@@ -135,9 +185,72 @@ func (app *APIApplication) rateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// streamPathPrefix is the path prefix of streaming endpoints, such as
+// /v1/stream/todos. Their responses stay open indefinitely, so they are exempt
+// from the request timeout.
+const streamPathPrefix = "/v1/stream/"
+
+// requestTimeoutBody is the body of the response sent when a request times
+// out.
+const requestTimeoutBody = `{"error":"the server took too long to process your request"}`
+
+// The timeout middleware cancels the request's context if the downstream
+// handlers haven't finished within app.Config.RequestTimeout, and sends a 503
+// Service Unavailable response. Database queries made with the request's
+// context are aborted when it is canceled. Requests that time out are logged
+// once their handlers return.
+//
+// The middleware is based on http.TimeoutHandler, which buffers responses
+// until the handlers finish, so streaming endpoints are exempt. It is disabled
+// if app.Config.RequestTimeout is 0.
+func (app *APIApplication) timeout(next http.Handler) http.Handler {
+	if app.Config.RequestTimeout <= 0 {
+		return next
+	}
+
+	logged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			app.Logger.Warn("request timed out",
+				"method", r.Method,
+				"uri", r.URL.RequestURI(),
+				"timeout", app.Config.RequestTimeout,
+			)
+		}
+	})
+	th := http.TimeoutHandler(logged, app.Config.RequestTimeout, requestTimeoutBody)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, streamPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		th.ServeHTTP(&timeoutResponseWriter{ResponseWriter: w}, r)
+	})
+}
+
+// timeoutResponseWriter sets the Content-Type of the 503 response sent by
+// http.TimeoutHandler, which doesn't set one. Like metricsResponseWriter, it
+// implements an Unwrap method that returns the wrapped http.ResponseWriter.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (tw *timeoutResponseWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusServiceUnavailable && tw.Header().Get("Content-Type") == "" {
+		tw.Header().Set("Content-Type", "application/json")
+	}
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *timeoutResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
 // The authenticate middleware authenticates a user based on the token provided
 // in the authorization header. The header should be of the form "Bearer
-// <token>". The token should be 26 bytes long.
+// <token>". The token should be data.TokenPlaintextLength bytes long.
 //
 // 401 Unauthorized responses are sent if the authorization header is
 // malformed, if the token is invalid, or if a user record corresponding to the
@@ -168,7 +281,7 @@ func (app *APIApplication) authenticate(next http.Handler) http.Handler {
 
 		token := parts[1]
 
-		// Validate that the token is 26 bytes long.
+		// Validate the token's length.
 		v := validator.New()
 		data.ValidateTokenPlaintext(v, token)
 		if !v.Valid() {
@@ -293,45 +406,380 @@ func (app *APIApplication) isPreflight(r *http.Request) bool {
 // origins must be passed as the -cors-trusted-origin flag at runtime.
 //
 // In the case of preflight requests, the appropriate response headers are set
-// and a 200 OK response is send. We send 200 rather than 204 because some
+// and a 200 OK response is send. The allowed methods and headers, and the
+// preflight cache duration sent as Access-Control-Max-Age, are configured by
+// app.Config.Cors. We send 200 rather than 204 because some
 // browsers don't support 204 No Content responses.
 //
-// This middleware allows the Authorization header in cross-origin requests, so
-// it it critical to not set the Access-Control-Allow-Origin header to *.
+// If there are no trusted origins, CORS is enabled for all origins, with the
+// Access-Control-Allow-Origin header set to *. Browsers never send
+// credentials to a wildcard origin, and the Authorization header isn't
+// allowed, so authenticated endpoints can't be used cross-origin in this mode.
+//
+// Otherwise, this middleware allows the Authorization header in cross-origin
+// requests from trusted origins, so it it critical to not set the
+// Access-Control-Allow-Origin header to * in that case.
 func (app *APIApplication) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Tell caches that response may vary depending on the value of the
-		// following request headers.
-		w.Header().Set("Vary", "Origin")
-		w.Header().Set("Vary", "Access-Control-Request-Method")
+		// following request headers. Add is used so that neither replaces the
+		// other, or any Vary header set by other middleware.
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
 
 		origin := r.Header.Get("Origin")
 
 		if origin != "" {
-			for i := range app.Config.Cors.TrustedOrigins {
-				if app.Config.Cors.TrustedOrigins[i] == origin {
-					w.Header().Set("Access-Control-Allow-Origin", origin)
-
-					// If the request is a preflight request, set the necessary headers
-					// and send a 200 OK response with no further action.
-					if app.isPreflight(r) {
-						w.Header().Set("Access-Control-Allow-Methods",
-							"OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers",
-							"Authorization, Content-Type")
-						w.WriteHeader(http.StatusOK)
-						return
-					}
-
-					break
-				}
+			allowed, wildcard := false, len(app.Config.Cors.TrustedOrigins) == 0
+			if wildcard {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				allowed = true
+			} else if slices.Contains(app.Config.Cors.TrustedOrigins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				allowed = true
 			}
 
+			// If the request is a preflight request, set the necessary headers
+			// and send a 200 OK response with no further action.
+			if allowed && app.isPreflight(r) {
+				app.setPreflightHeaders(w, wildcard)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// setPreflightHeaders sets the headers of a response to a preflight request
+// from an allowed origin. If wildcard is true, the Authorization header isn't
+// included in the allowed headers.
+func (app *APIApplication) setPreflightHeaders(w http.ResponseWriter, wildcard bool) {
+	methods := app.Config.Cors.AllowedMethods
+	if len(methods) == 0 {
+		methods = injector.DefaultCorsAllowedMethods
+	}
+	headers := app.Config.Cors.AllowedHeaders
+	if len(headers) == 0 {
+		headers = injector.DefaultCorsAllowedHeaders
+	}
+	if wildcard {
+		headers = slices.DeleteFunc(slices.Clone(headers), func(h string) bool {
+			return strings.EqualFold(h, "Authorization")
+		})
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if maxAge := app.Config.Cors.MaxAge; maxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+	}
+}
+
+// The requireDebugAccess middleware restricts access to debugging endpoints,
+// such as /debug/vars. Requests are allowed if their X-Debug-Token header
+// matches app.Config.DebugVars.Token, or if they come from one of the CIDRs in
+// app.Config.DebugVars.TrustedCIDRs. The client's IP is taken from
+// r.RemoteAddr, because X-Forwarded-For and X-Real-IP can be spoofed.
+//
+// Unauthorized requests are sent a 404 Not Found response, rather than a 403
+// Forbidden, so that the endpoint's existence isn't advertised.
+func (app *APIApplication) requireDebugAccess(next http.Handler) http.Handler {
+	trusted := app.parseCIDRs("debug vars", app.Config.DebugVars.TrustedCIDRs)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := app.Config.DebugVars.Token
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Debug-Token")), []byte(token)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if remoteAddrIn(r, trusted) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		app.notFoundResponse(w, r)
+	})
+}
+
+// parseCIDRs parses each of the CIDRs. Invalid CIDRs are logged and skipped.
+// The kind of CIDR is included in the log message.
+func (app *APIApplication) parseCIDRs(kind string, cidrs []string) []*net.IPNet {
+	var ipNets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			app.Logger.Error("invalid "+kind+" CIDR", "cidr", cidr, "error", err)
+			continue
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets
+}
+
+// remoteAddrIn reports whether the IP in r.RemoteAddr is in one of the
+// networks. X-Forwarded-For and X-Real-IP are ignored, because they can be
+// spoofed.
+func remoteAddrIn(r *http.Request, ipNets []*net.IPNet) bool {
+	return ipIn(remoteHost(r), ipNets)
+}
+
+// clientIP returns the IP of the client that made the request. If the request
+// comes from one of the trusted proxies, the IP is taken from its
+// X-Forwarded-For or X-Real-IP header. Otherwise the headers could have been
+// spoofed, so the IP in r.RemoteAddr is used.
+//
+// X-Forwarded-For is read from the right, since each proxy appends the address
+// it received the request from. The last address that isn't a trusted proxy is
+// the client's, and any before it could have been spoofed by the client.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	ip := remoteHost(r)
+	if !ipIn(ip, trustedProxies) {
+		return ip
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		addrs := strings.Split(xff, ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(addrs[i])
+			if net.ParseIP(addr) == nil {
+				break
+			}
+			ip = addr
+			if !ipIn(addr, trustedProxies) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return ip
+}
+
+// remoteHost returns the host in r.RemoteAddr, without the port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ipIn reports whether the IP address s is in one of the networks.
+func ipIn(s string, ipNets []*net.IPNet) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// Compression
+//
+
+// minCompressSize is the smallest response body, in bytes, that the compress
+// middleware will gzip. Smaller bodies aren't worth the overhead.
+const minCompressSize = 1024
+
+// incompressibleTypes is a list of Content-Type prefixes for responses that
+// are already compressed, or that are streamed, and so aren't gzipped by the
+// compress middleware.
+var incompressibleTypes = []string{"image/", "video/", "audio/", "application/gzip", "application/zip", "text/event-stream"}
+
+// gzipResponseWriter wraps (and implements) the http.ResponseWriter interface,
+// gzipping the response body when it is worth doing so.
+//
+// The status code and the start of the body are buffered until either
+// minCompressSize bytes have been written, Flush is called, or the handler
+// returns. At that point, the response is either compressed or written as-is.
+//
+// Like metricsResponseWriter, it implements an Unwrap method that returns the
+// wrapped interface, so that it can be used with http.ResponseController.
+type gzipResponseWriter struct {
+	wrapped    http.ResponseWriter
+	statusCode int
+	buf        []byte
+	gz         *gzip.Writer
+	decided    bool
+}
+
+// newGzipResponseWriter returns a gzipResponseWriter that wraps the
+// ResponseWriter that was passed as an argument.
+func newGzipResponseWriter(w http.ResponseWriter) *gzipResponseWriter {
+	return &gzipResponseWriter{
+		wrapped:    w,
+		statusCode: http.StatusOK,
+	}
+}
+
+// gzipResponseWriter.Header() calls the wrapped interface's Header() method.
+func (gw *gzipResponseWriter) Header() http.Header {
+	return gw.wrapped.Header()
+}
+
+// gzipResponseWriter.WriteHeader() records the status code. It is written to
+// the wrapped interface once the decision to compress has been made.
+func (gw *gzipResponseWriter) WriteHeader(statusCode int) {
+	if !gw.decided {
+		gw.statusCode = statusCode
+	}
+}
+
+// gzipResponseWriter.Write() buffers the response until there are at least
+// minCompressSize bytes, then starts writing the response.
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !gw.decided {
+		gw.buf = append(gw.buf, b...)
+		if len(gw.buf) >= minCompressSize {
+			if err := gw.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+
+	if gw.gz != nil {
+		return gw.gz.Write(b)
+	}
+	return gw.wrapped.Write(b)
+}
+
+// gzipResponseWriter.Flush() starts writing the response, if it hasn't been
+// started, and flushes any compressed data and the wrapped interface.
+func (gw *gzipResponseWriter) Flush() {
+	if !gw.decided {
+		gw.start(true)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	http.NewResponseController(gw.wrapped).Flush()
+}
+
+// gzipResponseWriter.Unwrap() returns the wrapped http.ResponseWriter
+// interface.
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.wrapped
+}
+
+// start writes the headers and any buffered data. If compress is true, and
+// the response can be compressed, the body is gzipped.
+func (gw *gzipResponseWriter) start(compress bool) error {
+	gw.decided = true
+
+	if compress && gw.compressible() {
+		gw.Header().Set("Content-Encoding", "gzip")
+		gw.Header().Del("Content-Length")
+		gw.gz = gzip.NewWriter(gw.wrapped)
+	}
+
+	gw.wrapped.WriteHeader(gw.statusCode)
+
+	if len(gw.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if gw.gz != nil {
+		_, err = gw.gz.Write(gw.buf)
+	} else {
+		_, err = gw.wrapped.Write(gw.buf)
+	}
+	gw.buf = nil
+	return err
+}
+
+// compressible returns true if the response's status and headers allow it to
+// be compressed.
+func (gw *gzipResponseWriter) compressible() bool {
+	if gw.statusCode < http.StatusOK ||
+		gw.statusCode == http.StatusNoContent ||
+		gw.statusCode == http.StatusNotModified {
+		return false
+	}
+
+	if gw.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := gw.Header().Get("Content-Type")
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// close finishes the response. Responses that are still buffered are smaller
+// than minCompressSize, so they are written uncompressed.
+func (gw *gzipResponseWriter) close() error {
+	if !gw.decided {
+		return gw.start(false)
+	}
+	if gw.gz != nil {
+		return gw.gz.Close()
+	}
+	return nil
+}
+
+// acceptsGzip returns true if the request's Accept-Encoding header includes
+// gzip, and doesn't give it a q-value of 0.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(params, "=")
+		if ok && strings.TrimSpace(name) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// The compress middleware gzips response bodies for clients that send an
+// "Accept-Encoding: gzip" header. Responses smaller than minCompressSize
+// bytes, and responses that are already compressed, are sent as-is.
+//
+// The "Vary: Accept-Encoding" header is added to all responses, because their
+// encoding depends on the value of that header.
+func (app *APIApplication) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := newGzipResponseWriter(w)
+		defer func() {
+			if err := gw.close(); err != nil {
+				app.Logger.Error("failed to finish compressed response", "error", err)
+			}
+		}()
+
+		next.ServeHTTP(gw, r)
+	})
+}
+
 //
 // Metrics
 //
@@ -401,28 +849,53 @@ func (mw *metricsResponseWriter) Unwrap() http.ResponseWriter {
 //   - total responses sent
 //   - total processing time (in microseconds)
 //   - a map of the total number responses sent for each status code
+//   - a map of latency histograms for each route, keyed by the method and the
+//     route's pattern, such as "GET /v1/todos/:id". Requests that don't match a
+//     route registered with handle are recorded as "unmatched".
 func (app *APIApplication) metrics(next http.Handler) http.Handler {
 	var (
-		totalRequestsRecieved           = expvar.NewInt("total_requests_recieved")
-		totalResponsesSent              = expvar.NewInt("total_responses_sent")
-		totalProcessingTimeMicroseconds = expvar.NewInt("total_processing_time_μs")
-		totalResponsesSentByStatus      = expvar.NewMap("total_responses_sent_by_status")
+		totalRequestsRecieved           = expvarInt("total_requests_recieved")
+		totalResponsesSent              = expvarInt("total_responses_sent")
+		totalProcessingTimeMicroseconds = expvarInt("total_processing_time_μs")
+		totalResponsesSentByStatus      = expvarMap("total_responses_sent_by_status")
+		routeLatency                    = expvarMap("route_latency")
+
+		// routeLatencyMu prevents two histograms being created for one route.
+		routeLatencyMu sync.Mutex
 	)
 
+	// histogramFor returns the latency histogram for the route, creating it if
+	// necessary.
+	histogramFor := func(route string) *latencyHistogram {
+		routeLatencyMu.Lock()
+		defer routeLatencyMu.Unlock()
+
+		if h, ok := routeLatency.Get(route).(*latencyHistogram); ok {
+			return h
+		}
+		h := newLatencyHistogram()
+		routeLatency.Set(route, h)
+		return h
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		totalRequestsRecieved.Add(1)
 
+		route := &routeLabel{}
+		r = r.WithContext(context.WithValue(r.Context(), routeContextKey, route))
+
 		mw := newMetricResponseWriter(w)
 		next.ServeHTTP(mw, r)
 
-		// Increment response counter for the response's status code, as well as the
-		// counter of total responses.
+		// Increment response counter for the response's status code, as well as
+		// the counter of total responses.
 		totalResponsesSentByStatus.Add(strconv.Itoa(mw.statusCode), 1)
 		totalResponsesSent.Add(1)
 
-		duration := time.Since(start).Microseconds()
-		totalProcessingTimeMicroseconds.Add(duration)
+		elapsed := time.Since(start)
+		totalProcessingTimeMicroseconds.Add(elapsed.Microseconds())
+		histogramFor(route.String()).observe(elapsed)
 	})
 }
 
@@ -439,7 +912,16 @@ type requestContext struct {
 // The contextualizeRequest middleware initializes a requestContext struct at
 // the start of the request, and stores it in the request context. It also
 // creates a response writer wrapper to capture the response's status code.
+//
+// The start and completion of requests are logged according to
+// app.Config.LogSampling. With a Rate of N, 1 in N requests are logged, and
+// with a Rate of 0, only server errors and requests slower than SlowThreshold
+// are logged. The completion of server errors and slow requests is always
+// logged, along with the method and URI, so that the request_id can be
+// correlated with other logs.
 func (app *APIApplication) contextualizeRequest(next http.Handler) http.Handler {
+	var requestCount atomic.Uint64
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := &requestContext{
 			start:     time.Now(),
@@ -447,11 +929,16 @@ func (app *APIApplication) contextualizeRequest(next http.Handler) http.Handler
 			requestID: uuid.New().String(),
 		}
 
-		app.Logger.Info("request started",
-			"request_id", ctx.requestID,
-			"method", r.Method,
-			"uri", r.URL.RequestURI(),
-		)
+		rate := uint64(app.Config.LogSampling.Rate)
+		sampled := rate > 0 && requestCount.Add(1)%rate == 0
+
+		if sampled {
+			app.Logger.Info("request started",
+				"request_id", ctx.requestID,
+				"method", r.Method,
+				"uri", r.URL.RequestURI(),
+			)
+		}
 
 		// Store our request context
 		r = r.WithContext(context.WithValue(r.Context(), requestContextKey, ctx))
@@ -474,8 +961,17 @@ func (app *APIApplication) contextualizeRequest(next http.Handler) http.Handler
 		ctx.statusCode = rw.statusCode
 		ctx.authStatus = authStatus
 
+		slowThreshold := app.Config.LogSampling.SlowThreshold
+		isError := ctx.statusCode >= http.StatusInternalServerError
+		isSlow := slowThreshold > 0 && ctx.duration > slowThreshold
+		if !sampled && !isError && !isSlow {
+			return
+		}
+
 		app.Logger.Info("request completed",
 			"request_id", ctx.requestID,
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
 			"duration", ctx.duration,
 			"status", ctx.statusCode,
 			"auth_status", ctx.authStatus,
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestCompress(t *testing.T) {
+	app, _ := newTestApplication(t)
+
+	large := envelope{"text": strings.Repeat("a", 2*minCompressSize)}
+	small := envelope{"text": "a"}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           envelope
+		encoding       string
+		compressed     bool
+	}{
+		{name: "Large response", acceptEncoding: "gzip, deflate", body: large, compressed: true},
+		{name: "Small response", acceptEncoding: "gzip", body: small},
+		{name: "Gzip not accepted", acceptEncoding: "", body: large},
+		{name: "Gzip refused", acceptEncoding: "gzip;q=0", body: large},
+		{name: "Already compressed", acceptEncoding: "gzip", body: large, encoding: "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The expected body is the uncompressed JSON.
+			expected := httptest.NewRecorder()
+			app.writeJSON(expected, http.StatusCreated, tt.body, nil)
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				app.writeJSON(w, http.StatusCreated, tt.body, nil)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/todos", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rr := httptest.NewRecorder()
+
+			// Wrap the recorder as the metrics middleware would, to check that the
+			// status code is still recorded.
+			mw := newMetricResponseWriter(rr)
+			app.compress(next).ServeHTTP(mw, r)
+
+			assert.Equal(t, mw.statusCode, http.StatusCreated)
+			assert.Equal(t, rr.Code, http.StatusCreated)
+			assert.Equal(t, rr.Header().Get("Vary"), "Accept-Encoding")
+
+			body := rr.Body.Bytes()
+			if tt.compressed {
+				assert.Equal(t, rr.Header().Get("Content-Encoding"), "gzip")
+
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, err = io.ReadAll(zr)
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				assert.Equal(t, rr.Header().Get("Content-Encoding"), tt.encoding)
+			}
+
+			assert.Equal(t, string(body), expected.Body.String())
+		})
+	}
+}
+
+func TestContextualizeRequestSampling(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	tests := []struct {
+		name          string
+		rate          int
+		slowThreshold time.Duration
+		handler       func(app *APIApplication) http.HandlerFunc
+		requests      int
+		logged        int
+	}{
+		{
+			name:     "Only errors, 200",
+			rate:     0,
+			handler:  func(app *APIApplication) http.HandlerFunc { return ok },
+			requests: 1,
+			logged:   0,
+		},
+		{
+			name: "Only errors, 500",
+			rate: 0,
+			handler: func(app *APIApplication) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					app.serverErrorResponse(w, r, errors.New("boom"))
+				}
+			},
+			requests: 1,
+			logged:   1,
+		},
+		{
+			name:          "Only errors, slow 200",
+			rate:          0,
+			slowThreshold: time.Millisecond,
+			handler: func(app *APIApplication) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) { time.Sleep(5 * time.Millisecond) }
+			},
+			requests: 1,
+			logged:   1,
+		},
+		{
+			name:     "Every request",
+			rate:     1,
+			handler:  func(app *APIApplication) http.HandlerFunc { return ok },
+			requests: 4,
+			logged:   4,
+		},
+		{
+			name:     "1 in 2 requests",
+			rate:     2,
+			handler:  func(app *APIApplication) http.HandlerFunc { return ok },
+			requests: 4,
+			logged:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			var buf bytes.Buffer
+			app.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+			app.Config.LogSampling.Rate = tt.rate
+			app.Config.LogSampling.SlowThreshold = tt.slowThreshold
+
+			handler := app.contextualizeRequest(tt.handler(app))
+			for range tt.requests {
+				handler.ServeHTTP(httptest.NewRecorder(), newTestRequest(http.MethodGet, "/v1/todos", nil, nil))
+			}
+
+			logs := buf.String()
+			assert.Equal(t, strings.Count(logs, `msg="request completed"`), tt.logged)
+
+			// Sampled requests log their start as well. Unsampled requests that are
+			// logged anyway include the method and URI for correlation.
+			if tt.rate > 0 {
+				assert.Equal(t, strings.Count(logs, `msg="request started"`), tt.logged)
+			}
+			if tt.logged > 0 {
+				assert.StringContains(t, logs, "request_id=")
+				assert.StringContains(t, logs, "uri=/v1/todos")
+			}
+		})
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	app, _ := newTestApplication(t)
+	app.Config.Limiter.Enabled = true
+	app.Config.Limiter.RPS = 0.5
+	app.Config.Limiter.Burst = 1
+
+	handler := app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "")
+
+	// The burst has been used, and a token is added every 2 seconds.
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
+	assert.Equal(t, rr.Code, http.StatusTooManyRequests)
+	assert.Equal(t, rr.Header().Get("Retry-After"), "2")
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		delay    time.Duration
+		wantCode int
+	}{
+		{name: "Slow handler", path: "/v1/todos", delay: time.Second, wantCode: http.StatusServiceUnavailable},
+		{name: "Fast handler", path: "/v1/todos", wantCode: http.StatusOK},
+		{name: "Stream", path: "/v1/stream/todos", delay: 50 * time.Millisecond, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			app.Config.RequestTimeout = 10 * time.Millisecond
+
+			// The handler waits for the delay, unless the request's context is
+			// canceled first, and reports the context's error.
+			ctxErr := make(chan error, 1)
+			handler := app.timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+				}
+				ctxErr <- r.Context().Err()
+				w.Write([]byte(`{"status":"ok"}`))
+			}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.wantCode == http.StatusServiceUnavailable {
+				assert.Equal(t, rr.Header().Get("Content-Type"), "application/json")
+				assert.StringContains(t, rr.Body.String(), "took too long")
+				assert.Equal(t, errors.Is(<-ctxErr, context.DeadlineExceeded), true)
+			} else {
+				assert.Equal(t, rr.Body.String(), `{"status":"ok"}`)
+				assert.IsNil(t, <-ctxErr)
+			}
+		})
+	}
+}
+
+func TestRateLimitExemptCIDRs(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		proxy      bool // Whether loopback addresses are trusted proxies.
+		wantCode   int
+	}{
+		{name: "Exempt IP", remoteAddr: "10.0.0.5:1234", wantCode: http.StatusOK},
+		{name: "Non-exempt IP", remoteAddr: "203.0.113.5:1234", wantCode: http.StatusTooManyRequests},
+		{name: "Spoofed header", remoteAddr: "203.0.113.5:1234", header: "10.0.0.5", wantCode: http.StatusTooManyRequests},
+		{name: "Exempt IP behind proxy", remoteAddr: "127.0.0.1:1234", header: "10.0.0.5", proxy: true, wantCode: http.StatusOK},
+		{name: "Non-exempt IP behind proxy", remoteAddr: "127.0.0.1:1234", header: "203.0.113.5", proxy: true, wantCode: http.StatusTooManyRequests},
+		{name: "Untrusted proxy", remoteAddr: "10.0.0.5:1234", header: "203.0.113.5", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			app.Config.Limiter.Enabled = true
+			app.Config.Limiter.RPS = 0.5
+			app.Config.Limiter.Burst = 1
+			app.Config.Limiter.ExemptCIDRs = []string{"10.0.0.0/8", "not a cidr"}
+			if tt.proxy {
+				app.Config.Limiter.TrustedProxyCIDRs = []string{"127.0.0.0/8"}
+			}
+
+			handler := app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			// The burst is used by the first request, so the second is only
+			// allowed if it is exempt.
+			var rr *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+				r.RemoteAddr = tt.remoteAddr
+				if tt.header != "" {
+					r.Header.Set("X-Forwarded-For", tt.header)
+				}
+				rr = httptest.NewRecorder()
+				handler.ServeHTTP(rr, r)
+			}
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+	app, _ := newTestApplication(t)
+	app.Config.Limiter.Enabled = true
+	app.Config.Limiter.RPS = 0.5
+	app.Config.Limiter.Burst = 1
+	app.Config.Limiter.TrustedProxyCIDRs = []string{"127.0.0.0/8"}
+
+	handler := app.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(remoteAddr, forwardedFor string) int {
+		r := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+
+	// Clients behind the proxy are limited separately.
+	assert.Equal(t, send("127.0.0.1:1234", "203.0.113.5"), http.StatusOK)
+	assert.Equal(t, send("127.0.0.1:1234", "203.0.113.6"), http.StatusOK)
+	assert.Equal(t, send("127.0.0.1:1234", "203.0.113.5"), http.StatusTooManyRequests)
+
+	// Other clients can't choose their IP with the header.
+	assert.Equal(t, send("198.51.100.1:1234", "203.0.113.7"), http.StatusOK)
+	assert.Equal(t, send("198.51.100.1:1234", "203.0.113.8"), http.StatusTooManyRequests)
+}
+
+func TestClientIP(t *testing.T) {
+	_, trusted, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		want       string
+	}{
+		{name: "No proxy", remoteAddr: "203.0.113.5:1234", xff: "198.51.100.1", want: "203.0.113.5"},
+		{name: "Trusted proxy", remoteAddr: "10.0.0.1:1234", xff: "198.51.100.1", want: "198.51.100.1"},
+		{name: "Spoofed entry", remoteAddr: "10.0.0.1:1234", xff: "192.0.2.1, 198.51.100.1", want: "198.51.100.1"},
+		{name: "Chained proxies", remoteAddr: "10.0.0.1:1234", xff: "198.51.100.1, 10.0.0.2", want: "198.51.100.1"},
+		{name: "Private client", remoteAddr: "10.0.0.1:1234", xff: "192.168.1.5", want: "192.168.1.5"},
+		{name: "X-Real-IP", remoteAddr: "10.0.0.1:1234", xRealIP: "198.51.100.1", want: "198.51.100.1"},
+		{name: "Invalid header", remoteAddr: "10.0.0.1:1234", xff: "unknown", want: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set("X-Forwarded-For", tt.xff)
+			r.Header.Set("X-Real-IP", tt.xRealIP)
+
+			assert.Equal(t, clientIP(r, []*net.IPNet{trusted}), tt.want)
+		})
+	}
+}
+
+func TestRequireDebugAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		cidrs      []string
+		remoteAddr string
+		header     string
+		wantCode   int
+	}{
+		{name: "Trusted IP", cidrs: []string{"127.0.0.1/32"}, remoteAddr: "127.0.0.1:1234", wantCode: http.StatusOK},
+		{name: "Trusted IPv6", cidrs: []string{"::1/128"}, remoteAddr: "[::1]:1234", wantCode: http.StatusOK},
+		{name: "Untrusted IP", cidrs: []string{"127.0.0.1/32"}, remoteAddr: "203.0.113.5:1234", wantCode: http.StatusNotFound},
+		{name: "Valid token", token: "secret", remoteAddr: "203.0.113.5:1234", header: "secret", wantCode: http.StatusOK},
+		{name: "Invalid token", token: "secret", remoteAddr: "203.0.113.5:1234", header: "guess", wantCode: http.StatusNotFound},
+		{name: "Token disabled", token: "", remoteAddr: "203.0.113.5:1234", header: "", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			app.Config.DebugVars.Token = tt.token
+			app.Config.DebugVars.TrustedCIDRs = tt.cidrs
+
+			handler := app.requireDebugAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set("X-Debug-Token", tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestEnableCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin      string
+		methods     []string
+		headers     []string
+		maxAge      time.Duration
+		wantOrigin  string
+		wantMethods string
+		wantHeaders string
+		wantMaxAge  string
+		wantNext    bool
+		wildcard    bool // No trusted origins.
+	}{
+		{
+			name:        "Defaults",
+			origin:      "https://godo.example",
+			maxAge:      time.Hour,
+			wantOrigin:  "https://godo.example",
+			wantMethods: "OPTIONS, GET, POST, PUT, PATCH, DELETE",
+			wantHeaders: "Authorization, Content-Type, Idempotency-Key, X-Error-Format",
+			wantMaxAge:  "3600",
+		},
+		{
+			name:        "Configured",
+			origin:      "https://godo.example",
+			methods:     []string{"GET", "POST"},
+			headers:     []string{"Authorization"},
+			wantOrigin:  "https://godo.example",
+			wantMethods: "GET, POST",
+			wantHeaders: "Authorization",
+		},
+		{
+			name:     "Untrusted origin",
+			origin:   "https://evil.example",
+			maxAge:   time.Hour,
+			wantNext: true,
+		},
+		{
+			name:        "No trusted origins",
+			origin:      "https://any.example",
+			headers:     []string{"Authorization", "Content-Type"},
+			wildcard:    true,
+			wantOrigin:  "*",
+			wantMethods: "OPTIONS, GET, POST, PUT, PATCH, DELETE",
+			wantHeaders: "Content-Type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			if !tt.wildcard {
+				app.Config.Cors.TrustedOrigins = []string{"https://godo.example"}
+			}
+			app.Config.Cors.AllowedMethods = tt.methods
+			app.Config.Cors.AllowedHeaders = tt.headers
+			app.Config.Cors.MaxAge = tt.maxAge
+
+			calledNext := false
+			handler := app.enableCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calledNext = true
+			}))
+
+			r := httptest.NewRequest(http.MethodOptions, "/v1/todos", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+			rr := httptest.NewRecorder()
+			rr.Header().Add("Vary", "Accept-Encoding")
+
+			handler.ServeHTTP(rr, r)
+
+			assert.Equal(t, calledNext, tt.wantNext)
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.Equal(t, rr.Header().Get("Access-Control-Allow-Origin"), tt.wantOrigin)
+			assert.Equal(t, rr.Header().Get("Access-Control-Allow-Methods"), tt.wantMethods)
+			assert.Equal(t, rr.Header().Get("Access-Control-Allow-Headers"), tt.wantHeaders)
+			assert.Equal(t, rr.Header().Get("Access-Control-Max-Age"), tt.wantMaxAge)
+
+			// Each Vary value is kept, including those set before the middleware.
+			assert.Equal(t, strings.Join(rr.Header().Values("Vary"), ", "), "Accept-Encoding, Origin, Access-Control-Request-Method")
+		})
+	}
+}
+
+func TestEnableCORSOrigins(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    []string
+		origin     string
+		wantOrigin string
+	}{
+		{name: "No trusted origins", origin: "https://any.example", wantOrigin: "*"},
+		{name: "Trusted origin", trusted: []string{"https://godo.example"}, origin: "https://godo.example", wantOrigin: "https://godo.example"},
+		{name: "Origin must match exactly", trusted: []string{"https://godo.example"}, origin: "https://godo.example.evil"},
+		{name: "No origin", origin: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+			app.Config.Cors.TrustedOrigins = tt.trusted
+
+			calledNext := false
+			handler := app.enableCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calledNext = true
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, r)
+
+			assert.Equal(t, calledNext, true)
+			assert.Equal(t, rr.Header().Get("Access-Control-Allow-Origin"), tt.wantOrigin)
+			assert.Equal(t, rr.Header().Get("Access-Control-Allow-Credentials"), "")
+		})
+	}
+}
+
+func TestVaryHeaders(t *testing.T) {
+	app, _ := newTestApplication(t)
+	app.Config.Cors.TrustedOrigins = []string{"https://godo.example"}
+
+	// The request passes through the full middleware chain, so that each
+	// middleware's Vary values must survive the others.
+	r := httptest.NewRequest(http.MethodGet, "/v1/livez", nil)
+	r.Header.Set("Origin", "https://godo.example")
+	r.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	app.Routes().ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	vary := rr.Header().Values("Vary")
+	for _, want := range []string{"Origin", "Access-Control-Request-Method", "Accept-Encoding"} {
+		if !slices.Contains(vary, want) {
+			t.Errorf("Vary %q doesn't include %q", vary, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/kvnloughead/godo/db/migrations"
+	"github.com/kvnloughead/godo/internal/migrate"
+)
+
+// migrateUsage describes the migrate subcommand.
+const migrateUsage = `usage: godo-api [flags] migrate <command>
+
+Commands:
+  up          Apply all pending migrations.
+  down [N]    Revert the last N migrations (default 1).
+  version     Print the database's migration version.`
+
+// newMigrator returns a migrate.Migrator for the embedded migrations.
+func newMigrator(db *sql.DB) (migrate.Migrator, error) {
+	m, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return migrate.Migrator{}, err
+	}
+	return migrate.Migrator{DB: db, Migrations: m}, nil
+}
+
+// runMigrateCommand runs the migrate subcommand with the arguments that follow
+// it. See migrateUsage.
+func runMigrateCommand(ctx context.Context, db *sql.DB, logger *slog.Logger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", migrateUsage)
+	}
+
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch command := args[0]; {
+	case command == "up" && len(args) == 1:
+		return migrateUp(ctx, migrator, logger)
+
+	case command == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations: %q", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			logger.Info("reverted migration", "version", m.Version, "name", m.Name)
+		}
+		return err
+
+	case command == "version" && len(args) == 1:
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Version:\t%d\nDirty:\t\t%t\n", version, dirty)
+		return nil
+
+	default:
+		return fmt.Errorf("invalid migrate command: %q\n%s", command, migrateUsage)
+	}
+}
+
+// migrateUp applies all pending migrations, logging each one.
+func migrateUp(ctx context.Context, migrator migrate.Migrator, logger *slog.Logger) error {
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		logger.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+	if err == nil && len(applied) == 0 {
+		logger.Info("database schema is up to date")
+	}
+	return err
+}
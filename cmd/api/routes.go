@@ -17,30 +17,86 @@ import (
 //
 //   - GET    /v1/healthcheck   				 Show application information.
 //
+//   - GET    /v1/livez                  Report that the process is up.
+//
+//   - GET    /v1/readyz                 Report whether requests can be served.
+//
 //   - GET    /v1/todos								   Show details of a subset of todos.
 //     [permissions - todos:read]
 //
 //   - POST   /v1/todos								   Create a new todo.
 //     [permissions - todos:write]
 //
+//   - DELETE /v1/todos								   Delete several todos by ID.
+//     [permissions - todos:write]
+//
+//   - POST   /v1/todos/complete				 Complete all todos matching a filter.
+//     [permissions - todos:write]
+//
+//   - POST   /v1/todos/archive-completed Archive all completed todos.
+//     [permissions - todos:write]
+//
+//   - POST   /v1/todos/tag              Add or remove tags on matching todos.
+//     [permissions - todos:write]
+//
+//   - POST   /v1/todos/import           Import todos from todo.txt text.
+//     [permissions - todos:write]
+//
+//   - POST   /v1/todos/validate         Parse and validate a todo.txt line.
+//     [permissions - todos:write]
+//
 //   - GET    /v1/todos/:id	  				   Show details of a specific todo.
 //     [permissions - todos:read]
 //
+//   - GET    /v1/todos/:id/raw			   Show a specific todo as todo.txt text.
+//     [permissions - todos:read]
+//
 //   - PATCH  /v1/todos/:id						   Update details of a specific todo.
 //     [permissions - todos:write]
 //
 //   - DELETE /v1/todos/:id	  				   Delete a specific todo.
 //     [permissions - todos:write]
 //
+//   - GET    /v1/stream/todos           Stream changes to the user's todos.
+//     [permissions - todos:read]
+//
+//   - GET    /v1/agenda                 Show overdue, due, and high priority todos.
+//     [permissions - todos:read]
+//
+//   - GET    /v1/report                 Count completed todos per week or month.
+//     [permissions - todos:read]
+//
+//   - GET    /v1/contexts               List the user's distinct contexts.
+//     [permissions - todos:read]
+//
+//   - GET    /v1/projects               List the user's distinct projects.
+//     [permissions - todos:read]
+//
 //   - POST   /v1/users         				 Register a new user.
 //
 //   - PUT    /v1/users/activation     	 Activates a user.
 //
+//   - DELETE /v1/users/me              Delete the user's account and data.
+//     [requires authentication]
+//
 //   - POST   /v1/tokens/activation   	 Generate a new activation token.
 //
 //   - POST   /v1/tokens/authentication  Generate an authentication token.
 //
+//   - GET    /v1/tokens                 List the user's active tokens.
+//     [requires authentication]
+//
+//   - DELETE /v1/tokens/:scope          Revoke all of the user's tokens of a scope.
+//     [requires authentication]
+//
+//   - PUT    /v1/admin/permissions      Grant a permission to a user.
+//     [permissions - admin]
+//
+//   - DELETE /v1/admin/permissions      Revoke a permission from a user.
+//     [permissions - admin]
+//
 //   - GET    /debug/vars                Display application metrics.
+//     [requires debug token or trusted IP]
 //
 // This function also sets up custom error handling for scenarios where no
 // route is matched (404 Not Found) and when a method is not allowed for a
@@ -48,32 +104,60 @@ import (
 // defined in api/errors.go.
 //
 // Finally, the router is wrapped with the recoverPanic middleware to handle any
-// panics that occur during request processing.
+// panics that occur during request processing, the compress middleware to
+// gzip large responses, and the timeout middleware to cancel requests that
+// run too long.
 func (app *APIApplication) Routes() http.Handler {
 	router := httprouter.New()
 
 	// Set custom error handlers for 404 and 405 errors.
-	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.NotFound = http.HandlerFunc(app.routeNotFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheck)
+	handle(router, http.MethodGet, "/v1/healthcheck", app.healthcheck)
+	handle(router, http.MethodGet, "/v1/livez", app.livez)
+	handle(router, http.MethodGet, "/v1/readyz", app.readyz)
 
 	// The /v1/todos endpoints require either todos:read or todos:write permission
-	router.HandlerFunc(http.MethodGet, "/v1/todos", app.requirePermission(data.TodosRead, app.listTodos))
-	router.HandlerFunc(http.MethodPost, "/v1/todos", app.requirePermission(data.TodosWrite, app.createTodo))
-	router.HandlerFunc(http.MethodGet, "/v1/todos/:id", app.requirePermission(data.TodosRead, app.getTodo))
-	router.HandlerFunc(http.MethodPatch, "/v1/todos/:id", app.requirePermission(data.TodosWrite, app.updateTodo))
-	router.HandlerFunc(http.MethodDelete, "/v1/todos/:id", app.requirePermission(data.TodosWrite, app.deleteTodo))
+	handle(router, http.MethodGet, "/v1/todos", app.requirePermission(data.TodosRead, app.listTodos))
+	handle(router, http.MethodPost, "/v1/todos", app.requirePermission(data.TodosWrite, app.createTodo))
+	handle(router, http.MethodDelete, "/v1/todos", app.requirePermission(data.TodosWrite, app.deleteTodosBatch))
+	handle(router, http.MethodPost, "/v1/todos/complete", app.requirePermission(data.TodosWrite, app.completeTodos))
+	handle(router, http.MethodPost, "/v1/todos/archive-completed", app.requirePermission(data.TodosWrite, app.archiveCompletedTodos))
+	handle(router, http.MethodPost, "/v1/todos/tag", app.requirePermission(data.TodosWrite, app.tagTodos))
+	handle(router, http.MethodPost, "/v1/todos/import", app.requirePermission(data.TodosWrite, app.importTodos))
+	handle(router, http.MethodPost, "/v1/todos/validate", app.requirePermission(data.TodosWrite, app.validateTodoLine))
+	handle(router, http.MethodGet, "/v1/todos/:id", app.requirePermission(data.TodosRead, app.getTodo))
+	handle(router, http.MethodGet, "/v1/todos/:id/raw", app.requirePermission(data.TodosRead, app.getRawTodo))
+	handle(router, http.MethodPatch, "/v1/todos/:id", app.requirePermission(data.TodosWrite, app.updateTodo))
+	handle(router, http.MethodDelete, "/v1/todos/:id", app.requirePermission(data.TodosWrite, app.deleteTodo))
+
+	// The stream can't be at /v1/todos/stream, since httprouter doesn't allow
+	// it to share a segment with /v1/todos/:id.
+	handle(router, http.MethodGet, "/v1/stream/todos", app.requirePermission(data.TodosRead, app.streamTodos))
+
+	// Like the stream, the agenda can't be at /v1/todos/agenda.
+	handle(router, http.MethodGet, "/v1/agenda", app.requirePermission(data.TodosRead, app.getAgenda))
+	handle(router, http.MethodGet, "/v1/report", app.requirePermission(data.TodosRead, app.getReport))
+
+	handle(router, http.MethodGet, "/v1/contexts", app.requirePermission(data.TodosRead, app.listContexts))
+	handle(router, http.MethodGet, "/v1/projects", app.requirePermission(data.TodosRead, app.listProjects))
+
+	handle(router, http.MethodPost, "/v1/users", app.registerUser)
+	handle(router, http.MethodPut, "/v1/users/activation", app.activateUser)
+	handle(router, http.MethodDelete, "/v1/users/me", app.requireAuthenticatedUser(app.deleteAccount))
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUser)
-	router.HandlerFunc(http.MethodPut, "/v1/users/activation", app.activateUser)
+	handle(router, http.MethodPost, "/v1/tokens/activation", app.createActivationToken)
+	handle(router, http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationToken)
+	handle(router, http.MethodGet, "/v1/tokens", app.requireAuthenticatedUser(app.listTokens))
+	handle(router, http.MethodDelete, "/v1/tokens/:scope", app.requireAuthenticatedUser(app.revokeTokens))
 
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", app.createActivationToken)
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationToken)
+	handle(router, http.MethodPut, "/v1/admin/permissions", app.requirePermission(data.Admin, app.grantPermission))
+	handle(router, http.MethodDelete, "/v1/admin/permissions", app.requirePermission(data.Admin, app.revokePermission))
 
-	// Expose application metrics as a JSON response to HTTP request.
-	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	// Expose application metrics as a JSON response to authorized requests.
+	handle(router, http.MethodGet, "/debug/vars", app.requireDebugAccess(expvar.Handler()).ServeHTTP)
 
-	middlewares := alice.New(app.metrics, app.recoverPanic, app.enableCORS, app.rateLimit, app.authenticate, app.contextualizeRequest)
+	middlewares := alice.New(app.metrics, app.compress, app.recoverPanic, app.enableCORS, app.rateLimit, app.timeout, app.authenticate, app.contextualizeRequest)
 	return middlewares.Then(router)
 }
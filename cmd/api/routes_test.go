@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestRouteNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "Missing version", path: "/todos"},
+		{name: "Unknown /v1 route", path: "/v1/todoz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			app.Routes().ServeHTTP(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusNotFound)
+			assert.Equal(t, rr.Header().Get("Content-Type"), "application/json")
+
+			var response map[string]string
+			err := json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, len(response), 3)
+			assert.Equal(t, response["error"], "the requested resource cannot be found")
+			assert.Equal(t, response["path"], tt.path)
+			assert.StringContains(t, response["hint"], "/v1/healthcheck")
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	app, mock := newTestApplication(t)
+
+	r := httptest.NewRequest(http.MethodPatch, "/v1/users", nil)
+	rr := httptest.NewRecorder()
+
+	app.Routes().ServeHTTP(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusMethodNotAllowed)
+	assert.Equal(t, rr.Header().Get("Allow"), "OPTIONS, POST")
+	assert.StringContains(t, rr.Body.String(), "the PATCH method is not allowed for this resource")
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
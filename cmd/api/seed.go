@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+// maxSeedCount is the maximum number of todos that can be seeded at once.
+const maxSeedCount = 10_000
+
+// seedChunkSize is the number of todos inserted by each call to InsertMany.
+// Each call has its own query timeout, so a large count can't exceed it.
+const seedChunkSize = data.DefaultMaxBatchSize
+
+// Words that seeded todos are made from. Each todo is a verb and a noun, with
+// up to two contexts and one project.
+var (
+	seedVerbs    = []string{"buy", "call", "email", "fix", "clean", "plan", "read", "write", "review", "book", "pay", "schedule"}
+	seedNouns    = []string{"milk", "the dentist", "mom", "the bike", "the garage", "the trip", "chapter 3", "the report", "the PR", "flights", "rent", "a haircut"}
+	seedContexts = []string{"home", "work", "phone", "errands", "computer"}
+	seedProjects = []string{"health", "house", "travel", "career", "garden"}
+)
+
+// runSeedCommand runs the seed subcommand with the arguments that follow it.
+// It inserts generated todos for a user, so that there is a realistic dataset
+// to develop with:
+//
+//	godo-api seed --count 200 --user alice@example.com
+//
+// It refuses to run in production.
+func (app *APIApplication) runSeedCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(out)
+	count := fs.Int("count", 50, "Number of todos to generate")
+	email := fs.String("user", "", "Email of the user who owns the todos")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case app.Config.Env == "production":
+		return errors.New("seed can't be run in production")
+	case *email == "":
+		return errors.New("the -user flag is required")
+	case *count < 1 || *count > maxSeedCount:
+		return fmt.Errorf("the -count flag must be between 1 and %d", maxSeedCount)
+	}
+
+	user, err := app.Models.Users.GetByEmail(*email)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return fmt.Errorf("no user with email %s", *email)
+		}
+		return err
+	}
+
+	todos := seedTodos(rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0)), user.ID, *count, app.Models.Clock.Now())
+	for start := 0; start < len(todos); start += seedChunkSize {
+		chunk := todos[start:min(start+seedChunkSize, len(todos))]
+		if err := app.Models.Todos.InsertMany(ctx, chunk); err != nil {
+			return fmt.Errorf("added %d of %d todos: %w", start, len(todos), err)
+		}
+	}
+
+	fmt.Fprintf(out, "Added %d todos for %s\n", len(todos), user.Email)
+	return nil
+}
+
+// seedTodos generates count todos for the user. About a third are completed,
+// a third have a due date within two weeks of now, and one in ten is starred.
+// Priorities, contexts, and projects are chosen at random.
+func seedTodos(rng *rand.Rand, userID int64, count int, now time.Time) []*data.Todo {
+	pick := func(words []string) string {
+		return words[rng.IntN(len(words))]
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	todos := make([]*data.Todo, 0, count)
+	for len(todos) < count {
+		// Build a todo.txt line, so that the tags are parsed as they are when
+		// todos are imported.
+		words := []string{pick(seedVerbs), pick(seedNouns)}
+		if rng.IntN(2) == 0 {
+			words = append([]string{"(" + string(rune('A'+rng.IntN(4))) + ")"}, words...)
+		}
+		for range rng.IntN(3) {
+			words = append(words, "@"+pick(seedContexts))
+		}
+		if rng.IntN(2) == 0 {
+			words = append(words, "+"+pick(seedProjects))
+		}
+
+		todo, err := data.ParseTodo(strings.Join(words, " "))
+		if err != nil {
+			// The generated lines always have text, so this can't happen.
+			panic(err)
+		}
+		todo.UserID = userID
+		todo.Completed = rng.IntN(3) == 0
+		todo.Starred = rng.IntN(10) == 0
+		if rng.IntN(3) == 0 {
+			due := today.AddDate(0, 0, rng.IntN(29)-14)
+			todo.DueDate = &due
+		}
+
+		todos = append(todos, todo)
+	}
+
+	return todos
+}
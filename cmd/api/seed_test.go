@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+func TestRunSeedCommand(t *testing.T) {
+	t.Run("Inserts todos", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+		todos := data.NewMemoryTodoModel()
+		app.Models.Todos = todos
+
+		mock.ExpectQuery("SELECT (.+) FROM users where email = \\$1").
+			WithArgs("alice@example.com").
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(2, time.Now(), "alice", "alice@example.com", []byte("hash"), true, 1))
+
+		var out bytes.Buffer
+		err := app.runSeedCommand(context.Background(), []string{"--count", "25", "--user", "alice@example.com"}, &out)
+		assert.IsNil(t, err)
+		assert.Equal(t, out.String(), "Added 25 todos for alice@example.com\n")
+
+		filters := data.Filters{Page: 1, PageSize: 100, Sort: "id", SortSafelist: []string{"id"}, IncludeArchived: true}
+		_, metadata, err := todos.GetAll(context.Background(), "", 2, nil, nil, filters)
+		assert.IsNil(t, err)
+		assert.Equal(t, metadata.TotalRecords, 25)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Inserts in chunks", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+		todos := &countingTodoStore{TodoStore: data.NewMemoryTodoModel()}
+		app.Models.Todos = todos
+
+		mock.ExpectQuery("SELECT (.+) FROM users where email = \\$1").
+			WithArgs("alice@example.com").
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(2, time.Now(), "alice", "alice@example.com", []byte("hash"), true, 1))
+
+		count := 2*seedChunkSize + 1
+		err := app.runSeedCommand(context.Background(), []string{"--count", fmt.Sprint(count), "--user", "alice@example.com"}, &bytes.Buffer{})
+		assert.IsNil(t, err)
+		assert.Equal(t, fmt.Sprint(todos.sizes), fmt.Sprint([]int{seedChunkSize, seedChunkSize, 1}))
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Refuses to run in production", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+		app.Config.Env = "production"
+
+		err := app.runSeedCommand(context.Background(), []string{"--user", "alice@example.com"}, &bytes.Buffer{})
+		assert.StringContains(t, err.Error(), "can't be run in production")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid count", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		err := app.runSeedCommand(context.Background(), []string{"--count", "0", "--user", "alice@example.com"}, &bytes.Buffer{})
+		assert.StringContains(t, err.Error(), "must be between 1 and 10000")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+// countingTodoStore records the number of todos in each call to InsertMany.
+type countingTodoStore struct {
+	data.TodoStore
+	sizes []int
+}
+
+func (s *countingTodoStore) InsertMany(ctx context.Context, todos []*data.Todo) error {
+	s.sizes = append(s.sizes, len(todos))
+	return s.TodoStore.InsertMany(ctx, todos)
+}
+
+func TestSeedTodosAreValid(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	todos := seedTodos(rand.New(rand.NewPCG(1, 2)), 1, 200, now)
+	assert.Equal(t, len(todos), 200)
+
+	for _, todo := range todos {
+		v := validator.New()
+		data.ValidateTodo(v, todo, data.TodoLimits{})
+		assert.Equal(t, v.Valid(), true)
+		assert.Equal(t, todo.UserID, int64(1))
+	}
+}
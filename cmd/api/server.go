@@ -16,8 +16,9 @@ import (
 // listen and serve method, and returns any resulting errors.
 //
 // serve also establishes a coroutine that listens for SIGTERM and SIGINT
-// signals. If either are found, the server's Shutdown() method is invoked,
-// which gracefully shuts down the server.
+// signals. If either are found, readiness checks begin to fail, and after
+// app.Config.ShutdownDelay the server's Shutdown() method is invoked, which
+// gracefully shuts down the server.
 func (app *APIApplication) serve() error {
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.Config.Port),
@@ -28,6 +29,13 @@ func (app *APIApplication) serve() error {
 		ErrorLog:     slog.NewLogLogger(app.Logger.Handler(), slog.LevelError),
 	}
 
+	// Close open event streams when shutdown begins, since Shutdown waits for
+	// active requests to finish.
+	srv.RegisterOnShutdown(app.todoEvents.close)
+
+	// Stop background jobs, since app.WG is waited on after Shutdown.
+	srv.RegisterOnShutdown(func() { close(app.shutdown) })
+
 	shutDownErr := make(chan error)
 
 	go func() {
@@ -42,6 +50,11 @@ func (app *APIApplication) serve() error {
 		// Log a message and quit application if SIGINT or SIGTERM is caught.
 		app.Logger.Info("shutting down server", "signal", s.String())
 
+		// Fail readiness checks, and keep serving for the configured delay so
+		// that load balancers can stop routing requests to the server.
+		app.shuttingDown.Store(true)
+		time.Sleep(app.Config.ShutdownDelay)
+
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+// listContexts handles GET requests to the /v1/contexts endpoint. It sends the
+// distinct contexts of the user's unarchived todos, in alphabetical order.
+//
+// If the "prefix" query parameter is provided, only contexts beginning with it
+// are sent. Matching is case-insensitive. The contexts are paged by the "page"
+// and "page_size" query parameters, which default to 1 and
+// data.MaxDistinctTags. The response includes the number of matching contexts
+// across all pages, and the pagination metadata.
+//
+//	{
+//	  "contexts": ["phone", "work"],
+//	  "count": 2,
+//	  "paginationData": { "current_page": 1, "page_size": 100, ... }
+//	}
+func (app *APIApplication) listContexts(w http.ResponseWriter, r *http.Request) {
+	app.listTags(w, r, "contexts", app.Models.Todos.DistinctContexts)
+}
+
+// listProjects handles GET requests to the /v1/projects endpoint. It is
+// identical to listContexts, except that it sends the user's projects.
+//
+//	{ "projects": ["godo", "groceries"], "count": 2, "paginationData": { ... } }
+func (app *APIApplication) listProjects(w http.ResponseWriter, r *http.Request) {
+	app.listTags(w, r, "projects", app.Models.Todos.DistinctProjects)
+}
+
+// listTags sends the tags returned by the distinct function, in an envelope
+// with the provided key.
+func (app *APIApplication) listTags(w http.ResponseWriter, r *http.Request, key string,
+	distinct func(ctx context.Context, userID int64, prefix string, filters data.Filters) ([]string, data.PaginationData, error)) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	prefix := app.readQueryString(qs, "prefix", "")
+
+	var filters data.Filters
+	filters.Page = app.readQueryInt(qs, "page", 1, v)
+	filters.PageSize = app.readQueryInt(qs, "page_size", data.MaxDistinctTags, v)
+
+	data.ValidatePagination(v, filters)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	tags, paginationData, err := distinct(r.Context(), contextGet[*data.User](r, userContextKey).ID, prefix, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{key: tags, "count": paginationData.TotalRecords, "paginationData": paginationData}
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestListContextsPrefix(t *testing.T) {
+	app, mock := newTestApplication(t)
+
+	mock.ExpectQuery(`SELECT count\(\*\) OVER\(\), tag FROM \(\s*SELECT DISTINCT tag\s+FROM todos, unnest\(contexts\) AS tag`).
+		WithArgs(testUser.ID, "wo", 100, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "tag"}).AddRow(2, "work").AddRow(2, "workshop"))
+
+	r := newTestRequest(http.MethodGet, "/v1/contexts?prefix=wo", nil, nil)
+	rr := httptest.NewRecorder()
+
+	app.listContexts(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.StringContains(t, rr.Body.String(), `"contexts": [`)
+	assert.StringContains(t, rr.Body.String(), `"workshop"`)
+	assert.StringContains(t, rr.Body.String(), `"count": 2`)
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
+
+func TestListProjectsPaging(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Second page", query: "?page=2&page_size=2", wantCode: http.StatusOK, wantBody: `"last_page": 3`},
+		{name: "Page size too large", query: "?page_size=101", wantCode: http.StatusUnprocessableEntity, wantBody: `"page_size"`},
+		{name: "Invalid page", query: "?page=0", wantCode: http.StatusUnprocessableEntity, wantBody: `"page"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+
+			if tt.wantCode == http.StatusOK {
+				mock.ExpectQuery(`unnest\(projects\)`).
+					WithArgs(testUser.ID, "", 2, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"count", "tag"}).AddRow(5, "godo").AddRow(5, "house"))
+			}
+
+			r := newTestRequest(http.MethodGet, "/v1/projects"+tt.query, nil, nil)
+			rr := httptest.NewRecorder()
+
+			app.listProjects(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			assert.StringContains(t, rr.Body.String(), tt.wantBody)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
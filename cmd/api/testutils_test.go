@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/kvnloughead/godo/internal/data"
+	"github.com/kvnloughead/godo/internal/injector"
+)
+
+// testUser is an activated user that is added to the context of requests
+// created by newTestRequest.
+var testUser = &data.User{ID: 1, Name: "test", Email: "test@example.com", Activated: true}
+
+// newTestApplication returns an APIApplication backed by a sqlmock database,
+// along with the mock for setting expectations. Logs are discarded, and emails
+// are disabled.
+func newTestApplication(t *testing.T) (*APIApplication, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	baseApp, err := injector.NewApplication(
+		injector.Config{Env: "testing", MaxRequestBody: 1_048_576, DisableEmails: true},
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		db,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewAPIApplication(baseApp), mock
+}
+
+// fakeTodoStore is a data.TodoStore for handler tests that don't need a
+// database. Its methods are provided by the test as functions, and calling a
+// method that isn't provided panics.
+type fakeTodoStore struct {
+	data.TodoStore
+
+	getTodoIfOwned func(id, userID int64) (*data.Todo, error)
+	update         func(todo *data.Todo) error
+}
+
+func (f fakeTodoStore) GetTodoIfOwned(ctx context.Context, id, userID int64) (*data.Todo, error) {
+	return f.getTodoIfOwned(id, userID)
+}
+
+func (f fakeTodoStore) Update(ctx context.Context, todo *data.Todo) error {
+	return f.update(todo)
+}
+
+// fixedClock is a data.Clock that always returns the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// newTestRequest returns a request with testUser and the supplied httprouter
+// params stored in its context. This allows handlers to be called directly,
+// bypassing the authentication and permission middleware.
+func newTestRequest(method, target string, body io.Reader, params httprouter.Params) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	ctx := context.WithValue(r.Context(), httprouter.ParamsKey, params)
+	ctx = context.WithValue(ctx, userContextKey, testUser)
+	return r.WithContext(ctx)
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	validator "github.com/kvnloughead/godo/internal"
 	"github.com/kvnloughead/godo/internal/data"
@@ -16,11 +21,15 @@ import (
 // TodoModel.GetAll for details.
 //
 // URL encoded search text can be provided in the text query parameter.
+// Contexts and projects can be provided as comma-separated values in the
+// contexts and projects query parameters.
 func (app *APIApplication) listTodos(w http.ResponseWriter, r *http.Request) {
 	// input is an anonymous struct intended to store the query params for
 	// filtering, sorting, and pagination.
 	var input struct {
-		Text string
+		Text     string
+		Contexts []string
+		Projects []string
 		data.Filters
 	}
 
@@ -36,10 +45,13 @@ func (app *APIApplication) listTodos(w http.ResponseWriter, r *http.Request) {
 	}
 	input.Text = decodedText
 
+	input.Contexts = app.readQueryCSV(qs, "contexts", nil)
+	input.Projects = app.readQueryCSV(qs, "projects", nil)
+
 	input.Filters.Page = app.readQueryInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readQueryInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readQueryString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "text", "-id", "-text"}
+	input.Filters.SortSafelist = []string{"id", "text", "priority", "created_at", "-id", "-text", "-priority", "-created_at"}
 
 	// Add archive filters
 	input.Filters.IncludeArchived = app.readQueryBool(qs, "include-archived", false, v)
@@ -49,16 +61,32 @@ func (app *APIApplication) listTodos(w http.ResponseWriter, r *http.Request) {
 	input.Filters.Done = app.readQueryBool(qs, "done", false, v)
 	input.Filters.Undone = app.readQueryBool(qs, "undone", false, v)
 
+	// Add starred filter
+	input.Filters.Starred = app.readQueryBool(qs, "star", false, v)
+
+	// Add priority filter
+	input.Filters.Priority = app.readQueryString(qs, "priority", "")
+
+	// Add creation date filters
+	input.Filters.CreatedAfter = app.readQueryTime(qs, "created_after", v)
+	input.Filters.CreatedBefore = app.readQueryTime(qs, "created_before", v)
+
+	// Add completion date filters
+	input.Filters.CompletedAfter = app.readQueryTime(qs, "completed_after", v)
+	input.Filters.CompletedBefore = app.readQueryTime(qs, "completed_before", v)
+
+	data.ValidateFilters(v, input.Filters)
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	todos, paginationData, err := app.Models.Todos.GetAll(
+		r.Context(),
 		input.Text,
 		contextGet[*data.User](r, userContextKey).ID,
-		nil,
-		nil,
+		input.Contexts,
+		input.Projects,
 		input.Filters,
 	)
 
@@ -80,22 +108,42 @@ func (app *APIApplication) listTodos(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxIdempotencyKeyLength is the maximum length of an Idempotency-Key header.
+const maxIdempotencyKeyLength = 255
+
 // createTodo handles POST requests to the /v1/todos endpoint. The request
 // body is decoded by the app.readJSON helper. See that function for details
 // about error handling.
 //
 // Request bodies are validated by ValidateTodo. A failedValidationResponse
 // error is sent if one or more fields fails validation.
+//
+// If the request has an Idempotency-Key header, and the user has already
+// created a todo with the same key within app.Config.IdempotencyKeyTTL, no
+// todo is created. The original todo is sent instead, with the same 201
+// response, and an Idempotent-Replayed: true header.
+//
+// If app.Config.DedupeTodos is true, or the "dedupe" query parameter is true,
+// no todo is created if the user already has an active todo with the same
+// text. A 409 Conflict response is sent instead, with the existing todo's ID:
+//
+//	{ "error": "an active todo with the same text already exists", "id": 42 }
+//
+// The idempotency key is checked before duplicates, so that retrying a request
+// that created a todo replays it rather than conflicting with it.
 func (app *APIApplication) createTodo(w http.ResponseWriter, r *http.Request) {
 	// Struct to store the data from the response's body. The struct's fields must
 	// be exported to use it with json.NewDecoder.
 	var input struct {
 		Text      string   `json:"text"`
+		Note      string   `json:"note"`
 		Contexts  []string `json:"contexts"`
 		Projects  []string `json:"projects"`
 		Priority  string   `json:"priority"`
 		Completed bool     `json:"completed"`
 		Archived  bool     `json:"archived"`
+		Starred   bool     `json:"starred"`
+		DueDate   string   `json:"due_date"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -105,32 +153,74 @@ func (app *APIApplication) createTodo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	todo := &data.Todo{
-		Text:      input.Text,
+		Text:      data.NormalizeText(input.Text),
+		Note:      input.Note,
 		UserID:    contextGet[*data.User](r, userContextKey).ID,
 		Contexts:  input.Contexts,
 		Projects:  input.Projects,
 		Priority:  input.Priority,
 		Completed: input.Completed,
 		Archived:  input.Archived,
+		Starred:   input.Starred,
 	}
 
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+
 	v := validator.New()
-	data.ValidateTodo(v, todo)
+	todo.DueDate = app.readDate("due_date", input.DueDate, v)
+	data.ValidateTodo(v, todo, app.Config.Todos)
+	v.CheckRule(len(idempotencyKey) <= maxIdempotencyKeyLength, "idempotency_key", validator.RuleTooLong, fmt.Sprintf("must be no more than %d bytes", maxIdempotencyKeyLength))
+	dedupe := app.readQueryBool(r.URL.Query(), "dedupe", app.Config.DedupeTodos, v)
 
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	err = app.Models.Todos.Insert(todo)
+	if dedupe && idempotencyKey != "" {
+		original, err := app.Models.Todos.GetIdempotent(r.Context(), todo.UserID, idempotencyKey)
+		switch {
+		case err == nil:
+			app.replayTodoResponse(w, r, original)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if dedupe {
+		id, err := app.Models.Todos.FindDuplicate(r.Context(), todo.UserID, todo.Text)
+		switch {
+		case err == nil:
+			app.duplicateTodoResponse(w, r, id)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	replayed := false
+	if idempotencyKey == "" {
+		err = app.Models.Todos.Insert(r.Context(), todo)
+	} else {
+		todo, replayed, err = app.Models.Todos.InsertIdempotent(r.Context(), todo, idempotencyKey, app.Config.IdempotencyKeyTTL)
+	}
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Specify the API location of the created resource.
+	if replayed {
+		app.replayTodoResponse(w, r, todo)
+		return
+	}
+	app.todoEvents.publish(todo.UserID, todoEvent{Type: todoCreated, Todo: todo})
+
+	// Specify the absolute API location of the created resource.
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/todos/%d", todo.ID))
+	headers.Set("Location", app.absoluteURL(r, fmt.Sprintf("/v1/todos/%d", todo.ID)))
 
 	err = app.writeJSON(w, http.StatusCreated, envelope{"todo": todo}, headers)
 	if err != nil {
@@ -139,20 +229,33 @@ func (app *APIApplication) createTodo(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getTodo handles GET requests to the /v1/todos/:id endpoint. If there is a
-// todo item with matching ID and userID it will be sent in the response.
-//
-// If not, a 404 Not Found response is sent.
-func (app *APIApplication) getTodo(w http.ResponseWriter, r *http.Request) {
+// replayTodoResponse sends the todo that was created by an earlier request
+// with the same idempotency key. The response is the same as the original
+// one, with an Idempotent-Replayed: true header.
+func (app *APIApplication) replayTodoResponse(w http.ResponseWriter, r *http.Request, todo *data.Todo) {
+	headers := make(http.Header)
+	headers.Set("Idempotent-Replayed", "true")
+	headers.Set("Location", app.absoluteURL(r, fmt.Sprintf("/v1/todos/%d", todo.ID)))
+
+	err := app.writeJSON(w, http.StatusCreated, envelope{"todo": todo}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getOwnedTodo returns the todo with the ID in the request's URL, if it is
+// owned by the current user. If it isn't found, or can't be retrieved, an
+// error response is sent, and ok is false.
+func (app *APIApplication) getOwnedTodo(w http.ResponseWriter, r *http.Request) (todo *data.Todo, ok bool) {
 	id, err := app.readIdParam(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
-		return
+		return nil, false
 	}
 
 	userID := contextGet[*data.User](r, userContextKey).ID
 
-	todo, err := app.Models.Todos.GetTodoIfOwned(id, userID)
+	todo, err = app.Models.Todos.GetTodoIfOwned(r.Context(), id, userID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -160,10 +263,49 @@ func (app *APIApplication) getTodo(w http.ResponseWriter, r *http.Request) {
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
+		return nil, false
+	}
+	return todo, true
+}
+
+// getTodo handles GET requests to the /v1/todos/:id endpoint. If there is a
+// todo item with matching ID and userID it will be sent in the response.
+//
+// The response is JSON by default. If the request's Accept header prefers
+// text/plain, the todo is sent as a single line of todo.txt text instead. See
+// data.FormatTodo for details.
+//
+// If not, a 404 Not Found response is sent.
+func (app *APIApplication) getTodo(w http.ResponseWriter, r *http.Request) {
+	todo, ok := app.getOwnedTodo(w, r)
+	if !ok {
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"todo": todo}, nil)
+	var err error
+	if app.acceptsPlainText(r) {
+		err = app.writeText(w, http.StatusOK, data.FormatTodo(todo), nil)
+	} else {
+		err = app.writeJSON(w, http.StatusOK, envelope{"todo": todo}, nil)
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// getRawTodo handles GET requests to the /v1/todos/:id/raw endpoint. It sends
+// the todo with matching ID and userID as a single line of todo.txt text,
+// regardless of the request's Accept header.
+//
+// If there is no such todo, a 404 Not Found response is sent.
+func (app *APIApplication) getRawTodo(w http.ResponseWriter, r *http.Request) {
+	todo, ok := app.getOwnedTodo(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeText(w, http.StatusOK, data.FormatTodo(todo), nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -175,26 +317,12 @@ func (app *APIApplication) getTodo(w http.ResponseWriter, r *http.Request) {
 // supported.
 //
 // If fields are omitted in the request body, or if they are given a null value
-// they will be unchanged.
+// they will be unchanged. To clear a todo's note, set it to an empty string.
 //
 // Only todo items with matching ID and userID can be updated.
 func (app *APIApplication) updateTodo(w http.ResponseWriter, r *http.Request) {
-	id, err := app.readIdParam(r)
-	if err != nil {
-		app.notFoundResponse(w, r)
-		return
-	}
-
-	userID := contextGet[*data.User](r, userContextKey).ID
-
-	todo, err := app.Models.Todos.GetTodoIfOwned(id, userID)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
-		}
+	todo, ok := app.getOwnedTodo(w, r)
+	if !ok {
 		return
 	}
 
@@ -203,15 +331,18 @@ func (app *APIApplication) updateTodo(w http.ResponseWriter, r *http.Request) {
 	// pointer will be nil, and we can leave the corresponding field unchanged.
 	var input struct {
 		Text      *string   `json:"text"`
+		Note      *string   `json:"note"`
 		Contexts  *[]string `json:"contexts"`
 		Projects  *[]string `json:"projects"`
 		Priority  *string   `json:"priority"`
 		Completed *bool     `json:"completed"`
 		Archived  *bool     `json:"archived"`
+		Starred   *bool     `json:"starred"`
+		DueDate   *string   `json:"due_date"`
 	}
 
 	// Read JSON from request body into the input struct.
-	err = app.readJSON(w, r, &input)
+	err := app.readJSON(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -219,7 +350,10 @@ func (app *APIApplication) updateTodo(w http.ResponseWriter, r *http.Request) {
 
 	// If the input field isn't nil, update the corresponding field in the record.
 	if input.Text != nil {
-		todo.Text = *input.Text
+		todo.Text = data.NormalizeText(*input.Text)
+	}
+	if input.Note != nil {
+		todo.Note = *input.Note
 	}
 	if input.Contexts != nil {
 		todo.Contexts = *input.Contexts
@@ -236,17 +370,24 @@ func (app *APIApplication) updateTodo(w http.ResponseWriter, r *http.Request) {
 	if input.Archived != nil {
 		todo.Archived = *input.Archived
 	}
+	if input.Starred != nil {
+		todo.Starred = *input.Starred
+	}
 
-	// Validate the updated todo record, or return a 422 response.
+	// Validate the updated todo record, or return a 422 response. An empty
+	// due date clears it.
 	v := validator.New()
-	data.ValidateTodo(v, todo)
+	if input.DueDate != nil {
+		todo.DueDate = app.readDate("due_date", *input.DueDate, v)
+	}
+	data.ValidateTodo(v, todo, app.Config.Todos)
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
 	// Pass updated todo record to Todos.Update().
-	err = app.Models.Todos.Update(todo)
+	err = app.Models.Todos.Update(r.Context(), todo)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -257,6 +398,8 @@ func (app *APIApplication) updateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	app.todoEvents.publish(todo.UserID, todoEvent{Type: todoUpdated, Todo: todo})
+
 	// Write updated JSON to response.
 	err = app.writeJSON(w, http.StatusOK, envelope{"todo": todo}, nil)
 	if err != nil {
@@ -266,10 +409,11 @@ func (app *APIApplication) updateTodo(w http.ResponseWriter, r *http.Request) {
 }
 
 // deleteTodo handles requests to DELETE /v1/todos/:id. If it finds a
-// document with the supplied ID it removes it from the database and sends a
-// JSON response: { "message": "todo successfully deleted" }
+// document with the supplied ID that the user owns, it removes it from the
+// database and sends a JSON response: { "message": "todo successfully deleted" }
 //
-// If the document is not found, a 404 response is sent.
+// If the document is not found, or belongs to another user, a 404 response is
+// sent, and no event is published.
 func (app *APIApplication) deleteTodo(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIdParam(r)
 	if err != nil {
@@ -277,8 +421,10 @@ func (app *APIApplication) deleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userID := contextGet[*data.User](r, userContextKey).ID
+
 	// Delete record or send an error response.
-	err = app.Models.Todos.Delete(id)
+	err = app.Models.Todos.DeleteIfOwned(r.Context(), id, userID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -289,8 +435,636 @@ func (app *APIApplication) deleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	app.todoEvents.publish(userID, todoEvent{Type: todoDeleted, ID: id})
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "todo successfuly deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// completeTodos handles POST requests to the /v1/todos/complete endpoint. It
+// marks all of the user's incomplete, unarchived todos that match the filters
+// in the request body as completed. The filters are the same as those used
+// for listing todos:
+//
+//	{
+//	    "text": "milk",
+//	    "contexts": ["store"],
+//	    "projects": ["groceries"]
+//	}
+//
+// At least one filter must be provided, to prevent completing all of a user's
+// todos by accident. The response contains the number of todos completed:
+//
+//	{ "completed": 3 }
+//
+// If "preview" is true in the request body or query string, no todos are
+// modified. Instead, the todos that would have been completed are sent:
+//
+//	{ "todos": [ ... ] }
+func (app *APIApplication) completeTodos(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Text     string   `json:"text"`
+		Contexts []string `json:"contexts"`
+		Projects []string `json:"projects"`
+		Preview  bool     `json:"preview"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	preview := app.readQueryBool(r.URL.Query(), "preview", false, v) || input.Preview
+	v.CheckRule(input.Text != "" || len(input.Contexts) > 0 || len(input.Projects) > 0,
+		"filters", validator.RuleRequired, "at least one of text, contexts, or projects must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	userID := contextGet[*data.User](r, userContextKey).ID
+
+	if preview {
+		todos, err := app.Models.Todos.PreviewCompleteMatching(r.Context(), input.Text, userID, input.Contexts, input.Projects)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"todos": todos}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	count, err := app.Models.Todos.CompleteMatching(r.Context(), input.Text, userID, input.Contexts, input.Projects)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if count > 0 {
+		app.todoEvents.publish(userID, todoEvent{Type: todosChanged})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"completed": count}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// tagTodos handles POST requests to the /v1/todos/tag endpoint. It adds
+// contexts and projects to, and removes them from, all of the user's
+// unarchived todos that match the filters in the request body. The filters are
+// the same as those used by completeTodos:
+//
+//	{
+//	    "projects": ["groceries"],
+//	    "add_contexts": ["store"],
+//	    "remove_contexts": ["home"],
+//	    "add_projects": [],
+//	    "remove_projects": []
+//	}
+//
+// At least one filter must be provided. Todos that would be left with too many
+// contexts or projects are skipped. The response contains the number of todos
+// updated and skipped:
+//
+//	{ "updated": 3, "skipped": 1 }
+func (app *APIApplication) tagTodos(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Text           string   `json:"text"`
+		Contexts       []string `json:"contexts"`
+		Projects       []string `json:"projects"`
+		AddContexts    []string `json:"add_contexts"`
+		RemoveContexts []string `json:"remove_contexts"`
+		AddProjects    []string `json:"add_projects"`
+		RemoveProjects []string `json:"remove_projects"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	changes := data.TagChanges{
+		AddContexts:    input.AddContexts,
+		RemoveContexts: input.RemoveContexts,
+		AddProjects:    input.AddProjects,
+		RemoveProjects: input.RemoveProjects,
+	}
+
+	v := validator.New()
+	v.CheckRule(input.Text != "" || len(input.Contexts) > 0 || len(input.Projects) > 0,
+		"filters", validator.RuleRequired, "at least one of text, contexts, or projects must be provided")
+	data.ValidateTagChanges(v, changes, app.Config.Todos)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	userID := contextGet[*data.User](r, userContextKey).ID
+
+	updated, skipped, err := app.Models.Todos.TagMatching(r.Context(), input.Text, userID, input.Contexts, input.Projects, changes, app.Config.Todos)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if updated > 0 {
+		app.todoEvents.publish(userID, todoEvent{Type: todosChanged})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"updated": updated, "skipped": skipped}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// archiveCompletedTodos handles POST requests to the
+// /v1/todos/archive-completed endpoint. It archives all of the user's
+// completed, unarchived todos. The request body is optional, and can narrow
+// the todos archived to those with each of the given contexts and projects:
+//
+//	{
+//	    "contexts": ["work"],
+//	    "projects": ["godo"]
+//	}
+//
+// The response contains the number of todos archived:
+//
+//	{ "archived": 3 }
+func (app *APIApplication) archiveCompletedTodos(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Contexts []string `json:"contexts"`
+		Projects []string `json:"projects"`
+	}
+
+	// The body is read to tell whether it is empty, since a chunked request's
+	// length isn't known in advance.
+	err := app.readJSON(w, r, &input)
+	if err != nil && !errors.Is(err, errEmptyBody) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	userID := contextGet[*data.User](r, userContextKey).ID
+
+	count, err := app.Models.Todos.ArchiveCompleted(r.Context(), userID, input.Contexts, input.Projects)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if count > 0 {
+		app.todoEvents.publish(userID, todoEvent{Type: todosChanged})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"archived": count}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// batchResult is the outcome of a batch operation for a single todo ID.
+type batchResult struct {
+	ID    int64  `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// deleteTodosBatch handles DELETE requests to the /v1/todos endpoint. The
+// request body contains the IDs of the todos to delete:
+//
+//	{ "ids": [1, 2, 3] }
+//
+// At most app.Config.MaxBatchSize IDs can be provided. Each todo is only
+// deleted if it is owned by the current user. The response
+// contains a result for each ID, in the order they were provided:
+//
+//	{
+//	    "results": [
+//	        { "id": 1, "ok": true },
+//	        { "id": 2, "ok": false, "error": "todo not found" }
+//	    ]
+//	}
+//
+// If "preview" is true in the request body or query string, no todos are
+// deleted. Instead, the todos that would have been deleted are sent:
+//
+//	{ "todos": [ ... ] }
+func (app *APIApplication) deleteTodosBatch(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		IDs     []int64 `json:"ids"`
+		Preview bool    `json:"preview"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	preview := app.readQueryBool(r.URL.Query(), "preview", false, v) || input.Preview
+	data.ValidateBatchIDs(v, input.IDs, app.Config.MaxBatchSize)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	userID := contextGet[*data.User](r, userContextKey).ID
+
+	if preview {
+		todos, err := app.Models.Todos.GetManyIfOwned(r.Context(), input.IDs, userID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"todos": todos}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	deleted, err := app.Models.Todos.DeleteManyIfOwned(r.Context(), input.IDs, userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Any ID that wasn't deleted either doesn't exist or is owned by another
+	// user. Both are reported as not found.
+	results := make([]batchResult, len(input.IDs))
+	for i, id := range input.IDs {
+		results[i].ID = id
+		if _, found := slices.BinarySearch(deleted, id); found {
+			results[i].OK = true
+		} else {
+			results[i].Error = "todo not found"
+		}
+	}
+
+	for _, id := range deleted {
+		app.todoEvents.publish(userID, todoEvent{Type: todoDeleted, ID: id})
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importResult is the outcome of importing a single line of todo.txt text.
+type importResult struct {
+	Line      int        `json:"line"`
+	OK        bool       `json:"ok"`
+	Duplicate bool       `json:"duplicate,omitempty"`
+	Todo      *data.Todo `json:"todo,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Errors    any        `json:"errors,omitempty"`
+}
+
+// importTodos handles POST requests to the /v1/todos/import endpoint. The
+// request body is a todo.txt file, sent with "Content-Type: text/plain". Each
+// non-empty line is parsed by data.ParseTodo and validated by ValidateTodo.
+//
+// At most app.Config.MaxBatchSize todos can be imported at once, and the body
+// may not exceed app.Config.MaxRequestBody bytes.
+//
+// The todos are inserted in a single transaction, so either every line is
+// imported or none are. The response contains a result for each non-empty
+// line, with its line number:
+//
+//	{
+//	    "results": [
+//	        { "line": 1, "ok": true, "todo": { "id": 1, ... } },
+//	        { "line": 3, "ok": true, "todo": { "id": 2, ... } }
+//	    ]
+//	}
+//
+// If any line is invalid, nothing is imported, and a 422 response is sent.
+// The results for the invalid lines contain either an error message, or the
+// validation errors for each field, in the same format as
+// failedValidationResponse:
+//
+//	{ "line": 2, "ok": false, "errors": { "priority": { ... } } }
+//
+// Idempotency keys and the "dedupe" query parameter are handled as they are by
+// createTodo. A retried request with the same Idempotency-Key header gets the
+// original response, with an Idempotent-Replayed: true header. When deduping,
+// lines with the same text as an active todo, or as an earlier line, aren't
+// inserted. Their results have "duplicate": true, and the existing todo.
+func (app *APIApplication) importTodos(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "text/plain" {
+		app.unsupportedMediaTypeResponse(w, r, "text/plain")
+		return
+	}
+
+	body, err := app.readText(w, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	userID := contextGet[*data.User](r, userContextKey).ID
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+
+	v := validator.New()
+	v.CheckRule(len(idempotencyKey) <= maxIdempotencyKeyLength, "idempotency_key", validator.RuleTooLong, fmt.Sprintf("must be no more than %d bytes", maxIdempotencyKeyLength))
+	dedupe := app.readQueryBool(r.URL.Query(), "dedupe", app.Config.DedupeTodos, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	var todos []*data.Todo
+	var results []importResult
+	valid := true
+
+	for i, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		result := importResult{Line: i + 1}
+
+		todo, err := data.ParseTodo(line)
+		if err != nil {
+			result.Error = err.Error()
+			valid = false
+			results = append(results, result)
+			continue
+		}
+		todo.UserID = userID
+
+		v := validator.New()
+		data.ValidateTodo(v, todo, app.Config.Todos)
+		if !v.Valid() {
+			result.Errors = app.validationErrors(r, v)
+			valid = false
+		} else {
+			result.OK = true
+			result.Todo = todo
+		}
+
+		todos = append(todos, todo)
+		results = append(results, result)
+	}
+
+	data.ValidateImportSize(v, len(results), app.Config.MaxBatchSize)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	if !valid {
+		w.Header().Add("Vary", "X-Error-Format")
+		err = app.writeJSON(w, http.StatusUnprocessableEntity, envelope{"results": results}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The idempotency key is checked before duplicates, so that retrying a
+	// request replays it rather than finding the todos it created.
+	if idempotencyKey != "" {
+		response, err := app.Models.Todos.GetIdempotentResponse(r.Context(), userID, idempotencyKey)
+		switch {
+		case err == nil:
+			app.replayImportResponse(w, r, response)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if dedupe {
+		todos, err = app.dedupeImport(r, todos, results)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if idempotencyKey == "" {
+		err = app.Models.Todos.InsertMany(r.Context(), todos)
+	} else {
+		var response []byte
+		var replayed bool
+		response, replayed, err = app.Models.Todos.InsertManyIdempotent(r.Context(), userID, todos, idempotencyKey, app.Config.IdempotencyKeyTTL, func() ([]byte, error) {
+			return json.Marshal(results)
+		})
+		if err == nil && replayed {
+			app.replayImportResponse(w, r, response)
+			return
+		}
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.todoEvents.publish(userID, todoEvent{Type: todosChanged})
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// dedupeImport marks the results of todos that have the same text as one of
+// the user's active todos, or as an earlier todo in the import, as duplicates,
+// and sets their todo to the existing one. The results are in the same order
+// as the todos, which must all be valid. The todos that aren't duplicates are
+// returned.
+func (app *APIApplication) dedupeImport(r *http.Request, todos []*data.Todo, results []importResult) ([]*data.Todo, error) {
+	var unique []*data.Todo
+	seen := make(map[string]*data.Todo)
+
+	for i, todo := range todos {
+		if earlier, ok := seen[todo.Text]; ok {
+			results[i].Duplicate = true
+			results[i].Todo = earlier
+			continue
+		}
+
+		id, err := app.Models.Todos.FindDuplicate(r.Context(), todo.UserID, todo.Text)
+		switch {
+		case err == nil:
+			existing, err := app.Models.Todos.GetTodoIfOwned(r.Context(), id, todo.UserID)
+			if err != nil {
+				return nil, err
+			}
+			results[i].Duplicate = true
+			results[i].Todo = existing
+			seen[todo.Text] = existing
+		case errors.Is(err, data.ErrRecordNotFound):
+			unique = append(unique, todo)
+			seen[todo.Text] = todo
+		default:
+			return nil, err
+		}
+	}
+
+	return unique, nil
+}
+
+// replayImportResponse sends the results stored for an import with the same
+// idempotency key, with an Idempotent-Replayed: true header.
+func (app *APIApplication) replayImportResponse(w http.ResponseWriter, r *http.Request, results []byte) {
+	headers := make(http.Header)
+	headers.Set("Idempotent-Replayed", "true")
+
+	err := app.writeJSON(w, http.StatusCreated, envelope{"results": json.RawMessage(results)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// validateTodoLine handles POST requests to the /v1/todos/validate endpoint.
+// It parses a line of todo.txt text with data.ParseTodo and validates it
+// with ValidateTodo, in the same way as importTodos, but doesn't insert the
+// todo. This lets clients check a line before submitting it. The request
+// body has the following form:
+//
+//	{ "line": "(A) call mom @phone" }
+//
+// The response has a 200 OK status whether or not the line is valid. It
+// contains the parsed todo, unless the line couldn't be parsed, and either an
+// error message or the validation errors for each field, in the same format
+// as failedValidationResponse:
+//
+//	{ "ok": false, "todo": { ... }, "errors": { "priority": { ... } } }
+func (app *APIApplication) validateTodoLine(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Line string `json:"line"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	result := envelope{"ok": false}
+
+	todo, err := data.ParseTodo(input.Line)
+	if err != nil {
+		result["error"] = err.Error()
+	} else {
+		todo.UserID = contextGet[*data.User](r, userContextKey).ID
+		todo.NilToSlices()
+		result["todo"] = todo
+
+		v := validator.New()
+		data.ValidateTodo(v, todo, app.Config.Todos)
+		if v.Valid() {
+			result["ok"] = true
+		} else {
+			result["errors"] = app.validationErrors(r, v)
+			w.Header().Add("Vary", "X-Error-Format")
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, result, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getAgenda handles GET requests to the /v1/agenda endpoint. It sends the
+// user's overdue, due today, and high priority todos, grouped by
+// data.GroupAgenda:
+//
+//	{
+//	    "date": "2024-03-10",
+//	    "agenda": {
+//	        "overdue": [ ... ],
+//	        "due_today": [ ... ],
+//	        "high_priority": [ ... ]
+//	    }
+//	}
+//
+// The date query parameter sets the date that is considered today, in the
+// format YYYY-MM-DD, so that clients can use their own time zone. It defaults
+// to the current date of app.Models.Clock.
+//
+// The endpoint can't be /v1/todos/agenda, since httprouter doesn't allow it
+// to share a segment with /v1/todos/:id.
+func (app *APIApplication) getAgenda(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	today := app.readQueryDate(r.URL.Query(), "date", v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+	if today.IsZero() {
+		today = app.Models.Clock.Now()
+	}
+
+	userID := contextGet[*data.User](r, userContextKey).ID
+
+	agenda, err := app.Models.Todos.GetAgenda(r.Context(), userID, today)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"date": today.Format(time.DateOnly), "agenda": agenda}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getReport handles GET requests to the /v1/report endpoint. It sends the
+// number of todos the user completed in each week or month, oldest first:
+//
+//	{
+//	    "period": "week",
+//	    "report": [
+//	        { "start": "2024-03-04T00:00:00Z", "completed": 3 },
+//	        { "start": "2024-03-11T00:00:00Z", "completed": 5 }
+//	    ]
+//	}
+//
+// The period query parameter is either "week" (the default) or "month".
+// Periods begin at midnight UTC. Periods in which no todos were completed are
+// omitted.
+//
+// Like the agenda, the endpoint can't be /v1/todos/report, since httprouter
+// doesn't allow a static segment such as "report" to share a position with the
+// :id parameter of /v1/todos/:id.
+func (app *APIApplication) getReport(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	period := app.readQueryString(r.URL.Query(), "period", "week")
+	v.CheckRule(validator.PermittedValue(period, data.ReportPeriods...),
+		"period", validator.RuleNotAllowed, "must be week or month")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	userID := contextGet[*data.User](r, userContextKey).ID
+
+	report, err := app.Models.Todos.CompletionReport(r.Context(), userID, period)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"period": period, "report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
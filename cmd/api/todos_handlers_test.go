@@ -0,0 +1,1437 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/julienschmidt/httprouter"
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+// todoColumns are the columns returned by TodoModel.GetTodoIfOwned.
+var todoColumns = []string{"id", "user_id", "created_at", "text", "contexts", "projects", "priority", "completed", "archived", "starred", "note", "version", "due_date", "priority_escalated_at", "completed_at"}
+
+func TestGetTodoContentNegotiation(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "JSON by default",
+			accept:      "",
+			contentType: "application/json",
+		},
+		{
+			name:        "JSON",
+			accept:      "application/json",
+			contentType: "application/json",
+		},
+		{
+			name:        "Plain text",
+			accept:      "text/plain",
+			contentType: "text/plain; charset=utf-8",
+			body:        "x (A) call mom @phone +family\n",
+		},
+		{
+			name:        "JSON has a higher q-value",
+			accept:      "text/plain;q=0.9, application/json",
+			contentType: "application/json",
+		},
+		{
+			name:        "Plain text has a higher q-value",
+			accept:      "application/json;q=0.5, text/plain",
+			contentType: "text/plain; charset=utf-8",
+			body:        "x (A) call mom @phone +family\n",
+		},
+		{
+			name:        "Equal q-values",
+			accept:      "text/plain, application/json",
+			contentType: "text/plain; charset=utf-8",
+			body:        "x (A) call mom @phone +family\n",
+		},
+		{
+			name:        "Plain text not acceptable",
+			accept:      "text/plain;q=0",
+			contentType: "application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+
+			rows := sqlmock.NewRows(todoColumns).
+				AddRow(1, testUser.ID, time.Now(), "call mom @phone", "{phone}", "{family}", "A", true, false, false, "", 1, nil, nil, nil)
+			mock.ExpectQuery("SELECT (.+) FROM todos WHERE ID = \\$1 AND user_id = \\$2").
+				WithArgs(1, testUser.ID).
+				WillReturnRows(rows)
+
+			r := newTestRequest(http.MethodGet, "/v1/todos/1", nil, httprouter.Params{{Key: "id", Value: "1"}})
+			r.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+
+			app.getTodo(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.Equal(t, rr.Header().Get("Content-type"), tt.contentType)
+
+			if tt.body != "" {
+				assert.Equal(t, rr.Body.String(), tt.body)
+			} else {
+				var response struct {
+					Todo struct {
+						ID   int64  `json:"id"`
+						Text string `json:"text"`
+					} `json:"todo"`
+				}
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, response.Todo.ID, 1)
+				assert.Equal(t, response.Todo.Text, "call mom @phone")
+			}
+
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetRawTodo(t *testing.T) {
+	app, _ := newTestApplication(t)
+	todos := data.NewMemoryTodoModel()
+	app.Models.Todos = todos
+
+	mine := &data.Todo{Text: "call mom @phone", Priority: "A", Completed: true, Contexts: []string{"phone"}, UserID: testUser.ID}
+	theirs := &data.Todo{Text: "call dad", UserID: testUser.ID + 1}
+	assert.IsNil(t, todos.InsertMany(context.Background(), []*data.Todo{mine, theirs}))
+
+	getRawTodo := func(id int64) *httptest.ResponseRecorder {
+		target := fmt.Sprintf("/v1/todos/%d/raw", id)
+		r := newTestRequest(http.MethodGet, target, nil, httprouter.Params{{Key: "id", Value: fmt.Sprint(id)}})
+		// The todo is sent as text, even if JSON is preferred.
+		r.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+		app.getRawTodo(rr, r)
+		return rr
+	}
+
+	rr := getRawTodo(mine.ID)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+	assert.Equal(t, rr.Body.String(), "x (A) call mom @phone\n")
+
+	// Another user's todo isn't found.
+	assert.Equal(t, getRawTodo(theirs.ID).Code, http.StatusNotFound)
+	assert.Equal(t, getRawTodo(theirs.ID+1).Code, http.StatusNotFound)
+}
+
+func TestCompleteTodos(t *testing.T) {
+	t.Run("Returns count", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		mock.ExpectExec("UPDATE todos SET completed = true").
+			WithArgs("", testUser.ID, `{"groceries"}`).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+
+		body := strings.NewReader(`{"projects": ["groceries"]}`)
+		r := newTestRequest(http.MethodPost, "/v1/todos/complete", body, nil)
+		rr := httptest.NewRecorder()
+
+		app.completeTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+
+		var response struct {
+			Completed int64 `json:"completed"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, response.Completed, 3)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Requires a filter", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		r := newTestRequest(http.MethodPost, "/v1/todos/complete", strings.NewReader(`{}`), nil)
+		rr := httptest.NewRecorder()
+
+		app.completeTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestArchiveCompletedTodos(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		args    []driver.Value
+		count   int64
+	}{
+		{
+			name:  "No body",
+			args:  []driver.Value{"", testUser.ID},
+			count: 4,
+		},
+		{
+			name:    "Chunked, no body",
+			chunked: true,
+			args:    []driver.Value{"", testUser.ID},
+			count:   4,
+		},
+		{
+			name:  "Scoped by project",
+			body:  `{"projects": ["godo"]}`,
+			args:  []driver.Value{"", testUser.ID, `{"godo"}`},
+			count: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+
+			mock.ExpectExec("UPDATE todos SET archived = true, (.+) AND archived = false AND completed = true").
+				WithArgs(tt.args...).
+				WillReturnResult(sqlmock.NewResult(0, tt.count))
+
+			r := newTestRequest(http.MethodPost, "/v1/todos/archive-completed", strings.NewReader(tt.body), nil)
+			if tt.chunked {
+				// The length of a chunked request's body isn't known.
+				r.ContentLength = -1
+				r.TransferEncoding = []string{"chunked"}
+			}
+			rr := httptest.NewRecorder()
+
+			app.archiveCompletedTodos(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusOK)
+
+			var response struct {
+				Archived int64 `json:"archived"`
+			}
+			err := json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, response.Archived, tt.count)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTagTodos(t *testing.T) {
+	t.Run("Returns counts", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		mock.ExpectQuery("UPDATE todos SET contexts = matched.contexts").
+			WithArgs("", testUser.ID, `{"groceries"}`, "home", "store", `{"store"}`, 5, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"updated", "skipped"}).AddRow(3, 1))
+
+		body := strings.NewReader(`{"projects": ["groceries"], "add_contexts": ["store"], "remove_contexts": ["home"]}`)
+		r := newTestRequest(http.MethodPost, "/v1/todos/tag", body, nil)
+		rr := httptest.NewRecorder()
+
+		app.tagTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+
+		var response struct {
+			Updated int64 `json:"updated"`
+			Skipped int64 `json:"skipped"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, response.Updated, 3)
+		assert.Equal(t, response.Skipped, 1)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rejects too many tags", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+		app.Config.Todos.MaxContexts = 1
+
+		body := strings.NewReader(`{"projects": ["groceries"], "add_contexts": ["store", "car"]}`)
+		r := newTestRequest(http.MethodPost, "/v1/todos/tag", body, nil)
+		rr := httptest.NewRecorder()
+
+		app.tagTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, rr.Body.String(), "must be no more than 1 contexts")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCompleteTodosPreview(t *testing.T) {
+	app, mock := newTestApplication(t)
+
+	// Only the SELECT is expected. Any UPDATE would fail the request.
+	rows := sqlmock.NewRows(todoColumns).
+		AddRow(1, testUser.ID, time.Now(), "buy milk", "{}", "{groceries}", "", false, false, false, "", 1, nil, nil, nil).
+		AddRow(2, testUser.ID, time.Now(), "buy eggs", "{}", "{groceries}", "", false, false, false, "", 1, nil, nil, nil)
+	mock.ExpectQuery(`SELECT (.+) FROM todos WHERE (.+) AND projects @> \$3 AND archived = false AND completed = false`).
+		WithArgs("", testUser.ID, `{"groceries"}`).
+		WillReturnRows(rows)
+
+	body := strings.NewReader(`{"projects": ["groceries"], "preview": true}`)
+	r := newTestRequest(http.MethodPost, "/v1/todos/complete", body, nil)
+	rr := httptest.NewRecorder()
+
+	app.completeTodos(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	var response struct {
+		Todos []struct {
+			ID int64 `json:"id"`
+		} `json:"todos"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(response.Todos), 2)
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteTodosBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		body    string
+		preview bool
+	}{
+		{name: "Delete", target: "/v1/todos", body: `{"ids": [1, 2]}`},
+		{name: "Preview in body", target: "/v1/todos", body: `{"ids": [1, 2], "preview": true}`, preview: true},
+		{name: "Preview in query", target: "/v1/todos?preview=true", body: `{"ids": [1, 2]}`, preview: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+
+			// A single query is expected, whether previewing or deleting. Todo 2
+			// isn't owned by the user, so it isn't returned.
+			if tt.preview {
+				rows := sqlmock.NewRows(todoColumns).
+					AddRow(1, testUser.ID, time.Now(), "buy milk", "{}", "{}", "", false, false, false, "", 1, nil, nil, nil)
+				mock.ExpectQuery("SELECT (.+) FROM todos WHERE id = ANY\\(\\$1\\) AND user_id = \\$2").
+					WithArgs(`{1,2}`, testUser.ID).
+					WillReturnRows(rows)
+			} else {
+				mock.ExpectQuery("DELETE FROM todos WHERE id = ANY\\(\\$1\\) AND user_id = \\$2 RETURNING id").
+					WithArgs(`{1,2}`, testUser.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			}
+
+			r := newTestRequest(http.MethodDelete, tt.target, strings.NewReader(tt.body), nil)
+			rr := httptest.NewRecorder()
+
+			app.deleteTodosBatch(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusOK)
+
+			var response struct {
+				Todos   []struct{ ID int64 } `json:"todos"`
+				Results []batchResult        `json:"results"`
+			}
+			err := json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.preview {
+				assert.Equal(t, len(response.Todos), 1)
+				assert.Equal(t, response.Todos[0].ID, 1)
+			} else {
+				assert.Equal(t, len(response.Results), 2)
+				assert.Equal(t, response.Results[0], batchResult{ID: 1, OK: true})
+				assert.Equal(t, response.Results[1], batchResult{ID: 2, Error: "todo not found"})
+			}
+
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteTodosBatchLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxBatchSize int
+		ids          int
+		errMsg       string
+	}{
+		{name: "Configured limit", maxBatchSize: 2, ids: 3, errMsg: "must not contain more than 2 IDs"},
+		{name: "Default limit", ids: data.DefaultMaxBatchSize + 1, errMsg: "must not contain more than 100 IDs"},
+		{name: "No IDs", errMsg: "must be provided"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+			app.Config.MaxBatchSize = tt.maxBatchSize
+
+			ids := make([]int64, tt.ids)
+			for i := range ids {
+				ids[i] = int64(i + 1)
+			}
+			body, err := json.Marshal(map[string]any{"ids": ids})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := newTestRequest(http.MethodDelete, "/v1/todos", bytes.NewReader(body), nil)
+			rr := httptest.NewRecorder()
+
+			// No queries are expected, so any attempt to delete a todo fails the
+			// test.
+			app.deleteTodosBatch(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+			assert.StringContains(t, rr.Body.String(), tt.errMsg)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListTodosCreatedFilters(t *testing.T) {
+	t.Run("Invalid date", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		r := newTestRequest(http.MethodGet, "/v1/todos?created_after=last-week", nil, nil)
+		rr := httptest.NewRecorder()
+
+		app.listTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, rr.Body.String(), "created_after")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Valid dates", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		after := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		before := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(`SELECT (.+) FROM todos WHERE (.+) AND created_at >= \$3 AND created_at < \$4`).
+			WithArgs("", testUser.ID, after, before, 20, 0).
+			WillReturnRows(sqlmock.NewRows(append([]string{"count"}, todoColumns...)))
+
+		r := newTestRequest(http.MethodGet, "/v1/todos?created_after=2024-05-01&created_before=2024-05-08", nil, nil)
+		rr := httptest.NewRecorder()
+
+		app.listTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Valid timestamps", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		after := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+		mock.ExpectQuery(`SELECT (.+) FROM todos WHERE (.+) AND created_at >= \$3`).
+			WithArgs("", testUser.ID, after, 20, 0).
+			WillReturnRows(sqlmock.NewRows(append([]string{"count"}, todoColumns...)))
+
+		r := newTestRequest(http.MethodGet, "/v1/todos?created_after=2024-05-01T12:30:00Z", nil, nil)
+		rr := httptest.NewRecorder()
+
+		app.listTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestListTodosConflictingFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		errMsg string
+	}{
+		{name: "Done and undone", query: "done=true&undone=true", errMsg: "done and undone are mutually exclusive"},
+		{name: "Include and only archived", query: "include-archived=true&only-archived=true", errMsg: "include-archived and only-archived are mutually exclusive"},
+		{name: "Backwards date range", query: "completed_after=2024-05-08&completed_before=2024-05-01", errMsg: "must be before completed_before"},
+		{name: "Unknown sort key", query: "sort=user_id", errMsg: "invalid sorting key: user_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+
+			r := newTestRequest(http.MethodGet, "/v1/todos?"+tt.query, nil, nil)
+			rr := httptest.NewRecorder()
+
+			app.listTodos(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+			assert.StringContains(t, rr.Body.String(), tt.errMsg)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListTodosCompletedFilters(t *testing.T) {
+	t.Run("Invalid date", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		r := newTestRequest(http.MethodGet, "/v1/todos?completed_before=yesterday", nil, nil)
+		rr := httptest.NewRecorder()
+
+		app.listTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, rr.Body.String(), "completed_before")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Valid dates", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		after := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		before := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(`SELECT (.+) FROM todos WHERE (.+) AND completed_at >= \$3 AND completed_at < \$4`).
+			WithArgs("", testUser.ID, after, before, 20, 0).
+			WillReturnRows(sqlmock.NewRows(append([]string{"count"}, todoColumns...)))
+
+		r := newTestRequest(http.MethodGet, "/v1/todos?completed_after=2024-05-01&completed_before=2024-05-08", nil, nil)
+		rr := httptest.NewRecorder()
+
+		app.listTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateTodoLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		headers  map[string]string
+		location string
+	}{
+		{
+			name:     "Request host",
+			host:     "localhost:4000",
+			location: "http://localhost:4000/v1/todos/7",
+		},
+		{
+			name:     "Trusted proxy",
+			host:     "localhost:4000",
+			headers:  map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.godo.example"},
+			location: "https://api.godo.example/v1/todos/7",
+		},
+		{
+			name:     "Untrusted proxy",
+			host:     "localhost:4000",
+			headers:  map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example"},
+			location: "http://localhost:4000/v1/todos/7",
+		},
+		{
+			name:     "No host",
+			host:     "",
+			location: "/v1/todos/7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+			app.Config.Cors.TrustedOrigins = []string{"https://api.godo.example"}
+
+			mock.ExpectQuery("INSERT INTO todos").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).
+					AddRow(7, time.Now(), 1, nil))
+
+			r := newTestRequest(http.MethodPost, "/v1/todos", strings.NewReader(`{"text": "call mom"}`), nil)
+			r.Host = tt.host
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+
+			app.createTodo(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusCreated)
+			assert.Equal(t, rr.Header().Get("Location"), tt.location)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateTodoNote(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		note string
+	}{
+		{name: "Set note", body: `{"note": "ask about the holidays"}`, note: "ask about the holidays"},
+		{name: "Clear note", body: `{"note": ""}`, note: ""},
+		{name: "Omitted note is unchanged", body: `{"completed": true}`, note: "old note"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+
+			rows := sqlmock.NewRows(todoColumns).
+				AddRow(1, testUser.ID, time.Now(), "call mom", "{}", "{}", "", false, false, false, "old note", 1, nil, nil, nil)
+			mock.ExpectQuery("SELECT (.+) FROM todos WHERE ID = \\$1 AND user_id = \\$2").
+				WithArgs(1, testUser.ID).
+				WillReturnRows(rows)
+			mock.ExpectQuery("UPDATE todos").
+				WithArgs("call mom", sqlmock.AnyArg(), sqlmock.AnyArg(), "", sqlmock.AnyArg(), false, false, tt.note, nil, 1, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"version", "completed_at"}).AddRow(2, nil))
+
+			r := newTestRequest(http.MethodPatch, "/v1/todos/1", strings.NewReader(tt.body), httprouter.Params{{Key: "id", Value: "1"}})
+			rr := httptest.NewRecorder()
+
+			app.updateTodo(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusOK)
+
+			var response struct {
+				Todo struct {
+					Note string `json:"note"`
+				} `json:"todo"`
+			}
+			err := json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, response.Todo.Note, tt.note)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("Note too long", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		rows := sqlmock.NewRows(todoColumns).
+			AddRow(1, testUser.ID, time.Now(), "call mom", "{}", "{}", "", false, false, false, "", 1, nil, nil, nil)
+		mock.ExpectQuery("SELECT (.+) FROM todos WHERE ID = \\$1 AND user_id = \\$2").
+			WithArgs(1, testUser.ID).
+			WillReturnRows(rows)
+
+		body := strings.NewReader(`{"note": "` + strings.Repeat("a", 10_001) + `"}`)
+		r := newTestRequest(http.MethodPatch, "/v1/todos/1", body, httprouter.Params{{Key: "id", Value: "1"}})
+		rr := httptest.NewRecorder()
+
+		app.updateTodo(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, rr.Body.String(), "must be no more than 10000 bytes")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateTodoFakeStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		updateErr error
+		status    int
+	}{
+		{name: "Updated", status: http.StatusOK},
+		{name: "Edit conflict", updateErr: data.ErrEditConflict, status: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t)
+
+			var updated *data.Todo
+			app.Models.Todos = fakeTodoStore{
+				getTodoIfOwned: func(id, userID int64) (*data.Todo, error) {
+					if id != 1 || userID != testUser.ID {
+						return nil, data.ErrRecordNotFound
+					}
+					return &data.Todo{ID: 1, UserID: userID, Text: "call mom", Version: 1}, nil
+				},
+				update: func(todo *data.Todo) error {
+					updated = todo
+					return tt.updateErr
+				},
+			}
+
+			r := newTestRequest(http.MethodPatch, "/v1/todos/1", strings.NewReader(`{"completed": true}`), httprouter.Params{{Key: "id", Value: "1"}})
+			rr := httptest.NewRecorder()
+
+			app.updateTodo(rr, r)
+
+			assert.Equal(t, rr.Code, tt.status)
+			assert.Equal(t, updated.Text, "call mom")
+			assert.Equal(t, updated.Completed, true)
+		})
+	}
+}
+
+// expectIdempotentInsert sets the expectations for a request to create a todo
+// with a new idempotency key, which creates a todo with the given ID.
+func expectIdempotentInsert(mock sqlmock.Sqlmock, key string, id int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM idempotency_keys WHERE user_id = \\$1 AND expiry < \\$2").
+		WithArgs(testUser.ID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO idempotency_keys").
+		WithArgs(testUser.ID, key, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO todos").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(id, time.Now(), 1, nil))
+	mock.ExpectExec("UPDATE idempotency_keys SET todo_id = \\$1").
+		WithArgs(id, testUser.ID, key).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestTodoTextNormalization(t *testing.T) {
+	t.Run("Create", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		mock.ExpectQuery("INSERT INTO todos").
+			WithArgs("buy milk", testUser.ID, sqlmock.AnyArg(), sqlmock.AnyArg(), "", false, false, false, "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(7, time.Now(), 1, nil))
+
+		r := newTestRequest(http.MethodPost, "/v1/todos", strings.NewReader(`{"text": "  buy   milk  "}`), nil)
+		rr := httptest.NewRecorder()
+
+		app.createTodo(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusCreated)
+		assert.StringContains(t, rr.Body.String(), `"text": "buy milk"`)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		rows := sqlmock.NewRows(todoColumns).
+			AddRow(1, testUser.ID, time.Now(), "call mom", "{}", "{}", "", false, false, false, "", 1, nil, nil, nil)
+		mock.ExpectQuery("SELECT (.+) FROM todos WHERE ID = \\$1 AND user_id = \\$2").
+			WithArgs(1, testUser.ID).
+			WillReturnRows(rows)
+		mock.ExpectQuery("UPDATE todos").
+			WithArgs("buy milk", sqlmock.AnyArg(), sqlmock.AnyArg(), "", sqlmock.AnyArg(), false, false, "", nil, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "completed_at"}).AddRow(2, nil))
+
+		r := newTestRequest(http.MethodPatch, "/v1/todos/1", strings.NewReader(`{"text": "\tbuy \n milk "}`), httprouter.Params{{Key: "id", Value: "1"}})
+		rr := httptest.NewRecorder()
+
+		app.updateTodo(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Only whitespace", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		r := newTestRequest(http.MethodPost, "/v1/todos", strings.NewReader(`{"text": "   "}`), nil)
+		rr := httptest.NewRecorder()
+
+		app.createTodo(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, rr.Body.String(), "must be provided")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Length is checked after trimming", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		mock.ExpectQuery("INSERT INTO todos").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(7, time.Now(), 1, nil))
+
+		body := `{"text": "   ` + strings.Repeat("a", 499) + `   "}`
+		r := newTestRequest(http.MethodPost, "/v1/todos", strings.NewReader(body), nil)
+		rr := httptest.NewRecorder()
+
+		app.createTodo(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusCreated)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateTodoIdempotencyKey(t *testing.T) {
+	// createTodo sends a request to create a todo with the given idempotency
+	// key, and returns the response and the ID of the todo in its body.
+	createTodo := func(t *testing.T, app *APIApplication, key string) (*httptest.ResponseRecorder, int64) {
+		r := newTestRequest(http.MethodPost, "/v1/todos", strings.NewReader(`{"text": "call mom"}`), nil)
+		r.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+
+		app.createTodo(rr, r)
+
+		var response struct {
+			Todo struct {
+				ID int64 `json:"id"`
+			} `json:"todo"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rr, response.Todo.ID
+	}
+
+	t.Run("Repeated key", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		expectIdempotentInsert(mock, "key-1", 7)
+
+		// The key is already claimed, so the original todo is returned.
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM idempotency_keys").
+			WithArgs(testUser.ID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO idempotency_keys").
+			WithArgs(testUser.ID, "key-1", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT todo_id FROM idempotency_keys").
+			WithArgs(testUser.ID, "key-1").
+			WillReturnRows(sqlmock.NewRows([]string{"todo_id"}).AddRow(7))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT (.+) FROM todos WHERE ID = \\$1 AND user_id = \\$2").
+			WithArgs(7, testUser.ID).
+			WillReturnRows(sqlmock.NewRows(todoColumns).
+				AddRow(7, testUser.ID, time.Now(), "call mom", "{}", "{}", "", false, false, false, "", 1, nil, nil, nil))
+
+		first, firstID := createTodo(t, app, "key-1")
+		second, secondID := createTodo(t, app, "key-1")
+
+		assert.Equal(t, first.Code, http.StatusCreated)
+		assert.Equal(t, second.Code, http.StatusCreated)
+		assert.Equal(t, firstID, 7)
+		assert.Equal(t, secondID, 7)
+		assert.Equal(t, first.Header().Get("Location"), second.Header().Get("Location"))
+		assert.Equal(t, first.Header().Get("Idempotent-Replayed"), "")
+		assert.Equal(t, second.Header().Get("Idempotent-Replayed"), "true")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Distinct keys", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		expectIdempotentInsert(mock, "key-1", 7)
+		expectIdempotentInsert(mock, "key-2", 8)
+
+		first, firstID := createTodo(t, app, "key-1")
+		second, secondID := createTodo(t, app, "key-2")
+
+		assert.Equal(t, first.Code, http.StatusCreated)
+		assert.Equal(t, second.Code, http.StatusCreated)
+		assert.Equal(t, firstID, 7)
+		assert.Equal(t, secondID, 8)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Repeated key with dedupe", func(t *testing.T) {
+		app, _ := newTestApplication(t)
+		app.Models.Todos = data.NewMemoryTodoModel()
+		app.Config.DedupeTodos = true
+		app.Config.IdempotencyKeyTTL = time.Hour
+
+		// The retry is replayed, rather than rejected as a duplicate of the todo
+		// created by the first request.
+		first, firstID := createTodo(t, app, "key-1")
+		second, secondID := createTodo(t, app, "key-1")
+
+		assert.Equal(t, first.Code, http.StatusCreated)
+		assert.Equal(t, second.Code, http.StatusCreated)
+		assert.Equal(t, secondID, firstID)
+		assert.Equal(t, second.Header().Get("Idempotent-Replayed"), "true")
+	})
+}
+
+func TestCreateTodoDedupe(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		config    bool
+		lookup    bool
+		duplicate bool
+		wantCode  int
+	}{
+		{name: "Duplicate with query param", target: "/v1/todos?dedupe=true", lookup: true, duplicate: true, wantCode: http.StatusConflict},
+		{name: "Duplicate with config", target: "/v1/todos", config: true, lookup: true, duplicate: true, wantCode: http.StatusConflict},
+		{name: "No duplicate", target: "/v1/todos?dedupe=true", lookup: true, wantCode: http.StatusCreated},
+		{name: "Disabled", target: "/v1/todos", wantCode: http.StatusCreated},
+		{name: "Disabled by query param", target: "/v1/todos?dedupe=false", config: true, wantCode: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+			app.Config.DedupeTodos = tt.config
+
+			// When deduping is disabled, no lookup is expected, so the todo is
+			// inserted even if it's a duplicate.
+			if tt.lookup {
+				rows := sqlmock.NewRows([]string{"id"})
+				if tt.duplicate {
+					rows.AddRow(42)
+				}
+				mock.ExpectQuery("SELECT id FROM todos WHERE user_id = \\$1 AND text = \\$2 AND completed = false AND archived = false").
+					WithArgs(testUser.ID, "call mom").
+					WillReturnRows(rows)
+			}
+			if !tt.duplicate {
+				mock.ExpectQuery("INSERT INTO todos").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(43, time.Now(), 1, nil))
+			}
+
+			r := newTestRequest(http.MethodPost, tt.target, strings.NewReader(`{"text": "call mom"}`), nil)
+			rr := httptest.NewRecorder()
+
+			app.createTodo(rr, r)
+
+			assert.Equal(t, rr.Code, tt.wantCode)
+			if tt.duplicate {
+				var response struct {
+					ID int64 `json:"id"`
+				}
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, response.ID, 42)
+			}
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateTodoValidationErrors(t *testing.T) {
+	body := `{"text": "", "priority": "a", "contexts": ["work", "work"]}`
+
+	t.Run("Rule codes", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		r := newTestRequest(http.MethodPost, "/v1/todos", strings.NewReader(body), nil)
+		rr := httptest.NewRecorder()
+
+		app.createTodo(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		assert.Equal(t, rr.Header().Get("Vary"), "X-Error-Format")
+
+		var response struct {
+			Error map[string]struct {
+				Message string `json:"message"`
+				Rule    string `json:"rule"`
+			} `json:"error"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(response.Error), 3)
+		assert.Equal(t, response.Error["text"].Message, "must be provided")
+		assert.Equal(t, response.Error["text"].Rule, validator.RuleRequired)
+		assert.Equal(t, response.Error["priority"].Rule, validator.RuleFormat)
+		assert.Equal(t, response.Error["contexts"].Message, "must not contain duplicate values")
+		assert.Equal(t, response.Error["contexts"].Rule, validator.RuleDuplicate)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Flat format", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		r := newTestRequest(http.MethodPost, "/v1/todos", strings.NewReader(body), nil)
+		r.Header.Set("X-Error-Format", "flat")
+		rr := httptest.NewRecorder()
+
+		app.createTodo(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+
+		var response struct {
+			Error map[string]string `json:"error"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(response.Error), 3)
+		assert.Equal(t, response.Error["text"], "must be provided")
+		assert.Equal(t, response.Error["contexts"], "must not contain duplicate values")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestImportTodos(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO todos").
+			WithArgs("call mom @phone", testUser.ID, `{"phone"}`, `{}`, "A", false, false, false, "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(1, time.Now(), 1, nil))
+		mock.ExpectQuery("INSERT INTO todos").
+			WithArgs("buy milk +groceries", testUser.ID, `{}`, `{"groceries"}`, "", true, false, false, "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(2, time.Now(), 1, nil))
+		mock.ExpectCommit()
+
+		body := "(A) call mom @phone\n\nx buy milk +groceries\n"
+		r := newTestRequest(http.MethodPost, "/v1/todos/import", strings.NewReader(body), nil)
+		r.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		rr := httptest.NewRecorder()
+
+		app.importTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusCreated)
+
+		var response struct {
+			Results []importResult `json:"results"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Blank lines are skipped, but still counted.
+		assert.Equal(t, len(response.Results), 2)
+		assert.Equal(t, response.Results[0].Line, 1)
+		assert.Equal(t, response.Results[0].OK, true)
+		assert.Equal(t, response.Results[0].Todo.ID, 1)
+		assert.Equal(t, response.Results[1].Line, 3)
+		assert.Equal(t, response.Results[1].Todo.ID, 2)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Malformed line", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		// No queries are expected, since nothing is imported if any line is
+		// invalid.
+		body := "call mom\nx (A)\nbuy milk @store!\n"
+		r := newTestRequest(http.MethodPost, "/v1/todos/import", strings.NewReader(body), nil)
+		r.Header.Set("Content-Type", "text/plain")
+		rr := httptest.NewRecorder()
+
+		app.importTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+
+		var response struct {
+			Results []struct {
+				Line   int                   `json:"line"`
+				OK     bool                  `json:"ok"`
+				Error  string                `json:"error"`
+				Errors map[string]fieldError `json:"errors"`
+			} `json:"results"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(response.Results), 3)
+		assert.Equal(t, response.Results[0].OK, true)
+		assert.Equal(t, response.Results[1].Line, 2)
+		assert.Equal(t, response.Results[1].Error, "todo has no text")
+		assert.Equal(t, response.Results[2].Line, 3)
+		assert.Equal(t, response.Results[2].Errors["contexts[0]"].Rule, validator.RuleFormat)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Too many tags", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		// The contexts parsed from the text are validated like those sent as
+		// JSON, so the todo isn't stored.
+		var tags []string
+		for i := range 50 {
+			tags = append(tags, fmt.Sprintf("@tag%d", i))
+		}
+		body := "call mom " + strings.Join(tags, " ") + "\n"
+		r := newTestRequest(http.MethodPost, "/v1/todos/import", strings.NewReader(body), nil)
+		r.Header.Set("Content-Type", "text/plain")
+		rr := httptest.NewRecorder()
+
+		app.importTodos(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+
+		var response struct {
+			Results []struct {
+				OK     bool                  `json:"ok"`
+				Errors map[string]fieldError `json:"errors"`
+			} `json:"results"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(response.Results), 1)
+		assert.Equal(t, response.Results[0].OK, false)
+		assert.Equal(t, response.Results[0].Errors["contexts"].Rule, validator.RuleTooMany)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			contentType string
+			body        string
+			code        int
+			errMsg      string
+		}{
+			{name: "JSON body", contentType: "application/json", body: `{"text": "call mom"}`, code: http.StatusUnsupportedMediaType, errMsg: "Content-Type must be text/plain"},
+			{name: "Too many lines", contentType: "text/plain", body: "a\nb\nc\n", code: http.StatusUnprocessableEntity, errMsg: "must not contain more than 2 todos"},
+			{name: "Body too large", contentType: "text/plain", body: strings.Repeat("call mom\n", 10), code: http.StatusBadRequest, errMsg: "body must not exceed 64 bytes"},
+			{name: "Empty body", contentType: "text/plain", body: "\n\n", code: http.StatusBadRequest, errMsg: "must not be empty"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				app, mock := newTestApplication(t)
+				app.Config.MaxBatchSize = 2
+				app.Config.MaxRequestBody = 64
+
+				r := newTestRequest(http.MethodPost, "/v1/todos/import", strings.NewReader(tt.body), nil)
+				r.Header.Set("Content-Type", tt.contentType)
+				rr := httptest.NewRecorder()
+
+				app.importTodos(rr, r)
+
+				assert.Equal(t, rr.Code, tt.code)
+				assert.StringContains(t, rr.Body.String(), tt.errMsg)
+				assert.IsNil(t, mock.ExpectationsWereMet())
+			})
+		}
+	})
+}
+
+func TestImportTodosIdempotencyAndDedupe(t *testing.T) {
+	// importTodos sends a request to import the body, and returns the response
+	// and its results.
+	importTodos := func(t *testing.T, app *APIApplication, target, key, body string) (*httptest.ResponseRecorder, []importResult) {
+		r := newTestRequest(http.MethodPost, target, strings.NewReader(body), nil)
+		r.Header.Set("Content-Type", "text/plain")
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+
+		app.importTodos(rr, r)
+
+		var response struct {
+			Results []importResult `json:"results"`
+		}
+		err := json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rr, response.Results
+	}
+
+	newApp := func(t *testing.T) *APIApplication {
+		app, _ := newTestApplication(t)
+		app.Models.Todos = data.NewMemoryTodoModel()
+		app.Config.IdempotencyKeyTTL = time.Hour
+		return app
+	}
+
+	t.Run("Repeated key", func(t *testing.T) {
+		app := newApp(t)
+
+		first, firstResults := importTodos(t, app, "/v1/todos/import", "key-1", "buy milk\ncall mom")
+		second, secondResults := importTodos(t, app, "/v1/todos/import?dedupe=true", "key-1", "buy milk\ncall mom")
+
+		assert.Equal(t, first.Code, http.StatusCreated)
+		assert.Equal(t, second.Code, http.StatusCreated)
+		assert.Equal(t, second.Header().Get("Idempotent-Replayed"), "true")
+		assert.Equal(t, len(secondResults), 2)
+		assert.Equal(t, secondResults[0].Todo.ID, firstResults[0].Todo.ID)
+		assert.Equal(t, secondResults[1].Todo.ID, firstResults[1].Todo.ID)
+		assert.Equal(t, secondResults[0].Duplicate, false)
+	})
+
+	t.Run("Dedupe", func(t *testing.T) {
+		app := newApp(t)
+
+		_, existing := importTodos(t, app, "/v1/todos/import", "", "buy milk")
+		rr, results := importTodos(t, app, "/v1/todos/import?dedupe=true", "", "buy milk\ncall mom\ncall mom")
+
+		assert.Equal(t, rr.Code, http.StatusCreated)
+		assert.Equal(t, len(results), 3)
+		assert.Equal(t, results[0].Duplicate, true)
+		assert.Equal(t, results[0].Todo.ID, existing[0].Todo.ID)
+		assert.Equal(t, results[1].Duplicate, false)
+		assert.Equal(t, results[2].Duplicate, true)
+		assert.Equal(t, results[2].Todo.ID, results[1].Todo.ID)
+
+		// Only "call mom" was inserted.
+		_, err := app.Models.Todos.GetTodoIfOwned(context.Background(), results[1].Todo.ID+1, testUser.ID)
+		assert.Equal(t, errors.Is(err, data.ErrRecordNotFound), true)
+	})
+}
+
+func TestValidateTodoLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		ok     bool
+		todo   string // The parsed todo's text, if it was parsed.
+		errMsg string
+	}{
+		{name: "Valid", body: `{"line": "x (A) call mom @phone +family"}`, ok: true, todo: "call mom @phone +family"},
+		{name: "Invalid", body: `{"line": "call mom @` + strings.Repeat("a", 51) + `"}`, todo: "call mom @" + strings.Repeat("a", 51), errMsg: `"contexts[0]"`},
+		{name: "Unparsable", body: `{"line": "(A)"}`, errMsg: "todo has no text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+
+			r := newTestRequest(http.MethodPost, "/v1/todos/validate", strings.NewReader(tt.body), nil)
+			rr := httptest.NewRecorder()
+
+			app.validateTodoLine(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusOK)
+			assert.StringContains(t, rr.Body.String(), tt.errMsg)
+
+			var response struct {
+				OK   bool       `json:"ok"`
+				Todo *data.Todo `json:"todo"`
+			}
+			err := json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, response.OK, tt.ok)
+			if tt.todo == "" {
+				assert.Equal(t, response.Todo == nil, true)
+			} else {
+				assert.Equal(t, response.Todo.Text, tt.todo)
+			}
+
+			// Nothing is inserted.
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("Parsed fields", func(t *testing.T) {
+		app, _ := newTestApplication(t)
+
+		r := newTestRequest(http.MethodPost, "/v1/todos/validate", strings.NewReader(`{"line": "x (B) buy milk @store +groceries"}`), nil)
+		rr := httptest.NewRecorder()
+
+		app.validateTodoLine(rr, r)
+
+		var response struct {
+			Todo data.Todo `json:"todo"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, response.Todo.Completed, true)
+		assert.Equal(t, response.Todo.Priority, "B")
+		assert.Equal(t, strings.Join(response.Todo.Contexts, ","), "store")
+		assert.Equal(t, strings.Join(response.Todo.Projects, ","), "groceries")
+	})
+}
+
+func TestGetAgenda(t *testing.T) {
+	app, mock := newTestApplication(t)
+
+	due := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// The date parameter is used as today's date, so the grouping doesn't
+	// depend on when the test is run.
+	rows := sqlmock.NewRows(todoColumns).
+		AddRow(1, testUser.ID, time.Now(), "pay rent", "{}", "{}", "", false, false, false, "", 1, due("2024-03-01"), nil, nil).
+		AddRow(2, testUser.ID, time.Now(), "call mom", "{}", "{}", "", false, false, false, "", 1, due("2024-03-10"), nil, nil).
+		AddRow(3, testUser.ID, time.Now(), "fix bug", "{}", "{}", "A", false, false, false, "", 1, nil, nil, nil)
+	mock.ExpectQuery(`SELECT (.+) FROM todos WHERE user_id = \$1 AND completed = false AND archived = false AND \(due_date <= \$2 OR priority BETWEEN 'A' AND \$3\)`).
+		WithArgs(testUser.ID, "2024-03-10", "B").
+		WillReturnRows(rows)
+
+	r := newTestRequest(http.MethodGet, "/v1/agenda?date=2024-03-10", nil, nil)
+	rr := httptest.NewRecorder()
+
+	app.getAgenda(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	var response struct {
+		Date   string `json:"date"`
+		Agenda struct {
+			Overdue      []struct{ ID int64 } `json:"overdue"`
+			DueToday     []struct{ ID int64 } `json:"due_today"`
+			HighPriority []struct{ ID int64 } `json:"high_priority"`
+		} `json:"agenda"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, response.Date, "2024-03-10")
+	assert.Equal(t, len(response.Agenda.Overdue), 1)
+	assert.Equal(t, response.Agenda.Overdue[0].ID, 1)
+	assert.Equal(t, len(response.Agenda.DueToday), 1)
+	assert.Equal(t, response.Agenda.DueToday[0].ID, 2)
+	assert.Equal(t, len(response.Agenda.HighPriority), 1)
+	assert.Equal(t, response.Agenda.HighPriority[0].ID, 3)
+	assert.IsNil(t, mock.ExpectationsWereMet())
+
+	// An invalid date is rejected.
+	r = newTestRequest(http.MethodGet, "/v1/agenda?date=tomorrow", nil, nil)
+	rr = httptest.NewRecorder()
+
+	app.getAgenda(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+}
+
+func TestGetAgendaClock(t *testing.T) {
+	app, mock := newTestApplication(t)
+
+	// Without a date parameter, today's date comes from the app's clock. Late
+	// in the evening of the 10th, a todo due on the 10th is due today, and one
+	// due on the 9th is overdue.
+	app.Models.Clock = fixedClock(time.Date(2024, 3, 10, 23, 59, 0, 0, time.UTC))
+
+	rows := sqlmock.NewRows(todoColumns).
+		AddRow(1, testUser.ID, time.Now(), "pay rent", "{}", "{}", "", false, false, false, "", 1, time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), nil, nil).
+		AddRow(2, testUser.ID, time.Now(), "call mom", "{}", "{}", "", false, false, false, "", 1, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), nil, nil)
+	mock.ExpectQuery(`SELECT (.+) FROM todos WHERE user_id = \$1`).
+		WithArgs(testUser.ID, "2024-03-10", "B").
+		WillReturnRows(rows)
+
+	r := newTestRequest(http.MethodGet, "/v1/agenda", nil, nil)
+	rr := httptest.NewRecorder()
+
+	app.getAgenda(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	var response struct {
+		Date   string      `json:"date"`
+		Agenda data.Agenda `json:"agenda"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, response.Date, "2024-03-10")
+	assert.Equal(t, len(response.Agenda.Overdue), 1)
+	assert.Equal(t, response.Agenda.Overdue[0].ID, 1)
+	assert.Equal(t, len(response.Agenda.DueToday), 1)
+	assert.Equal(t, response.Agenda.DueToday[0].ID, 2)
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetReport(t *testing.T) {
+	t.Run("Default period", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		mock.ExpectQuery(`SELECT date_trunc\(\$2, completed_at AT TIME ZONE 'UTC'\) AS start, count\(\*\) FROM todos WHERE user_id = \$1`).
+			WithArgs(testUser.ID, "week").
+			WillReturnRows(sqlmock.NewRows([]string{"start", "count"}).
+				AddRow(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), 3))
+
+		r := newTestRequest(http.MethodGet, "/v1/report", nil, nil)
+		rr := httptest.NewRecorder()
+
+		app.getReport(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+
+		var response struct {
+			Period string              `json:"period"`
+			Report []data.ReportBucket `json:"report"`
+		}
+		err := json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, response.Period, "week")
+		assert.Equal(t, len(response.Report), 1)
+		assert.Equal(t, response.Report[0].Completed, 3)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid period", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		r := newTestRequest(http.MethodGet, "/v1/report?period=day", nil, nil)
+		rr := httptest.NewRecorder()
+
+		app.getReport(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, rr.Body.String(), "must be week or month")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteTodoOwnership(t *testing.T) {
+	app, _ := newTestApplication(t)
+	todos := data.NewMemoryTodoModel()
+	app.Models.Todos = todos
+
+	mine := &data.Todo{Text: "call mom", UserID: testUser.ID}
+	theirs := &data.Todo{Text: "call dad", UserID: testUser.ID + 1}
+	assert.IsNil(t, todos.InsertMany(context.Background(), []*data.Todo{mine, theirs}))
+
+	events, unsubscribe := app.todoEvents.subscribe(testUser.ID)
+	defer unsubscribe()
+
+	deleteTodo := func(id int64) int {
+		target := fmt.Sprintf("/v1/todos/%d", id)
+		r := newTestRequest(http.MethodDelete, target, nil, httprouter.Params{{Key: "id", Value: fmt.Sprint(id)}})
+		rr := httptest.NewRecorder()
+		app.deleteTodo(rr, r)
+		return rr.Code
+	}
+
+	// Another user's todo isn't deleted, and no event is published.
+	assert.Equal(t, deleteTodo(theirs.ID), http.StatusNotFound)
+	assert.Equal(t, len(events), 0)
+	_, err := todos.GetTodoIfOwned(context.Background(), theirs.ID, theirs.UserID)
+	assert.IsNil(t, err)
+
+	assert.Equal(t, deleteTodo(mine.ID), http.StatusOK)
+	assert.Equal(t, (<-events).ID, mine.ID)
+}
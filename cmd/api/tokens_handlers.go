@@ -2,9 +2,10 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
-	"time"
 
+	"github.com/julienschmidt/httprouter"
 	validator "github.com/kvnloughead/godo/internal"
 	"github.com/kvnloughead/godo/internal/data"
 )
@@ -36,8 +37,8 @@ func (app *APIApplication) createActivationToken(w http.ResponseWriter, r *http.
 	v := validator.New()
 	data.ValidateEmail(v, input.Email)
 	if !v.Valid() {
-		v.AddError("email", "no matching email found")
-		app.failedValidationResponse(w, r, v.Errors)
+		v.AddRuleError("email", validator.RuleNotFound, "no matching email found")
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
@@ -53,12 +54,12 @@ func (app *APIApplication) createActivationToken(w http.ResponseWriter, r *http.
 	}
 
 	if user.Activated {
-		v.AddError("email", "user already activated")
-		app.failedValidationResponse(w, r, v.Errors)
+		v.AddRuleError("email", validator.RuleConflict, "user already activated")
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	token, err := app.Models.Tokens.New(user.ID, 72*time.Hour, data.Activation)
+	token, err := app.Models.Tokens.New(user.ID, app.Config.Tokens.ActivationTTL, data.Activation)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -67,9 +68,9 @@ func (app *APIApplication) createActivationToken(w http.ResponseWriter, r *http.
 	app.background(func() {
 		data := struct{ Token *data.Token }{Token: token}
 
-		err = app.Mailer.Send(user.Email, "token_activation.tmpl", data)
+		err := app.Mailer.Send(user.Email, "token_activation.tmpl", data)
 		if err != nil {
-			app.Logger.Error(err.Error())
+			app.Logger.Error("failed to send activation email", "user_id", user.ID, "error", err)
 		}
 	})
 
@@ -91,16 +92,21 @@ func (app *APIApplication) createActivationToken(w http.ResponseWriter, r *http.
 // or if the password is incorrect, a 401 response is sent by the
 // app.invalidCredentials helper.
 //
-// If the credentials check out we generate a token with a 24 hour expiry and
-// an "authentication" scope. This token is then sent to the client in a JSON
-// response with the following format:
+// If the credentials check out we generate a token with an "authentication"
+// scope. Its expiry is set by the -token-auth-ttl flag, and defaults to 14 days
+// in production and 28 days otherwise. This token is then sent to the client
+// in a JSON response with the following format:
 //
 //	{
 //	    "authentication_token": {
 //	        "token": "N4AN76GAQIXFKRIVRRKW463X5Q",
 //	        "expiry": "2024-03-03T17:12:34.711714248-05:00"
-//	    }
+//	    },
+//	    "last_login_at": "2024-02-18T09:30:00-05:00"
 //	}
+//
+// The user's last login time is updated, and its previous value is included
+// in the response. It is null on the user's first login.
 func (app *APIApplication) createAuthenticationToken(w http.ResponseWriter, r *http.Request) {
 	// Read user credentials from request body into the input struct.
 	var input struct {
@@ -118,7 +124,7 @@ func (app *APIApplication) createAuthenticationToken(w http.ResponseWriter, r *h
 	data.ValidateEmail(v, input.Email)
 	data.ValidatePasswordPlaintext(v, input.Password)
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
@@ -147,17 +153,16 @@ func (app *APIApplication) createAuthenticationToken(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Set the expiry time based on the environment.
-	var expiry time.Duration
-	if app.Config.Env == "production" {
-		expiry = 14 * 24 * time.Hour
-	} else {
-		expiry = 28 * 24 * time.Hour
+	token, err := app.Models.Tokens.New(user.ID, app.Config.Tokens.AuthTTL, data.Authentication)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
-	// If the credentials check out we generate a token with a 14 day expiry and
-	// an "authentication" scope.
-	token, err := app.Models.Tokens.New(user.ID, expiry, data.Authentication)
+	// Record the login, keeping the time of the previous one for the response.
+	// This is done once the token exists, so that a login that fails isn't
+	// recorded.
+	lastLogin, err := app.Models.Users.UpdateLastLogin(user.ID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -166,7 +171,7 @@ func (app *APIApplication) createAuthenticationToken(w http.ResponseWriter, r *h
 	err = app.writeJSON(
 		w,
 		http.StatusCreated,
-		envelope{"authentication_token": token},
+		envelope{"authentication_token": token, "last_login_at": lastLogin},
 		nil,
 	)
 	if err != nil {
@@ -174,3 +179,51 @@ func (app *APIApplication) createAuthenticationToken(w http.ResponseWriter, r *h
 		return
 	}
 }
+
+// listTokens handles GET requests to the /v1/tokens endpoint. It responds with
+// the scope, expiry, and creation time of each of the authenticated user's
+// unexpired tokens. Neither the plaintext tokens nor their full hashes are
+// included in the response.
+func (app *APIApplication) listTokens(w http.ResponseWriter, r *http.Request) {
+	user := contextGet[*data.User](r, userContextKey)
+
+	tokens, err := app.Models.Tokens.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tokens": tokens}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revokeTokens handles DELETE requests to the /v1/tokens/:scope endpoint. It
+// deletes all of the authenticated user's tokens with the given scope. If the
+// scope is "authentication", the token used to make the request is revoked as
+// well.
+//
+// A notFoundResponse is sent if the scope isn't valid.
+func (app *APIApplication) revokeTokens(w http.ResponseWriter, r *http.Request) {
+	user := contextGet[*data.User](r, userContextKey)
+
+	scope := data.Scope(httprouter.ParamsFromContext(r.Context()).ByName("scope"))
+	if !scope.Valid() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.Models.Tokens.DeleteAllForUser(scope, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"message": fmt.Sprintf("%s tokens successfully revoked", scope)}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
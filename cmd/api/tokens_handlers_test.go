@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/julienschmidt/httprouter"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/data"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestListTokens(t *testing.T) {
+	app, mock := newTestApplication(t)
+
+	hash := data.CalculateHash("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	rows := sqlmock.NewRows([]string{"hash", "scope", "expiry", "created_at"}).
+		AddRow(hash[:], data.Authentication, time.Now().Add(time.Hour), time.Now())
+	mock.ExpectQuery("SELECT (.+) FROM tokens WHERE user_id = \\$1 AND expiry > \\$2").
+		WithArgs(testUser.ID, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+
+	r := newTestRequest(http.MethodGet, "/v1/tokens", nil, nil)
+	rr := httptest.NewRecorder()
+
+	app.listTokens(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// The full hash must never be sent.
+	body := rr.Body.String()
+	fullHash := hex.EncodeToString(hash[:])
+	assert.Equal(t, strings.Contains(body, fullHash), false)
+
+	var response struct {
+		Tokens []data.TokenSummary `json:"tokens"`
+	}
+	err := json.Unmarshal([]byte(body), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(response.Tokens), 1)
+	assert.Equal(t, response.Tokens[0].Scope, data.Authentication)
+	assert.Equal(t, response.Tokens[0].HashPrefix, fullHash[:8])
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeTokens(t *testing.T) {
+	t.Run("Revoking authentication tokens invalidates the current token", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+		token := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+		// Call the handler through the authentication middleware, with the scope
+		// param added to the request's context.
+		handler := app.authenticate(app.requireAuthenticatedUser(app.revokeTokens))
+		newRequest := func() *http.Request {
+			r := newTestRequest(http.MethodDelete, "/v1/tokens/authentication", nil,
+				httprouter.Params{{Key: "scope", Value: "authentication"}})
+			r.Header.Set("Authorization", "Bearer "+token)
+			return r
+		}
+
+		mock.ExpectQuery("SELECT (.+) FROM users INNER JOIN tokens").
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(testUser.ID, time.Now(), "test", "test@example.com", []byte("hash"), true, 1))
+		mock.ExpectExec("DELETE FROM tokens WHERE scope = \\$1 AND user_id = \\$2").
+			WithArgs(data.Authentication, testUser.ID).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		// The token was deleted, so the second request can't be authenticated.
+		mock.ExpectQuery("SELECT (.+) FROM users INNER JOIN tokens").
+			WillReturnRows(sqlmock.NewRows(userColumns))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequest())
+		assert.Equal(t, rr.Code, http.StatusOK)
+
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequest())
+		assert.Equal(t, rr.Code, http.StatusUnauthorized)
+
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid scope", func(t *testing.T) {
+		app, mock := newTestApplication(t)
+
+		r := newTestRequest(http.MethodDelete, "/v1/tokens/bogus", nil,
+			httprouter.Params{{Key: "scope", Value: "bogus"}})
+		rr := httptest.NewRecorder()
+
+		app.revokeTokens(rr, r)
+
+		assert.Equal(t, rr.Code, http.StatusNotFound)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateAuthenticationTokenLastLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("pa55word"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := time.Date(2024, 2, 18, 9, 30, 0, 0, time.UTC)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		previous any // The value of last_login_at before the request.
+		want     *time.Time
+	}{
+		{name: "First login", previous: nil, want: nil},
+		{name: "Later login", previous: previous, want: &previous},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, mock := newTestApplication(t)
+			app.Config.Tokens.AuthTTL = time.Hour
+			app.Models = data.NewModels(app.DB, 0, 0, fixedClock(now))
+
+			mock.ExpectQuery("SELECT (.+) FROM users where email = \\$1").
+				WithArgs(testUser.Email).
+				WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(testUser.ID, time.Now(), testUser.Name, testUser.Email, hash, true, 1))
+			mock.ExpectExec("INSERT INTO tokens").
+				WithArgs(sqlmock.AnyArg(), testUser.ID, now.Add(time.Hour), data.Authentication).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectQuery("UPDATE users SET last_login_at = \\$2 (.+) RETURNING previous.last_login_at").
+				WithArgs(testUser.ID, now).
+				WillReturnRows(sqlmock.NewRows([]string{"last_login_at"}).AddRow(tt.previous))
+
+			body := strings.NewReader(`{"email": "test@example.com", "password": "pa55word"}`)
+			r := httptest.NewRequest(http.MethodPost, "/v1/tokens/authentication", body)
+			rr := httptest.NewRecorder()
+
+			app.createAuthenticationToken(rr, r)
+
+			assert.Equal(t, rr.Code, http.StatusCreated)
+
+			var response struct {
+				LastLoginAt *time.Time `json:"last_login_at"`
+			}
+			err := json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want == nil {
+				assert.Equal(t, response.LastLoginAt == nil, true)
+			} else {
+				assert.Equal(t, response.LastLoginAt.Equal(*tt.want), true)
+			}
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"net/http"
-	"time"
 
 	validator "github.com/kvnloughead/godo/internal"
 	"github.com/kvnloughead/godo/internal/data"
@@ -56,7 +55,7 @@ func (app *APIApplication) registerUser(w http.ResponseWriter, r *http.Request)
 	data.ValidateUser(v, user)
 
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
@@ -65,8 +64,8 @@ func (app *APIApplication) registerUser(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
-			v.AddError("email", "a user with this email address already exists")
-			app.failedValidationResponse(w, r, v.Errors)
+			v.AddRuleError("email", validator.RuleDuplicate, "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -74,7 +73,7 @@ func (app *APIApplication) registerUser(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Create activation token and add to database.
-	token, err := app.Models.Tokens.New(user.ID, 72*time.Hour, data.Activation)
+	token, err := app.Models.Tokens.New(user.ID, app.Config.Tokens.ActivationTTL, data.Activation)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -96,9 +95,9 @@ func (app *APIApplication) registerUser(w http.ResponseWriter, r *http.Request)
 			Token: token,
 			User:  user,
 		}
-		err = app.Mailer.Send(user.Email, "user_welcome.tmpl", data)
+		err := app.Mailer.Send(user.Email, "user_welcome.tmpl", data)
 		if err != nil {
-			app.Logger.Error(err.Error())
+			app.Logger.Error("failed to send welcome email", "user_id", user.ID, "error", err)
 		}
 	})
 
@@ -125,7 +124,7 @@ func (app *APIApplication) activateUser(w http.ResponseWriter, r *http.Request)
 	v := validator.New()
 	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
@@ -139,7 +138,7 @@ func (app *APIApplication) activateUser(w http.ResponseWriter, r *http.Request)
 		// If user can't be found, the token must be invalid or expired.
 		case errors.Is(err, data.ErrRecordNotFound):
 			v.AddError("token", "invalid or expired token")
-			app.failedValidationResponse(w, r, v.Errors)
+			app.failedValidationResponse(w, r, v)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -181,3 +180,31 @@ func (app *APIApplication) activateUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 }
+
+// deleteAccount handles DELETE requests to the /v1/users/me endpoint. It
+// permanently deletes the authenticated user, along with all of their todos,
+// tokens, and permissions. Since the user's tokens are deleted, the token used
+// to make the request can't be used again.
+//
+// On success, a 200 OK response is sent with the following body:
+//
+//	{ "message": "account successfully deleted" }
+func (app *APIApplication) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	user := contextGet[*data.User](r, userContextKey)
+
+	err := app.Models.Users.Delete(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "account successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
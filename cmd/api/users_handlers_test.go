@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+// userColumns are the columns returned by UserModel.GetForToken.
+var userColumns = []string{"id", "created_at", "name", "email", "password_hash", "activated", "version"}
+
+// expiresIn is a sqlmock.Argument that matches times roughly ttl from now.
+type expiresIn time.Duration
+
+func (ttl expiresIn) Match(v driver.Value) bool {
+	expiry, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	diff := time.Until(expiry) - time.Duration(ttl)
+	return diff > -time.Minute && diff < time.Minute
+}
+
+func TestRegisterUserActivationTTL(t *testing.T) {
+	app, mock := newTestApplication(t)
+	app.Config.Tokens.ActivationTTL = 6 * time.Hour
+
+	mock.ExpectQuery("INSERT INTO users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version"}).
+			AddRow(testUser.ID, time.Now(), 1))
+	mock.ExpectExec("INSERT INTO tokens").
+		WithArgs(sqlmock.AnyArg(), testUser.ID, expiresIn(6*time.Hour), data.Activation).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO users_permissions").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := strings.NewReader(`{"name": "test", "email": "test@example.com", "password": "pa55word"}`)
+	r := httptest.NewRequest(http.MethodPost, "/v1/users", body)
+	rr := httptest.NewRecorder()
+
+	app.registerUser(rr, r)
+
+	assert.Equal(t, rr.Code, http.StatusAccepted)
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAccount(t *testing.T) {
+	app, mock := newTestApplication(t)
+	handler := app.authenticate(app.requireAuthenticatedUser(app.deleteAccount))
+	token := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	// The first request authenticates, then deletes the user's data in order
+	// in a single transaction.
+	mock.ExpectQuery("SELECT (.+) FROM users INNER JOIN tokens").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(testUser.ID, time.Now(), "test", "test@example.com", []byte("hash"), true, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM todos WHERE user_id = \\$1").
+		WithArgs(testUser.ID).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM tokens WHERE user_id = \\$1").
+		WithArgs(testUser.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM users_permissions WHERE user_id = \\$1").
+		WithArgs(testUser.ID).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM users WHERE id = \\$1").
+		WithArgs(testUser.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// The token was deleted, so the second request can't be authenticated.
+	mock.ExpectQuery("SELECT (.+) FROM users INNER JOIN tokens").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	r := httptest.NewRequest(http.MethodDelete, "/v1/users/me", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	r = httptest.NewRequest(http.MethodDelete, "/v1/users/me", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
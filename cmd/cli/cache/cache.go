@@ -0,0 +1,142 @@
+// Package cache provides a local cache of the todo lists fetched by the CLI,
+// so that they can still be shown when the API can't be reached.
+//
+// The cache is a JSON file named "todos.json" in the cache directory. Each
+// list is stored under the URL it was fetched from, so that lists with
+// different filters, or from different servers, are cached separately.
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+)
+
+const cacheFile = "todos.json"
+
+// maxEntries is the number of lists kept in the cache. When it is full, the
+// oldest list is removed to make room for a new one.
+const maxEntries = 20
+
+var (
+	// ErrDisabled is returned by Load if the cache is disabled.
+	ErrDisabled = errors.New("the cache is disabled")
+
+	// ErrNotCached is returned by Load if the list isn't in the cache.
+	ErrNotCached = errors.New("no cached todos for this list")
+)
+
+// Entry is a cached todo list.
+type Entry struct {
+	SavedAt time.Time    `json:"saved_at"`
+	Todos   []types.Todo `json:"todos"`
+}
+
+// Cache is a struct that manages the cache file. A nil *Cache is disabled:
+// Save and Invalidate do nothing, and Load returns ErrDisabled.
+type Cache struct {
+	dir string // The directory where the cache file is stored.
+}
+
+// New creates a new Cache that stores its file in dir. The directory is
+// created when the cache is first saved.
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// File returns the path to the cache file.
+func (c *Cache) File() string {
+	return filepath.Join(c.dir, cacheFile)
+}
+
+// Save stores the todos fetched from the URL, replacing any that were cached
+// for it before.
+func (c *Cache) Save(url string, todos []types.Todo, now time.Time) error {
+	if c == nil {
+		return nil
+	}
+
+	entries, err := c.read()
+	if err != nil {
+		// A corrupt cache is replaced rather than preventing new saves.
+		entries = map[string]Entry{}
+	}
+
+	if _, ok := entries[url]; !ok && len(entries) >= maxEntries {
+		var oldest string
+		for k, e := range entries {
+			if oldest == "" || e.SavedAt.Before(entries[oldest].SavedAt) {
+				oldest = k
+			}
+		}
+		delete(entries, oldest)
+	}
+	entries[url] = Entry{SavedAt: now, Todos: todos}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(c.File(), data, 0600)
+}
+
+// Load returns the todos cached for the URL. If there aren't any, it returns
+// ErrNotCached.
+func (c *Cache) Load(url string) (Entry, error) {
+	if c == nil {
+		return Entry{}, ErrDisabled
+	}
+
+	entries, err := c.read()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry, ok := entries[url]
+	if !ok {
+		return Entry{}, ErrNotCached
+	}
+	return entry, nil
+}
+
+// Invalidate removes all cached lists. It is called after todos are changed,
+// so that lists from before the change aren't shown.
+func (c *Cache) Invalidate() error {
+	if c == nil {
+		return nil
+	}
+
+	err := os.Remove(c.File())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// read returns the entries in the cache file. If the file doesn't exist, there
+// are no entries.
+func (c *Cache) read() (map[string]Entry, error) {
+	data, err := os.ReadFile(c.File())
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = map[string]Entry{}
+	}
+	return entries, nil
+}
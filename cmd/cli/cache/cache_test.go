@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := New(t.TempDir())
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	todos := []types.Todo{{ID: 1, Text: "call mom"}, {ID: 2, Text: "buy milk", Completed: true}}
+
+	_, err := c.Load("http://localhost/v1/todos?")
+	assert.Equal(t, errors.Is(err, ErrNotCached), true)
+
+	err = c.Save("http://localhost/v1/todos?", todos, now)
+	assert.IsNil(t, err)
+
+	entry, err := c.Load("http://localhost/v1/todos?")
+	assert.IsNil(t, err)
+	assert.Equal(t, entry.SavedAt.Equal(now), true)
+	assert.Equal(t, reflect.DeepEqual(entry.Todos, todos), true)
+
+	// Lists with other filters are cached separately.
+	_, err = c.Load("http://localhost/v1/todos?done=true")
+	assert.Equal(t, errors.Is(err, ErrNotCached), true)
+
+	// The file is only readable by the user.
+	info, err := os.Stat(c.File())
+	assert.IsNil(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0600))
+
+	err = c.Invalidate()
+	assert.IsNil(t, err)
+	_, err = c.Load("http://localhost/v1/todos?")
+	assert.Equal(t, errors.Is(err, ErrNotCached), true)
+
+	// Invalidating an empty cache isn't an error.
+	assert.IsNil(t, c.Invalidate())
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := New(t.TempDir())
+	now := time.Now()
+
+	for i := range maxEntries + 1 {
+		err := c.Save(fmt.Sprintf("url-%d", i), nil, now.Add(time.Duration(i)*time.Second))
+		assert.IsNil(t, err)
+	}
+
+	// The oldest list was removed to make room for the newest.
+	_, err := c.Load("url-0")
+	assert.Equal(t, errors.Is(err, ErrNotCached), true)
+	_, err = c.Load(fmt.Sprintf("url-%d", maxEntries))
+	assert.IsNil(t, err)
+}
+
+func TestCacheCorrupt(t *testing.T) {
+	c := New(t.TempDir())
+	err := os.WriteFile(c.File(), []byte("not json"), 0600)
+	assert.IsNil(t, err)
+
+	_, err = c.Load("url")
+	assert.Equal(t, err != nil, true)
+
+	// Saving replaces the corrupt file.
+	assert.IsNil(t, c.Save("url", nil, time.Now()))
+	_, err = c.Load("url")
+	assert.IsNil(t, err)
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+
+	assert.IsNil(t, c.Save("url", nil, time.Now()))
+	assert.IsNil(t, c.Invalidate())
+	_, err := c.Load("url")
+	assert.Equal(t, errors.Is(err, ErrDisabled), true)
+}
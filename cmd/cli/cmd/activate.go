@@ -37,18 +37,15 @@ Activate a user's account with the given token. For example:
 The activation token is sent to the user in a welcome email received when
 they register.`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		activationToken = args[0]
 		url := app.Config.APIBaseURL + "/users/activation"
 
 		// Define error handler
 		handleError := func(msg string, err error) error {
-			app.Logger.Error(msg,
-				"error", err,
+			return app.cmdError(msg, "Error: Activation failed. Check logs for details.", err,
 				"method", http.MethodPut,
 				"url", url)
-			fmt.Println("Error: Activation failed. Check logs for details.")
-			return err
 		}
 
 		// Prepare JSON payload
@@ -64,23 +61,21 @@ they register.`,
 		// Create request
 		req, err := app.createJSONRequest(http.MethodPut, url, payload)
 		if err != nil {
-			handleError("failed to create request", err)
-			return
+			return handleError("failed to create request", err)
 		}
 		req.Header.Set("Content-Type", "application/json")
 
 		// Send request
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			handleError("failed to send request", err)
-			return
+			return handleError("failed to send request", err)
 		}
 		defer resp.Body.Close()
 
 		// Read response body and log it
 		body, err := app.readResponse(resp, handleError)
 		if err != nil {
-			return
+			return err
 		}
 
 		// Handle different status codes
@@ -88,11 +83,8 @@ they register.`,
 		case http.StatusAccepted:
 			var activationResp ActivationResponse
 			if err := json.Unmarshal(body, &activationResp); err != nil {
-				app.Logger.Error("failed to unmarshal response",
-					"error", err,
+				return app.cmdError("failed to unmarshal response", "Error: Failed to parse server response", err,
 					"body", string(body))
-				fmt.Println("Error: Failed to parse server response")
-				return
 			}
 			fmt.Printf("\nActivation successful for %s!\n",
 				activationResp.User.Email)
@@ -100,27 +92,29 @@ they register.`,
 		case http.StatusUnprocessableEntity:
 			// Handle validation errors (including duplicate email)
 			var errorResp struct {
-				Error map[string]string `json:"error"`
+				Error map[string]struct {
+					Message string `json:"message"`
+				} `json:"error"`
 			}
 			if err := json.Unmarshal(body, &errorResp); err != nil {
-				app.Logger.Error("failed to unmarshal error response",
-					"error", err,
+				return app.cmdError("failed to unmarshal error response", "Error: Failed to parse server error response", err,
 					"body", string(body))
-				fmt.Println("Error: Failed to parse server error response")
-				return
 			}
 			// Print each validation error
 			fmt.Println("\nRegistration failed:")
-			for field, message := range errorResp.Error {
-				fmt.Printf("- %s: %s\n", field, message)
+			for field, fieldErr := range errorResp.Error {
+				fmt.Printf("- %s: %s\n", field, fieldErr.Message)
 			}
+			return &reportedError{err: responseError(resp)}
 
 		default:
-			app.Logger.Error("unexpected status code",
-				"status", resp.Status,
+			return app.cmdError("unexpected status code",
+				fmt.Sprintf("\nError: Unexpected response from server (status %s)", resp.Status),
+				responseError(resp),
 				"body", string(body))
-			fmt.Printf("\nError: Unexpected response from server (status %s)\n", resp.Status)
 		}
+
+		return nil
 	},
 }
 
@@ -1,77 +1,340 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
 // addCmd creates a new todo item. The todo text must be quoted if it contains
 // spaces. This command requires authentication.
 var addCmd = &cobra.Command{
-	Use:   "add <text>",
-	Short: "Add a new todo item with the given text",
+	Use:   "add <text> [text...]",
+	Short: "Add new todo items with the given text",
 	Long: `
-Add a new todo item with the given text. Text with spaces must be enclosed in quotes. For example:
+Add a new todo item with the given text. Text with spaces must be enclosed in quotes.
+The text is parsed as a todo.txt line, so a leading "(A)" sets the priority,
+and words beginning with "@" or "+" are added to the todo's contexts and
+projects.
+
+Several todos can be added at once, by giving the text of each as a separate
+argument. They are added with a single request. Either all of them are added,
+or none are, and the result for each todo is printed, followed by a summary.
+The server limits how many todos can be added at once, 100 by default.
+
+With "-" as the only argument, or no arguments when the input isn't a
+terminal, a todo is added for each line of the input. Blank lines are
+skipped.
+
+With the --dedupe flag, a todo isn't added if you already have an active todo
+with the same text, or if an earlier todo in the same command has the same
+text. The server may also be configured to always check for duplicates.
+
+If the request fails with a network error, it is retried. Retries can't add
+the todos twice.
+
+Examples:
 
     # Add a todo item
     godo add "Buy groceries"
 
+    # Add a todo item, unless it already exists
+    godo add --dedupe "Buy groceries"
+
+    # Add three todo items
+    godo add "Buy groceries" "(A) Call mom @phone" "Water the plants"
+
+    # Add a todo item for each line of a file
+    godo add - < todo.txt
+
 This command requires authentication. Run 'godo auth -h' for more information.`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		url := app.Config.APIBaseURL + "/todos"
-		stdoutMsg := "\nError: failed to add todo item. \nCheck `~/.config/godo/logs` for details.\n"
-
-		// handleError captures parameters that are common to all errors
-		handleError := func(logMsg string, err error) error {
-			app.handleError(logMsg, stdoutMsg, err,
-				"method", http.MethodPost,
-				"url", url)
-			return err
+	Args: func(cmd *cobra.Command, args []string) error {
+		// The todos can be piped in without any arguments.
+		if len(args) == 0 && !stdinIsTerminal() {
+			return nil
 		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dedupe, _ := cmd.Flags().GetBool("dedupe")
+		fromStdin := len(args) == 0 || (len(args) == 1 && args[0] == "-")
 
-		token, err := app.TokenManager.LoadToken()
-		if err != nil {
-			app.handleAuthenticationError("Failed to read token", err)
-			return
+		if fromStdin {
+			var err error
+			args, err = readTodoLines(stdin)
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				return validationError(errors.New("no todos in the input"))
+			}
+		}
+		for _, text := range args {
+			if strings.ContainsAny(text, "\r\n") {
+				return validationError(errors.New("todo text can't contain line breaks"))
+			}
 		}
 
-		text := args[0]
-		payload := map[string]any{"text": text}
+		results, err := addTodos(args, dedupe)
 
-		req, err := app.createJSONRequest(http.MethodPost, url, payload)
+		// A single todo given as an argument is reported like the result of the
+		// other commands that change a single todo.
+		if len(args) == 1 && !fromStdin {
+			if err != nil {
+				return reportTodoResult(cmd, 0, "add", "", err)
+			}
+			msg := "Todo added successfully"
+			if results[0].Duplicate {
+				msg = fmt.Sprintf("Already exists (id %d).", results[0].ID)
+			}
+			return reportTodoResult(cmd, results[0].ID, "add", msg, results[0].Err)
+		}
 		if err != nil {
-			handleError("Failed to create request", err)
-			return
+			return err
 		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+string(token))
 
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			handleError("Failed to send request", err)
-			return
+		// Each result is printed with the todo's text, so that the failures
+		// can be told apart.
+		asJSON, _ := cmd.Flags().GetBool("json")
+		failed := 0
+		for i, result := range results {
+			switch {
+			case asJSON:
+				printTodoResult(true, result.ID, "add", "", result.Err)
+			case result.Err != nil:
+				fmt.Printf("Error: %q: %v\n", args[i], result.Err)
+			case result.Duplicate:
+				fmt.Printf("Todo %d already exists: %s\n", result.ID, args[i])
+			default:
+				fmt.Printf("Todo %d added: %s\n", result.ID, args[i])
+			}
+			if result.Err != nil {
+				failed++
+			}
+		}
+		if !asJSON {
+			fmt.Println(batchSummary("Added", len(results), 0, failed))
+		}
+
+		if failed > 0 {
+			return &reportedError{err: validationError(errors.New("some todos couldn't be added"))}
+		}
+		return nil
+	},
+}
+
+// readTodoLines returns the lines read from r, without surrounding whitespace,
+// skipping blank lines.
+func readTodoLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
 		}
-		defer resp.Body.Close()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read todos: %w", err)
+	}
+	return lines, nil
+}
+
+// rejectionMessage returns the messages of a 422 response that rejected a
+// request as a whole, sorted by field and separated by semicolons, or "" if
+// the response isn't one. The request must have asked for flat errors with the
+// X-Error-Format header.
+func rejectionMessage(resp *http.Response, body []byte) string {
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		return ""
+	}
+
+	var errorResp struct {
+		Error map[string]string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorResp); err != nil {
+		return ""
+	}
 
-		// Read response body and log it
-		_, err = app.readResponse(resp, handleError)
+	fields := make([]string, 0, len(errorResp.Error))
+	for field := range errorResp.Error {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = errorResp.Error[field]
+	}
+	return strings.Join(messages, "; ")
+}
+
+// addResult is the result of adding one of several todos with addTodos. ID
+// is 0 if the todo wasn't added. If Duplicate is true, the todo wasn't added
+// because it has the same text as an active todo, whose ID is ID.
+type addResult struct {
+	ID        int
+	Duplicate bool
+	Err       error
+}
+
+// errNotAdded is the error for todos that are valid, but weren't added because
+// another of the todos added with them is invalid.
+var errNotAdded = errors.New("not added, because another todo is invalid")
+
+// addTodos sends a request to the import endpoint to add a todo for each of
+// the texts, and returns the result for each, in the same order. The todos
+// are added in a transaction, so if any is invalid, none are added. An error
+// is returned if the request fails for any other reason.
+//
+// If dedupe is true, todos with the same text as an active todo aren't added.
+// If the request fails with a network error, it is sent again, up to
+// addAttempts times in all.
+func addTodos(texts []string, dedupe bool) ([]addResult, error) {
+	url := app.Config.APIBaseURL + "/todos/import"
+	if dedupe {
+		url += "?dedupe=true"
+	}
+	noun := "todo items"
+	if len(texts) == 1 {
+		noun = "todo item"
+	}
+	stdoutMsg := app.failureMsg("add " + noun)
+
+	// handleError captures parameters that are common to all errors
+	handleError := func(logMsg string, err error) error {
+		return app.cmdError(logMsg, stdoutMsg, err,
+			"method", http.MethodPost,
+			"url", url)
+	}
+
+	token, err := loadToken()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Join(texts, "\n")
+
+	// The same idempotency key is sent with each attempt, so that retrying
+	// after a network error can't add the todos twice.
+	idempotencyKey := uuid.NewString()
+
+	var resp *http.Response
+	for attempt := 1; attempt <= addAttempts; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(lines))
 		if err != nil {
-			return
+			return nil, handleError("Failed to create request", err)
 		}
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Error-Format", "flat")
+		req.Header.Set("Idempotency-Key", idempotencyKey)
 
-		if resp.StatusCode != http.StatusCreated {
-			handleError("Failed to add todo", fmt.Errorf("response status: %s", resp.Status))
-			return
+		resp, err = http.DefaultClient.Do(req)
+		if err == nil {
+			break
+		}
+		if attempt == addAttempts {
+			return nil, handleError("Failed to send request", err)
 		}
+		app.Logger.Warn("Failed to send request, retrying", "attempt", attempt, "error", err)
+		time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+	}
+	defer resp.Body.Close()
 
-		fmt.Println("Todo added successfully")
+	body, err := app.readResponse(resp, handleError)
+	if err != nil {
+		return nil, err
+	}
 
-	},
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusUnprocessableEntity {
+		return nil, handleError("Failed to add todos", responseError(resp))
+	}
+
+	// A 422 response may also reject the request as a whole, such as when
+	// there are too many todos, in which case there are no results.
+	var importResp struct {
+		Results []struct {
+			Line      int  `json:"line"`
+			OK        bool `json:"ok"`
+			Duplicate bool `json:"duplicate"`
+			Todo      struct {
+				ID int `json:"id"`
+			} `json:"todo"`
+			Error  string            `json:"error"`
+			Errors map[string]string `json:"errors"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &importResp); err != nil || len(importResp.Results) == 0 {
+		if msg := rejectionMessage(resp, body); msg != "" {
+			app.Logger.Error("Todos rejected", "method", http.MethodPost, "url", url, "error", msg)
+			return nil, validationError(errors.New("todos rejected: " + msg))
+		}
+		return nil, handleError("Failed to add todos", responseError(resp))
+	}
+
+	if resp.StatusCode == http.StatusCreated {
+		app.invalidateCache()
+	}
+
+	// Empty texts aren't sent back, so each result is matched to its text by
+	// its line number.
+	results := make([]addResult, len(texts))
+	for i := range results {
+		results[i].Err = validationError(errors.New("text must be provided"))
+	}
+	for _, result := range importResp.Results {
+		if result.Line < 1 || result.Line > len(texts) {
+			continue
+		}
+		r := &results[result.Line-1]
+		switch {
+		case result.OK && resp.StatusCode == http.StatusCreated:
+			*r = addResult{ID: result.Todo.ID, Duplicate: result.Duplicate}
+		case result.OK:
+			r.Err = errNotAdded
+		case result.Error != "":
+			r.Err = validationError(errors.New(result.Error))
+		default:
+			r.Err = validationError(errors.New(formatFieldErrors(result.Errors)))
+		}
+	}
+
+	return results, nil
+}
+
+// formatFieldErrors formats the errors of a failed validation response, such
+// as "priority must be a single uppercase letter", in order of the fields.
+func formatFieldErrors(errs map[string]string) string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	msgs := make([]string, len(fields))
+	for i, field := range fields {
+		msgs[i] = field + " " + errs[field]
+	}
+	return strings.Join(msgs, "; ")
 }
 
+// addAttempts is the number of times addTodos tries to send its request if it
+// fails with a network error.
+const addAttempts = 3
+
 func init() {
 	rootCmd.AddCommand(addCmd)
+
+	addCmd.Flags().Bool("dedupe", false, "don't add todos with the same text as an active todo")
+	addJSONFlag(addCmd)
 }
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/kvnloughead/godo/internal/assert"
+	"golang.org/x/term"
+)
+
+func TestAddMultiple(t *testing.T) {
+	var path, query, body string
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		path, query, body = r.URL.Path, r.URL.RawQuery, string(b)
+
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"results": [
+			{"line": 1, "ok": true, "todo": {"id": 7}},
+			{"line": 2, "ok": true, "todo": {"id": 8}}
+		]}`)
+	}))
+
+	t.Run("Text", func(t *testing.T) {
+		output, status := runCommand(t, addCmd, []string{"buy milk", "(A) call mom @phone"}, nil)
+
+		// The todos are sent to the import endpoint, one per line.
+		assert.Equal(t, path, "/v1/todos/import")
+		assert.Equal(t, body, "buy milk\n(A) call mom @phone")
+		assert.Equal(t, output, "Todo 7 added: buy milk\nTodo 8 added: (A) call mom @phone\nAdded 2 of 2.\n")
+		assert.Equal(t, status, 0)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		output, status := runCommand(t, addCmd, []string{"buy milk", "call mom"}, map[string]string{"json": "true"})
+
+		assert.Equal(t, output, `{"id":7,"action":"add","success":true}`+"\n"+`{"id":8,"action":"add","success":true}`+"\n")
+		assert.Equal(t, status, 0)
+	})
+
+	t.Run("Dedupe", func(t *testing.T) {
+		_, status := runCommand(t, addCmd, []string{"buy milk", "call mom"}, map[string]string{"dedupe": "true"})
+
+		assert.Equal(t, query, "dedupe=true")
+		assert.Equal(t, status, 0)
+	})
+}
+
+func TestAddSingle(t *testing.T) {
+	var path, body string
+	var keys []string
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		path, body = r.URL.Path, string(b)
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+
+		w.WriteHeader(http.StatusCreated)
+		if r.URL.Query().Get("dedupe") == "true" {
+			io.WriteString(w, `{"results": [{"line": 1, "ok": true, "duplicate": true, "todo": {"id": 4}}]}`)
+			return
+		}
+		io.WriteString(w, `{"results": [{"line": 1, "ok": true, "todo": {"id": 7}}]}`)
+	}))
+
+	t.Run("Added", func(t *testing.T) {
+		output, status := runCommand(t, addCmd, []string{"(A) call mom @phone"}, nil)
+
+		// A single todo is parsed in the same way as several.
+		assert.Equal(t, path, "/v1/todos/import")
+		assert.Equal(t, body, "(A) call mom @phone")
+		assert.Equal(t, keys[len(keys)-1] != "", true)
+		assert.Equal(t, output, "Todo added successfully\n")
+		assert.Equal(t, status, 0)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		output, status := runCommand(t, addCmd, []string{"call mom"}, map[string]string{"dedupe": "true"})
+
+		assert.Equal(t, output, "Already exists (id 4).\n")
+		assert.Equal(t, status, 0)
+	})
+}
+
+func TestAddRetry(t *testing.T) {
+	var keys []string
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+
+		// The first attempt fails with a network error.
+		if len(keys) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"results": [
+			{"line": 1, "ok": true, "todo": {"id": 7}},
+			{"line": 2, "ok": true, "todo": {"id": 8}}
+		]}`)
+	}))
+
+	output, status := runCommand(t, addCmd, []string{"buy milk", "call mom"}, nil)
+
+	// Both attempts send the same key, so the server adds the todos once.
+	assert.Equal(t, len(keys), 2)
+	assert.Equal(t, keys[0], keys[1])
+	assert.Equal(t, output, "Todo 7 added: buy milk\nTodo 8 added: call mom\nAdded 2 of 2.\n")
+	assert.Equal(t, status, 0)
+}
+
+func TestAddMultipleInvalid(t *testing.T) {
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		io.WriteString(w, `{"results": [
+			{"line": 1, "ok": true, "todo": {"text": "buy milk"}},
+			{"line": 2, "ok": false, "errors": {"priority": "must be a single uppercase letter"}},
+			{"line": 3, "ok": false, "error": "todo has more than 100 contexts"}
+		]}`)
+	}))
+
+	// None of the todos are added, so the valid one fails too.
+	output, status := runCommand(t, addCmd, []string{"buy milk", "(a) call mom", "@too @many"}, nil)
+
+	assert.Equal(t, output, "Error: \"buy milk\": not added, because another todo is invalid\n"+
+		"Error: \"(a) call mom\": priority must be a single uppercase letter\n"+
+		"Error: \"@too @many\": todo has more than 100 contexts\n"+
+		"Added 0 of 3 (3 failed).\n")
+	assert.Equal(t, status, exitValidation)
+}
+
+func TestAddTooMany(t *testing.T) {
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		io.WriteString(w, `{"error": {"body": "must not contain more than 2 todos"}}`)
+	}))
+
+	// The server's limit applies, rather than a limit in the client.
+	output, status := runCommand(t, addCmd, []string{"buy milk", "call mom", "walk dog"}, nil)
+
+	assert.Equal(t, output, "Error: todos rejected: must not contain more than 2 todos\n"+
+		"Run 'godo add --help' for usage.\n")
+	assert.Equal(t, status, exitValidation)
+}
+
+func TestReadTodoLinesError(t *testing.T) {
+	_, err := readTodoLines(iotest.ErrReader(errors.New("broken pipe")))
+
+	assert.Equal(t, err.Error(), "failed to read todos: broken pipe")
+}
+
+func TestAddFromStdin(t *testing.T) {
+	var body string
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"results": [
+			{"line": 1, "ok": true, "todo": {"id": 7}},
+			{"line": 2, "ok": true, "todo": {"id": 8}}
+		]}`)
+	}))
+
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string
+		status int
+	}{
+		{
+			name:   "Dash",
+			args:   []string{"-"},
+			input:  "buy milk\n\n  \n  call mom @phone  \n",
+			output: "Todo 7 added: buy milk\nTodo 8 added: call mom @phone\nAdded 2 of 2.\n",
+		},
+		{
+			name:   "No arguments",
+			args:   []string{},
+			input:  "buy milk\ncall mom @phone",
+			output: "Todo 7 added: buy milk\nTodo 8 added: call mom @phone\nAdded 2 of 2.\n",
+		},
+		{
+			name:   "Only blank lines",
+			args:   []string{"-"},
+			input:  "\n \n",
+			output: "Error: no todos in the input\nRun 'godo add --help' for usage.\n",
+			status: exitValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body = ""
+			stdin = strings.NewReader(tt.input)
+			stdinIsTerminal = func() bool { return false }
+			t.Cleanup(func() {
+				stdin = os.Stdin
+				stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+			})
+
+			// Without arguments, the input is only read if it isn't a terminal.
+			assert.IsNil(t, addCmd.Args(addCmd, tt.args))
+
+			output, status := runCommand(t, addCmd, tt.args, nil)
+
+			assert.Equal(t, output, tt.output)
+			assert.Equal(t, status, tt.status)
+			if tt.status == 0 {
+				// Blank lines aren't sent.
+				assert.Equal(t, body, "buy milk\ncall mom @phone")
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// permissionCodes are the permissions that can be granted and revoked.
+var permissionCodes = []string{"todos:read", "todos:write", "admin"}
+
+// adminCmd groups commands that require the admin permission.
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Manage other users' permissions",
+	Long: `
+Manage other users' permissions. These commands require the admin permission,
+which can only be granted by another admin, or directly in the database.
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+}
+
+// adminGrantCmd grants a permission to a user.
+var adminGrantCmd = &cobra.Command{
+	Use:   "grant <email> <permission>",
+	Short: "Grant a permission to a user",
+	Long: `
+Grant a permission to the user with the given email. The permission must be
+"todos:read", "todos:write", or "admin". Granting a permission that the user
+already has does nothing.
+
+Examples:
+
+    # Allow a user to create and change todos
+    godo admin grant alice@example.com todos:write
+
+This command requires the admin permission.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return changePermission(http.MethodPut, args[0], args[1], "grant permission")
+	},
+}
+
+// adminRevokeCmd revokes a permission from a user.
+var adminRevokeCmd = &cobra.Command{
+	Use:   "revoke <email> <permission>",
+	Short: "Revoke a permission from a user",
+	Long: `
+Revoke a permission from the user with the given email. The permission must be
+"todos:read", "todos:write", or "admin". Revoking a permission that the user
+doesn't have does nothing.
+
+Examples:
+
+    # Prevent a user from changing todos
+    godo admin revoke alice@example.com todos:write
+
+This command requires the admin permission.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return changePermission(http.MethodDelete, args[0], args[1], "revoke permission")
+	},
+}
+
+// changePermission sends a request to grant or revoke the permission, and
+// prints the user's permissions afterwards. The method is PUT to grant the
+// permission, and DELETE to revoke it.
+func changePermission(method, email, permission, failMsg string) error {
+	if !slices.Contains(permissionCodes, permission) {
+		return validationError(fmt.Errorf("permission must be one of %s", strings.Join(permissionCodes, ", ")))
+	}
+
+	url := app.Config.APIBaseURL + "/admin/permissions"
+	stdoutMsg := app.failureMsg(failMsg)
+
+	handleError := func(logMsg string, err error) error {
+		return app.cmdError(logMsg, stdoutMsg, err,
+			"method", method,
+			"url", url)
+	}
+
+	token, err := app.TokenManager.LoadToken()
+	if err != nil {
+		return app.authError("Failed to read token", err)
+	}
+
+	payload := map[string]any{"email": email, "permission": permission}
+	req, err := app.createJSONRequest(method, url, payload)
+	if err != nil {
+		return handleError("Failed to create request", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+string(token))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return handleError("Failed to send request", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := app.readResponse(resp, handleError)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return handleError("Failed to "+failMsg, responseError(resp))
+	}
+
+	var permResp struct {
+		Email       string   `json:"email"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.Unmarshal(body, &permResp); err != nil {
+		return handleError("Failed to unmarshal response", err)
+	}
+
+	permissions := "none"
+	if len(permResp.Permissions) > 0 {
+		permissions = strings.Join(permResp.Permissions, ", ")
+	}
+	fmt.Printf("Permissions of %s: %s\n", permResp.Email, permissions)
+	return nil
+}
+
+func init() {
+	adminCmd.AddCommand(adminGrantCmd)
+	adminCmd.AddCommand(adminRevokeCmd)
+	rootCmd.AddCommand(adminCmd)
+}
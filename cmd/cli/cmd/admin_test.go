@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/spf13/cobra"
+)
+
+func TestAdminPermissionCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmd    *cobra.Command
+		args   []string
+		method string
+		output string
+		status int
+	}{
+		{
+			name:   "Grant",
+			cmd:    adminGrantCmd,
+			args:   []string{"alice@example.com", "todos:write"},
+			method: http.MethodPut,
+			output: "Permissions of alice@example.com: todos:read, todos:write\n",
+		},
+		{
+			name:   "Revoke",
+			cmd:    adminRevokeCmd,
+			args:   []string{"alice@example.com", "todos:read"},
+			method: http.MethodDelete,
+			output: "Permissions of alice@example.com: none\n",
+		},
+		{
+			name:   "Unknown permission",
+			cmd:    adminGrantCmd,
+			args:   []string{"alice@example.com", "todos:delete"},
+			output: "Error: permission must be one of todos:read, todos:write, admin\nRun 'godo admin grant --help' for usage.\n",
+			status: exitValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method string
+			var input struct {
+				Email      string `json:"email"`
+				Permission string `json:"permission"`
+			}
+
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method = r.Method
+				json.NewDecoder(r.Body).Decode(&input)
+
+				permissions := `["todos:read", "todos:write"]`
+				if r.Method == http.MethodDelete {
+					permissions = `[]`
+				}
+				io.WriteString(w, `{"email": "alice@example.com", "permissions": `+permissions+`}`)
+			}))
+
+			output, status := runCommand(t, tt.cmd, tt.args, nil)
+
+			assert.Equal(t, output, tt.output)
+			assert.Equal(t, status, tt.status)
+			assert.Equal(t, method, tt.method)
+			if tt.method != "" {
+				assert.Equal(t, input.Email, tt.args[0])
+				assert.Equal(t, input.Permission, tt.args[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/spf13/cobra"
+)
+
+// agendaCmd shows the authenticated user's overdue, due today, and high
+// priority todos. The todos are grouped by the server, so that every client
+// shows the same agenda.
+var agendaCmd = &cobra.Command{
+	Use:     "agenda",
+	Aliases: []string{"today"},
+	Short:   "Show overdue, due today, and high priority todos",
+	Long: `
+Show a focused view of your active todos, in three groups:
+
+  - Overdue: todos that were due before today, oldest first.
+  - Due today: todos that are due today.
+  - High priority: todos with priority A or B that aren't yet due.
+
+Each todo is only shown in the first group it belongs to. Today's date is your
+computer's local date.
+
+Examples:
+
+    # Show your agenda
+    godo agenda
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url := app.Config.APIBaseURL + "/agenda?date=" + time.Now().Format(time.DateOnly)
+		stdoutMsg := app.failureMsg("show agenda")
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodGet,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		req, err := app.createJSONRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return handleError("Failed to create request", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return handleError("Failed to send request", err)
+		}
+		defer resp.Body.Close()
+
+		// Read response body and log it
+		body, err := app.readResponse(resp, handleError)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return handleError("Failed to show agenda", responseError(resp))
+		}
+
+		var agendaResp struct {
+			Agenda types.Agenda `json:"agenda"`
+		}
+		if err := json.Unmarshal(body, &agendaResp); err != nil {
+			return handleError("Failed to unmarshal response", err)
+		}
+
+		writeAgenda(os.Stdout, agendaResp.Agenda)
+		return nil
+	},
+}
+
+// writeAgenda writes each non-empty group of the agenda to w under a heading.
+// Each todo is shown with its ID, priority, and due date, if it has them.
+func writeAgenda(w io.Writer, agenda types.Agenda) {
+	groups := []struct {
+		heading string
+		todos   []types.Todo
+	}{
+		{"Overdue", agenda.Overdue},
+		{"Due today", agenda.DueToday},
+		{"High priority", agenda.HighPriority},
+	}
+
+	empty := true
+	for _, g := range groups {
+		if len(g.todos) == 0 {
+			continue
+		}
+		empty = false
+
+		fmt.Fprintf(w, "\n%s:\n\n", g.heading)
+		for _, todo := range g.todos {
+			priority := "   "
+			if todo.Priority != "" {
+				priority = "(" + todo.Priority + ")"
+			}
+			due := ""
+			if len(todo.DueDate) >= len(time.DateOnly) {
+				due = " \033[90m[due " + todo.DueDate[:len(time.DateOnly)] + "]\033[0m"
+			}
+			fmt.Fprintf(w, "%4d. %s %s%s\n", todo.ID, priority, todo.Text, due)
+		}
+	}
+
+	if empty {
+		fmt.Fprintln(w, "Nothing on your agenda.")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(agendaCmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+)
+
+// archiveDoneCmd archives every completed todo item.
+var archiveDoneCmd = &cobra.Command{
+	Use:   "archive-done [@context]... [+project]...",
+	Short: "Archive all completed todo items",
+	Long: `
+Archive all completed todo items.
+
+Arguments beginning with "@" limit the todos archived to those with the
+context, and arguments beginning with "+" limit them to those with the
+project.
+
+Examples:
+
+    # Archive all completed todos
+    godo archive-done
+
+    # Archive the completed todos in the godo project
+    godo archive-done +godo
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		text, contexts, projects := parseFilterArgs(args)
+		if text != "" {
+			return validationError(errors.New("only @context and +project filters are supported"))
+		}
+
+		url := app.Config.APIBaseURL + "/todos/archive-completed"
+		stdoutMsg := app.failureMsg("archive completed todos")
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodPost,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		payload := map[string]any{
+			"contexts": contexts,
+			"projects": projects,
+		}
+
+		req, err := app.createJSONRequest(http.MethodPost, url, payload)
+		if err != nil {
+			return handleError("Failed to create request", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return handleError("Failed to send request", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := app.readResponse(resp, handleError)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return handleError("Failed to archive completed todos", responseError(resp))
+		}
+
+		app.invalidateCache()
+
+		var archiveResp struct {
+			Archived int `json:"archived"`
+		}
+		if err := json.Unmarshal(body, &archiveResp); err != nil {
+			return handleError("Failed to unmarshal response", err)
+		}
+
+		fmt.Printf("%d todo(s) archived\n", archiveResp.Archived)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(archiveDoneCmd)
+}
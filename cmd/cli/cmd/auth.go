@@ -2,20 +2,25 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 
 	"syscall"
+	"time"
 
+	"github.com/kvnloughead/godo/cmd/cli/token"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
 type authResponse struct {
 	AuthenticationToken struct {
-		Token  string `json:"token"`
-		Expiry string `json:"expiry"`
+		Token  string    `json:"token"`
+		Expiry time.Time `json:"expiry"`
 	} `json:"authentication_token"`
+	LastLoginAt *time.Time `json:"last_login_at"`
 }
 
 var (
@@ -46,8 +51,10 @@ Examples:
     # Authenticate with email flag only (will prompt for password)
     godo auth -e user@example.com
 
-Only an activated user can be authenticated. Run 'godo activate -h' for more information.`,
-	Run: func(cmd *cobra.Command, args []string) {
+Only an activated user can be authenticated. Run 'godo activate -h' for more information.
+
+If the GODO_TOKEN environment variable is set, its token is still used instead of the saved one, and a warning is printed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// If email wasn't provided via flag, prompt for it
 		if email == "" {
 			fmt.Print("Enter email: ")
@@ -59,8 +66,7 @@ Only an activated user can be authenticated. Run 'godo activate -h' for more inf
 			fmt.Print("Enter password: ")
 			bytePassword, err := term.ReadPassword(int(syscall.Stdin))
 			if err != nil {
-				app.handleAuthenticationError("Failed to read password", err)
-				return
+				return app.authError("Failed to read password", err)
 			}
 			fmt.Println() // Add newline after password input
 			password = string(bytePassword)
@@ -71,10 +77,9 @@ Only an activated user can be authenticated. Run 'godo activate -h' for more inf
 		// Define a helper function that captures the parameters that are common to
 		// all errors
 		handleError := func(msg string, err error) error {
-			app.handleAuthenticationError(msg, err,
+			return app.cmdError(msg, app.failureMsg("authenticate"), err,
 				"method", http.MethodPost,
 				"url", url)
-			return err
 		}
 		// Prepare JSON payload from args
 		payload := map[string]any{
@@ -85,51 +90,56 @@ Only an activated user can be authenticated. Run 'godo activate -h' for more inf
 		// Create request
 		req, err := app.createJSONRequest(http.MethodPost, url, payload)
 		if err != nil {
-			handleError("Failed to create request", err)
-			return
+			return handleError("Failed to create request", err)
 		}
 		req.Header.Set("Context-Type", "application/json")
 
 		// Send request
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			handleError("Failed to send request", err)
-			return
+			return handleError("Failed to send request", err)
 		}
 
 		// Read response body and log it
 		body, err := app.readResponse(resp, handleError)
 		if err != nil {
-			return
+			return err
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusCreated {
-			handleError(fmt.Sprintf("Failed to authenticate: %s", string(body)), nil)
-			return
+			return handleError(fmt.Sprintf("Failed to authenticate: %s", string(body)), responseError(resp))
 		}
 
 		// Unmarshal response
 		var authResp authResponse
 		err = json.Unmarshal(body, &authResp)
 		if err != nil {
-			handleError("Failed to unmarshal response", err)
-			return
+			return handleError("Failed to unmarshal response", err)
 		}
 
 		// Retrieve token from response
 		if authResp.AuthenticationToken.Token == "" {
-			handleError("Token not found in response", nil)
-			return
+			return handleError("Token not found in response", errors.New("no token in response"))
 		}
 		authToken := authResp.AuthenticationToken.Token
 
-		// Save token securely using token manager
-		if err := app.TokenManager.SaveToken(authToken); err != nil {
-			handleError("Failed to save token", err)
-			return
+		// Save token securely using token manager, with its expiry so that
+		// later commands can tell that it has expired without a request
+		if err := app.TokenManager.SaveToken(authToken, authResp.AuthenticationToken.Expiry); err != nil {
+			return handleError("Failed to save token", err)
 		}
+		// The cached todos may belong to another user.
+		app.invalidateCache()
+
 		fmt.Println("Authentication successful and token saved")
+		if authResp.LastLoginAt != nil {
+			fmt.Printf("Last login: %s\n", authResp.LastLoginAt.Local().Format("Mon Jan 2 15:04:05 2006"))
+		}
+		if os.Getenv(token.EnvVar) != "" {
+			fmt.Printf("Warning: %s is set, so its token is used instead of the saved one until it is unset\n", token.EnvVar)
+		}
+		return nil
 	},
 }
 
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestAuthWithEnvToken(t *testing.T) {
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"authentication_token": {"token": "JRKA3TSQXUF7M5EKA5ILZ2PF2Y", "expiry": "2099-01-01T00:00:00Z"}}`)
+	}))
+
+	tests := []struct {
+		name     string
+		envToken string
+		output   string
+	}{
+		{
+			name:   "Unset",
+			output: "Authentication successful and token saved\n",
+		},
+		{
+			name:     "Set",
+			envToken: "F6SB76ZCLKLJBHP7K7A6N2S7JM",
+			output: "Authentication successful and token saved\n" +
+				"Warning: GODO_TOKEN is set, so its token is used instead of the saved one until it is unset\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GODO_TOKEN", tt.envToken)
+
+			output, status := runCommand(t, authCmd, nil, map[string]string{
+				"email":    "alice@example.com",
+				"password": "pa55word",
+			})
+
+			assert.Equal(t, output, tt.output)
+			assert.Equal(t, status, 0)
+		})
+	}
+}
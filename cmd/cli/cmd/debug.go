@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kvnloughead/godo/cmd/cli/config"
+	"github.com/kvnloughead/godo/cmd/cli/token"
+	"github.com/spf13/cobra"
+)
+
+// debugCmd groups commands for troubleshooting the CLI. It is hidden from the
+// help output.
+var debugCmd = &cobra.Command{
+	Use:    "debug",
+	Short:  "Commands for troubleshooting godo",
+	Hidden: true,
+}
+
+// debugConfigCmd prints the CLI's effective configuration.
+var debugConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show the effective configuration",
+	Long: `
+Show the effective configuration: the config file that was read, the API base
+URL and where it came from, and the token file. The token itself is redacted.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return writeDebugConfig(os.Stdout)
+	},
+}
+
+// writeDebugConfig writes the CLI's effective configuration to w. The API
+// base URL is read from the config file or its profile, unless the
+// GODO_API_URL environment variable overrides it.
+func writeDebugConfig(w io.Writer) error {
+	file, err := config.ConfigFile(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	source := "config file"
+	if app.Config.Profile != "" {
+		source = fmt.Sprintf("profile %q", app.Config.Profile)
+	}
+	if os.Getenv("GODO_API_URL") != "" {
+		source = "GODO_API_URL"
+	}
+
+	tokenStatus := "(none)"
+	fromEnv := os.Getenv(token.EnvVar) != ""
+	t, err := app.TokenManager.LoadToken()
+	switch {
+	case errors.Is(err, token.ErrExpired):
+		tokenStatus = "(expired)"
+	case err != nil && fromEnv:
+		tokenStatus = "(invalid)"
+	case err == nil && t != "":
+		tokenStatus = "xxxxx"
+	}
+	if fromEnv {
+		tokenStatus += " (from " + token.EnvVar + ")"
+	}
+
+	fmt.Fprintf(w, "Config file:\t%s\n", file)
+	fmt.Fprintf(w, "API base URL:\t%s (from %s)\n", app.Config.APIBaseURL, source)
+	fmt.Fprintf(w, "Token file:\t%s\n", app.TokenManager.TokenFile())
+	fmt.Fprintf(w, "Token:\t\t%s\n", tokenStatus)
+	return nil
+}
+
+func init() {
+	debugCmd.AddCommand(debugConfigCmd)
+	rootCmd.AddCommand(debugCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestWriteDebugConfig(t *testing.T) {
+	newTestCLIApplication(t, http.NotFoundHandler())
+	t.Setenv("GODO_API_URL", "")
+	t.Setenv("GODO_TOKEN", "")
+
+	var buf strings.Builder
+	assert.IsNil(t, writeDebugConfig(&buf))
+	out := buf.String()
+
+	assert.StringContains(t, out, "API base URL:\t"+app.Config.APIBaseURL+" (from config file)")
+	assert.StringContains(t, out, "Token:\t\txxxxx")
+	assert.Equal(t, strings.Contains(out, "F6SB76ZCLKLJBHP7K7A6N2S7JM"), false)
+
+	// Without a saved token, none is shown.
+	err := app.TokenManager.DeleteToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	assert.IsNil(t, writeDebugConfig(&buf))
+	assert.StringContains(t, buf.String(), "Token:\t\t(none)")
+
+	// The profile that the URL came from is shown.
+	app.Config.Profile = "dev"
+	buf.Reset()
+	assert.IsNil(t, writeDebugConfig(&buf))
+	assert.StringContains(t, buf.String(), `(from profile "dev")`)
+
+	// A token from GODO_TOKEN is redacted too.
+	t.Setenv("GODO_TOKEN", "F6SB76ZCLKLJBHP7K7A6N2S7JM")
+	buf.Reset()
+	assert.IsNil(t, writeDebugConfig(&buf))
+	assert.StringContains(t, buf.String(), "Token:\t\txxxxx (from GODO_TOKEN)")
+}
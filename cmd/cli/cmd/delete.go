@@ -1,87 +1,294 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"strconv"
 
+	"github.com/kvnloughead/godo/cmd/cli/types"
 	"github.com/spf13/cobra"
 )
 
-// deleteCmd removes a todo item by its ID. Users can only delete their own todos. This command requires authentication.
+// deleteCmd removes todo items by their IDs. Users can only delete their own todos. This command requires authentication.
 var deleteCmd = &cobra.Command{
-	Use:   "delete <id>",
-	Short: "Delete a todo item by its ID",
+	Use:   "delete [--force] [--yes | --dry-run] <id>...",
+	Short: "Delete todo items by their IDs",
 	Long: `
-Delete a todo item by its ID. The ID can be found in the leftmost column when
-listing todos.
+Delete todo items by their IDs. The IDs can be found in the leftmost column
+when listing todos. Several todos are deleted with a single request.
+
+You are asked to confirm before the todos are deleted. The --yes flag skips the
+confirmation, and is required when the input isn't a terminal, such as in
+scripts.
+
+With the --dry-run flag, the todos that would be deleted are listed, and you
+are asked to confirm before any of them are deleted. If the input isn't a
+terminal, nothing is deleted.
+
+With the --force flag, it isn't an error if a todo doesn't exist, so that
+scripts can safely delete todos that may already have been deleted.
 
 Examples:
 
     # Delete todo with ID 123
     godo delete 123
 
+    # Delete todo 123 without confirmation, even if it was already deleted
+    godo delete --yes --force 123
+
+    # See which of todos 1, 2 and 3 would be deleted
+    godo delete --dry-run 1 2 3
+
 This command requires authentication. Run 'godo auth -h' for more information
 about authentication.`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		id, err := strconv.Atoi(args[0])
-		if err != nil || id < 1 {
-			fmt.Println("Error: ID must be a positive integer")
-			return
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+		force, _ := cmd.Flags().GetBool("force")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if yes && dryRun {
+			return validationError(errors.New("--yes and --dry-run can't be used together"))
 		}
 
-		url := fmt.Sprintf("%s/todos/%d", app.Config.APIBaseURL, id)
-		stdoutMsg := "\nError: failed to delete todo item. \nCheck `~/.config/godo/logs` for details.\n"
-
-		// handleError captures parameters that are common to all errors
-		handleError := func(logMsg string, err error) error {
-			app.handleError(logMsg, stdoutMsg, err,
-				"method", http.MethodDelete,
-				"url", url)
-			return err
+		if len(args) > 1 || dryRun {
+			ids := make([]int, len(args))
+			for i, arg := range args {
+				id, err := parseID(arg)
+				if err != nil {
+					return err
+				}
+				ids[i] = id
+			}
+			return deleteTodos(cmd, ids, yes, force, dryRun)
 		}
 
-		token, err := app.TokenManager.LoadToken()
+		id, err := parseID(args[0])
 		if err != nil {
-			app.handleAuthenticationError("Failed to read token", err)
-			return
+			return reportTodoResult(cmd, id, "delete", "", err)
 		}
 
-		req, err := http.NewRequest(http.MethodDelete, url, nil)
-		if err != nil {
-			handleError("Failed to create request", err)
-			return
+		if !yes {
+			err := confirmDeletion(stdin, stdinIsTerminal(), []int{id})
+			if err != nil {
+				return reportTodoResult(cmd, id, "delete", "", err)
+			}
 		}
-		req.Header.Set("Authorization", "Bearer "+string(token))
 
-		resp, err := http.DefaultClient.Do(req)
+		token, err := loadToken()
 		if err != nil {
-			handleError("Failed to send request", err)
-			return
+			return reportTodoResult(cmd, id, "delete", "", err)
 		}
-		defer resp.Body.Close()
 
-		// Read response body and log it
-		_, err = app.readResponse(resp, handleError)
+		msg, err := deleteTodo(token, id, force)
+		return reportTodoResult(cmd, id, "delete", msg, err)
+	},
+}
+
+// errDeletionCancelled is returned when the user doesn't confirm a deletion.
+var errDeletionCancelled = errors.New("deletion cancelled")
+
+// confirmDeletion asks the user to confirm that the todos with the given IDs
+// should be deleted, reading the answer from in. If in isn't a terminal,
+// there's no one to ask, so the deletion is refused.
+func confirmDeletion(in io.Reader, isTerminal bool, ids []int) error {
+	if !isTerminal {
+		return validationError(errors.New("can't confirm deletion, because the input isn't a terminal; use --yes to delete without confirmation"))
+	}
+
+	prompt := fmt.Sprintf("Delete todo %d?", ids[0])
+	if len(ids) > 1 {
+		prompt = fmt.Sprintf("Delete %d todos?", len(ids))
+	}
+	if !confirm(in, prompt) {
+		return errDeletionCancelled
+	}
+	return nil
+}
+
+// deleteTodo sends a request to delete the todo with the given ID, and
+// returns the message to print if it succeeds. If force is true, a todo that
+// doesn't exist is treated as already deleted, rather than as an error.
+func deleteTodo(token string, id int, force bool) (string, error) {
+	url := fmt.Sprintf("%s/todos/%d", app.Config.APIBaseURL, id)
+	stdoutMsg := app.failureMsg("delete todo item")
+
+	// handleError captures parameters that are common to all errors
+	handleError := func(logMsg string, err error) error {
+		return app.cmdError(logMsg, stdoutMsg, err,
+			"method", http.MethodDelete,
+			"url", url)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return "", handleError("Failed to create request", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+string(token))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", handleError("Failed to send request", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body and log it
+	_, err = app.readResponse(resp, handleError)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		app.invalidateCache()
+		return "Todo deleted successfully", nil
+	case resp.StatusCode == http.StatusNotFound && force:
+		return fmt.Sprintf("Todo %d not found, nothing to delete", id), nil
+	case resp.StatusCode == http.StatusNotFound:
+		return "", errTodoNotFound
+	default:
+		return "", handleError("Failed to delete todo", responseError(resp))
+	}
+}
+
+// deleteTodos deletes the todos with the given IDs with a single request, and
+// prints the result for each of them. If dryRun is true, the todos that would
+// be deleted are listed first, and the user is asked to confirm.
+func deleteTodos(cmd *cobra.Command, ids []int, yes, force, dryRun bool) error {
+	token, err := loadToken()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dryRun:
+		body, err := sendDeleteBatch(token, ids, true)
 		if err != nil {
-			return
+			return err
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			switch resp.StatusCode {
-			case http.StatusNotFound:
-				fmt.Println("Error: todo not found")
-			default:
-				handleError("Failed to delete todo", fmt.Errorf("response status: %s", resp.Status))
-			}
-			return
+		var previewResp struct {
+			Todos []types.Todo `json:"todos"`
+		}
+		if err := json.Unmarshal(body, &previewResp); err != nil {
+			return app.cmdError("Failed to unmarshal response", app.failureMsg("delete todo items"), err)
 		}
 
-		fmt.Println("Todo deleted successfully")
-	},
+		if len(previewResp.Todos) == 0 {
+			fmt.Println("No matches found.")
+			return nil
+		}
+
+		fmt.Println("The following todos will be deleted:")
+		for _, todo := range previewResp.Todos {
+			fmt.Printf("%4d  %s\n", todo.ID, todo.Text)
+		}
+
+		// Without a terminal to answer in, the list is all that is shown.
+		if !stdinIsTerminal() || !confirm(stdin, fmt.Sprintf("\nDelete %d todo(s)?", len(previewResp.Todos))) {
+			fmt.Println("No todos were deleted.")
+			return nil
+		}
+	case !yes:
+		if err := confirmDeletion(stdin, stdinIsTerminal(), ids); err != nil {
+			return err
+		}
+	}
+
+	body, err := sendDeleteBatch(token, ids, false)
+	if err != nil {
+		return err
+	}
+	app.invalidateCache()
+
+	var deleteResp struct {
+		Results []struct {
+			ID    int    `json:"id"`
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &deleteResp); err != nil {
+		return app.cmdError("Failed to unmarshal response", app.failureMsg("delete todo items"), err)
+	}
+
+	// The API reports todos that don't exist, or that belong to another
+	// user, as not found.
+	asJSON, _ := cmd.Flags().GetBool("json")
+	notFound := 0
+	for _, result := range deleteResp.Results {
+		var err error
+		msg := fmt.Sprintf("Todo %d deleted", result.ID)
+		switch {
+		case result.OK:
+		case force:
+			msg = fmt.Sprintf("Todo %d not found, nothing to delete", result.ID)
+		default:
+			err = errTodoNotFound
+			notFound++
+		}
+
+		if err != nil && !asJSON {
+			fmt.Printf("Error: todo %d: %v\n", result.ID, err)
+			continue
+		}
+		printTodoResult(asJSON, result.ID, "delete", msg, err)
+	}
+	if !asJSON {
+		fmt.Println(batchSummary("Deleted", len(deleteResp.Results), notFound, 0))
+	}
+
+	if notFound > 0 {
+		return &reportedError{err: errors.New("some todos couldn't be deleted")}
+	}
+	return nil
+}
+
+// sendDeleteBatch sends a request to delete the todos with the given IDs, and
+// returns the response body. If preview is true, nothing is deleted, and the
+// response lists the todos that would have been.
+func sendDeleteBatch(token string, ids []int, preview bool) ([]byte, error) {
+	url := app.Config.APIBaseURL + "/todos"
+	stdoutMsg := app.failureMsg("delete todo items")
+
+	// handleError captures parameters that are common to all errors
+	handleError := func(logMsg string, err error) error {
+		return app.cmdError(logMsg, stdoutMsg, err,
+			"method", http.MethodDelete,
+			"url", url)
+	}
+
+	payload := map[string]any{"ids": ids, "preview": preview}
+	req, err := app.createJSONRequest(http.MethodDelete, url, payload)
+	if err != nil {
+		return nil, handleError("Failed to create request", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+string(token))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, handleError("Failed to send request", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body and log it
+	body, err := app.readResponse(resp, handleError)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, handleError("Failed to delete todos", responseError(resp))
+	}
+	return body, nil
 }
 
 func init() {
 	rootCmd.AddCommand(deleteCmd)
+
+	deleteCmd.Flags().BoolP("force", "f", false, "don't report an error if the todo doesn't exist")
+	deleteCmd.Flags().BoolP("yes", "y", false, "delete without asking for confirmation")
+	deleteCmd.Flags().Bool("dry-run", false, "list the todos that would be deleted and confirm before deleting them")
+	addJSONFlag(deleteCmd)
 }
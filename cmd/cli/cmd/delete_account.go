@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// deleteAccountConfirmation is the text that must be typed to confirm that
+// an account should be deleted.
+const deleteAccountConfirmation = "delete my account"
+
+// deleteAccountCmd permanently deletes the authenticated user's account, along
+// with all of their todos. The stored token is deleted afterwards.
+var deleteAccountCmd = &cobra.Command{
+	Use:   "delete-account",
+	Short: "Permanently delete your account and all of your todos",
+	Long: `
+Permanently delete your account, along with all of your todos. This can't be
+undone.
+
+You will be asked to type "` + deleteAccountConfirmation + `" to confirm. Once the account
+is deleted, your stored authentication token is removed.
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url := app.Config.APIBaseURL + "/users/me"
+		stdoutMsg := app.failureMsg("delete account")
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodDelete,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		fmt.Printf("This will permanently delete your account and all of your todos.\n")
+		fmt.Printf("Type %q to confirm: ", deleteAccountConfirmation)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != deleteAccountConfirmation {
+			fmt.Println("Account deletion cancelled.")
+			return nil
+		}
+
+		req, err := http.NewRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			return handleError("Failed to create request", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return handleError("Failed to send request", err)
+		}
+		defer resp.Body.Close()
+
+		// Read response body and log it
+		_, err = app.readResponse(resp, handleError)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return handleError("Failed to delete account", responseError(resp))
+		}
+
+		// The todos are gone, and the token is no longer valid, so remove them.
+		app.invalidateCache()
+		if err := app.TokenManager.DeleteToken(); err != nil {
+			app.Logger.Error("failed to delete token", "error", err)
+		}
+
+		fmt.Println("Account deleted successfully")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deleteAccountCmd)
+}
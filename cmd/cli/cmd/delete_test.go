@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/cmd/cli/cache"
+	"github.com/kvnloughead/godo/cmd/cli/config"
+	"github.com/kvnloughead/godo/cmd/cli/token"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// newTestCLIApplication sets app to a CLIApplication that sends requests to
+// the handler, with a saved token and an empty cache. Logs are discarded.
+func newTestCLIApplication(t *testing.T, h http.Handler) {
+	t.Helper()
+
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	app = &CLIApplication{
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		LogDir:       "/tmp/godo/logs",
+		Config:       config.Config{APIBaseURL: ts.URL + "/v1"},
+		TokenManager: token.NewManager(t.TempDir(), ts.URL),
+		Cache:        cache.New(t.TempDir()),
+	}
+	err := app.TokenManager.SaveToken("F6SB76ZCLKLJBHP7K7A6N2S7JM", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// captureStdout returns everything written to os.Stdout while f runs.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// runCommand runs the command with the arguments and flags, as Execute would,
+// and returns everything written to os.Stdout and the status it would exit
+// with. The flags are reset when the test finishes.
+func runCommand(t *testing.T, cmd *cobra.Command, args []string, flags map[string]string) (string, int) {
+	t.Helper()
+
+	for name, value := range flags {
+		f := cmd.Flags().Lookup(name)
+		defValue := f.DefValue
+		t.Cleanup(func() { f.Value.Set(defValue); f.Changed = false })
+		if err := cmd.Flags().Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status := 0
+	output := captureStdout(t, func() {
+		if err := cmd.RunE(cmd, args); err != nil {
+			status = reportError(cmd, err)
+		}
+	})
+	return output, status
+}
+
+func TestDeleteTodoNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		force  string
+		output string
+		status int
+	}{
+		{name: "Without force", force: "false", output: "Error: todo not found\n", status: exitFailure},
+		{name: "With force", force: "true", output: "Todo 42 not found, nothing to delete\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, r.Method, http.MethodDelete)
+				assert.Equal(t, r.URL.Path, "/v1/todos/42")
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `{"error": "the requested resource could not be found"}`)
+			}))
+
+			output, status := runCommand(t, deleteCmd, []string{"42"}, map[string]string{"force": tt.force, "yes": "true"})
+
+			assert.Equal(t, output, tt.output)
+			assert.Equal(t, status, tt.status)
+		})
+	}
+}
+
+func TestDeleteConfirmation(t *testing.T) {
+	tests := []struct {
+		name       string
+		isTerminal bool
+		input      string
+		flags      map[string]string
+		deleted    bool
+		output     string
+		status     int
+	}{
+		{name: "Yes", isTerminal: true, input: "y\n", deleted: true, output: "Delete todo 42? [y/N] Todo deleted successfully\n"},
+		{name: "No", isTerminal: true, input: "n\n", output: "Delete todo 42? [y/N] Error: deletion cancelled\n", status: exitFailure},
+		{name: "No answer", isTerminal: true, input: "", output: "Delete todo 42? [y/N] Error: deletion cancelled\n", status: exitFailure},
+		{name: "Not a terminal", output: "Error: can't confirm deletion, because the input isn't a terminal; use --yes to delete without confirmation\n", status: exitValidation},
+		{name: "Yes flag", flags: map[string]string{"yes": "true"}, deleted: true, output: "Todo deleted successfully\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted := false
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deleted = true
+				io.WriteString(w, `{"message": "todo successfully deleted"}`)
+			}))
+
+			stdin = strings.NewReader(tt.input)
+			stdinIsTerminal = func() bool { return tt.isTerminal }
+			t.Cleanup(func() {
+				stdin = os.Stdin
+				stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+			})
+
+			output, status := runCommand(t, deleteCmd, []string{"42"}, tt.flags)
+
+			assert.Equal(t, output, tt.output)
+			assert.Equal(t, status, tt.status)
+			assert.Equal(t, deleted, tt.deleted)
+		})
+	}
+}
+
+func TestConfirmDeletionBatch(t *testing.T) {
+	var err error
+	output := captureStdout(t, func() {
+		err = confirmDeletion(strings.NewReader("yes\n"), true, []int{1, 2, 3})
+	})
+
+	assert.IsNil(t, err)
+	assert.Equal(t, output, "Delete 3 todos? [y/N] ")
+}
+
+func TestDeleteMultiple(t *testing.T) {
+	const preview = `{"todos": [{"id": 1, "text": "call mom"}, {"id": 2, "text": "buy milk"}]}`
+	const results = `{"results": [{"id": 1, "ok": true}, {"id": 2, "ok": true}, {"id": 3, "ok": false, "error": "todo not found"}]}`
+	const list = "The following todos will be deleted:\n   1  call mom\n   2  buy milk\n"
+	const deleted = "Todo 1 deleted\nTodo 2 deleted\n"
+
+	tests := []struct {
+		name       string
+		isTerminal bool
+		input      string
+		flags      map[string]string
+		requests   string
+		output     string
+		status     int
+	}{
+		{
+			name:       "Dry run confirmed",
+			isTerminal: true,
+			input:      "y\n",
+			flags:      map[string]string{"dry-run": "true"},
+			requests:   "[1 2 3] true,[1 2 3] false",
+			output:     list + "\nDelete 2 todo(s)? [y/N] " + deleted + "Error: todo 3: todo not found\nDeleted 2 of 3 (1 not found).\n",
+			status:     exitFailure,
+		},
+		{
+			name:       "Dry run cancelled",
+			isTerminal: true,
+			input:      "n\n",
+			flags:      map[string]string{"dry-run": "true"},
+			requests:   "[1 2 3] true",
+			output:     list + "\nDelete 2 todo(s)? [y/N] No todos were deleted.\n",
+		},
+		{
+			name:     "Dry run without a terminal",
+			flags:    map[string]string{"dry-run": "true"},
+			requests: "[1 2 3] true",
+			output:   list + "No todos were deleted.\n",
+		},
+		{
+			name:     "Force",
+			flags:    map[string]string{"yes": "true", "force": "true"},
+			requests: "[1 2 3] false",
+			output:   deleted + "Todo 3 not found, nothing to delete\nDeleted 3 of 3.\n",
+		},
+		{
+			name:   "Yes and dry run",
+			flags:  map[string]string{"yes": "true", "dry-run": "true"},
+			output: "Error: --yes and --dry-run can't be used together\nRun 'godo delete --help' for usage.\n",
+			status: exitValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var input struct {
+					IDs     []int `json:"ids"`
+					Preview bool  `json:"preview"`
+				}
+				json.NewDecoder(r.Body).Decode(&input)
+				requests = append(requests, fmt.Sprint(input.IDs, " ", input.Preview))
+
+				if input.Preview {
+					io.WriteString(w, preview)
+					return
+				}
+				io.WriteString(w, results)
+			}))
+
+			stdin = strings.NewReader(tt.input)
+			stdinIsTerminal = func() bool { return tt.isTerminal }
+			t.Cleanup(func() {
+				stdin = os.Stdin
+				stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+			})
+
+			output, status := runCommand(t, deleteCmd, []string{"1", "2", "3"}, tt.flags)
+
+			assert.Equal(t, output, tt.output)
+			assert.Equal(t, status, tt.status)
+			assert.Equal(t, strings.Join(requests, ","), tt.requests)
+		})
+	}
+}
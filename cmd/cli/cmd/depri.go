@@ -1,30 +1,34 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 )
 
+// depriCmd removes the priority from a todo item.
 var depriCmd = &cobra.Command{
-	Use:   "depri",
-	Short: "A brief description of your command",
-	Long:  `TODO - add long help text`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("depri called")
+	Use:   "depri <id>",
+	Short: "Remove the priority from a todo item",
+	Long: `
+Remove the priority from a todo item. For example:
+
+    # Remove the priority from todo #42
+    godo depri 42
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID(args[0])
+		if err == nil {
+			_, err = withToken(id, func(token string, id int) (string, error) {
+				return "", patchTodo(token, id, map[string]any{"priority": ""}, "remove todo priority")
+			})
+		}
+		return reportTodoResult(cmd, id, "depri", "Todo priority removed", err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(depriCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// depriCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// depriCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	addJSONFlag(depriCmd)
 }
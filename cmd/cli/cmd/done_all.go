@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/spf13/cobra"
+)
+
+// doneAllCmd marks every todo item matching a filter as completed.
+var doneAllCmd = &cobra.Command{
+	Use:   "done-all [--dry-run] <filter>...",
+	Short: "Mark all todo items matching a filter as completed",
+	Long: `
+Mark all incomplete, unarchived todo items matching a filter as completed.
+
+Arguments beginning with "@" filter by context, and arguments beginning with
+"+" filter by project. Any other arguments are used as search text. At least
+one filter must be provided.
+
+With the --dry-run flag, the matching todos are listed, and you are asked to
+confirm before any of them are changed.
+
+Examples:
+
+    # Complete all todos in the godo project
+    godo done-all +godo
+
+    # Complete all todos with the @phone context that mention "mom"
+    godo done-all @phone mom
+
+    # See which todos in the work project would be completed
+    godo done-all --dry-run +work
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url := app.Config.APIBaseURL + "/todos/complete"
+		stdoutMsg := app.failureMsg("mark todos as completed")
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodPost,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		text, contexts, projects := parseFilterArgs(args)
+		payload := map[string]any{
+			"text":     text,
+			"contexts": contexts,
+			"projects": projects,
+		}
+
+		// send posts the payload and returns the response body.
+		send := func(payload map[string]any) ([]byte, error) {
+			req, err := app.createJSONRequest(http.MethodPost, url, payload)
+			if err != nil {
+				return nil, handleError("Failed to create request", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+string(token))
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return nil, handleError("Failed to send request", err)
+			}
+			defer resp.Body.Close()
+
+			// Read response body and log it
+			body, err := app.readResponse(resp, handleError)
+			if err != nil {
+				return nil, err
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, handleError("Failed to mark todos as completed", responseError(resp))
+			}
+			return body, nil
+		}
+
+		// With --dry-run, preview the matching todos and ask for confirmation.
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			previewPayload := map[string]any{"preview": true}
+			for k, v := range payload {
+				previewPayload[k] = v
+			}
+
+			body, err := send(previewPayload)
+			if err != nil {
+				return err
+			}
+
+			var previewResp struct {
+				Todos []types.Todo `json:"todos"`
+			}
+			if err := json.Unmarshal(body, &previewResp); err != nil {
+				return handleError("Failed to unmarshal response", err)
+			}
+
+			if len(previewResp.Todos) == 0 {
+				fmt.Println("No matches found.")
+				return nil
+			}
+
+			fmt.Println("The following todos will be marked as completed:")
+			for _, todo := range previewResp.Todos {
+				fmt.Printf("%4d  %s\n", todo.ID, todo.Text)
+			}
+
+			if !confirm(stdin, fmt.Sprintf("\nComplete %d todo(s)?", len(previewResp.Todos))) {
+				fmt.Println("No todos were changed.")
+				return nil
+			}
+		}
+
+		body, err := send(payload)
+		if err != nil {
+			return err
+		}
+
+		app.invalidateCache()
+
+		var completeResp struct {
+			Completed int `json:"completed"`
+		}
+		if err := json.Unmarshal(body, &completeResp); err != nil {
+			return handleError("Failed to unmarshal response", err)
+		}
+
+		fmt.Printf("%d todo(s) marked as completed\n", completeResp.Completed)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doneAllCmd)
+
+	doneAllCmd.Flags().Bool("dry-run", false, "list the matching todos and confirm before completing them")
+}
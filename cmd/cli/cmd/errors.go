@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/kvnloughead/godo/cmd/cli/token"
+	"github.com/spf13/cobra"
+)
+
+// Exit statuses of the godo CLI. They are listed in the root command's help.
+const (
+	exitFailure    = 1 // Any other failure, such as a todo not being found.
+	exitValidation = 2 // Invalid arguments or flags, or input rejected by the API.
+	exitAuth       = 3 // No saved token, or the API rejected the credentials.
+	exitNetwork    = 4 // The API couldn't be reached.
+)
+
+// errTodoNotFound is returned when the API responds that a todo doesn't exist.
+var errTodoNotFound = errors.New("todo not found")
+
+// errInvalidID is returned when a todo ID argument isn't a positive integer.
+var errInvalidID = validationError(errors.New("ID must be a positive integer"))
+
+// errUnauthorized is wrapped by the error for a response that rejected the
+// token, with 401 Unauthorized.
+var errUnauthorized = errors.New("unauthorized")
+
+// errEnvTokenRejected is wrapped by the error for a response that rejected the
+// token from the token.EnvVar environment variable.
+var errEnvTokenRejected = errors.New("token from " + token.EnvVar + " rejected")
+
+// envTokenRejectedMsg is printed when the API rejects the token from the
+// token.EnvVar environment variable, which is easy to forget about, since it
+// takes precedence over the saved token.
+const envTokenRejectedMsg = "The token in " + token.EnvVar + " was rejected. Set it to a valid token, or unset it to use the saved token.\n"
+
+// statusError is an error that the CLI exits with a particular status for.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+
+func (e *statusError) Unwrap() error { return e.err }
+
+// validationError returns err as an error that the CLI exits with
+// exitValidation for.
+func validationError(err error) error {
+	return &statusError{status: exitValidation, err: err}
+}
+
+// responseError returns an error for an API response with an unexpected
+// status code. Authentication and validation failures are given the matching
+// exit statuses.
+func responseError(resp *http.Response) error {
+	err := fmt.Errorf("response status: %s", resp.Status)
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if resp.StatusCode == http.StatusUnauthorized {
+			err = fmt.Errorf("%w: %w", errUnauthorized, err)
+			if sentEnvToken(resp) {
+				err = fmt.Errorf("%w: %w", errEnvTokenRejected, err)
+			}
+		}
+		return &statusError{status: exitAuth, err: err}
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return validationError(err)
+	default:
+		return err
+	}
+}
+
+// sentEnvToken reports whether the request for resp was authenticated with the
+// token from the token.EnvVar environment variable.
+func sentEnvToken(resp *http.Response) bool {
+	return resp.Request != nil && resp.Request.Header.Get("Authorization") != "" &&
+		os.Getenv(token.EnvVar) != ""
+}
+
+// cmdError is an error that has already been logged. msg is printed instead of
+// the error, since the details are in the log file.
+type cmdError struct {
+	msg string
+	err error
+}
+
+func (e *cmdError) Error() string { return e.err.Error() }
+
+func (e *cmdError) Unwrap() error { return e.err }
+
+// cmdError logs the error and returns it as a *cmdError, so that stdoutMsg is
+// printed when the command fails.
+//
+// - logMsg is added as the msg field in the log.
+// - err is added as the error field in the log.
+// - fields is variadic and is added as additional fields in the log.
+func (app *CLIApplication) cmdError(logMsg, stdoutMsg string, err error, fields ...any) error {
+	app.logError(logMsg, err, fields...)
+	return &cmdError{msg: stdoutMsg, err: err}
+}
+
+// authError is like cmdError, for failures to authenticate. The CLI exits with
+// exitAuth for it.
+func (app *CLIApplication) authError(logMsg string, err error, fields ...any) error {
+	stdoutMsg := app.failureMsg("authenticate")
+	if errors.Is(err, token.ErrExpired) {
+		stdoutMsg = expiredStdoutMsg
+	}
+	err = app.cmdError(logMsg, stdoutMsg, err, fields...)
+	return &statusError{status: exitAuth, err: err}
+}
+
+// expiredStdoutMsg is printed when the saved token has expired.
+const expiredStdoutMsg = "\nError: your session expired. \nPlease run `godo auth` to sign in again.\n"
+
+// failureMsg returns the message printed when a command fails for a reason
+// that is only logged, such as "failed to add todo item". It points to the
+// directory that the log is in.
+func (app *CLIApplication) failureMsg(action string) string {
+	return fmt.Sprintf("\nError: failed to %s. \nCheck `%s` for details.\n", action, app.LogDir)
+}
+
+// reportedError wraps an error that a command has already printed, so that
+// it isn't printed again.
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string { return e.err.Error() }
+
+func (e *reportedError) Unwrap() error { return e.err }
+
+// exitStatus returns the status that the CLI exits with when a command fails
+// with err.
+func exitStatus(err error) int {
+	var statusErr *statusError
+	var urlErr *url.Error
+
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.status
+	case errors.As(err, &urlErr):
+		return exitNetwork
+	default:
+		return exitFailure
+	}
+}
+
+// reportError prints the error that cmd failed with, unless it has already
+// been printed, and returns the status to exit with.
+func reportError(cmd *cobra.Command, err error) int {
+	return writeError(os.Stdout, cmd, err)
+}
+
+// writeError is like reportError, but writes the error to w.
+func writeError(w io.Writer, cmd *cobra.Command, err error) int {
+	var reported *reportedError
+	var cmdErr *cmdError
+	status := exitStatus(err)
+
+	switch {
+	case errors.As(err, &reported):
+	case errors.As(err, &cmdErr):
+		// The message points to the log, so the run's ID is printed too.
+		fmt.Fprint(w, cmdErr.msg)
+		if errors.Is(err, errEnvTokenRejected) {
+			fmt.Fprint(w, envTokenRejectedMsg)
+		}
+		if app != nil && app.InvocationID != "" {
+			fmt.Fprintf(w, "Look for invocation_id=%s in the log.\n", app.InvocationID)
+		}
+		fmt.Fprintln(w)
+	default:
+		fmt.Fprintf(w, "Error: %v\n", err)
+		if status == exitValidation && cmd != nil {
+			fmt.Fprintf(w, "Run '%s --help' for usage.\n", cmd.CommandPath())
+		}
+	}
+
+	return status
+}
+
+// validateArgsWithStatus wraps the argument validators of cmd and its
+// subcommands, so that the CLI exits with exitValidation when they fail.
+func validateArgsWithStatus(cmd *cobra.Command) {
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			if err := validate(cmd, args); err != nil {
+				return validationError(err)
+			}
+			return nil
+		}
+	}
+
+	for _, c := range cmd.Commands() {
+		validateArgsWithStatus(c)
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/spf13/cobra"
+)
+
+func TestExitStatus(t *testing.T) {
+	// respond returns a handler that responds with the status code.
+	respond := func(status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			io.WriteString(w, `{"error": "something went wrong"}`)
+		})
+	}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		setup   func(t *testing.T)
+		args    []string
+		output  string
+		status  int
+	}{
+		{
+			name:    "Success",
+			handler: respond(http.StatusOK),
+			args:    []string{"42"},
+			output:  "Todo starred\n",
+			status:  0,
+		},
+		{
+			name:    "Server error",
+			handler: respond(http.StatusInternalServerError),
+			args:    []string{"42"},
+			output:  "\nError: failed to star todo. \nCheck `/tmp/godo/logs` for details.\n\n",
+			status:  exitFailure,
+		},
+		{
+			name:    "Invalid ID",
+			handler: respond(http.StatusOK),
+			args:    []string{"abc"},
+			output:  "Error: ID must be a positive integer\nRun 'godo star --help' for usage.\n",
+			status:  exitValidation,
+		},
+		{
+			name:    "Rejected input",
+			handler: respond(http.StatusUnprocessableEntity),
+			args:    []string{"42"},
+			output:  "\nError: failed to star todo. \nCheck `/tmp/godo/logs` for details.\n\n",
+			status:  exitValidation,
+		},
+		{
+			name:    "Rejected token",
+			handler: respond(http.StatusUnauthorized),
+			args:    []string{"42"},
+			output:  "\nError: failed to star todo. \nCheck `/tmp/godo/logs` for details.\n\n",
+			status:  exitAuth,
+		},
+		{
+			name:    "Rejected GODO_TOKEN",
+			handler: respond(http.StatusUnauthorized),
+			setup: func(t *testing.T) {
+				t.Setenv("GODO_TOKEN", "F6SB76ZCLKLJBHP7K7A6N2S7JM")
+			},
+			args: []string{"42"},
+			output: "\nError: failed to star todo. \nCheck `/tmp/godo/logs` for details.\n" +
+				"The token in GODO_TOKEN was rejected. Set it to a valid token, or unset it to use the saved token.\n\n",
+			status: exitAuth,
+		},
+		{
+			name:    "No token",
+			handler: respond(http.StatusOK),
+			setup: func(t *testing.T) {
+				if err := app.TokenManager.DeleteToken(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			args:   []string{"42"},
+			output: "\nError: failed to authenticate. \nCheck `/tmp/godo/logs` for details.\n\n",
+			status: exitAuth,
+		},
+		{
+			name:    "Server unreachable",
+			handler: respond(http.StatusOK),
+			setup: func(t *testing.T) {
+				ts := httptest.NewServer(respond(http.StatusOK))
+				ts.Close()
+				app.Config.APIBaseURL = ts.URL + "/v1"
+			},
+			args:   []string{"42"},
+			output: "\nError: failed to star todo. \nCheck `/tmp/godo/logs` for details.\n\n",
+			status: exitNetwork,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestCLIApplication(t, tt.handler)
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+
+			output, status := runCommand(t, starCmd, tt.args, nil)
+
+			assert.Equal(t, output, tt.output)
+			assert.Equal(t, status, tt.status)
+		})
+	}
+}
+
+func TestValidateArgsWithStatus(t *testing.T) {
+	root := &cobra.Command{Use: "godo"}
+	child := &cobra.Command{Use: "child", Args: cobra.ExactArgs(1), RunE: func(*cobra.Command, []string) error { return nil }}
+	root.AddCommand(child)
+
+	validateArgsWithStatus(root)
+
+	err := child.Args(child, nil)
+	assert.Equal(t, exitStatus(err), exitValidation)
+	assert.IsNil(t, child.Args(child, []string{"42"}))
+}
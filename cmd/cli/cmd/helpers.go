@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -8,22 +9,26 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/kvnloughead/godo/cmd/cli/config"
 	validator "github.com/kvnloughead/godo/internal"
 	"github.com/kvnloughead/godo/internal/data"
+	"golang.org/x/term"
 )
 
 // ReadTokenFromFile attempts to read the contents of the authentication token
-// from a file /home/username/.config/godo/.token. If the file exists and
+// from the .token file in the config directory. If the file exists and
 // contains a potentially valid token string, this string is returned.
 // Otherwise, an error is returned.
 func (app *CLIApplication) ReadTokenFromFile() (string, error) {
-	homeDir, err := os.UserHomeDir()
-
+	configDir, err := config.Dir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+		return "", err
 	}
-	tokenFile := filepath.Join(homeDir, ".config/godo", ".token")
+	tokenFile := filepath.Join(configDir, ".token")
 
 	if _, err := os.Stat(tokenFile); os.IsNotExist(err) {
 		return "", fmt.Errorf("token file doesn't exist: %w", err)
@@ -45,14 +50,9 @@ func (app *CLIApplication) ReadTokenFromFile() (string, error) {
 	return token, nil
 }
 
-// handleError handles CLI errors by logging the error with app.Logger.Error and
-// sending a user friendly message with fmt.Println.
-//
-// - logMsg is added as the msg field in the log.
-// - stdoutMsg is printed to stdout.
-// - err is added as the error field in the log.
-// - fields is variadic and is added as additional fields in the log.
-func (app *CLIApplication) handleError(logMsg, stdoutMsg string, err error, fields ...any) {
+// logError logs the error with app.Logger.Error. The error is added to the
+// fields as the error field.
+func (app *CLIApplication) logError(logMsg string, err error, fields ...any) {
 	// Convert fields to []any for slog.Error
 	logFields := make([]any, len(fields)+2) // +2 for error field
 	copy(logFields, fields)
@@ -61,17 +61,14 @@ func (app *CLIApplication) handleError(logMsg, stdoutMsg string, err error, fiel
 	logFields[len(fields)+1] = err
 
 	app.Logger.Error(logMsg, logFields...)
-	fmt.Println(stdoutMsg)
 }
 
-// handleAuthenticationError handles authentication related errors. It calls
-// handleError with the appropriate log message, error message, and additional
-// fields.
-func (app *CLIApplication) handleAuthenticationError(logMsg string, err error, fields ...any) {
-	app.handleError(logMsg,
-		"\nError: failed to authenticate. \nCheck `~/.config/godo/logs` for details.\n",
-		err,
-		fields...)
+// invalidateCache clears the cached todo lists after todos are changed. Errors
+// are only logged, since the change itself succeeded.
+func (app *CLIApplication) invalidateCache() {
+	if err := app.Cache.Invalidate(); err != nil {
+		app.Logger.Error("failed to invalidate cache", "error", err)
+	}
 }
 
 // createJSONRequest creates a new HTTP request with the given method, URL, and
@@ -138,28 +135,36 @@ func (app *CLIApplication) readResponse(resp *http.Response, handleError func(st
 		"status", resp.Status,
 		"body", responseBody)
 
+	// The rejected token is read from the file again for the next request.
+	if resp.StatusCode == http.StatusUnauthorized {
+		app.TokenManager.Forget()
+	}
+
 	return body, nil
 }
 
-// readTodoListResponse is logs condensed data from the GET /v1/todos endpoint.
+// readTodoListResponse logs condensed data from the GET /v1/todos endpoint.
 // Specifically, it logs the pagination data and the number of todos. The actual
-// todos are not logged.
+// todos are not logged. Responses without a list of todos, such as errors, are
+// logged in full. Like readResponse, it forgets the token if it was rejected.
 func (app *CLIApplication) readTodoListResponse(resp *http.Response, handleError func(string, error) error) ([]byte, error) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, handleError("failed to read response body", err)
 	}
 
-	if json.Valid(body) {
-		var data map[string]interface{}
-		if err := json.Unmarshal(body, &data); err != nil {
-			return nil, handleError("failed to parse JSON response", err)
-		}
+	var data map[string]interface{}
+	var todos []interface{}
+	var isList bool
+	if err := json.Unmarshal(body, &data); err == nil {
+		todos, isList = data["todos"].([]interface{})
+	}
 
+	if isList {
 		// Create condensed version for logging
 		logData := map[string]interface{}{
 			"pagination": data["paginationData"],
-			"todo_count": len(data["todos"].([]interface{})),
+			"todo_count": len(todos),
 		}
 
 		app.Logger.Info("received todos",
@@ -167,7 +172,83 @@ func (app *CLIApplication) readTodoListResponse(resp *http.Response, handleError
 			"url", resp.Request.URL,
 			"status", resp.Status,
 			"summary", logData)
+	} else {
+		// Other responses, such as errors, are logged in full.
+		app.Logger.Info("received response",
+			"method", resp.Request.Method,
+			"url", resp.Request.URL,
+			"status", resp.Status,
+			"body", string(body))
+	}
+
+	// The rejected token is read from the file again for the next request.
+	if resp.StatusCode == http.StatusUnauthorized {
+		app.TokenManager.Forget()
 	}
 
 	return body, nil
 }
+
+// parseFilterArgs splits command arguments into todo filters. Words beginning
+// with "@" are contexts and words beginning with "+" are projects. The prefix
+// is removed from each. The remaining words are joined with spaces to form the
+// search text.
+func parseFilterArgs(args []string) (text string, contexts []string, projects []string) {
+	var words []string
+	for _, arg := range args {
+		for _, word := range strings.Fields(arg) {
+			switch {
+			case len(word) > 1 && strings.HasPrefix(word, "@"):
+				contexts = append(contexts, word[1:])
+			case len(word) > 1 && strings.HasPrefix(word, "+"):
+				projects = append(projects, word[1:])
+			default:
+				words = append(words, word)
+			}
+		}
+	}
+	return strings.Join(words, " "), contexts, projects
+}
+
+// stdin is read for answers to prompts, and stdinIsTerminal reports whether
+// it is a terminal. They are replaced in tests.
+var (
+	stdin           io.Reader = os.Stdin
+	stdinIsTerminal           = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+)
+
+// confirm prints the prompt followed by "[y/N]" and reads a line from in. It
+// returns true only if the answer is "y" or "yes" (case insensitive).
+func confirm(in io.Reader, prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+// parseRelativeDuration parses a duration such as "7d" or "2w". In addition to
+// the units accepted by time.ParseDuration, "d" (days) and "w" (weeks) are
+// supported. Negative durations are rejected.
+func parseRelativeDuration(s string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	var d time.Duration
+	var err error
+	if unit, ok := units[s[max(len(s)-1, 0):]]; ok {
+		var n int
+		n, err = strconv.Atoi(s[:len(s)-1])
+		d = time.Duration(n) * unit
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q: use a positive number followed by a unit, such as 12h, 7d, or 2w", s)
+	}
+	return d, nil
+}
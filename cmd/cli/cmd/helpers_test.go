@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestParseRelativeDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{input: "7d", expected: 7 * 24 * time.Hour},
+		{input: "2w", expected: 14 * 24 * time.Hour},
+		{input: "12h", expected: 12 * time.Hour},
+		{input: "90m", expected: 90 * time.Minute},
+		{input: "0d", expected: 0},
+		{input: "d", wantErr: true},
+		{input: "-3d", wantErr: true},
+		{input: "7x", wantErr: true},
+		{input: "week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, err := parseRelativeDuration(tt.input)
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, d, tt.expected)
+		})
+	}
+}
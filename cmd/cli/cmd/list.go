@@ -2,13 +2,19 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
+	"github.com/kvnloughead/godo/cmd/cli/config"
 	"github.com/kvnloughead/godo/cmd/cli/interactive"
 	"github.com/kvnloughead/godo/cmd/cli/types"
 	"github.com/spf13/cobra"
@@ -20,34 +26,71 @@ var boolFlags = []types.QueryFlag{
 	{Flag: "only-archived", Param: "only-archived", Msg: "only show archived todos"},
 	{Flag: "done", Param: "done", Short: "d", Msg: "show only completed todos"},
 	{Flag: "undone", Param: "undone", Short: "u", Msg: "show only incomplete todos"},
+	{Flag: "starred", Param: "star", Short: "s", Msg: "show only starred todos"},
 }
 
 // listCmd displays todos and can be filtered by a plain text search pattern.
 // By default, the command enters an interactive mode. With the --plain flag
-// set, the todos are output in plain text.
+// set, the todos are output in plain text. The default can be changed with
+// default_list_mode in the config file.
 var listCmd = &cobra.Command{
-	Use:   "list [--all|--archived|--unarchived|--done|--undone|--plain] [pattern]",
+	Use:   "list [--all|--archived|--unarchived|--done|--undone|--starred|--plain|--interactive|--print0|--json] [--fields list] [--priority letter] [--context name]... [--project name]... [--since duration] [--offline] [pattern]",
 	Short: "List and manage todo items",
 	Long: `List and manage todo items for the authenticated user.
 
 Items can be filtered by a plain text search pattern. If the pattern contains multiple words it must be enclosed in quotes.
 
+Starred todos are always listed first, and are marked with a "*". Todos with
+notes are marked with "[note]". Run 'godo note -h' for more information.
+
 The --plain flag outputs the todos in plain text format suitable for scripts and piping to other commands. The output has the following columns:
 
   - id: the todo ID
   - completed: the todo completion status
   - text: the todo text
 
-Without the --plain flag, the command enters an interactive mode. When in
+Columns are separated by tabs. Tabs, newlines, other control characters, and
+backslashes in the text are escaped as \t, \n, \xHH, and \\, so each todo is
+always a single line with three columns.
+
+The --print0 flag outputs the same records without a header, each ending with a
+NUL byte rather than a newline, for use with 'xargs -0'.
+
+The --json flag outputs each todo as a JSON object on its own line.
+
+The --fields flag chooses the columns of plain text output, or the keys of JSON
+output, in order. It takes a comma-separated list of id, text, priority,
+completed, archived, contexts, projects, and created_at. Contexts and projects
+are separated by commas in plain text. By default, plain text has the id,
+completed, and text columns, and JSON has every field.
+
+Without the --plain flag, the command enters an interactive mode. To output
+plain text by default, set "default_list_mode" to "plain" in the config file,
+and use --interactive to enter interactive mode instead. When in
 interactive mode, the command will prompt for a command and one or more todo
 IDs. The command will then be applied to the corresponding todos.
 
 To see the available interactive-mode commands, run 'godo list' and press '?'.
 
+Each list is saved to a local cache. If the server can't be reached, or with the
+--offline flag, the cached list is shown instead, marked as "(cached, possibly
+stale)". Interactive mode isn't available for cached lists. The cache is cleared
+whenever todos are changed, and can be turned off by setting "disable_cache" to
+true in the config file.
+
 Examples:
     # List unarchived todos in plain text format
     godo list --plain
 
+    # Archive each completed todo
+    godo list --done --print0 | cut -z -f1 | xargs -0 -n1 godo archive
+
+    # List the ID and priority of each todo
+    godo list --plain --fields id,priority
+
+    # List todos as JSON, with only their IDs and contexts
+    godo list --json --fields id,contexts
+
     # List unarchived todos with @phone in the text in interactive mode
     godo list @phone
 
@@ -57,9 +100,22 @@ Examples:
     # List only archived and uncompleted todos
     godo list --only-archived --undone
 
+    # List todos created in the last week
+    godo list --since 7d
+
+    # List todos with priority A, and todos without a priority
+    godo list --priority A
+    godo list --priority none
+
+    # List todos with both the @phone and @home contexts, in the +work project
+    godo list --context phone --context home --project work
+
+    # List the cached todos, without contacting the server
+    godo list --offline --plain
+
 This command requires authentication. Run 'godo auth -h' for more information.`,
 	Args: cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get boolean flags that map to URL query parameters.
 		params := url.Values{}
 
@@ -70,8 +126,62 @@ This command requires authentication. Run 'godo auth -h' for more information.`,
 			}
 		}
 
+		// Add the priority filter, which is a letter or "none".
+		if priority, _ := cmd.Flags().GetString("priority"); priority != "" {
+			priority, err := parsePriorityFilter(priority)
+			if err != nil {
+				return validationError(err)
+			}
+			params.Add("priority", priority)
+		}
+
+		// Add the contexts and projects filters. The server matches todos that
+		// have all of them.
+		for flag, param := range map[string]string{"context": "contexts", "project": "projects"} {
+			if tags, _ := cmd.Flags().GetStringSlice(flag); len(tags) > 0 {
+				params.Add(param, strings.Join(trimTagPrefixes(tags), ","))
+			}
+		}
+
+		// Translate --since into a created_after date. Durations that aren't a
+		// whole number of days, such as 12h, are sent as a timestamp instead.
+		// Dates are kept otherwise, so that the URL, which todos are cached
+		// by, stays the same throughout the day.
+		if since, _ := cmd.Flags().GetString("since"); since != "" {
+			d, err := parseRelativeDuration(since)
+			if err != nil {
+				return validationError(err)
+			}
+			after := time.Now().Add(-d)
+			if d%(24*time.Hour) == 0 {
+				params.Add("created_after", after.Format(time.DateOnly))
+			} else {
+				params.Add("created_after", after.UTC().Format(time.RFC3339))
+			}
+		}
+
 		// Get other flags.
-		plain, _ := cmd.Flags().GetBool("plain")
+		plain, err := plainOutput(cmd)
+		if err != nil {
+			return validationError(err)
+		}
+		print0, _ := cmd.Flags().GetBool("print0")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		offline, _ := cmd.Flags().GetBool("offline")
+
+		// Choose the fields of plain text and JSON output.
+		fields := defaultPlainFields
+		if asJSON {
+			fields = listFields
+		}
+		if list, _ := cmd.Flags().GetString("fields"); list != "" {
+			if !plain && !print0 && !asJSON {
+				return validationError(errors.New("--fields can only be used with --plain, --print0, or --json"))
+			}
+			if fields, err = parseFields(list); err != nil {
+				return validationError(err)
+			}
+		}
 
 		// Set up interactive commands.
 		commands := map[string]*interactive.Command{
@@ -79,162 +189,217 @@ This command requires authentication. Run 'godo auth -h' for more information.`,
 				Name:    "delete",
 				Aliases: []string{"rm", "del"},
 				Action: func(todoIDs []int) error {
-					dummyCmd := &cobra.Command{}
-					for _, todoID := range todoIDs {
-						deleteCmd.Run(dummyCmd, []string{strconv.Itoa(todoID)})
+					if err := confirmDeletion(stdin, stdinIsTerminal(), todoIDs); err != nil {
+						return err
 					}
-					return nil
+					return interactiveAction("Deleted", func(token string, id int) (string, error) { return deleteTodo(token, id, false) })(todoIDs)
 				},
 			},
 			"done": {
 				Name:    "done",
 				Aliases: []string{"d", "complete"},
-				Action: func(todoIDs []int) error {
-					dummyCmd := &cobra.Command{}
-					for _, todoID := range todoIDs {
-						doneCmd.Run(dummyCmd, []string{strconv.Itoa(todoID)})
-					}
-					return nil
-				},
+				Action:  interactiveAction("Completed", doneTodo),
 			},
 			"undone": {
 				Name:    "undone",
 				Aliases: []string{"ud", "incomplete"},
-				Action: func(todoIDs []int) error {
-					dummyCmd := &cobra.Command{}
-					for _, todoID := range todoIDs {
-						undoneCmd.Run(dummyCmd, []string{strconv.Itoa(todoID)})
-					}
-					return nil
-				},
+				Action:  interactiveAction("Reopened", undoneTodo),
 			},
 			"archive": {
 				Name:    "archive",
 				Aliases: []string{"a"},
-				Action: func(todoIDs []int) error {
-					dummyCmd := &cobra.Command{}
-					for _, todoID := range todoIDs {
-						archiveCmd.Run(dummyCmd, []string{strconv.Itoa(todoID)})
-					}
-					return nil
-				},
+				Action:  interactiveAction("Archived", archiveTodo),
 			},
 			"unarchive": {
 				Name:    "unarchive",
 				Aliases: []string{"ua"},
-				Action: func(todoIDs []int) error {
-					dummyCmd := &cobra.Command{}
-					for _, todoID := range todoIDs {
-						unarchiveCmd.Run(dummyCmd, []string{strconv.Itoa(todoID)})
-					}
-					return nil
-				},
+				Action:  interactiveAction("Unarchived", unarchiveTodo),
 			},
 		}
-		interactive := interactive.New(commands)
+		mode := interactive.New(commands)
 
 		// Fetch todos and display them. If plain mode is enabled, the loop
 		// will exit after the todos are displayed. Otherwise, the loop will
 		// continue until the user exits interactive mode.
 		for {
-			todos, err := fetchTodos(args, params)
+			todos, savedAt, err := loadTodos(args, params, offline)
 			if err != nil {
-				return
+				return err
+			}
+
+			cached := !savedAt.IsZero()
+			if cached {
+				writeCacheMarker(os.Stderr, savedAt)
+			}
+
+			if print0 {
+				writePlainTodos(os.Stdout, todos, fields, true)
+				break
+			}
+
+			if asJSON {
+				if err := writeJSONTodos(os.Stdout, todos, fields); err != nil {
+					return app.cmdError("Failed to write todos as JSON", app.failureMsg("write todos"), err)
+				}
+				break
+			}
+
+			if len(todos) == 0 {
+				fmt.Println("No matches found.")
 			}
 
 			// Store the ordered todos for interactive mode
-			orderedTodos := displayTodos(todos, plain)
+			orderedTodos := displayTodos(todos, plain, fields)
 
-			if plain {
+			// The interactive commands need the server, so they aren't
+			// available for cached todos.
+			if plain || cached {
 				break
 			}
 
-			if err := interactive.Prompt(orderedTodos); err != nil {
+			if err := mode.Prompt(orderedTodos); err != nil {
+				if errors.Is(err, interactive.ErrQuit) {
+					break
+				}
 				fmt.Printf("Error: %v\n", err)
 			}
 		}
+
+		return nil
 	},
 }
 
-// fetchTodos retrieves todos from the API, handling authentication and
-// filtering.
-func fetchTodos(args []string, params url.Values) ([]types.Todo, error) {
-	// Add query parameters to the base URL.
-	baseURL := app.Config.APIBaseURL + "/todos"
+// listURL returns the URL to fetch the todos from, with the filters from the
+// arguments and query parameters.
+func listURL(args []string, params url.Values) string {
 	if len(args) > 0 {
 		searchText := strings.ReplaceAll(args[0], "+", "%2B")
 		searchPattern := url.QueryEscape(searchText)
-		params.Add("text", searchPattern)
+		params.Set("text", searchPattern)
+	}
+	return app.Config.APIBaseURL + "/todos?" + params.Encode()
+}
+
+// loadTodos returns the todos from the API, or from the cache if offline is
+// true or the API can't be reached. If the todos came from the cache, savedAt
+// is the time they were cached. Otherwise, it is the zero time.
+func loadTodos(args []string, params url.Values, offline bool) (todos []types.Todo, savedAt time.Time, err error) {
+	baseURL := listURL(args, params)
+
+	if !offline {
+		todos, err = fetchTodos(baseURL)
+		if err == nil || exitStatus(err) != exitNetwork {
+			return todos, time.Time{}, err
+		}
+		app.Logger.Warn("Failed to reach API, using cached todos", "url", baseURL)
+	}
+
+	entry, cacheErr := app.Cache.Load(baseURL)
+	if cacheErr != nil {
+		if offline {
+			return nil, time.Time{}, cacheErr
+		}
+		// Report the network error, rather than the missing cache.
+		return nil, time.Time{}, err
+	}
+	return entry.Todos, entry.SavedAt, nil
+}
+
+// writeCacheMarker writes a line to w marking the todos as cached.
+func writeCacheMarker(w io.Writer, savedAt time.Time) {
+	fmt.Fprintf(w, "(cached, possibly stale) Todos as of %s\n", savedAt.Local().Format("Mon Jan 2 15:04:05 2006"))
+}
+
+// fetchTodos retrieves todos from the API at baseURL, and saves them to the
+// cache.
+func fetchTodos(baseURL string) ([]types.Todo, error) {
+	todoResponse, err := getTodoList(baseURL)
+	if err != nil {
+		return nil, err
 	}
-	baseURL = baseURL + "?" + params.Encode()
 
-	stdoutMsg := "\nError: failed to list todo items. \nCheck `~/.config/godo/logs` for details.\n"
+	if err := app.Cache.Save(baseURL, todoResponse.Todos, time.Now()); err != nil {
+		app.Logger.Error("failed to save todos to cache", "error", err)
+	}
+
+	return todoResponse.Todos, nil
+}
+
+// getTodoList sends a GET request for a page of todos to the URL, and returns
+// the response.
+func getTodoList(baseURL string) (types.TodoResponse, error) {
+	stdoutMsg := app.failureMsg("list todo items")
 
 	// handleError captures parameters that are common to all errors
 	handleError := func(logMsg string, err error) error {
-		app.handleError(logMsg, stdoutMsg, err,
+		return app.cmdError(logMsg, stdoutMsg, err,
 			"method", http.MethodGet,
 			"url", baseURL)
-		return err
 	}
 
 	token, err := app.TokenManager.LoadToken()
 	if err != nil {
-		app.handleAuthenticationError("Failed to read token", err)
-		return nil, err
+		return types.TodoResponse{}, app.authError("Failed to read token", err)
 	}
 
 	req, err := app.createJSONRequest(http.MethodGet, baseURL, nil)
 	if err != nil {
-		return nil, handleError("Failed to create request", err)
+		return types.TodoResponse{}, handleError("Failed to create request", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+string(token))
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, handleError("Failed to send request", err)
+		return types.TodoResponse{}, handleError("Failed to send request", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response body and log it
 	body, err := app.readTodoListResponse(resp, handleError)
 	if err != nil {
-		return nil, err
+		return types.TodoResponse{}, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, handleError("Failed to retrieve todos", fmt.Errorf("response status: %s", resp.Status))
+		return types.TodoResponse{}, handleError("Failed to retrieve todos", responseError(resp))
 	}
 
 	var todoResponse types.TodoResponse
 	if err := json.Unmarshal(body, &todoResponse); err != nil {
-		return nil, handleError("Failed to unmarshal JSON", err)
+		return types.TodoResponse{}, handleError("Failed to unmarshal JSON", err)
 	}
 
-	if len(todoResponse.Todos) == 0 {
-		fmt.Println("No matches found.")
-		return nil, nil
+	return todoResponse, nil
+}
+
+// plainOutput reports whether the list command should output plain text
+// rather than enter interactive mode. The --plain and --interactive flags
+// override app.Config.DefaultListMode. An error is returned if neither flag
+// is given and the setting isn't one of config.ListModes.
+func plainOutput(cmd *cobra.Command) (bool, error) {
+	if plain, _ := cmd.Flags().GetBool("plain"); plain {
+		return true, nil
+	}
+	if interact, _ := cmd.Flags().GetBool("interactive"); interact {
+		return false, nil
 	}
 
-	return todoResponse.Todos, nil
+	mode := app.Config.DefaultListMode
+	if mode != "" && !slices.Contains(config.ListModes, mode) {
+		return false, fmt.Errorf("invalid default_list_mode %q in the config file, must be one of %s", mode, strings.Join(config.ListModes, ", "))
+	}
+	return mode == config.ListModePlain, nil
 }
 
 // displayTodos outputs todos in either plain text or interactive mode. In plain
 // text mode, the output is suitable for scripts and piping to other commands.
-// It has the following columns:
-//
-//   - id: the todo ID
-//   - completed: the todo completion status
-//   - text: the todo text
+// See writePlainTodos for details, and for fields.
 //
 // In interactive mode, the output is formatted for use with the interactive //
 // package.
-func displayTodos(todos []types.Todo, plain bool) []types.Todo {
+func displayTodos(todos []types.Todo, plain bool, fields []string) []types.Todo {
 	if plain {
-		fmt.Println("id\tcompleted\ttext")
-		for _, todo := range todos {
-			fmt.Printf("%d\t%t\t\t%s\n", todo.ID, todo.Completed, todo.Text)
-		}
+		writePlainTodos(os.Stdout, todos, fields, false)
 		return todos
 	} else {
 		// Split todos into active and archived
@@ -247,10 +412,11 @@ func displayTodos(todos []types.Todo, plain bool) []types.Todo {
 			}
 		}
 
-		// Sort each slice by completion status (uncompleted first)
+		// Sort each slice by completion status (uncompleted first). The sort is
+		// stable so that starred todos stay ahead of the others.
 		sortTodos := func(todos []types.Todo) {
-			sort.Slice(todos, func(i, j int) bool {
-				return !todos[i].Completed
+			sort.SliceStable(todos, func(i, j int) bool {
+				return !todos[i].Completed && todos[j].Completed
 			})
 		}
 		sortTodos(active)
@@ -265,10 +431,18 @@ func displayTodos(todos []types.Todo, plain bool) []types.Todo {
 			if len(todos) > 0 {
 				fmt.Println("\n" + heading + ":\n")
 				for _, todo := range todos {
+					star := " "
+					if todo.Starred {
+						star = "*"
+					}
+					note := ""
+					if todo.Note != "" {
+						note = " \033[90m[note]\033[0m"
+					}
 					if todo.Completed {
-						fmt.Printf("%2d. [\033[90m✓\033[0m] \033[90m%s\033[0m\n", displayIndex, todo.Text)
+						fmt.Printf("%2d.%s[\033[90m✓\033[0m] \033[90m%s\033[0m%s\n", displayIndex, star, todo.Text, note)
 					} else {
-						fmt.Printf("%2d. [ ] %s\n", displayIndex, todo.Text)
+						fmt.Printf("%2d.%s[ ] %s%s\n", displayIndex, star, todo.Text, note)
 					}
 					displayIndex++
 				}
@@ -281,10 +455,179 @@ func displayTodos(todos []types.Todo, plain bool) []types.Todo {
 	}
 }
 
+// listFields are the fields that the --fields flag of the list command can
+// choose, in the order that JSON output has them by default.
+var listFields = []string{"id", "text", "priority", "completed", "archived", "contexts", "projects", "created_at"}
+
+// defaultPlainFields are the fields of plain text output without --fields.
+var defaultPlainFields = []string{"id", "completed", "text"}
+
+// parseFields parses the comma-separated list of fields given to --fields.
+// Each field must be one of listFields.
+func parseFields(list string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(listFields, field) {
+			return nil, fmt.Errorf("unknown field %q, must be one of %s", field, strings.Join(listFields, ", "))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// todoField returns the value of the field of todo, which must be one of
+// listFields. Contexts and projects are never nil, so that they are written
+// as [] in JSON.
+func todoField(todo types.Todo, field string) any {
+	switch field {
+	case "id":
+		return todo.ID
+	case "text":
+		return todo.Text
+	case "priority":
+		return todo.Priority
+	case "completed":
+		return todo.Completed
+	case "archived":
+		return todo.Archived
+	case "contexts":
+		return append([]string{}, todo.Contexts...)
+	case "projects":
+		return append([]string{}, todo.Projects...)
+	case "created_at":
+		return todo.CreatedAt
+	default:
+		panic("unknown list field: " + field)
+	}
+}
+
+// writePlainTodos writes the todos to w, one record per todo. Each record has
+// the fields, separated by tabs. See listFields. By default, they are:
+//
+//   - id: the todo ID
+//   - completed: the todo completion status
+//   - text: the todo text
+//
+// By default, records end with a newline, and are preceded by a header line.
+// If print0 is true, records end with a NUL byte instead, for use with
+// "xargs -0", and there is no header.
+//
+// Control characters and backslashes in the text are escaped, so that each
+// record always has exactly one column per field. See escapeControlChars.
+// Contexts and projects are separated by commas.
+func writePlainTodos(w io.Writer, todos []types.Todo, fields []string, print0 bool) {
+	end := "\n"
+	if print0 {
+		end = "\x00"
+	} else {
+		fmt.Fprint(w, strings.Join(fields, "\t")+end)
+	}
+
+	values := make([]string, len(fields))
+	for _, todo := range todos {
+		for i, field := range fields {
+			switch v := todoField(todo, field).(type) {
+			case string:
+				values[i] = escapeControlChars(v)
+			case []string:
+				values[i] = escapeControlChars(strings.Join(v, ","))
+			default:
+				values[i] = fmt.Sprint(v)
+			}
+		}
+		fmt.Fprint(w, strings.Join(values, "\t")+end)
+	}
+}
+
+// writeJSONTodos writes the todos to w as JSON, one object per line. Each
+// object has the fields as keys, in order. See listFields.
+func writeJSONTodos(w io.Writer, todos []types.Todo, fields []string) error {
+	for _, todo := range todos {
+		// The object is built by hand, since maps are marshaled with their keys
+		// sorted.
+		var b strings.Builder
+		b.WriteString("{")
+		for i, field := range fields {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			value, err := json.Marshal(todoField(todo, field))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&b, "%q:%s", field, value)
+		}
+		b.WriteString("}")
+
+		if _, err := fmt.Fprintln(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapeControlChars returns s with each backslash and control character
+// replaced by an escape sequence. Tabs, newlines, and carriage returns become
+// \t, \n, and \r, a backslash becomes \\, and any other control character
+// becomes \xHH.
+func escapeControlChars(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// trimTagPrefixes returns the tags without a leading "@" or "+", so that
+// contexts and projects can be given as they're written in a todo's text.
+func trimTagPrefixes(tags []string) []string {
+	trimmed := make([]string, len(tags))
+	for i, tag := range tags {
+		trimmed[i] = strings.TrimLeft(tag, "@+")
+	}
+	return trimmed
+}
+
+// parsePriorityFilter returns the value of the priority query parameter for
+// the --priority flag, which is either a single letter, in either case, or
+// "none".
+func parsePriorityFilter(priority string) (string, error) {
+	if strings.EqualFold(priority, "none") {
+		return "none", nil
+	}
+	if len(priority) != 1 || !unicode.IsLetter(rune(priority[0])) {
+		return "", fmt.Errorf(`priority must be a letter from A to Z, or "none"`)
+	}
+	return strings.ToUpper(priority), nil
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 
 	listCmd.Flags().BoolP("plain", "p", false, "output in plain text to stdout")
+	listCmd.Flags().BoolP("interactive", "i", false, "enter interactive mode, even if default_list_mode is plain")
+	listCmd.Flags().BoolP("print0", "0", false, "output plain text records ending with NUL bytes, for xargs -0")
+	listCmd.Flags().Bool("json", false, "output each todo as a JSON object on its own line")
+	listCmd.Flags().String("fields", "", "comma-separated fields of plain text or JSON output, such as id,priority")
+	listCmd.Flags().String("since", "", "only show todos created within a duration, such as 12h, 7d, or 2w")
+	listCmd.Flags().StringSliceP("context", "C", nil, "only show todos with the context (repeatable)")
+	listCmd.Flags().StringSliceP("project", "P", nil, "only show todos in the project (repeatable)")
+	listCmd.Flags().StringP("priority", "r", "", `only show todos with a priority (A to Z), or "none" for todos without one`)
+	listCmd.Flags().Bool("offline", false, "show the cached todos, without contacting the server")
 
 	// Add boolean flags that map to URL query parameters.
 	for _, f := range boolFlags {
@@ -298,4 +641,5 @@ func init() {
 	// Mark flags as mutually exclusive.
 	listCmd.MarkFlagsMutuallyExclusive("only-archived", "include-archived")
 	listCmd.MarkFlagsMutuallyExclusive("done", "undone")
+	listCmd.MarkFlagsMutuallyExclusive("plain", "print0", "interactive", "json")
 }
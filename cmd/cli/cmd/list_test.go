@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/cmd/cli/cache"
+	"github.com/kvnloughead/godo/cmd/cli/config"
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/spf13/pflag"
+)
+
+func TestWritePlainTodos(t *testing.T) {
+	todos := []types.Todo{
+		{ID: 1, Text: "call mom\tabout dinner"},
+		{ID: 2, Text: "line one\nline two \\ back\x07", Completed: true},
+	}
+
+	t.Run("Plain", func(t *testing.T) {
+		var b bytes.Buffer
+		writePlainTodos(&b, todos, defaultPlainFields, false)
+
+		lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+		assert.Equal(t, len(lines), 3)
+		assert.Equal(t, lines[0], "id\tcompleted\ttext")
+
+		// Each line has exactly three fields, despite the tab and newline in the
+		// text.
+		for _, line := range lines {
+			assert.Equal(t, len(strings.Split(line, "\t")), 3)
+		}
+		assert.Equal(t, lines[1], `1	false	call mom\tabout dinner`)
+		assert.Equal(t, lines[2], `2	true	line one\nline two \\ back\x07`)
+	})
+
+	t.Run("Print0", func(t *testing.T) {
+		var b bytes.Buffer
+		writePlainTodos(&b, todos, defaultPlainFields, true)
+
+		out := b.String()
+		assert.Equal(t, strings.HasSuffix(out, "\x00"), true)
+
+		records := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+		assert.Equal(t, len(records), 2)
+		for _, record := range records {
+			fields := strings.Split(record, "\t")
+			assert.Equal(t, len(fields), 3)
+			assert.Equal(t, strings.Contains(record, "\n"), false)
+		}
+		assert.Equal(t, records[0], `1	false	call mom\tabout dinner`)
+	})
+}
+
+func TestWriteAgenda(t *testing.T) {
+	var b bytes.Buffer
+	writeAgenda(&b, types.Agenda{
+		Overdue:  []types.Todo{{ID: 1, Text: "pay rent", DueDate: "2024-03-01T00:00:00Z"}},
+		DueToday: []types.Todo{{ID: 12, Text: "call mom", Priority: "A", DueDate: "2024-03-10T00:00:00Z"}},
+	})
+
+	out := b.String()
+	assert.StringContains(t, out, "Overdue:\n\n   1.     pay rent \033[90m[due 2024-03-01]\033[0m\n")
+	assert.StringContains(t, out, "Due today:\n\n  12. (A) call mom")
+	assert.Equal(t, strings.Contains(out, "High priority"), false)
+
+	b.Reset()
+	writeAgenda(&b, types.Agenda{})
+	assert.Equal(t, b.String(), "Nothing on your agenda.\n")
+}
+
+func TestLoadTodosCache(t *testing.T) {
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"todos": [{"id": 1, "text": "call mom"}]}`)
+	}))
+	params := url.Values{"done": {"true"}}
+
+	// Offline, nothing has been cached yet.
+	_, _, err := loadTodos(nil, params, true)
+	assert.Equal(t, errors.Is(err, cache.ErrNotCached), true)
+
+	// Fetching the todos caches them.
+	todos, savedAt, err := loadTodos(nil, params, false)
+	assert.IsNil(t, err)
+	assert.Equal(t, savedAt.IsZero(), true)
+	assert.Equal(t, len(todos), 1)
+
+	todos, savedAt, err = loadTodos(nil, params, true)
+	assert.IsNil(t, err)
+	assert.Equal(t, savedAt.IsZero(), false)
+	assert.Equal(t, todos[0].Text, "call mom")
+
+	// Lists with other filters aren't cached.
+	_, _, err = loadTodos([]string{"mom"}, params, true)
+	assert.Equal(t, errors.Is(err, cache.ErrNotCached), true)
+
+	// When the server can't be reached, the cache is used.
+	server := app.Config.APIBaseURL
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+	app.Config.APIBaseURL = ts.URL + "/v1"
+	_, _, err = loadTodos(nil, params, false)
+	assert.Equal(t, exitStatus(err), exitNetwork)
+
+	app.Cache.Save(listURL(nil, params), []types.Todo{{ID: 2, Text: "buy milk"}}, time.Now())
+	todos, savedAt, err = loadTodos(nil, params, false)
+	assert.IsNil(t, err)
+	assert.Equal(t, savedAt.IsZero(), false)
+	assert.Equal(t, todos[0].Text, "buy milk")
+
+	// Changing a todo clears the cache.
+	app.Config.APIBaseURL = server
+	_, err = doneTodo("token", 1)
+	assert.IsNil(t, err)
+	_, _, err = loadTodos(nil, params, true)
+	assert.Equal(t, errors.Is(err, cache.ErrNotCached), true)
+}
+
+func TestGetTodoListRejectedToken(t *testing.T) {
+	var saved string
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer "+saved {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error": "invalid or missing authentication token"}`)
+			return
+		}
+		io.WriteString(w, `{"todos": [{"id": 1, "text": "call mom"}]}`)
+	}))
+	saved, err := app.TokenManager.LoadToken()
+	assert.IsNil(t, err)
+
+	// An error body, which has no todos, doesn't stop the response from
+	// being logged.
+	_, err = getTodoList(listURL(nil, url.Values{}))
+	assert.Equal(t, exitStatus(err), exitAuth)
+
+	// The rejected token was forgotten, so the replaced one is read.
+	err = os.WriteFile(app.TokenManager.TokenFile(), []byte("new-token"), 0600)
+	assert.IsNil(t, err)
+	resp, err := getTodoList(listURL(nil, url.Values{}))
+	assert.IsNil(t, err)
+	assert.Equal(t, len(resp.Todos), 1)
+}
+
+func TestWriteCacheMarker(t *testing.T) {
+	var b bytes.Buffer
+	writeCacheMarker(&b, time.Date(2026, 10, 15, 9, 30, 0, 0, time.Local))
+
+	assert.Equal(t, b.String(), "(cached, possibly stale) Todos as of Thu Oct 15 09:30:00 2026\n")
+}
+
+func TestListPriorityFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority string
+		param    string
+		status   int
+	}{
+		{name: "Letter", priority: "A", param: "A"},
+		{name: "Lower case letter", priority: "b", param: "B"},
+		{name: "None", priority: "None", param: "none"},
+		{name: "Invalid", priority: "AB", status: exitValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query url.Values
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Query()
+				io.WriteString(w, `{"todos": []}`)
+			}))
+
+			_, status := runCommand(t, listCmd, nil, map[string]string{"priority": tt.priority, "plain": "true"})
+			assert.Equal(t, status, tt.status)
+			assert.Equal(t, query.Get("priority"), tt.param)
+		})
+	}
+}
+
+func TestListSinceFlag(t *testing.T) {
+	var query url.Values
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		io.WriteString(w, `{"todos": []}`)
+	}))
+
+	_, status := runCommand(t, listCmd, nil, map[string]string{"since": "12h", "plain": "true"})
+	assert.Equal(t, status, 0)
+
+	// Durations shorter than a day are sent as a timestamp.
+	after, err := time.Parse(time.RFC3339, query.Get("created_after"))
+	assert.IsNil(t, err)
+	ago := time.Since(after)
+	assert.Equal(t, ago > 11*time.Hour && ago < 13*time.Hour, true)
+
+	// Whole days are sent as a date.
+	_, status = runCommand(t, listCmd, nil, map[string]string{"since": "7d", "plain": "true"})
+	assert.Equal(t, status, 0)
+	assert.Equal(t, query.Get("created_after"), time.Now().Add(-7*24*time.Hour).Format(time.DateOnly))
+}
+
+func TestListTagFlags(t *testing.T) {
+	var query url.Values
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		io.WriteString(w, `{"todos": []}`)
+	}))
+
+	// Each repeated flag is added to the same list.
+	for _, f := range []struct{ name, value string }{
+		{"context", "a"}, {"context", "@b"}, {"project", "+work"},
+	} {
+		if err := listCmd.Flags().Set(f.name, f.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, name := range []string{"context", "project"} {
+			f := listCmd.Flags().Lookup(name)
+			f.Value.(pflag.SliceValue).Replace(nil)
+			f.Changed = false
+		}
+	})
+
+	_, status := runCommand(t, listCmd, nil, map[string]string{"plain": "true"})
+	assert.Equal(t, status, 0)
+	assert.Equal(t, query.Get("contexts"), "a,b")
+	assert.Equal(t, query.Get("projects"), "work")
+}
+
+func TestPlainOutput(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  string
+		flags map[string]string
+		want  bool
+		err   string
+	}{
+		{name: "No config", want: false},
+		{name: "Interactive config", mode: config.ListModeInteractive, want: false},
+		{name: "Plain config", mode: config.ListModePlain, want: true},
+		{name: "Plain flag", flags: map[string]string{"plain": "true"}, want: true},
+		{name: "Interactive flag overrides config", mode: config.ListModePlain, flags: map[string]string{"interactive": "true"}, want: false},
+		{name: "Invalid config", mode: "fancy", err: `invalid default_list_mode "fancy"`},
+		{name: "Flag overrides invalid config", mode: "fancy", flags: map[string]string{"plain": "true"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestCLIApplication(t, http.NotFoundHandler())
+			app.Config.DefaultListMode = tt.mode
+
+			for name, value := range tt.flags {
+				f := listCmd.Flags().Lookup(name)
+				t.Cleanup(func() { f.Value.Set(f.DefValue); f.Changed = false })
+				if err := listCmd.Flags().Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plain, err := plainOutput(listCmd)
+			if tt.err != "" {
+				assert.StringContains(t, err.Error(), tt.err)
+				return
+			}
+			assert.IsNil(t, err)
+			assert.Equal(t, plain, tt.want)
+		})
+	}
+}
+
+func TestListDefaultModePlain(t *testing.T) {
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"todos": [{"id": 1, "text": "call mom"}]}`)
+	}))
+	app.Config.DefaultListMode = config.ListModePlain
+
+	// Without flags, the todos are written as plain text, and there is no
+	// prompt.
+	output, status := runCommand(t, listCmd, nil, nil)
+	assert.Equal(t, status, 0)
+	assert.Equal(t, output, "id\tcompleted\ttext\n1\tfalse\tcall mom\n")
+}
+
+func TestListFieldsFlag(t *testing.T) {
+	const body = `{"todos": [
+		{"id": 1, "text": "call mom", "priority": "A", "contexts": ["phone", "home"]},
+		{"id": 2, "text": "buy milk"}
+	]}`
+
+	tests := []struct {
+		name   string
+		flags  map[string]string
+		output string
+		status int
+	}{
+		{
+			name:   "Plain",
+			flags:  map[string]string{"plain": "true", "fields": "id,priority"},
+			output: "id\tpriority\n1\tA\n2\t\n",
+		},
+		{
+			name:   "Plain order",
+			flags:  map[string]string{"plain": "true", "fields": "priority, id,contexts"},
+			output: "priority\tid\tcontexts\nA\t1\tphone,home\n\t2\t\n",
+		},
+		{
+			name:   "JSON",
+			flags:  map[string]string{"json": "true", "fields": "id,priority,contexts"},
+			output: `{"id":1,"priority":"A","contexts":["phone","home"]}` + "\n" + `{"id":2,"priority":"","contexts":[]}` + "\n",
+		},
+		{
+			name:   "Unknown field",
+			flags:  map[string]string{"plain": "true", "fields": "id,due"},
+			output: `Error: unknown field "due"`,
+			status: exitValidation,
+		},
+		{
+			name:   "Interactive",
+			flags:  map[string]string{"fields": "id"},
+			output: "Error: --fields can only be used with --plain, --print0, or --json",
+			status: exitValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, body)
+			}))
+
+			output, status := runCommand(t, listCmd, nil, tt.flags)
+			assert.Equal(t, status, tt.status)
+			if tt.status == 0 {
+				assert.Equal(t, output, tt.output)
+			} else {
+				assert.StringContains(t, output, tt.output)
+			}
+		})
+	}
+}
+
+func TestListJSONDefaultFields(t *testing.T) {
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"todos": [{"id": 1, "text": "call mom", "created_at": "2026-10-15T09:30:00Z"}]}`)
+	}))
+
+	output, status := runCommand(t, listCmd, nil, map[string]string{"json": "true"})
+	assert.Equal(t, status, 0)
+	assert.Equal(t, output, `{"id":1,"text":"call mom","priority":"","completed":false,"archived":false,"contexts":[],"projects":[],"created_at":"2026-10-15T09:30:00Z"}`+"\n")
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kvnloughead/godo/cmd/cli/config"
+	"github.com/kvnloughead/godo/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+// logsPollInterval is how often the log file is checked for new lines with
+// the --follow flag.
+const logsPollInterval = 500 * time.Millisecond
+
+// logsCmd prints the end of the CLI's log file.
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the most recent lines of the log file",
+	Long: `
+Show the most recent lines of the CLI's log file, which is in the logs directory
+of the config directory. When a command fails, the details are in the log.
+
+Examples:
+
+    # Show the last 20 lines
+    godo logs
+
+    # Show the last 100 lines, and then print new lines as they are written
+    godo logs --lines 100 --follow`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lines, _ := cmd.Flags().GetInt("lines")
+		follow, _ := cmd.Flags().GetBool("follow")
+		if lines < 0 {
+			return validationError(errors.New("--lines must not be negative"))
+		}
+
+		configDir, err := config.Dir()
+		if err != nil {
+			return err
+		}
+		path := logger.LogFile(configDir)
+		offset, err := writeLastLines(os.Stdout, path, lines)
+		if err != nil {
+			return err
+		}
+		if !follow {
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return followLog(ctx, os.Stdout, path, offset, logsPollInterval)
+	},
+}
+
+// writeLastLines writes the last n lines of the file at path to w. It returns
+// the size of the file, so that lines written later can be followed.
+func writeLastLines(w io.Writer, path string, n int) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("no log file at %s", path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// Keep the last n lines in a ring buffer, so that large logs aren't read
+	// into memory.
+	ring := make([]string, n)
+	var count int
+	var offset int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		offset += int64(len(line))
+		if line != "" && n > 0 {
+			ring[count%n] = line
+			count++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for i := max(count-n, 0); i < count; i++ {
+		io.WriteString(w, ring[i%n])
+	}
+	return offset, nil
+}
+
+// followLog checks the file at path for data after offset every interval, and
+// writes it to w, until ctx is done. If the file is truncated, it is followed
+// from the beginning.
+func followLog(ctx context.Context, w io.Writer, path string, offset int64, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// The file may be replaced while it's being followed.
+			continue
+		}
+		if info.Size() < offset {
+			offset = 0
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		written, err := io.Copy(w, io.NewSectionReader(f, offset, info.Size()-offset))
+		f.Close()
+		offset += written
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().IntP("lines", "n", 20, "the number of lines to show")
+	logsCmd.Flags().BoolP("follow", "f", false, "print new lines as they are written")
+}
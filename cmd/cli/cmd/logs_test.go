@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestWriteLastLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	content := "line 1\nline 2\nline 3\nline 4\nline 5"
+	err := os.WriteFile(path, []byte(content), 0644)
+	assert.IsNil(t, err)
+
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{name: "Last lines", n: 2, want: "line 4\nline 5"},
+		{name: "More lines than the file", n: 20, want: content},
+		{name: "No lines", n: 0, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			offset, err := writeLastLines(&buf, path, tt.n)
+			assert.IsNil(t, err)
+			assert.Equal(t, buf.String(), tt.want)
+			assert.Equal(t, offset, int64(len(content)))
+		})
+	}
+
+	t.Run("Missing file", func(t *testing.T) {
+		var buf strings.Builder
+		_, err := writeLastLines(&buf, filepath.Join(t.TempDir(), "app.log"), 20)
+		assert.StringContains(t, err.Error(), "no log file at")
+	})
+}
+
+func TestFollowLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	err := os.WriteFile(path, []byte("old line\n"), 0644)
+	assert.IsNil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf strings.Builder
+	done := make(chan error)
+	go func() {
+		done <- followLog(ctx, &buf, path, int64(len("old line\n")), 5*time.Millisecond)
+	}()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.IsNil(t, err)
+	f.WriteString("new line\n")
+	f.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.IsNil(t, <-done)
+
+	// Only the lines written after the offset are printed.
+	assert.Equal(t, buf.String(), "new line\n")
+}
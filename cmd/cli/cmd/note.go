@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// noteCmd sets or clears the note attached to a todo item.
+var noteCmd = &cobra.Command{
+	Use:   "note [--clear] <id> [text]...",
+	Short: "Attach a note to a todo item",
+	Long: `
+Attach a note to a todo item, replacing any existing note. Notes are free-form
+text, up to 10,000 bytes long, that is stored separately from the todo's
+single line of todo.txt text. For example:
+
+    # Attach a note to todo #42
+    godo note 42 "Ask about the holiday schedule"
+
+    # Remove the note from todo #42
+    godo note --clear 42
+
+Todos with notes are marked with "[note]" in the output of 'godo list'.
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if clear, _ := cmd.Flags().GetBool("clear"); clear {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		if len(args) < 2 {
+			return errors.New("requires an ID and the text of the note")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID(args[0])
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("%s/todos/%d", app.Config.APIBaseURL, id)
+		stdoutMsg := app.failureMsg("update note")
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodPatch,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		// An empty note clears the existing note.
+		note := strings.Join(args[1:], " ")
+		payload := map[string]any{"note": note}
+
+		req, err := app.createJSONRequest(http.MethodPatch, url, payload)
+		if err != nil {
+			return handleError("Failed to create request", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return handleError("Failed to send request", err)
+		}
+		defer resp.Body.Close()
+
+		// Read response body and log it
+		_, err = app.readResponse(resp, handleError)
+		if err != nil {
+			return err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			app.invalidateCache()
+		case http.StatusNotFound:
+			return errTodoNotFound
+		case http.StatusUnprocessableEntity:
+			return validationError(errors.New("note must be no more than 10,000 bytes"))
+		default:
+			return handleError("Failed to update note", responseError(resp))
+		}
+
+		if note == "" {
+			fmt.Println("Note removed")
+		} else {
+			fmt.Println("Note saved")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(noteCmd)
+
+	noteCmd.Flags().Bool("clear", false, "remove the todo's note")
+}
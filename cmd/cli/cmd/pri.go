@@ -1,30 +1,52 @@
 package cmd
 
 import (
-	"fmt"
+	"errors"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+// priCmd sets the priority of a todo item.
 var priCmd = &cobra.Command{
-	Use:   "pri",
-	Short: "A brief description of your command",
-	Long:  `TODO - add long help text`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("pri called")
+	Use:   "pri <id> <priority>",
+	Short: "Set the priority of a todo item",
+	Long: `
+Set the priority of a todo item. The priority is a letter from A to Z, with A
+being the highest. For example:
+
+    # Give todo #42 the highest priority
+    godo pri 42 A
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseID(args[0])
+		if err == nil {
+			var priority string
+			priority, err = parsePriority(args[1])
+			if err == nil {
+				_, err = withToken(id, func(token string, id int) (string, error) {
+					return "", patchTodo(token, id, map[string]any{"priority": priority}, "set todo priority")
+				})
+			}
+		}
+		return reportTodoResult(cmd, id, "pri", "Todo priority set", err)
 	},
 }
 
+// parsePriority parses a priority argument, which is a single letter. Lower
+// case letters are converted to upper case.
+func parsePriority(arg string) (string, error) {
+	p := strings.ToUpper(arg)
+	if len(p) != 1 || p[0] < 'A' || p[0] > 'Z' {
+		return "", validationError(errors.New("priority must be a letter from A to Z"))
+	}
+	return p, nil
+}
+
 func init() {
 	rootCmd.AddCommand(priCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// priCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// priCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	addJSONFlag(priCmd)
 }
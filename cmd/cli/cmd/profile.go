@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kvnloughead/godo/cmd/cli/config"
+	"github.com/spf13/cobra"
+)
+
+// profileCmd groups the commands that manage the profiles in the config file.
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage config profiles",
+	Long: `
+Manage the profiles in the config file. A profile is a named set of settings,
+such as the API base URL of a local server or of production:
+
+    {
+        "profiles": {
+            "dev": { "api_base_url": "http://localhost:4000/v1" },
+            "prod": { "api_base_url": "https://godo.kevinloughead.com/v1" }
+        },
+        "active": "dev"
+    }
+
+The active profile is used unless another is chosen with the --profile flag.
+Without profiles, the api_base_url at the top level of the file is used.`,
+}
+
+// profileUseCmd makes a profile the active one.
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Make a profile the active one",
+	Long: `
+Make the profile the active one in the config file, so that it is used by
+later commands. For example:
+
+    # Use the local server
+    godo profile use dev
+
+    # Run a single command with another profile
+    godo --profile prod list`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		file, err := config.ConfigFile(cfgFile)
+		if err != nil {
+			return err
+		}
+		err = config.SetActiveProfile(file, name)
+		if errors.Is(err, config.ErrUnknownProfile) {
+			return validationError(err)
+		}
+		if err != nil {
+			return app.cmdError("Failed to set active profile", app.failureMsg("update the config file"), err,
+				"profile", name)
+		}
+
+		fmt.Printf("Now using profile %q\n", name)
+		return nil
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileUseCmd)
+	rootCmd.AddCommand(profileCmd)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kvnloughead/godo/cmd/cli/config"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestProfileUse(t *testing.T) {
+	newTestCLIApplication(t, http.NotFoundHandler())
+
+	path := filepath.Join(t.TempDir(), "settings.json")
+	err := os.WriteFile(path, []byte(`{"profiles": {"dev": {}, "prod": {}}, "active": "dev"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := cfgFile
+	cfgFile = path
+	t.Cleanup(func() { cfgFile = previous })
+
+	output, status := runCommand(t, profileUseCmd, []string{"prod"}, nil)
+	assert.Equal(t, status, 0)
+	assert.StringContains(t, output, `Now using profile "prod"`)
+
+	cfg, err := config.LoadConfig(path, "", app.Logger)
+	assert.IsNil(t, err)
+	assert.Equal(t, cfg.Profile, "prod")
+
+	// An unknown profile is a validation error, and the file isn't changed.
+	output, status = runCommand(t, profileUseCmd, []string{"staging"}, nil)
+	assert.Equal(t, status, exitValidation)
+	assert.StringContains(t, output, `unknown profile "staging"`)
+
+	cfg, err = config.LoadConfig(path, "", app.Logger)
+	assert.IsNil(t, err)
+	assert.Equal(t, cfg.Profile, "prod")
+}
@@ -44,17 +44,14 @@ After registering, check your email for the activation token and run:
     godo activate <token>
 
 See 'godo activate -h' for more information.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		url := app.Config.APIBaseURL + "/users"
 
 		// Define error handler
 		handleError := func(msg string, err error) error {
-			app.Logger.Error(msg,
-				"error", err,
+			return app.cmdError(msg, "Error: Registration failed. Check logs for details.", err,
 				"method", http.MethodPost,
 				"url", url)
-			fmt.Println("Error: Registration failed. Check logs for details.")
-			return err
 		}
 
 		// Prepare JSON payload
@@ -67,23 +64,21 @@ See 'godo activate -h' for more information.`,
 		// Create request. The password will be omitted from the log.
 		req, err := app.createJSONRequest(http.MethodPost, url, payload, "password")
 		if err != nil {
-			handleError("failed to create request", err)
-			return
+			return handleError("failed to create request", err)
 		}
 		req.Header.Set("Content-Type", "application/json")
 
 		// Send request
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			handleError("failed to send request", err)
-			return
+			return handleError("failed to send request", err)
 		}
 		defer resp.Body.Close()
 
 		// Read response body and log it
 		body, err := app.readResponse(resp, handleError)
 		if err != nil {
-			return
+			return err
 		}
 
 		// Handle different status codes
@@ -91,11 +86,8 @@ See 'godo activate -h' for more information.`,
 		case http.StatusAccepted:
 			var registerResp RegisterResponse
 			if err := json.Unmarshal(body, &registerResp); err != nil {
-				app.Logger.Error("failed to unmarshal response",
-					"error", err,
+				return app.cmdError("failed to unmarshal response", "Error: Failed to parse server response", err,
 					"body", string(body))
-				fmt.Println("Error: Failed to parse server response")
-				return
 			}
 			fmt.Printf("\nRegistration successful for %s!\nPlease check your email for activation instructions.\n",
 				registerResp.User.Email)
@@ -103,27 +95,29 @@ See 'godo activate -h' for more information.`,
 		case http.StatusUnprocessableEntity:
 			// Handle validation errors (including duplicate email)
 			var errorResp struct {
-				Error map[string]string `json:"error"`
+				Error map[string]struct {
+					Message string `json:"message"`
+				} `json:"error"`
 			}
 			if err := json.Unmarshal(body, &errorResp); err != nil {
-				app.Logger.Error("failed to unmarshal error response",
-					"error", err,
+				return app.cmdError("failed to unmarshal error response", "Error: Failed to parse server error response", err,
 					"body", string(body))
-				fmt.Println("Error: Failed to parse server error response")
-				return
 			}
 			// Print each validation error
 			fmt.Println("\nRegistration failed:")
-			for field, message := range errorResp.Error {
-				fmt.Printf("- %s: %s\n", field, message)
+			for field, fieldErr := range errorResp.Error {
+				fmt.Printf("- %s: %s\n", field, fieldErr.Message)
 			}
+			return &reportedError{err: responseError(resp)}
 
 		default:
-			app.Logger.Error("unexpected status code",
-				"status", resp.Status,
+			return app.cmdError("unexpected status code",
+				fmt.Sprintf("\nError: Unexpected response from server (status %s)", resp.Status),
+				responseError(resp),
 				"body", string(body))
-			fmt.Printf("\nError: Unexpected response from server (status %s)\n", resp.Status)
 		}
+
+		return nil
 	},
 }
 
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/spf13/cobra"
+)
+
+// reportBarWidth is the width of the longest bar in a report's bar chart.
+const reportBarWidth = 40
+
+// reportCmd shows the number of todos the authenticated user completed in
+// each week or month.
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Show how many todos you completed each week or month",
+	Long: `
+Show how many todos you completed in each week, or in each month, as a bar
+chart. Weeks begin on Monday. Weeks or months in which you completed no todos
+aren't shown.
+
+Examples:
+
+    # Show todos completed per week
+    godo report
+
+    # Show todos completed per month
+    godo report --period month
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		period, _ := cmd.Flags().GetString("period")
+		if !slices.Contains([]string{"week", "month"}, period) {
+			return validationError(fmt.Errorf("period must be week or month"))
+		}
+
+		url := app.Config.APIBaseURL + "/report?period=" + period
+		stdoutMsg := app.failureMsg("show report")
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodGet,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		req, err := app.createJSONRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return handleError("Failed to create request", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return handleError("Failed to send request", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := app.readResponse(resp, handleError)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return handleError("Failed to show report", responseError(resp))
+		}
+
+		var reportResp struct {
+			Report []types.ReportBucket `json:"report"`
+		}
+		if err := json.Unmarshal(body, &reportResp); err != nil {
+			return handleError("Failed to unmarshal response", err)
+		}
+
+		writeReport(os.Stdout, period, reportResp.Report)
+		return nil
+	},
+}
+
+// writeReport writes a bar chart of the report to w, with a line for each
+// week or month. The bars are scaled so that the longest is reportBarWidth
+// wide, and every non-empty period has a bar at least one block wide.
+func writeReport(w io.Writer, period string, report []types.ReportBucket) {
+	if len(report) == 0 {
+		fmt.Fprintln(w, "No completed todos.")
+		return
+	}
+
+	most := 0
+	for _, b := range report {
+		most = max(most, b.Completed)
+	}
+
+	heading := "Week of"
+	if period == "month" {
+		heading = "Month"
+	}
+	fmt.Fprintf(w, "\n%-10s  Completed\n\n", heading)
+
+	for _, b := range report {
+		label := b.Start
+		if start, err := time.Parse(time.RFC3339, b.Start); err == nil {
+			label = start.Format(time.DateOnly)
+			if period == "month" {
+				label = start.Format("2006-01")
+			}
+		}
+
+		width := max(1, b.Completed*reportBarWidth/most)
+		fmt.Fprintf(w, "%-10s  %s %d\n", label, strings.Repeat("█", width), b.Completed)
+	}
+}
+
+func init() {
+	reportCmd.Flags().String("period", "week", "group completed todos by week or month")
+	rootCmd.AddCommand(reportCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestWriteReport(t *testing.T) {
+	var b bytes.Buffer
+	writeReport(&b, "week", []types.ReportBucket{
+		{Start: "2024-03-04T00:00:00Z", Completed: 80},
+		{Start: "2024-03-11T00:00:00Z", Completed: 1},
+	})
+
+	out := b.String()
+	assert.StringContains(t, out, "Week of     Completed\n")
+	assert.StringContains(t, out, "2024-03-04  ████████████████████████████████████████ 80\n")
+	// Small counts still get a bar.
+	assert.StringContains(t, out, "2024-03-11  █ 1\n")
+
+	b.Reset()
+	writeReport(&b, "month", []types.ReportBucket{{Start: "2024-03-01T00:00:00Z", Completed: 2}})
+	assert.StringContains(t, b.String(), "2024-03     ████████████████████████████████████████ 2\n")
+
+	b.Reset()
+	writeReport(&b, "week", []types.ReportBucket{})
+	assert.Equal(t, b.String(), "No completed todos.\n")
+}
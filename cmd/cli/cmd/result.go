@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kvnloughead/godo/cmd/cli/config"
+	"github.com/spf13/cobra"
+)
+
+// todoResult is the result of a command that changes a single todo, as
+// printed with the --json flag. ID is 0 if it isn't known, such as when add
+// fails.
+type todoResult struct {
+	ID      int    `json:"id"`
+	Action  string `json:"action"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// addJSONFlag adds the --json flag to a command that changes a single todo.
+func addJSONFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("json", false, "print the result as JSON")
+}
+
+// reportTodoResult prints the result of a command that changes a single todo.
+// With the --json flag, the result is printed as a todoResult. If err isn't
+// nil, it is returned as a *reportedError, so that it isn't printed again.
+func reportTodoResult(cmd *cobra.Command, id int, action, msg string, err error) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	printTodoResult(asJSON, id, action, msg, err)
+	if err != nil {
+		return &reportedError{err: err}
+	}
+	return nil
+}
+
+// printTodoResult prints the result of an action on the todo with the given
+// ID. If asJSON is false, msg is printed if the action succeeded, and the
+// error if it failed.
+func printTodoResult(asJSON bool, id int, action, msg string, err error) {
+	if asJSON {
+		result := todoResult{ID: id, Action: action, Success: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		js, _ := json.Marshal(result)
+		fmt.Println(string(js))
+		return
+	}
+
+	if err != nil {
+		reportError(nil, err)
+		return
+	}
+	fmt.Println(msg)
+}
+
+// parseID parses a todo ID argument.
+func parseID(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id < 1 {
+		return 0, errInvalidID
+	}
+	return id, nil
+}
+
+// todoFunc is an action on the todo with the given ID, authenticated with
+// token, that returns the message to print if it succeeds. The token is
+// passed in, so that actions on several todos only read it once.
+type todoFunc func(token string, id int) (string, error)
+
+// loadToken reads the authentication token. If it can't be read, an auth error
+// is returned.
+func loadToken() (string, error) {
+	token, err := app.TokenManager.LoadToken()
+	if err != nil {
+		return "", app.authError("Failed to read token", err)
+	}
+	return token, nil
+}
+
+// withToken reads the authentication token and calls fn with it, for commands
+// that change a single todo.
+func withToken(id int, fn todoFunc) (string, error) {
+	token, err := loadToken()
+	if err != nil {
+		return "", err
+	}
+	return fn(token, id)
+}
+
+// patchTodo sends a request to update the todo with the given ID with the
+// payload. If the request fails, failMsg is used in the error that is printed
+// in text output.
+func patchTodo(token string, id int, payload map[string]any, failMsg string) error {
+	url := fmt.Sprintf("%s/todos/%d", app.Config.APIBaseURL, id)
+	stdoutMsg := app.failureMsg(failMsg)
+
+	// handleError captures parameters that are common to all errors
+	handleError := func(logMsg string, err error) error {
+		return app.cmdError(logMsg, stdoutMsg, err,
+			"method", http.MethodPatch,
+			"url", url)
+	}
+
+	req, err := app.createJSONRequest(http.MethodPatch, url, payload)
+	if err != nil {
+		return handleError("Failed to create request", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+string(token))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return handleError("Failed to send request", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body and log it
+	_, err = app.readResponse(resp, handleError)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		app.invalidateCache()
+		return nil
+	case http.StatusNotFound:
+		return errTodoNotFound
+	default:
+		return handleError("Failed to "+failMsg, responseError(resp))
+	}
+}
+
+// interactiveAction returns an action for list's interactive mode, that calls
+// fn for each of the todos and prints a summary of the results, such as
+// "Deleted 2 of 3 (1 not found)." verb is the past tense of the action, as it
+// begins the summary. The todos are changed concurrently, by up to
+// app.Config.BatchWorkers requests at a time. Errors other than
+// errTodoNotFound are printed in the order of the IDs, before the summary,
+// since it only counts them. Unlike the commands, it doesn't exit if fn fails.
+//
+// The token is read once for all of the todos. If the API rejects it, it is
+// read again, in case it has been replaced, such as by logging in from
+// another shell, and the todo is tried again with the new token.
+func interactiveAction(verb string, fn todoFunc) func([]int) error {
+	return func(todoIDs []int) error {
+		token, err := loadToken()
+		if err != nil {
+			return err
+		}
+
+		// mu guards token, which is replaced by the first worker to find that
+		// it was rejected.
+		var mu sync.Mutex
+		reloadToken := func(rejected string) (string, bool) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if token != rejected {
+				return token, true
+			}
+			// The rejected token was forgotten when the response was read, so
+			// the token file is read again.
+			fresh, err := app.TokenManager.LoadToken()
+			if err != nil || fresh == rejected {
+				return "", false
+			}
+			token = fresh
+			return token, true
+		}
+
+		errs := runBatch(todoIDs, app.Config.BatchWorkers, func(id int) error {
+			mu.Lock()
+			current := token
+			mu.Unlock()
+
+			_, err := fn(current, id)
+			if errors.Is(err, errUnauthorized) {
+				if fresh, ok := reloadToken(current); ok {
+					_, err = fn(fresh, id)
+				}
+			}
+			return err
+		})
+
+		var notFound, failed int
+		for i, id := range todoIDs {
+			err := errs[i]
+			switch {
+			case errors.Is(err, errTodoNotFound):
+				notFound++
+			case err != nil:
+				failed++
+				// The messages of logged errors are surrounded by blank
+				// lines, which are trimmed so that they follow the prefix.
+				var msg strings.Builder
+				writeError(&msg, nil, err)
+				fmt.Printf("Todo %d: %s\n", id, strings.TrimSpace(msg.String()))
+			}
+		}
+		fmt.Println(batchSummary(verb, len(todoIDs), notFound, failed))
+		return nil
+	}
+}
+
+// runBatch calls fn for each of the IDs, with up to workers calls running at
+// once, and returns the errors in the same order as the IDs. If workers is
+// less than 1, config.DefaultBatchWorkers is used.
+func runBatch(ids []int, workers int, fn func(id int) error) []error {
+	if workers < 1 {
+		workers = config.DefaultBatchWorkers
+	}
+
+	errs := make([]error, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range min(workers, len(ids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each job is an index into ids, so that each worker writes to
+			// its own elements of errs.
+			for i := range jobs {
+				errs[i] = fn(ids[i])
+			}
+		}()
+	}
+
+	for i := range ids {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errs
+}
+
+// batchSummary returns the summary of an action on total todos, of which
+// notFound didn't exist and failed failed for other reasons.
+func batchSummary(verb string, total, notFound, failed int) string {
+	var problems []string
+	if notFound > 0 {
+		problems = append(problems, fmt.Sprintf("%d not found", notFound))
+	}
+	if failed > 0 {
+		problems = append(problems, fmt.Sprintf("%d failed", failed))
+	}
+
+	summary := fmt.Sprintf("%s %d of %d", verb, total-notFound-failed, total)
+	if len(problems) > 0 {
+		summary += " (" + strings.Join(problems, ", ") + ")"
+	}
+	return summary + "."
+}
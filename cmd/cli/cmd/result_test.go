@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/spf13/cobra"
+)
+
+func TestTodoCommandsNotFound(t *testing.T) {
+	tests := []struct {
+		cmd    *cobra.Command
+		args   []string
+		flags  map[string]string
+		id     int
+		output string
+		error  string
+	}{
+		{cmd: addCmd, args: []string{"call mom"}, output: "\nError: failed to add todo item. \nCheck `/tmp/godo/logs` for details.\n\n", error: "response status: 404 Not Found"},
+		{cmd: doneCmd, args: []string{"42"}, id: 42, output: "Error: todo not found\n", error: "todo not found"},
+		{cmd: undoneCmd, args: []string{"42"}, id: 42, output: "Error: todo not found\n", error: "todo not found"},
+		{cmd: archiveCmd, args: []string{"42"}, id: 42, output: "Error: todo not found\n", error: "todo not found"},
+		{cmd: unarchiveCmd, args: []string{"42"}, id: 42, output: "Error: todo not found\n", error: "todo not found"},
+		{cmd: deleteCmd, args: []string{"42"}, flags: map[string]string{"yes": "true"}, id: 42, output: "Error: todo not found\n", error: "todo not found"},
+		{cmd: priCmd, args: []string{"42", "a"}, id: 42, output: "Error: todo not found\n", error: "todo not found"},
+	}
+
+	for _, tt := range tests {
+		action := tt.cmd.Name()
+
+		t.Run(action, func(t *testing.T) {
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `{"error": "the requested resource could not be found"}`)
+			}))
+
+			t.Run("Text", func(t *testing.T) {
+				output, status := runCommand(t, tt.cmd, tt.args, tt.flags)
+
+				assert.Equal(t, output, tt.output)
+				assert.Equal(t, status, exitFailure)
+			})
+
+			t.Run("JSON", func(t *testing.T) {
+				output, status := runCommand(t, tt.cmd, tt.args, withFlag(tt.flags, "json", "true"))
+
+				var result todoResult
+				err := json.Unmarshal([]byte(output), &result)
+				assert.IsNil(t, err)
+				assert.Equal(t, result, todoResult{ID: tt.id, Action: action, Error: tt.error})
+				assert.Equal(t, status, exitFailure)
+			})
+		})
+	}
+}
+
+func TestExpiredTokenSkipsRequest(t *testing.T) {
+	for _, cmd := range []*cobra.Command{addCmd, doneCmd, archiveCmd} {
+		t.Run(cmd.Name(), func(t *testing.T) {
+			var requests atomic.Int32
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			err := app.TokenManager.SaveToken("F6SB76ZCLKLJBHP7K7A6N2S7JM", time.Now().Add(-time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			output, status := runCommand(t, cmd, []string{"42"}, nil)
+			assert.StringContains(t, output, "your session expired")
+			assert.StringContains(t, output, "godo auth")
+			assert.Equal(t, status, exitAuth)
+			assert.Equal(t, requests.Load(), int32(0))
+		})
+	}
+}
+
+func TestTodoCommandsJSON(t *testing.T) {
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"results": [{"line": 1, "ok": true, "todo": {"id": 7, "text": "call mom"}}]}`)
+			return
+		}
+		io.WriteString(w, `{"todo": {"id": 42}}`)
+	}))
+
+	output, status := runCommand(t, addCmd, []string{"call mom"}, map[string]string{"json": "true"})
+	assert.Equal(t, output, `{"id":7,"action":"add","success":true}`+"\n")
+	assert.Equal(t, status, 0)
+
+	output, status = runCommand(t, priCmd, []string{"42", "B"}, map[string]string{"json": "true"})
+	assert.Equal(t, output, `{"id":42,"action":"pri","success":true}`+"\n")
+	assert.Equal(t, status, 0)
+
+	output, status = runCommand(t, depriCmd, []string{"42"}, map[string]string{"json": "true"})
+	assert.Equal(t, output, `{"id":42,"action":"depri","success":true}`+"\n")
+	assert.Equal(t, status, 0)
+
+	output, status = runCommand(t, doneCmd, []string{"0"}, map[string]string{"json": "true"})
+	assert.Equal(t, output, `{"id":0,"action":"done","success":false,"error":"ID must be a positive integer"}`+"\n")
+	assert.Equal(t, status, exitValidation)
+}
+
+// withFlag returns a copy of flags with the flag set to value.
+func withFlag(flags map[string]string, name, value string) map[string]string {
+	m := map[string]string{name: value}
+	for k, v := range flags {
+		m[k] = v
+	}
+	return m
+}
+
+func TestTodoFuncs(t *testing.T) {
+	tests := []struct {
+		name   string
+		fn     todoFunc
+		method string
+		body   string
+		msg    string
+	}{
+		{name: "done", fn: doneTodo, method: http.MethodPatch, body: `{"completed":true}`, msg: "Todo marked as completed"},
+		{name: "undone", fn: undoneTodo, method: http.MethodPatch, body: `{"completed":false}`, msg: "Todo marked as not completed"},
+		{name: "archive", fn: archiveTodo, method: http.MethodPatch, body: `{"archived":true}`, msg: "Todo marked as archived"},
+		{name: "unarchive", fn: unarchiveTodo, method: http.MethodPatch, body: `{"archived":false}`, msg: "Todo marked as not archived"},
+		{
+			name: "delete",
+			fn: func(token string, id int) (string, error) {
+				return deleteTodo(token, id, false)
+			},
+			method: http.MethodDelete,
+			msg:    "Todo deleted successfully",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var body []byte
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				body, _ = io.ReadAll(r.Body)
+				io.WriteString(w, `{"todo": {"id": 42}}`)
+			}))
+
+			msg, err := tt.fn("my-token", 42)
+			assert.IsNil(t, err)
+			assert.Equal(t, msg, tt.msg)
+
+			// The request is authenticated with the token that was passed in.
+			assert.Equal(t, got.Method, tt.method)
+			assert.Equal(t, got.URL.Path, "/v1/todos/42")
+			assert.Equal(t, got.Header.Get("Authorization"), "Bearer my-token")
+			assert.Equal(t, string(body), tt.body)
+		})
+	}
+}
+
+func TestInteractiveActionReadsTokenOnce(t *testing.T) {
+	var tokens []string
+	var status int
+	var mu sync.Mutex
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The todos are changed concurrently.
+		mu.Lock()
+		defer mu.Unlock()
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		w.WriteHeader(status)
+		io.WriteString(w, `{"todo": {"id": 42}}`)
+	}))
+	saved, err := app.TokenManager.LoadToken()
+	assert.IsNil(t, err)
+
+	// Once the token has been read, changes to the file aren't seen.
+	err = os.WriteFile(app.TokenManager.TokenFile(), []byte("new-token"), 0600)
+	assert.IsNil(t, err)
+
+	status = http.StatusOK
+	captureStdout(t, func() {
+		interactiveAction("Completed", doneTodo)([]int{1, 2, 3})
+	})
+	assert.Equal(t, fmt.Sprint(tokens), fmt.Sprint([]string{"Bearer " + saved, "Bearer " + saved, "Bearer " + saved}))
+
+	// A rejected token is read from the file again, and the todo is tried
+	// again with it. If that is rejected too, the todo fails.
+	tokens = nil
+	status = http.StatusUnauthorized
+	captureStdout(t, func() {
+		interactiveAction("Completed", doneTodo)([]int{1})
+	})
+	status = http.StatusOK
+	captureStdout(t, func() {
+		interactiveAction("Completed", doneTodo)([]int{1})
+	})
+	assert.Equal(t, fmt.Sprint(tokens), fmt.Sprint([]string{"Bearer " + saved, "Bearer new-token", "Bearer new-token"}))
+}
+
+func TestInteractiveActionReloadsRejectedToken(t *testing.T) {
+	var mu sync.Mutex
+	var saved string
+	var requests int
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		// The saved token has been revoked, and replaced in the file.
+		if r.Header.Get("Authorization") == "Bearer "+saved {
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error": "invalid or missing authentication token"}`)
+			return
+		}
+		io.WriteString(w, `{"todo": {"id": 42}}`)
+	}))
+	saved, err := app.TokenManager.LoadToken()
+	assert.IsNil(t, err)
+	err = os.WriteFile(app.TokenManager.TokenFile(), []byte("new-token"), 0600)
+	assert.IsNil(t, err)
+
+	output := captureStdout(t, func() {
+		assert.IsNil(t, interactiveAction("Completed", doneTodo)([]int{1, 2, 3, 4, 5}))
+	})
+
+	assert.Equal(t, output, "Completed 5 of 5.\n")
+	// Each todo is tried at most twice, once with each token.
+	assert.Equal(t, requests <= 10, true)
+}
+
+func TestInteractiveActionSummary(t *testing.T) {
+	newTestCLIApplication(t, http.NotFoundHandler())
+
+	action := interactiveAction("Deleted", func(token string, id int) (string, error) {
+		switch id {
+		case 2:
+			return "", errTodoNotFound
+		case 3:
+			return "", errors.New("server error")
+		case 5:
+			return "", app.cmdError("Failed", app.failureMsg("delete todo"), errors.New("boom"))
+		default:
+			return "Todo deleted successfully", nil
+		}
+	})
+
+	output := captureStdout(t, func() {
+		assert.IsNil(t, action([]int{1, 2, 3, 4}))
+	})
+	assert.Equal(t, output, "Todo 3: Error: server error\nDeleted 2 of 4 (1 not found, 1 failed).\n")
+
+	output = captureStdout(t, func() {
+		assert.IsNil(t, action([]int{1, 4}))
+	})
+	assert.Equal(t, output, "Deleted 2 of 2.\n")
+
+	// A logged error's message follows the prefix, without blank lines.
+	output = captureStdout(t, func() {
+		assert.IsNil(t, action([]int{5}))
+	})
+	assert.Equal(t, output, "Todo 5: Error: failed to delete todo. \nCheck `/tmp/godo/logs` for details.\n"+
+		"Deleted 0 of 1 (1 failed).\n")
+}
+
+func TestBatchSummary(t *testing.T) {
+	assert.Equal(t, batchSummary("Archived", 3, 0, 0), "Archived 3 of 3.")
+	assert.Equal(t, batchSummary("Deleted", 3, 1, 0), "Deleted 2 of 3 (1 not found).")
+	assert.Equal(t, batchSummary("Completed", 2, 0, 2), "Completed 0 of 2 (2 failed).")
+}
+
+func TestRunBatch(t *testing.T) {
+	ids := make([]int, 20)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+
+	var running, maxRunning atomic.Int32
+	errs := runBatch(ids, 3, func(id int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		// Later IDs finish first, so the errors must be put back in order.
+		time.Sleep(time.Duration(len(ids)-id) * time.Millisecond)
+		if id%5 == 0 {
+			return fmt.Errorf("todo %d failed", id)
+		}
+		return nil
+	})
+
+	assert.Equal(t, len(errs), len(ids))
+	for i, err := range errs {
+		if ids[i]%5 == 0 {
+			assert.Equal(t, err.Error(), fmt.Sprintf("todo %d failed", ids[i]))
+		} else {
+			assert.IsNil(t, err)
+		}
+	}
+	assert.Equal(t, maxRunning.Load() <= 3, true)
+
+	// Without any IDs, fn isn't called.
+	errs = runBatch(nil, 0, func(id int) error {
+		t.Fatal("fn was called")
+		return nil
+	})
+	assert.Equal(t, len(errs), 0)
+}
+
+func TestDepri(t *testing.T) {
+	var body string
+	newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		io.WriteString(w, `{"todo": {"id": 42}}`)
+	}))
+
+	output, status := runCommand(t, depriCmd, []string{"42"}, nil)
+
+	assert.Equal(t, output, "Todo priority removed\n")
+	assert.Equal(t, status, 0)
+	assert.Equal(t, body, `{"priority":""}`)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/kvnloughead/godo/cmd/cli/token"
+	"github.com/spf13/cobra"
+)
+
+// revokeCmd revokes all of the authenticated user's tokens of a given scope.
+var revokeCmd = &cobra.Command{
+	Use:       "revoke <scope>",
+	Short:     "Revoke all of your tokens of a given scope",
+	ValidArgs: []string{"authentication", "activation"},
+	Long: `
+Revoke all of your tokens of a given scope. The scope must be either
+"authentication" or "activation".
+
+Revoking your authentication tokens signs you out everywhere, including on
+this device. Afterwards, your stored token is removed and you will need to run
+'godo auth' again.
+
+If the GODO_TOKEN environment variable is set, its token is revoked too, and a
+warning is printed, since it is still used until it is unset.
+
+Examples:
+
+    # Sign out of all sessions
+    godo revoke authentication
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope := args[0]
+		url := app.Config.APIBaseURL + "/tokens/" + scope
+		stdoutMsg := app.failureMsg("revoke tokens")
+		fromEnv := os.Getenv(token.EnvVar) != ""
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodDelete,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		req, err := http.NewRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			return handleError("Failed to create request", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return handleError("Failed to send request", err)
+		}
+		defer resp.Body.Close()
+
+		// Read response body and log it
+		_, err = app.readResponse(resp, handleError)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return handleError("Failed to revoke tokens", responseError(resp))
+		}
+
+		// The stored token was revoked along with the others, so remove it.
+		if scope == "authentication" {
+			if err := app.TokenManager.DeleteToken(); err != nil {
+				app.Logger.Error("failed to delete token", "error", err)
+			}
+		}
+
+		fmt.Printf("All %s tokens revoked\n", scope)
+		if scope == "authentication" && fromEnv {
+			fmt.Println("Warning: GODO_TOKEN is still set, so its revoked token is used until it is unset")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(revokeCmd)
+}
@@ -9,6 +9,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/google/uuid"
+	"github.com/kvnloughead/godo/cmd/cli/cache"
 	"github.com/kvnloughead/godo/cmd/cli/config"
 	"github.com/kvnloughead/godo/cmd/cli/token"
 	"github.com/kvnloughead/godo/internal/logger"
@@ -23,9 +25,25 @@ var (
 		Use:   "godo [command]",
 		Short: "godo is a CLI todo tracker",
 		Long: "\n" + `godo is a CLI todo tracker application written in Go. It supports todo.txt syntax and is backed by an HTTP server and Postrgresql database.
+
+The config file, token, logs and cache are stored in ~/.config/godo. Set
+GODO_CONFIG_DIR to use another directory. If XDG_CONFIG_HOME is set, the
+directory is $XDG_CONFIG_HOME/godo instead.
+
+Exit status:
+
+  0  The command succeeded.
+  1  The command failed, such as when a todo isn't found.
+  2  The arguments or flags were invalid, or the server rejected the input.
+  3  There is no saved token, or the server rejected it. Run 'godo auth'.
+  4  The server couldn't be reached.
 	`,
+		// Errors are printed by Execute, so that each is only printed once.
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 	cfgFile string
+	profile string
 	app     *CLIApplication
 )
 
@@ -36,7 +54,13 @@ func init() {
 		"config",
 		"c",
 		"",
-		"config file (default is $HOME/.config/godo/settings.json)",
+		"config file (default is settings.json in the config directory)",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&profile,
+		"profile",
+		"",
+		"profile in the config file to use (default is the active profile)",
 	)
 
 	// Log the command, its arguments, and all flags and their values
@@ -57,23 +81,26 @@ func init() {
 
 	// Then initialize the application
 	cobra.OnInitialize(func() {
-		logger := logger.NewLogger()
-		cliConfig, err := config.LoadConfig(cfgFile, logger)
+		var err error
+		app, err = NewCLIApplication()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 			os.Exit(1)
 		}
-		app = &CLIApplication{
-			Logger:       logger,
-			Config:       cliConfig,
-			TokenManager: token.NewManager(filepath.Join(os.Getenv("HOME"), ".config/godo"), cliConfig.APIBaseURL),
-		}
 	})
 }
 
+// Execute runs the command given by the CLI's arguments. If it fails, the
+// error is printed, and the process exits with the status for the error. See
+// exitStatus.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	validateArgsWithStatus(rootCmd)
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return validationError(err)
+	})
+
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		os.Exit(reportError(cmd, err))
 	}
 }
 
@@ -84,28 +111,58 @@ type CLIConfig struct {
 
 type CLIApplication struct {
 	Logger       *slog.Logger
+	InvocationID string // Identifies this run of the CLI in the log. See withInvocationID.
+	LogDir       string // The directory that the log is in. See logger.LogFile.
 	Config       config.Config
 	TokenManager *token.Manager
+	Cache        *cache.Cache // nil if the cache is disabled
 }
 
+// NewCLIApplication creates the CLIApplication, with its logs in the config
+// directory, and its token and cache in the profile's directory. See
+// config.Dir and config.ProfileDir.
 func NewCLIApplication() (*CLIApplication, error) {
-	// Get config file path from flag
+	// Get config file path and profile from flags
 	cfgFile, err := rootCmd.PersistentFlags().GetString("config")
 	if err != nil {
 		return nil, err
 	}
+	profile, err := rootCmd.PersistentFlags().GetString("profile")
+	if err != nil {
+		return nil, err
+	}
 
-	logger := logger.NewLogger()
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	logDir := filepath.Dir(logger.LogFile(configDir))
+	logger, invocationID := withInvocationID(logger.NewLogger(configDir))
 
 	// Use the config package's LoadConfig function
-	cliConfig, err := config.LoadConfig(cfgFile, logger)
+	cliConfig, err := config.LoadConfig(cfgFile, profile, logger)
 	if err != nil {
 		return nil, err
 	}
+	profileDir := config.ProfileDir(configDir, cliConfig.Profile)
 
-	return &CLIApplication{
+	app := &CLIApplication{
 		Logger:       logger,
+		InvocationID: invocationID,
+		LogDir:       logDir,
 		Config:       cliConfig,
-		TokenManager: token.NewManager(cliConfig.APIBaseURL, cliConfig.APIBaseURL),
-	}, nil
+		TokenManager: token.NewManager(profileDir, cliConfig.APIBaseURL),
+	}
+	if !cliConfig.DisableCache {
+		app.Cache = cache.New(filepath.Join(profileDir, "cache"))
+	}
+	return app, nil
+}
+
+// withInvocationID returns a logger that adds a new UUID to each line as the
+// "invocation_id" field, and the UUID. Each run of the CLI has its own ID, so
+// that the lines from runs that overlap can be told apart in the log.
+func withInvocationID(l *slog.Logger) (*slog.Logger, string) {
+	id := uuid.NewString()
+	return l.With("invocation_id", id), id
 }
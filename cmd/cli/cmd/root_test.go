@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/cmd/cli/config"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestNewCLIApplicationConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GODO_CONFIG_DIR", dir)
+	t.Setenv("GODO_API_URL", "")
+
+	app, err := NewCLIApplication()
+	assert.IsNil(t, err)
+
+	// The config file, log file and token are all in the config directory.
+	file, err := config.ConfigFile("")
+	assert.IsNil(t, err)
+	assert.Equal(t, file, filepath.Join(dir, "settings.json"))
+	_, err = os.Stat(filepath.Join(dir, "settings.json"))
+	assert.IsNil(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "logs", "app.log"))
+	assert.IsNil(t, err)
+
+	assert.Equal(t, app.TokenManager.TokenFile(), filepath.Join(dir, ".token"))
+	assert.Equal(t, app.Cache.File(), filepath.Join(dir, "cache", "todos.json"))
+
+	// Error messages point to the log's actual directory.
+	assert.Equal(t, app.LogDir, filepath.Join(dir, "logs"))
+	assert.StringContains(t, app.failureMsg("add todo item"), filepath.Join(dir, "logs"))
+}
+
+func TestNewCLIApplicationProfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GODO_CONFIG_DIR", dir)
+	t.Setenv("GODO_API_URL", "")
+
+	settings := `{"profiles": {"dev": {"api_base_url": "http://localhost:4000/v1"}}, "active": "dev"}`
+	err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(settings), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each profile has its own token and cache.
+	app, err := NewCLIApplication()
+	assert.IsNil(t, err)
+	assert.Equal(t, app.Config.Profile, "dev")
+	assert.Equal(t, app.TokenManager.TokenFile(), filepath.Join(dir, "profiles", "dev", ".token.dev"))
+	assert.Equal(t, app.Cache.File(), filepath.Join(dir, "profiles", "dev", "cache", "todos.json"))
+
+	err = app.TokenManager.SaveToken("F6SB76ZCLKLJBHP7K7A6N2S7JM", time.Time{})
+	assert.IsNil(t, err)
+}
+
+func TestWithInvocationID(t *testing.T) {
+	var b bytes.Buffer
+	base := slog.New(slog.NewJSONHandler(&b, nil))
+
+	first, firstID := withInvocationID(base)
+	second, secondID := withInvocationID(base)
+	assert.Equal(t, firstID != secondID, true)
+
+	// Each line has its logger's ID.
+	first.Info("first")
+	second.Info("second")
+
+	dec := json.NewDecoder(&b)
+	for _, want := range []string{firstID, secondID} {
+		var line struct {
+			InvocationID string `json:"invocation_id"`
+		}
+		err := dec.Decode(&line)
+		assert.IsNil(t, err)
+		assert.Equal(t, line.InvocationID, want)
+	}
+}
+
+func TestReportErrorInvocationID(t *testing.T) {
+	newTestCLIApplication(t, http.NotFoundHandler())
+	app.InvocationID = "3f2b1c4d"
+
+	output := captureStdout(t, func() {
+		reportError(nil, app.cmdError("Failed", "\nError: failed to star todo. \nCheck the logs for details.\n", errors.New("boom")))
+	})
+	assert.Equal(t, output, "\nError: failed to star todo. \nCheck the logs for details.\nLook for invocation_id=3f2b1c4d in the log.\n\n")
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/spf13/cobra"
+)
+
+// sessionsCmd lists the authenticated user's active tokens.
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List your active sessions",
+	Long: `
+List the active tokens for your account. For each token, the scope, creation
+time, and expiry time are shown, along with the first few characters of the
+token's hash. The tokens themselves are never shown.
+
+To revoke tokens, run 'godo revoke -h' for more information.
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url := app.Config.APIBaseURL + "/tokens"
+		stdoutMsg := app.failureMsg("list sessions")
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodGet,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		req, err := app.createJSONRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return handleError("Failed to create request", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return handleError("Failed to send request", err)
+		}
+		defer resp.Body.Close()
+
+		// Read response body and log it
+		body, err := app.readResponse(resp, handleError)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return handleError("Failed to list sessions", responseError(resp))
+		}
+
+		var sessionsResp struct {
+			Tokens []types.Session `json:"tokens"`
+		}
+		if err := json.Unmarshal(body, &sessionsResp); err != nil {
+			return handleError("Failed to unmarshal response", err)
+		}
+
+		if len(sessionsResp.Tokens) == 0 {
+			fmt.Println("No active sessions.")
+			return nil
+		}
+
+		fmt.Printf("%-10s %-16s %-26s %s\n", "HASH", "SCOPE", "CREATED", "EXPIRES")
+		for _, s := range sessionsResp.Tokens {
+			fmt.Printf("%-10s %-16s %-26s %s\n", s.HashPrefix, s.Scope, s.CreatedAt, s.Expiry)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// starCmd stars a todo item, pinning it to the top of the list.
+var starCmd = &cobra.Command{
+	Use:   "star <id>",
+	Short: "Star a todo item",
+	Long: `
+Star a todo item. Starred todos are listed before all other todos, regardless
+of how the list is sorted. For example:
+
+    # Star todo #42
+    godo star 42
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setStarred(args[0], true)
+	},
+}
+
+// setStarred sends a PATCH request setting the starred field of the todo with
+// the given ID. It is shared by the star and unstar commands.
+func setStarred(arg string, starred bool) error {
+	id, err := parseID(arg)
+	if err != nil {
+		return err
+	}
+
+	action := "star"
+	if !starred {
+		action = "unstar"
+	}
+
+	token, err := loadToken()
+	if err != nil {
+		return err
+	}
+
+	err = patchTodo(token, id, map[string]any{"starred": starred}, action+" todo")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Todo %sred\n", action)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(starCmd)
+}
@@ -0,0 +1,550 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/kvnloughead/godo/internal/data"
+	"github.com/spf13/cobra"
+)
+
+// syncCmd reconciles a local todo.txt file with the user's todos on the server.
+var syncCmd = &cobra.Command{
+	Use:   "sync <file>",
+	Short: "Sync a local todo.txt file with your todos",
+	Long: `
+Sync a local todo.txt file with your unarchived todos on the server.
+
+Each todo in the file is matched to a todo on the server by its key, a
+"godo:<id>" word that sync adds to the end of the line, or else by its text.
+The text, priority and completion of matched todos are compared.
+
+By default, changes are made in both directions:
+
+    - Todos that are only in the file are created on the server.
+    - Matched todos that differ are updated on the server to match the file.
+    - Todos that are only on the server are added to the file.
+    - Lines whose key refers to a todo that is no longer on the server, such as
+      one that was archived or deleted, are removed from the file.
+
+With --push-only, the server is made to match the file, and the file isn't
+changed. Todos that are only on the server are archived. The file must exist,
+and if it has no todos, you are asked to confirm before all of your todos are
+archived. The --yes flag skips the confirmation.
+
+With --pull-only, the file is made to match the server, and no changes are
+made on the server. Lines that don't match a todo on the server are removed.
+
+Blank lines, and lines that aren't todos, are left as they are. Each change is
+printed, followed by a summary.
+
+Examples:
+
+    # Sync todo.txt in both directions
+    godo sync todo.txt
+
+    # Make your todos match todo.txt
+    godo sync --push-only todo.txt
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pushOnly, _ := cmd.Flags().GetBool("push-only")
+		pullOnly, _ := cmd.Flags().GetBool("pull-only")
+
+		mode := syncBoth
+		switch {
+		case pushOnly && pullOnly:
+			return validationError(errors.New("--push-only and --pull-only can't be used together"))
+		case pushOnly:
+			mode = syncPushOnly
+		case pullOnly:
+			mode = syncPullOnly
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		return syncFile(args[0], mode, yes)
+	},
+}
+
+// syncMode is the direction that sync makes changes in.
+type syncMode int
+
+const (
+	syncBoth     syncMode = iota // Changes are made to both the file and the server.
+	syncPushOnly                 // Only the server is changed.
+	syncPullOnly                 // Only the file is changed.
+)
+
+// syncKeyPrefix begins the word that stores a todo's ID in a todo.txt line.
+const syncKeyPrefix = "godo:"
+
+// syncLine is a line of a todo.txt file.
+type syncLine struct {
+	raw  string     // The line, as read from the file.
+	todo *data.Todo // The parsed todo, or nil if the line isn't a todo.
+	id   int        // The ID from the line's key, or 0 if it has none.
+}
+
+// parseSyncLines parses the lines of a todo.txt file. The key is removed from
+// each todo before it is parsed.
+func parseSyncLines(text string) []syncLine {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+
+	var lines []syncLine
+	for _, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		line := syncLine{raw: raw}
+
+		var words []string
+		for _, word := range strings.Fields(raw) {
+			if id := parseSyncKey(word); id != 0 && line.id == 0 {
+				line.id = id
+				continue
+			}
+			words = append(words, word)
+		}
+
+		if todo, err := data.ParseTodo(strings.Join(words, " ")); err == nil {
+			line.todo = todo
+		} else {
+			line.id = 0
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseSyncKey returns the ID in word if it is a key, such as "godo:42", or 0
+// if it isn't.
+func parseSyncKey(word string) int {
+	idStr, ok := strings.CutPrefix(word, syncKeyPrefix)
+	if !ok {
+		return 0
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 {
+		return 0
+	}
+	return id
+}
+
+// syncActionKind is a change that sync makes.
+type syncActionKind string
+
+const (
+	syncCreate  syncActionKind = "created"   // A todo from the file is created on the server.
+	syncUpdate  syncActionKind = "updated"   // A todo on the server is updated to match the file.
+	syncArchive syncActionKind = "archived"  // A todo that isn't in the file is archived.
+	syncPull    syncActionKind = "pulled"    // A todo from the server is added to the file.
+	syncRefresh syncActionKind = "refreshed" // A line is updated to match the server.
+	syncRemove  syncActionKind = "removed"   // A line is removed from the file.
+)
+
+// syncActionKinds lists the kinds of action in the order that they are
+// summarized.
+var syncActionKinds = []syncActionKind{syncCreate, syncUpdate, syncArchive, syncPull, syncRefresh, syncRemove}
+
+// syncAction is a change to make to either the file or the server.
+type syncAction struct {
+	kind   syncActionKind
+	line   int         // The index of the line in the file, or -1 for a pull or archive.
+	remote *types.Todo // The matching todo on the server, if there is one.
+}
+
+// syncPlan is the result of comparing a todo.txt file with the server's todos.
+type syncPlan struct {
+	actions []syncAction
+	ids     map[int]int // The ID of the matched todo for each line that has one.
+}
+
+// diffTodos compares the lines of a todo.txt file with the todos on the
+// server, and returns the actions that make them converge in the given mode.
+//
+// Lines are matched by their key first, and then by their text. Each todo on
+// the server is matched to at most one line.
+func diffTodos(lines []syncLine, remote []types.Todo, mode syncMode) syncPlan {
+	plan := syncPlan{ids: map[int]int{}}
+
+	byID := make(map[int]int, len(remote))
+	for i, t := range remote {
+		byID[t.ID] = i
+	}
+	matched := make([]bool, len(remote))
+	match := make([]int, len(lines))
+
+	// Match by key, and then by text, so that a line with a key isn't
+	// matched by text to a todo that another line has the key of.
+	for i, line := range lines {
+		match[i] = -1
+		if line.todo == nil || line.id == 0 {
+			continue
+		}
+		if r, ok := byID[line.id]; ok && !matched[r] {
+			match[i] = r
+			matched[r] = true
+		}
+	}
+	for i, line := range lines {
+		if line.todo == nil || match[i] != -1 {
+			continue
+		}
+		for r := range remote {
+			if !matched[r] && remote[r].Text == line.todo.Text {
+				match[i] = r
+				matched[r] = true
+				break
+			}
+		}
+	}
+
+	for i, line := range lines {
+		if line.todo == nil {
+			continue
+		}
+
+		r := match[i]
+		if r == -1 {
+			switch {
+			case mode == syncPullOnly:
+				plan.actions = append(plan.actions, syncAction{kind: syncRemove, line: i})
+			case mode == syncBoth && line.id != 0:
+				// The todo was archived or deleted on the server.
+				plan.actions = append(plan.actions, syncAction{kind: syncRemove, line: i})
+			default:
+				plan.actions = append(plan.actions, syncAction{kind: syncCreate, line: i})
+			}
+			continue
+		}
+
+		plan.ids[i] = remote[r].ID
+		if data.FormatTodo(line.todo) == data.FormatTodo(remoteTodo(remote[r])) {
+			continue
+		}
+		kind := syncUpdate
+		if mode == syncPullOnly {
+			kind = syncRefresh
+		}
+		plan.actions = append(plan.actions, syncAction{kind: kind, line: i, remote: &remote[r]})
+	}
+
+	for r := range remote {
+		if matched[r] {
+			continue
+		}
+		kind := syncPull
+		if mode == syncPushOnly {
+			kind = syncArchive
+		}
+		plan.actions = append(plan.actions, syncAction{kind: kind, line: -1, remote: &remote[r]})
+	}
+
+	return plan
+}
+
+// remoteTodo returns the fields of a todo from the server that are synced.
+func remoteTodo(t types.Todo) *data.Todo {
+	return &data.Todo{Text: t.Text, Priority: t.Priority, Completed: t.Completed}
+}
+
+// formatSyncLine formats a todo as a line of a todo.txt file, with its key if
+// it has an ID.
+func formatSyncLine(todo *data.Todo, id int) string {
+	line := data.FormatTodo(todo)
+	if id != 0 {
+		line += " " + syncKeyPrefix + strconv.Itoa(id)
+	}
+	return line
+}
+
+// syncFile syncs the todo.txt file at path with the server's todos, and prints
+// the actions taken. If yes is true, a push from a file without todos isn't
+// confirmed.
+func syncFile(path string, mode syncMode, yes bool) error {
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && mode == syncPushOnly:
+		// Pushing a missing file would archive every todo.
+		return validationError(fmt.Errorf("%s doesn't exist", path))
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		// A missing file is created.
+		return err
+	}
+
+	lines := parseSyncLines(string(content))
+	remote, err := fetchAllTodos()
+	if err != nil {
+		return err
+	}
+	if mode == syncPushOnly && !yes && len(remote) > 0 && !hasSyncTodos(lines) {
+		if err := confirmEmptyPush(stdin, stdinIsTerminal(), path, len(remote)); err != nil {
+			return err
+		}
+	}
+	plan := diffTodos(lines, remote, mode)
+
+	token, err := loadToken()
+	if err != nil {
+		return err
+	}
+
+	var creates []int
+	counts := map[syncActionKind]int{}
+	for _, action := range plan.actions {
+		if action.kind == syncCreate {
+			creates = append(creates, action.line)
+		}
+	}
+
+	ids, err := importSyncLines(lines, creates)
+	for i, id := range ids {
+		plan.ids[i] = id
+	}
+	for _, i := range creates {
+		if _, ok := ids[i]; ok {
+			fmt.Printf("%s: %s\n", syncCreate, data.FormatTodo(lines[i].todo))
+			counts[syncCreate]++
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	pulled := []string{}
+	removed := map[int]bool{}
+	for _, action := range plan.actions {
+		switch action.kind {
+		case syncCreate:
+			continue
+		case syncUpdate:
+			todo := lines[action.line].todo
+			payload := map[string]any{"text": todo.Text, "priority": todo.Priority, "completed": todo.Completed}
+			if err := patchTodo(token, action.remote.ID, payload, "update todo"); err != nil {
+				return err
+			}
+			fmt.Printf("%s #%d: %s\n", action.kind, action.remote.ID, data.FormatTodo(todo))
+		case syncArchive:
+			if err := patchTodo(token, action.remote.ID, map[string]any{"archived": true}, "archive todo"); err != nil {
+				return err
+			}
+			fmt.Printf("%s #%d: %s\n", action.kind, action.remote.ID, data.FormatTodo(remoteTodo(*action.remote)))
+		case syncPull:
+			pulled = append(pulled, formatSyncLine(remoteTodo(*action.remote), action.remote.ID))
+			fmt.Printf("%s #%d: %s\n", action.kind, action.remote.ID, data.FormatTodo(remoteTodo(*action.remote)))
+		case syncRefresh:
+			lines[action.line].todo = remoteTodo(*action.remote)
+			fmt.Printf("%s #%d: %s\n", action.kind, action.remote.ID, data.FormatTodo(lines[action.line].todo))
+		case syncRemove:
+			removed[action.line] = true
+			fmt.Printf("%s: %s\n", action.kind, lines[action.line].raw)
+		}
+		counts[action.kind]++
+	}
+
+	if mode != syncPushOnly {
+		var out []string
+		for i, line := range lines {
+			switch {
+			case removed[i]:
+			case line.todo == nil:
+				out = append(out, line.raw)
+			default:
+				out = append(out, formatSyncLine(line.todo, plan.ids[i]))
+			}
+		}
+		out = append(out, pulled...)
+
+		text := strings.Join(out, "\n")
+		if len(out) > 0 {
+			text += "\n"
+		}
+		if err := writeFileAtomic(path, []byte(text)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	var summary []string
+	for _, kind := range syncActionKinds {
+		summary = append(summary, fmt.Sprintf("%d %s", counts[kind], kind))
+	}
+	fmt.Printf("Sync complete: %s\n", strings.Join(summary, ", "))
+	return nil
+}
+
+// errSyncCancelled is returned when the user doesn't confirm a push that would
+// archive all of their todos.
+var errSyncCancelled = errors.New("sync cancelled")
+
+// hasSyncTodos reports whether any of the lines is a todo.
+func hasSyncTodos(lines []syncLine) bool {
+	for _, line := range lines {
+		if line.todo != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmEmptyPush asks the user to confirm that the n todos on the server
+// should be archived to match the file at path, which has no todos, reading
+// the answer from in. If in isn't a terminal, the push is refused.
+func confirmEmptyPush(in io.Reader, isTerminal bool, path string, n int) error {
+	if !isTerminal {
+		return validationError(fmt.Errorf("%s has no todos, so all of your todos would be archived; use --yes to push it anyway", path))
+	}
+	if !confirm(in, fmt.Sprintf("%s has no todos. Archive all %d of your todos?", path, n)) {
+		return errSyncCancelled
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with data. The data is written to
+// a temporary file in the same directory, which is then renamed over path, so
+// that the file is never left partly written. The file's permissions are kept
+// if it exists.
+func writeFileAtomic(path string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Removing the temporary file fails harmlessly once it has been renamed.
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fetchAllTodos retrieves every page of the user's unarchived todos.
+func fetchAllTodos() ([]types.Todo, error) {
+	var todos []types.Todo
+	for page := 1; ; page++ {
+		params := url.Values{}
+		params.Set("page", strconv.Itoa(page))
+		params.Set("page_size", "100")
+
+		resp, err := getTodoList(app.Config.APIBaseURL + "/todos?" + params.Encode())
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, resp.Todos...)
+
+		if page >= resp.PaginationData.LastPage {
+			return todos, nil
+		}
+	}
+}
+
+// importSyncLines creates the todos on the given lines with the import
+// endpoint, in batches of at most data.DefaultMaxBatchSize. It returns the IDs
+// of the created todos by line, including those created before an error.
+func importSyncLines(lines []syncLine, indexes []int) (map[int]int, error) {
+	url := app.Config.APIBaseURL + "/todos/import"
+	stdoutMsg := app.failureMsg("create todos")
+
+	// handleError captures parameters that are common to all errors
+	handleError := func(logMsg string, err error) error {
+		return app.cmdError(logMsg, stdoutMsg, err,
+			"method", http.MethodPost,
+			"url", url)
+	}
+
+	ids := map[int]int{}
+	if len(indexes) == 0 {
+		return ids, nil
+	}
+
+	token, err := app.TokenManager.LoadToken()
+	if err != nil {
+		return ids, app.authError("Failed to read token", err)
+	}
+
+	for len(indexes) > 0 {
+		batch := indexes[:min(len(indexes), data.DefaultMaxBatchSize)]
+		indexes = indexes[len(batch):]
+
+		var body bytes.Buffer
+		for _, i := range batch {
+			body.WriteString(data.FormatTodo(lines[i].todo) + "\n")
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, &body)
+		if err != nil {
+			return ids, handleError("Failed to create request", err)
+		}
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return ids, handleError("Failed to send request", err)
+		}
+		respBody, err := app.readResponse(resp, handleError)
+		resp.Body.Close()
+		if err != nil {
+			return ids, err
+		}
+
+		if resp.StatusCode != http.StatusCreated {
+			return ids, handleError("Failed to import todos", responseError(resp))
+		}
+		app.invalidateCache()
+
+		var importResp struct {
+			Results []struct {
+				Line int `json:"line"`
+				Todo struct {
+					ID int `json:"id"`
+				} `json:"todo"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(respBody, &importResp); err != nil {
+			return ids, handleError("Failed to unmarshal response", err)
+		}
+		for _, result := range importResp.Results {
+			if result.Line >= 1 && result.Line <= len(batch) {
+				ids[batch[result.Line-1]] = result.Todo.ID
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().Bool("push-only", false, "only change the todos on the server")
+	syncCmd.Flags().Bool("pull-only", false, "only change the file")
+	syncCmd.Flags().BoolP("yes", "y", false, "push a file without todos without asking for confirmation")
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/kvnloughead/godo/internal/assert"
+	"golang.org/x/term"
+)
+
+func TestParseSyncLines(t *testing.T) {
+	lines := parseSyncLines("(A) call mom godo:7\r\n\nx buy milk @store\nx\nwater plants godo:abc\n")
+
+	assert.Equal(t, len(lines), 5)
+
+	assert.Equal(t, lines[0].id, 7)
+	assert.Equal(t, lines[0].todo.Text, "call mom")
+	assert.Equal(t, lines[0].todo.Priority, "A")
+
+	// Blank lines, and lines that aren't todos, are kept as they are.
+	assert.Equal(t, lines[1].todo == nil, true)
+	assert.Equal(t, lines[3].todo == nil, true)
+	assert.Equal(t, lines[3].raw, "x")
+
+	assert.Equal(t, lines[2].id, 0)
+	assert.Equal(t, lines[2].todo.Text, "buy milk @store")
+	assert.Equal(t, lines[2].todo.Completed, true)
+
+	// Words that only look like keys are part of the text.
+	assert.Equal(t, lines[4].id, 0)
+	assert.Equal(t, lines[4].todo.Text, "water plants godo:abc")
+
+	assert.Equal(t, len(parseSyncLines("")), 0)
+}
+
+func TestDiffTodos(t *testing.T) {
+	local := parseSyncLines(strings.Join([]string{
+		"(A) call mom godo:1",     // Matched by key, and the priority differs.
+		"buy milk",                // Matched by text.
+		"",                        // Not a todo.
+		"water plants",            // Only in the file.
+		"x renew passport godo:9", // Its todo is no longer on the server.
+	}, "\n"))
+	remote := []types.Todo{
+		{ID: 1, Text: "call mom"},
+		{ID: 2, Text: "buy milk"},
+		{ID: 3, Text: "pay rent"}, // Only on the server.
+	}
+
+	tests := []struct {
+		name    string
+		mode    syncMode
+		actions []string
+	}{
+		{
+			name:    "Both",
+			mode:    syncBoth,
+			actions: []string{"updated line 0 #1", "created line 3", "removed line 4", "pulled #3"},
+		},
+		{
+			name:    "Push only",
+			mode:    syncPushOnly,
+			actions: []string{"updated line 0 #1", "created line 3", "created line 4", "archived #3"},
+		},
+		{
+			name:    "Pull only",
+			mode:    syncPullOnly,
+			actions: []string{"refreshed line 0 #1", "removed line 3", "removed line 4", "pulled #3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := diffTodos(local, remote, tt.mode)
+
+			var actions []string
+			for _, a := range plan.actions {
+				s := string(a.kind)
+				if a.line != -1 {
+					s += fmt.Sprintf(" line %d", a.line)
+				}
+				if a.remote != nil {
+					s += fmt.Sprintf(" #%d", a.remote.ID)
+				}
+				actions = append(actions, s)
+			}
+			assert.Equal(t, strings.Join(actions, ", "), strings.Join(tt.actions, ", "))
+
+			// Matched lines are given the IDs of their todos.
+			assert.Equal(t, len(plan.ids), 2)
+			assert.Equal(t, plan.ids[0], 1)
+			assert.Equal(t, plan.ids[1], 2)
+		})
+	}
+}
+
+func TestDiffTodosMatching(t *testing.T) {
+	t.Run("Unchanged", func(t *testing.T) {
+		local := parseSyncLines("x (B) call mom @phone godo:1\n")
+		remote := []types.Todo{{ID: 1, Text: "call mom @phone", Priority: "B", Completed: true}}
+
+		plan := diffTodos(local, remote, syncBoth)
+		assert.Equal(t, len(plan.actions), 0)
+	})
+
+	t.Run("Key before text", func(t *testing.T) {
+		// The first line has the same text as todo #2, but todo #2 is matched
+		// to the second line by its key.
+		local := parseSyncLines("buy milk\nbuy bread godo:2\n")
+		remote := []types.Todo{{ID: 2, Text: "buy milk"}}
+
+		plan := diffTodos(local, remote, syncBoth)
+		assert.Equal(t, len(plan.actions), 2)
+		assert.Equal(t, plan.actions[0].kind, syncCreate)
+		assert.Equal(t, plan.actions[0].line, 0)
+		assert.Equal(t, plan.actions[1].kind, syncUpdate)
+		assert.Equal(t, plan.actions[1].line, 1)
+		assert.Equal(t, plan.ids[1], 2)
+	})
+
+	t.Run("Duplicate text", func(t *testing.T) {
+		// Each todo is matched to at most one line.
+		local := parseSyncLines("buy milk\nbuy milk\n")
+		remote := []types.Todo{{ID: 4, Text: "buy milk"}}
+
+		plan := diffTodos(local, remote, syncBoth)
+		assert.Equal(t, len(plan.actions), 1)
+		assert.Equal(t, plan.actions[0].kind, syncCreate)
+		assert.Equal(t, plan.actions[0].line, 1)
+		assert.Equal(t, plan.ids[0], 4)
+	})
+}
+
+func TestFormatSyncLine(t *testing.T) {
+	lines := parseSyncLines("x (A) call mom @phone\n")
+
+	assert.Equal(t, formatSyncLine(lines[0].todo, 0), "x (A) call mom @phone")
+	assert.Equal(t, formatSyncLine(lines[0].todo, 12), "x (A) call mom @phone godo:12")
+}
+
+func TestSyncPushOnlyEmptyFile(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "todo.txt")
+	if err := os.WriteFile(empty, []byte("\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+
+	tests := []struct {
+		name       string
+		path       string
+		isTerminal bool
+		input      string
+		flags      map[string]string
+		archived   bool
+		output     string
+		status     int
+	}{
+		{name: "Missing", path: missing, output: "Error: " + missing + " doesn't exist\nRun 'godo sync --help' for usage.\n", status: exitValidation},
+		{name: "Not a terminal", path: empty, output: "Error: " + empty + " has no todos, so all of your todos would be archived; use --yes to push it anyway\nRun 'godo sync --help' for usage.\n", status: exitValidation},
+		{name: "No", path: empty, isTerminal: true, input: "n\n", output: empty + " has no todos. Archive all 1 of your todos? [y/N] Error: sync cancelled\n", status: exitFailure},
+		{name: "Yes", path: empty, isTerminal: true, input: "y\n", archived: true},
+		{name: "Yes flag", path: empty, flags: map[string]string{"push-only": "true", "yes": "true"}, archived: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archived := false
+			newTestCLIApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPatch {
+					archived = true
+					io.WriteString(w, `{"todo": {"id": 1, "text": "call mom", "archived": true}}`)
+					return
+				}
+				io.WriteString(w, `{"todos": [{"id": 1, "text": "call mom"}]}`)
+			}))
+
+			stdin = strings.NewReader(tt.input)
+			stdinIsTerminal = func() bool { return tt.isTerminal }
+			t.Cleanup(func() {
+				stdin = os.Stdin
+				stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+			})
+
+			flags := tt.flags
+			if flags == nil {
+				flags = map[string]string{"push-only": "true"}
+			}
+			output, status := runCommand(t, syncCmd, []string{tt.path}, flags)
+
+			if tt.output != "" {
+				assert.Equal(t, output, tt.output)
+			}
+			assert.Equal(t, status, tt.status)
+			assert.Equal(t, archived, tt.archived)
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.txt")
+	if err := os.WriteFile(path, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeFileAtomic(path, []byte("new\n"))
+	assert.IsNil(t, err)
+
+	content, err := os.ReadFile(path)
+	assert.IsNil(t, err)
+	assert.Equal(t, string(content), "new\n")
+
+	// The permissions are kept, and the temporary file is gone.
+	info, err := os.Stat(path)
+	assert.IsNil(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0600))
+
+	entries, err := os.ReadDir(dir)
+	assert.IsNil(t, err)
+	assert.Equal(t, len(entries), 1)
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+)
+
+// tagCmd adds contexts and projects to, and removes them from, every todo item
+// matching a filter.
+var tagCmd = &cobra.Command{
+	Use:   "tag [--add <tag>]... [--remove <tag>]... <filter>...",
+	Short: "Add or remove contexts and projects on all todo items matching a filter",
+	Long: `
+Add contexts and projects to, and remove them from, all unarchived todo items
+matching a filter.
+
+Arguments beginning with "@" filter by context, and arguments beginning with
+"+" filter by project. Any other arguments are used as search text. At least
+one filter must be provided.
+
+The --add and --remove flags take a context, beginning with "@", or a project,
+beginning with "+". They can be repeated, or given a comma-separated list.
+Todos that would be left with too many contexts or projects are skipped.
+
+Examples:
+
+    # Move all todos in the groceries project from @home to @store
+    godo tag +groceries --add @store --remove @home
+
+    # Add the errands project to todos that mention "pharmacy"
+    godo tag pharmacy --add +errands
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		add, _ := cmd.Flags().GetStringSlice("add")
+		remove, _ := cmd.Flags().GetStringSlice("remove")
+
+		addText, addContexts, addProjects := parseFilterArgs(add)
+		removeText, removeContexts, removeProjects := parseFilterArgs(remove)
+		if addText != "" || removeText != "" {
+			return validationError(errors.New("--add and --remove only accept @contexts and +projects"))
+		}
+		if len(add)+len(remove) == 0 {
+			return validationError(errors.New("at least one of --add or --remove must be provided"))
+		}
+
+		url := app.Config.APIBaseURL + "/todos/tag"
+		stdoutMsg := app.failureMsg("tag todos")
+
+		handleError := func(logMsg string, err error) error {
+			return app.cmdError(logMsg, stdoutMsg, err,
+				"method", http.MethodPost,
+				"url", url)
+		}
+
+		token, err := app.TokenManager.LoadToken()
+		if err != nil {
+			return app.authError("Failed to read token", err)
+		}
+
+		text, contexts, projects := parseFilterArgs(args)
+		payload := map[string]any{
+			"text":            text,
+			"contexts":        contexts,
+			"projects":        projects,
+			"add_contexts":    addContexts,
+			"remove_contexts": removeContexts,
+			"add_projects":    addProjects,
+			"remove_projects": removeProjects,
+		}
+
+		req, err := app.createJSONRequest(http.MethodPost, url, payload)
+		if err != nil {
+			return handleError("Failed to create request", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return handleError("Failed to send request", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := app.readResponse(resp, handleError)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return handleError("Failed to tag todos", responseError(resp))
+		}
+
+		app.invalidateCache()
+
+		var tagResp struct {
+			Updated int `json:"updated"`
+			Skipped int `json:"skipped"`
+		}
+		if err := json.Unmarshal(body, &tagResp); err != nil {
+			return handleError("Failed to unmarshal response", err)
+		}
+
+		fmt.Printf("%d todo(s) updated\n", tagResp.Updated)
+		if tagResp.Skipped > 0 {
+			fmt.Printf("%d todo(s) skipped, because they would have too many contexts or projects\n", tagResp.Skipped)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+
+	tagCmd.Flags().StringSlice("add", nil, "a context (@name) or project (+name) to add")
+	tagCmd.Flags().StringSlice("remove", nil, "a context (@name) or project (+name) to remove")
+}
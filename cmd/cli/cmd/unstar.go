@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// unstarCmd removes the star from a todo item.
+var unstarCmd = &cobra.Command{
+	Use:   "unstar <id>",
+	Short: "Remove the star from a todo item",
+	Long: `
+Remove the star from a todo item. For example:
+
+    # Unstar todo #42
+    godo unstar 42
+
+This command requires authentication. Run 'godo auth -h' for more information.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setStarred(args[0], false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unstarCmd)
+}
@@ -10,8 +10,9 @@ var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "A brief description of your command",
 	Long:  `TODO - add long help text`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("update called")
+		return nil
 	},
 }
 
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/kvnloughead/godo/internal/vcs"
+	"github.com/spf13/cobra"
+)
+
+// versionCmd prints the version of the CLI.
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show the version of godo",
+	Long: `
+Show the version of godo, the Go version it was built with, and the API base
+URL that it is configured to use. The version is made from the time and
+revision of the commit that godo was built from.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		writeVersion(os.Stdout)
+		return nil
+	},
+}
+
+// writeVersion writes the CLI's version, the Go version, and the API base URL
+// to w.
+func writeVersion(w io.Writer) {
+	fmt.Fprintf(w, "Version:\t%s\n", vcs.Version())
+	fmt.Fprintf(w, "Go version:\t%s\n", runtime.Version())
+	fmt.Fprintf(w, "API base URL:\t%s\n", app.Config.APIBaseURL)
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/vcs"
+)
+
+func TestWriteVersion(t *testing.T) {
+	newTestCLIApplication(t, http.NotFoundHandler())
+
+	var buf strings.Builder
+	writeVersion(&buf)
+	out := buf.String()
+
+	assert.StringContains(t, out, "Version:\t"+vcs.Version()+"\n")
+	assert.StringContains(t, out, "Go version:\t"+runtime.Version()+"\n")
+	assert.StringContains(t, out, "API base URL:\t"+app.Config.APIBaseURL+"\n")
+}
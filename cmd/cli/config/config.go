@@ -2,9 +2,12 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
 )
 
 const (
@@ -14,24 +17,78 @@ const (
 
 type Config struct {
 	APIBaseURL string `json:"api_base_url"`
+
+	// Profiles are named sets of settings, such as one for a local server and
+	// one for production. The settings of the Active profile replace the
+	// settings above. See LoadConfig.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	Active   string             `json:"active,omitempty"`
+
+	// Profile is the name of the profile that was used, if any. It is set by
+	// LoadConfig, and isn't saved in the config file.
+	Profile string `json:"-"`
+
+	// DisableCache turns off the local cache of todo lists, which is used to
+	// show todos when the API can't be reached.
+	DisableCache bool `json:"disable_cache,omitempty"`
+
+	// BatchWorkers is the number of requests that are sent at once when a
+	// command changes several todos. If it is 0, DefaultBatchWorkers is used.
+	// LoadConfig replaces a negative value with DefaultBatchWorkers, with a
+	// warning.
+	BatchWorkers int `json:"batch_workers,omitempty"`
+
+	// DefaultListMode is the output of the list command when neither --plain
+	// nor --interactive is given. It should be one of ListModes. If it is
+	// empty, ListModeInteractive is used. The list command rejects other
+	// values, so that a typo doesn't break other commands.
+	DefaultListMode string `json:"default_list_mode,omitempty"`
+}
+
+// The modes that the list command can output todos in.
+const (
+	ListModeInteractive = "interactive"
+	ListModePlain       = "plain"
+)
+
+// ListModes are the valid values of default_list_mode.
+var ListModes = []string{ListModeInteractive, ListModePlain}
+
+// ErrUnknownProfile is returned if a profile isn't in the config file.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// DefaultBatchWorkers is the number of requests that are sent at once when a
+// command changes several todos, unless the config file sets batch_workers.
+const DefaultBatchWorkers = 4
+
+// Profile is a named set of settings in the config file. Empty settings are
+// taken from the top level of the file.
+type Profile struct {
+	APIBaseURL string `json:"api_base_url,omitempty"`
 }
 
 // LoadConfig loads the configuration file for the CLI. The config file is
 // loaded in this order:
 //  1. If a specific config file is provided as a flag or env var, use it.
-//  2. If no specific config file is provided, load ~/.config/godo/settings.json
+//  2. If no specific config file is provided, load settings.json in the config
+//     directory. See Dir.
 //  3. If no config file is found, use the default configuration.
-func LoadConfig(cfgFile string, logger *slog.Logger) (Config, error) {
+//
+// If profile isn't empty, the settings of that profile are used, and an error
+// is returned if it isn't in the file. Otherwise, the file's active profile is
+// used, if it has one. If the active profile isn't in the file, a warning is
+// printed and the top-level settings are used, so that the active profile can
+// still be changed with "godo profile use". The GODO_API_URL environment
+// variable overrides the profile's API base URL.
+func LoadConfig(cfgFile, profile string, logger *slog.Logger) (Config, error) {
 	// Default configuration
 	config := Config{
 		APIBaseURL: defaultAPIBaseURL,
 	}
 
-	configDir := filepath.Join(os.Getenv("HOME"), ".config", "godo")
-
-	// If no specific config file is provided, use default
-	if cfgFile == "" {
-		cfgFile = filepath.Join(configDir, defaultConfigFile)
+	cfgFile, err := ConfigFile(cfgFile)
+	if err != nil {
+		return config, err
 	}
 
 	// Ensure config file exists (creates it with defaults if it doesn't)
@@ -51,6 +108,31 @@ func LoadConfig(cfgFile string, logger *slog.Logger) (Config, error) {
 		return config, err
 	}
 
+	explicit := profile != ""
+	if !explicit {
+		profile = config.Active
+	}
+	if profile != "" {
+		p, err := config.lookupProfile(profile)
+		switch {
+		case err != nil && explicit:
+			logger.Error("error loading profile", "error", err)
+			return config, err
+		case err != nil:
+			warn(logger, fmt.Sprintf("ignoring the active profile: %v", err))
+		default:
+			if p.APIBaseURL != "" {
+				config.APIBaseURL = p.APIBaseURL
+			}
+			config.Profile = profile
+		}
+	}
+
+	if config.BatchWorkers < 0 {
+		warn(logger, fmt.Sprintf("ignoring batch_workers %d: it must not be negative, so %d is used", config.BatchWorkers, DefaultBatchWorkers))
+		config.BatchWorkers = DefaultBatchWorkers
+	}
+
 	// Override with environment variables
 	if url := os.Getenv("GODO_API_URL"); url != "" {
 		config.APIBaseURL = url
@@ -59,6 +141,76 @@ func LoadConfig(cfgFile string, logger *slog.Logger) (Config, error) {
 	return config, nil
 }
 
+// profileNameRX matches valid profile names. Since the name is used as a
+// directory name, it can't contain path separators or dots. See ProfileDir.
+var profileNameRX = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// lookupProfile returns the profile with the name. An error wrapping
+// ErrUnknownProfile is returned if it isn't in the config, or if the name
+// isn't valid.
+func (c Config) lookupProfile(name string) (Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("%w %q", ErrUnknownProfile, name)
+	}
+	if !profileNameRX.MatchString(name) {
+		return Profile{}, fmt.Errorf("%w %q: names may only contain letters, digits, hyphens, and underscores", ErrUnknownProfile, name)
+	}
+	return p, nil
+}
+
+// ProfileDir returns the directory where the token and cache of the profile
+// are stored, so that profiles for different servers don't share them. This
+// is dir itself if profile is empty.
+func ProfileDir(dir, profile string) string {
+	if profile == "" {
+		return dir
+	}
+	return filepath.Join(dir, "profiles", profile)
+}
+
+// warn logs msg, and prints it to stderr, for problems with the config file
+// that don't stop the CLI from running.
+func warn(logger *slog.Logger, msg string) {
+	logger.Warn(msg)
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+}
+
+// ConfigFile returns the path of the config file that LoadConfig reads. This
+// is cfgFile if it isn't empty, and otherwise settings.json in the config
+// directory.
+func ConfigFile(cfgFile string) (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, defaultConfigFile), nil
+}
+
+// Dir returns the directory where the CLI stores its config file, token, logs
+// and cache. The directory is chosen in this order:
+//  1. $GODO_CONFIG_DIR, if it is set.
+//  2. $XDG_CONFIG_HOME/godo, if XDG_CONFIG_HOME is set to an absolute path.
+//  3. ~/.config/godo.
+//
+// An error is returned if the home directory is needed, but can't be found.
+func Dir() (string, error) {
+	if dir := os.Getenv("GODO_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "godo"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding the config directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "godo"), nil
+}
+
 // EnsureConfigFile checks if the config file exists. If it doesn't, it creates
 // it with the default configuration.
 func EnsureConfigFile(cfgFile string) error {
@@ -83,3 +235,35 @@ func EnsureConfigFile(cfgFile string) error {
 	}
 	return os.WriteFile(cfgFile, data, 0644)
 }
+
+// SetActiveProfile makes the profile the active one in the config file, so
+// that it is used when no other profile is chosen. The profile must be in the
+// file. The file's other settings are kept as they are.
+func SetActiveProfile(cfgFile, profile string) error {
+	data, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	if _, err := config.lookupProfile(profile); err != nil {
+		return err
+	}
+
+	// The file is updated as a map, so that settings this version of the CLI
+	// doesn't know about aren't lost.
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return err
+	}
+	settings["active"], _ = json.Marshal(profile)
+
+	data, err = json.MarshalIndent(settings, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cfgFile, data, 0644)
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestDir(t *testing.T) {
+	tests := []struct {
+		name      string
+		configDir string
+		xdgHome   string
+		want      string
+	}{
+		{name: "Default", want: "/home/user/.config/godo"},
+		{name: "XDG_CONFIG_HOME", xdgHome: "/xdg", want: "/xdg/godo"},
+		{name: "Relative XDG_CONFIG_HOME", xdgHome: "xdg", want: "/home/user/.config/godo"},
+		{name: "GODO_CONFIG_DIR", configDir: "/profile", xdgHome: "/xdg", want: "/profile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", "/home/user")
+			t.Setenv("GODO_CONFIG_DIR", tt.configDir)
+			t.Setenv("XDG_CONFIG_HOME", tt.xdgHome)
+
+			dir, err := Dir()
+			assert.IsNil(t, err)
+			assert.Equal(t, dir, tt.want)
+
+			file, err := ConfigFile("")
+			assert.IsNil(t, err)
+			assert.Equal(t, file, filepath.Join(tt.want, "settings.json"))
+
+			file, err = ConfigFile("other.json")
+			assert.IsNil(t, err)
+			assert.Equal(t, file, "other.json")
+		})
+	}
+}
+
+func TestDirWithoutHome(t *testing.T) {
+	t.Setenv("HOME", "")
+	t.Setenv("GODO_CONFIG_DIR", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	_, err := Dir()
+	assert.Equal(t, err != nil, true)
+
+	// The home directory isn't needed if the directory is set explicitly.
+	t.Setenv("GODO_CONFIG_DIR", "/profile")
+	dir, err := Dir()
+	assert.IsNil(t, err)
+	assert.Equal(t, dir, "/profile")
+}
+
+// writeConfigFile writes a config file with the content to a temporary
+// directory, and returns its path.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	cfgFile := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return cfgFile
+}
+
+func TestLoadConfigProfiles(t *testing.T) {
+	const profiles = `{
+		"api_base_url": "http://flat/v1",
+		"profiles": {
+			"dev": {"api_base_url": "http://localhost:4000/v1"},
+			"prod": {"api_base_url": "https://prod/v1"},
+			"empty": {}
+		},
+		"active": "dev"
+	}`
+
+	tests := []struct {
+		name        string
+		content     string
+		profile     string
+		envURL      string
+		wantURL     string
+		wantProfile string
+		wantErr     string
+	}{
+		{
+			name:    "Flat api_base_url",
+			content: `{"api_base_url": "http://flat/v1"}`,
+			wantURL: "http://flat/v1",
+		},
+		{
+			name:        "Active profile",
+			content:     profiles,
+			wantURL:     "http://localhost:4000/v1",
+			wantProfile: "dev",
+		},
+		{
+			name:        "Profile flag overrides active",
+			content:     profiles,
+			profile:     "prod",
+			wantURL:     "https://prod/v1",
+			wantProfile: "prod",
+		},
+		{
+			name:        "Profile without api_base_url",
+			content:     profiles,
+			profile:     "empty",
+			wantURL:     "http://flat/v1",
+			wantProfile: "empty",
+		},
+		{
+			name:        "GODO_API_URL overrides profile",
+			content:     profiles,
+			envURL:      "http://env/v1",
+			wantURL:     "http://env/v1",
+			wantProfile: "dev",
+		},
+		{
+			name:    "Unknown profile",
+			content: profiles,
+			profile: "staging",
+			wantErr: `unknown profile "staging"`,
+		},
+		{
+			name:    "Unknown active profile falls back",
+			content: `{"api_base_url": "http://flat/v1", "profiles": {"dev": {}}, "active": "staging"}`,
+			wantURL: "http://flat/v1",
+		},
+		{
+			name:    "Invalid profile name",
+			content: `{"profiles": {"../dev": {}}}`,
+			profile: "../dev",
+			wantErr: `unknown profile "../dev"`,
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GODO_API_URL", tt.envURL)
+
+			cfg, err := LoadConfig(writeConfigFile(t, tt.content), tt.profile, logger)
+			if tt.wantErr != "" {
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.IsNil(t, err)
+			assert.Equal(t, cfg.APIBaseURL, tt.wantURL)
+			assert.Equal(t, cfg.Profile, tt.wantProfile)
+		})
+	}
+}
+
+func TestSetActiveProfile(t *testing.T) {
+	cfgFile := writeConfigFile(t, `{
+		"api_base_url": "http://flat/v1",
+		"profiles": {"dev": {}, "prod": {}},
+		"active": "dev",
+		"unknown_setting": 7
+	}`)
+
+	assert.IsNil(t, SetActiveProfile(cfgFile, "prod"))
+
+	data, err := os.ReadFile(cfgFile)
+	assert.IsNil(t, err)
+	var settings map[string]any
+	assert.IsNil(t, json.Unmarshal(data, &settings))
+	assert.Equal(t, settings["active"], any("prod"))
+	assert.Equal(t, settings["api_base_url"], any("http://flat/v1"))
+	assert.Equal(t, settings["unknown_setting"], any(float64(7)))
+
+	err = SetActiveProfile(cfgFile, "staging")
+	assert.StringContains(t, err.Error(), `unknown profile "staging"`)
+}
+
+func TestLoadConfigDefaultListMode(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cfg, err := LoadConfig(writeConfigFile(t, `{"default_list_mode": "plain"}`), "", logger)
+	assert.IsNil(t, err)
+	assert.Equal(t, cfg.DefaultListMode, ListModePlain)
+
+	// Invalid values are left for the list command to reject, so that they
+	// don't break other commands.
+	cfg, err = LoadConfig(writeConfigFile(t, `{"default_list_mode": "fancy"}`), "", logger)
+	assert.IsNil(t, err)
+	assert.Equal(t, cfg.DefaultListMode, "fancy")
+}
+
+func TestLoadConfigBatchWorkers(t *testing.T) {
+	tests := []struct {
+		content string
+		want    int
+		warning bool
+	}{
+		{content: `{}`, want: 0},
+		{content: `{"batch_workers": 8}`, want: 8},
+		{content: `{"batch_workers": -2}`, want: DefaultBatchWorkers, warning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			var logs strings.Builder
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			cfg, err := LoadConfig(writeConfigFile(t, tt.content), "", logger)
+			assert.IsNil(t, err)
+			assert.Equal(t, cfg.BatchWorkers, tt.want)
+			assert.Equal(t, strings.Contains(logs.String(), "ignoring batch_workers -2"), tt.warning)
+		})
+	}
+}
+
+func TestProfileDir(t *testing.T) {
+	assert.Equal(t, ProfileDir("/config", ""), "/config")
+	assert.Equal(t, ProfileDir("/config", "dev"), filepath.Join("/config", "profiles", "dev"))
+}
@@ -5,7 +5,9 @@ package interactive
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"slices"
 	"strconv"
@@ -14,6 +16,9 @@ import (
 	"github.com/kvnloughead/godo/cmd/cli/types"
 )
 
+// ErrQuit is returned by Prompt when the user quits interactive mode.
+var ErrQuit = errors.New("interactive: quit")
+
 // Command represents an action that can be performed on an item. Each command
 // has a primary name, optional aliases, and an action function to execute.
 type Command struct {
@@ -30,6 +35,11 @@ type Command struct {
 type Mode struct {
 	commands map[string]*Command
 	todos    []types.Todo
+	// The reader that commands are read from. It is os.Stdin, except in tests.
+	in io.Reader
+	// reader buffers in. It is kept between prompts, so that input read ahead
+	// of the current line isn't lost.
+	reader *bufio.Reader
 }
 
 // New creates a new interactive mode with the provided commands.
@@ -47,27 +57,38 @@ type Mode struct {
 func New(commands map[string]*Command) *Mode {
 	return &Mode{
 		commands: commands,
+		in:       os.Stdin,
 	}
 }
 
 // Prompt starts an interactive session, displaying the current items and
 // accepting user commands. It handles command parsing, validation, and
-// execution. Returns an error if command execution fails.
+// execution. Returns an error if command execution fails, or ErrQuit if the
+// user quits, so that the caller can end its loop. Reaching the end of the
+// input, such as when Ctrl-D is pressed, also quits. A final line without a
+// newline is run before quitting.
 func (m *Mode) Prompt(todos []types.Todo) error {
 	m.todos = todos
 
 	fmt.Print("Enter command (? for help): ")
 
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
+	if m.reader == nil {
+		m.reader = bufio.NewReader(m.in)
+	}
+	input, err := m.reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
 		return fmt.Errorf("error reading input: %v", err)
 	}
 	input = strings.TrimSpace(input)
 
+	if errors.Is(err, io.EOF) && input == "" {
+		fmt.Print("\nExiting interactive mode.\n\n")
+		return ErrQuit
+	}
+
 	if input == "q" || input == "quit" || input == "exit" {
 		fmt.Print("Exiting interactive mode.\n\n")
-		os.Exit(0)
+		return ErrQuit
 	}
 
 	if input == "?" || input == "help" {
@@ -105,30 +126,85 @@ func (m *Mode) executeCommand(input string) error {
 		return fmt.Errorf("no todo numbers provided")
 	}
 
-	var ids []int
-	for _, numStr := range fields[1:] {
-		num, err := strconv.Atoi(numStr)
-		if err != nil {
-			return fmt.Errorf("invalid todo number: %s", numStr)
+	nums, err := parseSelection(fields[1:], len(m.todos))
+	if err != nil {
+		return err
+	}
+
+	// Convert from 1-based display numbers to actual todo IDs
+	ids := make([]int, len(nums))
+	for i, num := range nums {
+		ids[i] = m.todos[num-1].ID
+	}
+
+	return cmd.Action(ids)
+}
+
+// parseSelection expands the arguments of a command into 1-based display
+// numbers, each no greater than count. An argument is either a number, a range
+// of numbers such as "1-8", which includes both ends, or "all", which selects
+// every displayed todo. The numbers are returned in the order they were
+// given, without duplicates.
+func parseSelection(args []string, count int) ([]int, error) {
+	var nums []int
+	add := func(num int) {
+		if !slices.Contains(nums, num) {
+			nums = append(nums, num)
 		}
-		if num < 1 || num > len(m.todos) {
+	}
+	inRange := func(num int) error {
+		if num < 1 || num > count {
 			return fmt.Errorf("todo number out of range: %d", num)
 		}
-		// Convert from 1-based display number to actual todo ID
-		ids = append(ids, m.todos[num-1].ID)
+		return nil
 	}
 
-	return cmd.Action(ids)
+	for _, arg := range args {
+		if arg == "all" {
+			for num := 1; num <= count; num++ {
+				add(num)
+			}
+			continue
+		}
+
+		first, last, isRange := strings.Cut(arg, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid todo number: %s", arg)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid range: %s", arg)
+			}
+		}
+
+		if err := inRange(start); err != nil {
+			return nil, err
+		}
+		if err := inRange(end); err != nil {
+			return nil, err
+		}
+		for num := start; num <= end; num++ {
+			add(num)
+		}
+	}
+
+	return nums, nil
 }
 
 // showHelp displays the available commands in interactive mode.
 func (m *Mode) showHelp() {
 	fmt.Println("\nUsage:")
 	fmt.Println("  command [numbers...]   Apply command to one or more todos")
+	fmt.Println("  Numbers can be single numbers, ranges such as 1-8, or all.")
 	fmt.Println("\nExamples:")
-	fmt.Println("  rm 1 2 3  \tDelete todos 1, 2, and 3")
-	fmt.Println("  done 4 5  \tMark todos 4 and 5 as done")
-	fmt.Println("  archive 6 \tArchive todo 6")
+	fmt.Println("  rm 1 2 3    \tDelete todos 1, 2, and 3")
+	fmt.Println("  done 4 5    \tMark todos 4 and 5 as done")
+	fmt.Println("  archive 6   \tArchive todo 6")
+	fmt.Println("  done 1-3 5  \tMark todos 1, 2, 3, and 5 as done")
+	fmt.Println("  archive all \tArchive every listed todo")
 	fmt.Println("\nCommands:")
 	for _, cmd := range m.commands {
 		aliases := strings.Join(cmd.Aliases, "/")
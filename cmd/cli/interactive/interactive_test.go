@@ -0,0 +1,85 @@
+package interactive
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kvnloughead/godo/cmd/cli/types"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		nums   string
+		errMsg string
+	}{
+		{name: "Numbers", args: []string{"2", "4"}, nums: "[2 4]"},
+		{name: "Range", args: []string{"1-3"}, nums: "[1 2 3]"},
+		{name: "Range and number", args: []string{"1-3", "5"}, nums: "[1 2 3 5]"},
+		{name: "All", args: []string{"all"}, nums: "[1 2 3 4 5]"},
+		{name: "Duplicates are removed", args: []string{"2", "1-3", "2"}, nums: "[2 1 3]"},
+		{name: "Range of one", args: []string{"4-4"}, nums: "[4]"},
+		{name: "Range past the end", args: []string{"3-6"}, errMsg: "todo number out of range: 6"},
+		{name: "Backwards range", args: []string{"3-1"}, errMsg: "invalid range: 3-1"},
+		{name: "Open range", args: []string{"3-"}, errMsg: "invalid range: 3-"},
+		{name: "Zero", args: []string{"0"}, errMsg: "todo number out of range: 0"},
+		{name: "Not a number", args: []string{"x"}, errMsg: "invalid todo number: x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nums, err := parseSelection(tt.args, 5)
+			if tt.errMsg != "" {
+				assert.Equal(t, err.Error(), tt.errMsg)
+				return
+			}
+			assert.IsNil(t, err)
+			assert.Equal(t, fmt.Sprint(nums), tt.nums)
+		})
+	}
+}
+
+func TestExecuteCommandRange(t *testing.T) {
+	var got []int
+	m := New(map[string]*Command{
+		"done": {Name: "done", Aliases: []string{"d"}, Action: func(ids []int) error {
+			got = ids
+			return nil
+		}},
+	})
+	m.todos = []types.Todo{{ID: 10}, {ID: 20}, {ID: 30}}
+
+	// Display numbers are converted to the todos' IDs.
+	assert.IsNil(t, m.executeCommand("d 2-3"))
+	assert.Equal(t, fmt.Sprint(got), "[20 30]")
+
+	assert.IsNil(t, m.executeCommand("done all"))
+	assert.Equal(t, fmt.Sprint(got), "[10 20 30]")
+}
+
+func TestPromptQuit(t *testing.T) {
+	for _, input := range []string{"q\n", "quit\n", "exit\n", "q", ""} {
+		m := New(nil)
+		m.in = strings.NewReader(input)
+
+		err := m.Prompt([]types.Todo{{ID: 1}})
+		assert.Equal(t, errors.Is(err, ErrQuit), true)
+	}
+
+	// Other input doesn't quit.
+	m := New(nil)
+	m.in = strings.NewReader("help\n")
+	assert.IsNil(t, m.Prompt(nil))
+
+	// A final line without a newline is run, and then the end of the input
+	// quits.
+	m = New(nil)
+	m.in = strings.NewReader("help\nhelp")
+	assert.IsNil(t, m.Prompt(nil))
+	assert.IsNil(t, m.Prompt(nil))
+	assert.Equal(t, errors.Is(m.Prompt(nil), ErrQuit), true)
+}
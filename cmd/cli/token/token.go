@@ -2,14 +2,28 @@
 // authenticate HTTP requests to the API made from the CLI.
 //
 // The token is stored in the user's home directory in a file named ".token" or
-// ".token.dev" depending on the environment.
+// ".token.dev" depending on the environment. The token is on the first line of
+// the file, and its expiry, in RFC 3339 format, is on the second. Files saved
+// by older versions of the CLI have no expiry. The file is read once, and kept
+// in memory for the rest of the process.
+//
+// If the GODO_TOKEN environment variable is set, its token is used instead of
+// the file, so that the CLI can be used where writing a file is awkward, such
+// as on CI runners.
 
 package token
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/data"
 )
 
 const (
@@ -17,10 +31,23 @@ const (
 	devTokenFile     = ".token.dev"
 )
 
+// EnvVar is the environment variable that overrides the token file.
+const EnvVar = "GODO_TOKEN"
+
+// ErrExpired is returned by LoadToken if the token has expired, so that
+// commands can ask the user to authenticate again instead of sending a request
+// that the API would reject.
+var ErrExpired = errors.New("token: expired")
+
 // Manager is a struct that manages the token file.
 type Manager struct {
 	configDir string // The directory where the token file is stored.
 	isDev     bool   // Whether the token is for development.
+
+	mu     sync.Mutex
+	token  string    // The token, once it has been read from the file.
+	expiry time.Time // When the token expires. Zero if it isn't known.
+	loaded bool      // Whether token has been read.
 }
 
 // NewManager creates a new Manager. It determines whether the token is for
@@ -40,21 +67,85 @@ func (m *Manager) TokenFile() string {
 	return filepath.Join(m.configDir, defaultTokenFile)
 }
 
-// SaveToken saves the authentication token to the token file.
-func (m *Manager) SaveToken(token string) error {
-	return os.WriteFile(m.TokenFile(), []byte(token), 0600)
+// SaveToken saves the authentication token and its expiry to the token file.
+// If the expiry is zero, only the token is saved.
+func (m *Manager) SaveToken(token string, expiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A profile's directory is only created once it has a token.
+	if err := os.MkdirAll(m.configDir, 0700); err != nil {
+		return err
+	}
+
+	data := token
+	if !expiry.IsZero() {
+		data += "\n" + expiry.Format(time.RFC3339) + "\n"
+	}
+	if err := os.WriteFile(m.TokenFile(), []byte(data), 0600); err != nil {
+		return err
+	}
+	m.token, m.expiry, m.loaded = token, expiry, true
+	return nil
 }
 
-// LoadToken loads the authentication token from the token file.
+// LoadToken loads the authentication token from the token file. The file is
+// only read the first time, or after Forget is called. If the token has
+// expired, ErrExpired is returned.
+//
+// If the EnvVar environment variable is set, its token is returned instead,
+// and the file isn't read. An error is returned if it isn't a valid token.
 func (m *Manager) LoadToken() (string, error) {
-	data, err := os.ReadFile(m.TokenFile())
-	if err != nil {
-		return "", err
+	if token := os.Getenv(EnvVar); token != "" {
+		v := validator.New()
+		data.ValidateTokenPlaintext(v, token)
+		if !v.Valid() {
+			return "", fmt.Errorf("token: invalid %s: %s", EnvVar, v.Errors["token"])
+		}
+		return token, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		data, err := os.ReadFile(m.TokenFile())
+		if err != nil {
+			return "", err
+		}
+
+		token, expiry, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+		m.token, m.expiry = token, time.Time{}
+		if expiry != "" {
+			m.expiry, err = time.Parse(time.RFC3339, strings.TrimSpace(expiry))
+			if err != nil {
+				return "", fmt.Errorf("token: invalid expiry in %s: %w", m.TokenFile(), err)
+			}
+		}
+		m.loaded = true
 	}
-	return string(data), nil
+
+	// The expiry is checked every time, since a long interactive session can
+	// outlast the token.
+	if !m.expiry.IsZero() && !time.Now().Before(m.expiry) {
+		return "", ErrExpired
+	}
+	return m.token, nil
+}
+
+// Forget discards the token kept in memory, so that the next call to
+// LoadToken reads the token file again. It is called when the API rejects the
+// token, in case it has since been replaced, such as by logging in from
+// another shell.
+func (m *Manager) Forget() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.token, m.expiry, m.loaded = "", time.Time{}, false
 }
 
 // DeleteToken deletes the authentication token from the token file.
 func (m *Manager) DeleteToken() error {
+	m.Forget()
 	return os.Remove(m.TokenFile())
 }
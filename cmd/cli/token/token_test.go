@@ -0,0 +1,100 @@
+package token
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestLoadToken(t *testing.T) {
+	m := NewManager(t.TempDir(), "https://godo.example.com/v1")
+
+	_, err := m.LoadToken()
+	assert.Equal(t, os.IsNotExist(err), true)
+
+	err = m.SaveToken("first", time.Time{})
+	assert.IsNil(t, err)
+
+	// Changes to the file aren't seen until the token is forgotten.
+	err = os.WriteFile(m.TokenFile(), []byte("second"), 0600)
+	assert.IsNil(t, err)
+
+	token, err := m.LoadToken()
+	assert.IsNil(t, err)
+	assert.Equal(t, token, "first")
+
+	m.Forget()
+	token, err = m.LoadToken()
+	assert.IsNil(t, err)
+	assert.Equal(t, token, "second")
+
+	// A deleted token isn't kept in memory.
+	err = m.DeleteToken()
+	assert.IsNil(t, err)
+	_, err = m.LoadToken()
+	assert.Equal(t, os.IsNotExist(err), true)
+}
+
+func TestLoadTokenExpiry(t *testing.T) {
+	m := NewManager(t.TempDir(), "https://godo.example.com/v1")
+
+	// The expiry is saved with the token.
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	err := m.SaveToken("valid", expiry)
+	assert.IsNil(t, err)
+
+	m.Forget()
+	token, err := m.LoadToken()
+	assert.IsNil(t, err)
+	assert.Equal(t, token, "valid")
+	assert.Equal(t, m.expiry.Equal(expiry), true)
+
+	// An expired token isn't returned.
+	err = os.WriteFile(m.TokenFile(), []byte("expired\n2020-01-01T00:00:00Z\n"), 0600)
+	assert.IsNil(t, err)
+	m.Forget()
+	_, err = m.LoadToken()
+	assert.Equal(t, errors.Is(err, ErrExpired), true)
+
+	// A token saved without an expiry, as by older versions, doesn't expire.
+	err = os.WriteFile(m.TokenFile(), []byte("old"), 0600)
+	assert.IsNil(t, err)
+	m.Forget()
+	token, err = m.LoadToken()
+	assert.IsNil(t, err)
+	assert.Equal(t, token, "old")
+
+	err = os.WriteFile(m.TokenFile(), []byte("bad\nyesterday\n"), 0600)
+	assert.IsNil(t, err)
+	m.Forget()
+	_, err = m.LoadToken()
+	assert.StringContains(t, err.Error(), "invalid expiry")
+}
+
+func TestLoadTokenFromEnv(t *testing.T) {
+	m := NewManager(t.TempDir(), "https://godo.example.com/v1")
+	err := m.SaveToken("FILETOKENFILETOKENFILETOKE", time.Now().Add(-time.Hour))
+	assert.IsNil(t, err)
+
+	// The environment variable takes precedence over the file, even if the
+	// file's token has expired.
+	t.Setenv(EnvVar, "F6SB76ZCLKLJBHP7K7A6N2S7JM")
+	token, err := m.LoadToken()
+	assert.IsNil(t, err)
+	assert.Equal(t, token, "F6SB76ZCLKLJBHP7K7A6N2S7JM")
+
+	// The file isn't needed.
+	err = m.DeleteToken()
+	assert.IsNil(t, err)
+	token, err = m.LoadToken()
+	assert.IsNil(t, err)
+	assert.Equal(t, token, "F6SB76ZCLKLJBHP7K7A6N2S7JM")
+
+	// The token must be valid.
+	t.Setenv(EnvVar, "short")
+	_, err = m.LoadToken()
+	assert.StringContains(t, err.Error(), "invalid GODO_TOKEN: must be 26 bytes long")
+}
@@ -9,14 +9,19 @@ type PaginationData struct {
 }
 
 type Todo struct {
-	ID        int    `json:"id"`
-	UserID    int    `json:"user_id"`
-	CreatedAt string `json:"created_at"`
-	Text      string `json:"text"`
-	Priority  string `json:"priority"`
-	Completed bool   `json:"completed"`
-	Archived  bool   `json:"archived"`
-	Version   int    `json:"version"`
+	ID        int      `json:"id"`
+	UserID    int      `json:"user_id"`
+	CreatedAt string   `json:"created_at"`
+	Text      string   `json:"text"`
+	Note      string   `json:"note"`
+	Contexts  []string `json:"contexts"`
+	Projects  []string `json:"projects"`
+	Priority  string   `json:"priority"`
+	Completed bool     `json:"completed"`
+	Archived  bool     `json:"archived"`
+	Starred   bool     `json:"starred"`
+	DueDate   string   `json:"due_date"`
+	Version   int      `json:"version"`
 }
 
 type TodoResponse struct {
@@ -24,6 +29,29 @@ type TodoResponse struct {
 	Todos          []Todo         `json:"todos"`
 }
 
+// Agenda is a user's overdue, due today, and high priority todos, as returned
+// by GET /v1/agenda.
+type Agenda struct {
+	Overdue      []Todo `json:"overdue"`
+	DueToday     []Todo `json:"due_today"`
+	HighPriority []Todo `json:"high_priority"`
+}
+
+// ReportBucket is the number of todos completed in the week or month that
+// begins at Start, as returned by GET /v1/report.
+type ReportBucket struct {
+	Start     string `json:"start"`
+	Completed int    `json:"completed"`
+}
+
+// Session is an active token, as returned by GET /v1/tokens.
+type Session struct {
+	HashPrefix string `json:"hash_prefix"`
+	Scope      string `json:"scope"`
+	Expiry     string `json:"expiry"`
+	CreatedAt  string `json:"created_at"`
+}
+
 // queryFlag represents a boolean flag that maps to a URL query parameter
 type QueryFlag struct {
 	Flag  string // flag name in CLI
@@ -0,0 +1,2 @@
+ALTER TABLE tokens
+ADD COLUMN created_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
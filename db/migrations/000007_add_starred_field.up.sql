@@ -0,0 +1,2 @@
+ALTER TABLE todos 
+ADD COLUMN starred BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,18 @@
+--- The idempotency_keys table records the Idempotency-Key headers of requests
+--- to create todos, so that retried requests don't create duplicates.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  --- DELETE CASCADE ensures that a user's keys are deleted along with them.
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+
+  key text NOT NULL,
+
+  --- The todo created by the request. It is NULL until the todo is inserted,
+  --- which happens in the same transaction. If the todo is deleted, the key is
+  --- deleted too, and can be reused.
+  todo_id bigint REFERENCES todos ON DELETE CASCADE,
+
+  expiry timestamp(0) with time zone NOT NULL,
+
+  PRIMARY KEY (user_id, key)
+);
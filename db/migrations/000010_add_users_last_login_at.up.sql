@@ -0,0 +1,4 @@
+--- last_login_at is the time the user last authenticated. It is NULL until
+--- their first login.
+ALTER TABLE users
+ADD COLUMN last_login_at timestamp(0) with time zone;
@@ -0,0 +1,3 @@
+ALTER TABLE todos
+DROP COLUMN due_date,
+DROP COLUMN priority_escalated_at;
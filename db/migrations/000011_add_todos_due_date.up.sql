@@ -0,0 +1,5 @@
+--- due_date is the date the todo is due, if any. priority_escalated_at is the
+--- time its priority was last raised because it was overdue.
+ALTER TABLE todos
+ADD COLUMN due_date date,
+ADD COLUMN priority_escalated_at timestamp(0) with time zone;
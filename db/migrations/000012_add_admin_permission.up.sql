@@ -0,0 +1,4 @@
+--- The admin permission allows a user to grant and revoke other users'
+--- permissions.
+INSERT INTO permissions (code)
+VALUES ('admin');
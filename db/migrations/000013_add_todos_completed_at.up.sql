@@ -0,0 +1,3 @@
+--- completed_at is the time the todo was completed, or NULL if it isn't.
+ALTER TABLE todos
+ADD COLUMN completed_at timestamp(0) with time zone;
@@ -0,0 +1,5 @@
+--- response is the results of a request to import todos, which are sent
+--- again if the request is retried. It is NULL for keys used to create a
+--- single todo, which are replayed from todo_id instead.
+ALTER TABLE idempotency_keys
+ADD COLUMN response jsonb;
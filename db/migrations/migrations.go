@@ -0,0 +1,11 @@
+// Package migrations embeds the database migrations, so that they can be
+// applied by the API itself. See the migrate package.
+package migrations
+
+import "embed"
+
+// FS contains the migration files, named in the format used by the migrate
+// CLI: 000001_create_users_table.up.sql and 000001_create_users_table.down.sql.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AgendaHighPriority is the lowest priority that a todo can have to be listed
+// as high priority in an Agenda.
+const AgendaHighPriority = "B"
+
+// Agenda is a focused view of a user's active todos, in three groups. Each
+// todo appears in at most one group, the first that it belongs to.
+type Agenda struct {
+	Overdue      []*Todo `json:"overdue"`       // Due before today, oldest first.
+	DueToday     []*Todo `json:"due_today"`     // Due today.
+	HighPriority []*Todo `json:"high_priority"` // Priority A or B, and not yet due.
+}
+
+// GroupAgenda groups the todos into an Agenda for the date today. Only the
+// date of today is used, so that all clients agree on which todos are due
+// today, whatever the time of day. Completed and archived todos are excluded.
+//
+// Within each group, todos are sorted by due date, then by priority, then by
+// ID. Todos without a priority are sorted last.
+func GroupAgenda(todos []*Todo, today time.Time) Agenda {
+	agenda := Agenda{Overdue: []*Todo{}, DueToday: []*Todo{}, HighPriority: []*Todo{}}
+	date := today.Format(time.DateOnly)
+
+	for _, t := range todos {
+		if t.Completed || t.Archived {
+			continue
+		}
+
+		var due string
+		if t.DueDate != nil {
+			due = t.DueDate.Format(time.DateOnly)
+		}
+
+		switch {
+		case due != "" && due < date:
+			agenda.Overdue = append(agenda.Overdue, t)
+		case due == date:
+			agenda.DueToday = append(agenda.DueToday, t)
+		case t.Priority != "" && t.Priority <= AgendaHighPriority:
+			agenda.HighPriority = append(agenda.HighPriority, t)
+		}
+	}
+
+	for _, group := range [][]*Todo{agenda.Overdue, agenda.DueToday, agenda.HighPriority} {
+		slices.SortStableFunc(group, compareAgendaTodos)
+	}
+
+	return agenda
+}
+
+// compareAgendaTodos orders todos by due date, then by priority, then by ID.
+// Todos without a due date or priority are ordered after those with one.
+func compareAgendaTodos(a, b *Todo) int {
+	switch {
+	case a.DueDate != nil && b.DueDate == nil:
+		return -1
+	case a.DueDate == nil && b.DueDate != nil:
+		return 1
+	case a.DueDate != nil && !a.DueDate.Equal(*b.DueDate):
+		return a.DueDate.Compare(*b.DueDate)
+	}
+
+	switch {
+	case a.Priority != "" && b.Priority == "":
+		return -1
+	case a.Priority == "" && b.Priority != "":
+		return 1
+	case a.Priority != b.Priority:
+		return strings.Compare(a.Priority, b.Priority)
+	}
+
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// GetAgenda retrieves the user's active todos that belong in their Agenda for
+// the date today, grouped by GroupAgenda.
+func (m TodoModel) GetAgenda(ctx context.Context, userID int64, today time.Time) (Agenda, error) {
+	todos, err := m.getMatching(ctx,
+		`WHERE user_id = $1 AND completed = false AND archived = false AND (due_date <= $2 OR priority BETWEEN 'A' AND $3)`,
+		[]any{userID, today.Format(time.DateOnly), AgendaHighPriority})
+	if err != nil {
+		return Agenda{}, err
+	}
+
+	return GroupAgenda(todos, today), nil
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestGroupAgenda(t *testing.T) {
+	// The time of day doesn't matter, only the date.
+	today := time.Date(2024, 3, 10, 23, 30, 0, 0, time.UTC)
+	date := func(s string) *time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+
+	todos := []*Todo{
+		{ID: 1, Priority: "C", DueDate: date("2024-03-09")},
+		{ID: 2, DueDate: date("2024-03-01")},
+		{ID: 3, DueDate: date("2024-03-10")},
+		{ID: 4, Priority: "A", DueDate: date("2024-03-10")},
+		{ID: 5, Priority: "B"},
+		{ID: 6, Priority: "A", DueDate: date("2024-03-20")},
+		// Not in the agenda.
+		{ID: 7, Priority: "C"},
+		{ID: 8, DueDate: date("2024-03-11")},
+		{ID: 9, Priority: "A", DueDate: date("2024-03-09"), Completed: true},
+		{ID: 10, Priority: "A", Archived: true},
+	}
+
+	agenda := GroupAgenda(todos, today)
+
+	ids := func(todos []*Todo) []int64 {
+		ids := []int64{}
+		for _, t := range todos {
+			ids = append(ids, t.ID)
+		}
+		return ids
+	}
+
+	// Overdue todos are sorted by due date, and todos due on the same date by
+	// priority.
+	assert.Equal(t, fmt.Sprint(ids(agenda.Overdue)), "[2 1]")
+	assert.Equal(t, fmt.Sprint(ids(agenda.DueToday)), "[4 3]")
+	assert.Equal(t, fmt.Sprint(ids(agenda.HighPriority)), "[6 5]")
+
+	// Empty groups are empty slices, so that they are sent as [] rather than
+	// null.
+	empty := GroupAgenda(nil, today)
+	assert.Equal(t, empty.Overdue != nil && empty.DueToday != nil && empty.HighPriority != nil, true)
+}
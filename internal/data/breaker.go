@@ -0,0 +1,177 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Defaults for the Breaker created by NewModels.
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 10 * time.Second
+)
+
+// States of a Breaker, as returned by Breaker.State.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Breaker is a circuit breaker for database queries. It opens after threshold
+// consecutive queries fail with bad connection errors, and while it is open,
+// queries fail immediately with ErrCircuitOpen instead of waiting on a
+// database that is down.
+//
+// Once the cooldown has elapsed, the breaker is half-open, and queries are
+// attempted again. The first success closes it, and the first failure opens
+// it for another cooldown.
+//
+// A nil *Breaker never opens.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+// NewBreaker returns a closed Breaker. If threshold or cooldown aren't
+// positive, DefaultBreakerThreshold and DefaultBreakerCooldown are used.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// State returns BreakerClosed, BreakerOpen, or BreakerHalfOpen.
+func (b *Breaker) State() string {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.failures < b.threshold:
+		return BreakerClosed
+	case b.now().Before(b.openUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// Cooldown returns how long the breaker stays open after it opens. For a nil
+// *Breaker, it is DefaultBreakerCooldown.
+func (b *Breaker) Cooldown() time.Duration {
+	if b == nil {
+		return DefaultBreakerCooldown
+	}
+	return b.cooldown
+}
+
+// allow reports whether a query should be attempted.
+func (b *Breaker) allow() bool {
+	return b.State() != BreakerOpen
+}
+
+// record updates the breaker with the result of a query. Only bad connection
+// errors count as failures, since other errors show that the database is up.
+func (b *Breaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isBadConn(err) {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// isBadConn reports whether the error shows that the query's connection is
+// unusable, such as after Postgres restarts. The query may have run before the
+// connection was lost, so it isn't necessarily safe to retry. See notAttempted.
+func isBadConn(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08 is connection exceptions.
+		return pqErr.Code.Class() == "08"
+	}
+
+	return false
+}
+
+// notAttempted reports whether the error shows that the query was never sent,
+// because a connection couldn't be made. Unlike other bad connection errors,
+// such as when Postgres is shut down mid-query, these are safe to retry even
+// for writes, since the query can't have run.
+func notAttempted(err error) bool {
+	// database/sql already retries driver.ErrBadConn, but it is returned once
+	// its own retries are used up.
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "08001", // sqlclient_unable_to_establish_sqlconnection
+			"08004", // sqlserver_rejected_establishment_of_sqlconnection
+			"57P03": // cannot_connect_now
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryBadConn calls fn, and calls it again up to retries times while it
+// fails because the query couldn't be sent. See notAttempted. The pool
+// discards bad connections, so each retry uses a new one. Other bad connection
+// errors aren't retried, since a write such as an insert may have run, but
+// they are counted by the breaker. If the breaker is open, fn isn't called,
+// and ErrCircuitOpen is returned.
+func retryBadConn(ctx context.Context, b *Breaker, retries int, fn func() error) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+
+	err := fn()
+	for i := 0; i < retries && notAttempted(err) && ctx.Err() == nil; i++ {
+		err = fn()
+	}
+
+	b.record(err)
+	return err
+}
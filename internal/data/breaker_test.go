@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/lib/pq"
+)
+
+func TestRetryBadConn(t *testing.T) {
+	// database/sql already retries driver.ErrBadConn itself, so the bad
+	// connections are simulated with the error Postgres sends while it is
+	// starting up.
+	startingUp := &pq.Error{Code: "57P03", Message: "the database system is starting up"}
+
+	// The query may have run before the connection was terminated.
+	adminShutdown := &pq.Error{Code: "57P01", Message: "terminating connection due to administrator command"}
+
+	tests := []struct {
+		name    string
+		retries int
+		errs    []error // Errors returned by each attempt. nil means success.
+		wantErr error
+	}{
+		{name: "Success on retry", retries: 1, errs: []error{startingUp, nil}},
+		{name: "Bad connection on retry", retries: 1, errs: []error{startingUp, startingUp}, wantErr: startingUp},
+		{name: "Success on third retry", retries: 3, errs: []error{startingUp, startingUp, startingUp, nil}},
+		{name: "Retries disabled", retries: 0, errs: []error{startingUp}, wantErr: startingUp},
+		{name: "Terminated connection not retried", retries: 1, errs: []error{adminShutdown}, wantErr: adminShutdown},
+		{name: "Other errors not retried", retries: 1, errs: []error{errors.New("syntax error")}, wantErr: errors.New("syntax error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+			m.Breaker = NewBreaker(0, 0)
+			m.Retries = tt.retries
+
+			for _, err := range tt.errs {
+				exp := mock.ExpectQuery("SELECT (.+) FROM todos WHERE ID = \\$1 AND user_id = \\$2").WithArgs(1, 1)
+				if err != nil {
+					exp.WillReturnError(err)
+				} else {
+					exp.WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "created_at", "text", "contexts", "projects", "priority", "completed", "archived", "starred", "note", "version", "due_date", "priority_escalated_at", "completed_at"}).
+						AddRow(1, 1, time.Now(), "call mom", "{}", "{}", "", false, false, false, "", 1, nil, nil, nil))
+				}
+			}
+
+			todo, err := m.GetTodoIfOwned(context.Background(), 1, 1)
+
+			if tt.wantErr == nil {
+				assert.IsNil(t, err)
+				assert.Equal(t, todo.Text, "call mom")
+			} else {
+				assert.Equal(t, err.Error(), tt.wantErr.Error())
+			}
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	badConn := &pq.Error{Code: "08006"}
+
+	assert.Equal(t, b.State(), BreakerClosed)
+
+	// Other errors show that the database is up, so they don't count.
+	b.record(badConn)
+	b.record(errors.New("syntax error"))
+	b.record(badConn)
+	assert.Equal(t, b.State(), BreakerClosed)
+
+	b.record(badConn)
+	assert.Equal(t, b.State(), BreakerOpen)
+
+	// While the breaker is open, queries aren't attempted.
+	m, mock := newTestTodoModel(t)
+	m.Breaker = b
+	_, err := m.GetTodoIfOwned(context.Background(), 1, 1)
+	assert.Equal(t, errors.Is(err, ErrCircuitOpen), true)
+	assert.IsNil(t, mock.ExpectationsWereMet())
+
+	// After the cooldown, a failure opens it again, and a success closes it.
+	now = now.Add(time.Minute)
+	assert.Equal(t, b.State(), BreakerHalfOpen)
+	b.record(badConn)
+	assert.Equal(t, b.State(), BreakerOpen)
+
+	now = now.Add(time.Minute)
+	b.record(nil)
+	assert.Equal(t, b.State(), BreakerClosed)
+
+	// A nil breaker never opens.
+	var nilBreaker *Breaker
+	nilBreaker.record(badConn)
+	assert.Equal(t, nilBreaker.State(), BreakerClosed)
+}
@@ -0,0 +1,28 @@
+package data
+
+import "time"
+
+// Clock provides the current time. Code that depends on the date, such as
+// grouping todos by their due dates or setting the expiry of tokens, should use
+// the Models' Clock rather than calling time.Now directly, so that tests can
+// use a fixed time.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock that returns the system's current time.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clockNow returns the current time of c, or of the system if c is nil, so
+// that models created without a Clock use the system's time.
+func clockNow(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
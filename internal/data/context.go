@@ -5,14 +5,37 @@ import (
 	"time"
 )
 
-// Duration to use for SQL operation timeouts.
-const QueryTimeout = 3 * time.Second
+// DefaultQueryTimeout is the duration to use for SQL operation timeouts, if a
+// model's Timeout isn't set.
+const DefaultQueryTimeout = 3 * time.Second
+
+// DefaultQueryRetries is the number of times a query that fails with a bad
+// connection error is retried, unless the API is configured otherwise.
+const DefaultQueryRetries = 1
+
+// queryTimeout returns the timeout, or DefaultQueryTimeout if the timeout
+// isn't positive.
+func queryTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultQueryTimeout
+	}
+	return timeout
+}
 
 // createTimeoutContext accepts a time duration and returns a context and cancel
 // function with a timeout of that duration.
 //
 // The caller should defer calling the cancel() function.
 func CreateTimeoutContext(timeout time.Duration) (context.Context, context.CancelFunc) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	return ctx, cancel
+	return CreateTimeoutContextFrom(context.Background(), timeout)
+}
+
+// CreateTimeoutContextFrom is like CreateTimeoutContext, but the returned
+// context is derived from parent. It is canceled when either the timeout
+// elapses or parent is canceled, so a query that is passed a request's context
+// is aborted if the client disconnects.
+//
+// The caller should defer calling the cancel() function.
+func CreateTimeoutContextFrom(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, timeout)
 }
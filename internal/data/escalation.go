@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// escalationInterval is the minimum time between escalations of a todo's
+// priority.
+const escalationInterval = 24 * time.Hour
+
+// Escalation is a change to an overdue todo's priority, raising it by one
+// letter.
+type Escalation struct {
+	TodoID int64
+	UserID int64
+	From   string
+	To     string
+}
+
+// Escalations decides which of the todos should have their priority escalated
+// at the time now. A todo is escalated if
+//
+//   - it is neither completed nor archived,
+//   - its due date has passed, meaning the day it was due has ended,
+//   - it has a priority lower than A, and
+//   - its priority hasn't already been escalated in the last 24 hours.
+//
+// Todos without a priority are left alone. The priority of each todo that is
+// escalated is raised by one letter, such as from B to A.
+func Escalations(todos []*Todo, now time.Time) []Escalation {
+	var escalations []Escalation
+
+	for _, t := range todos {
+		switch {
+		case t.Completed || t.Archived:
+			continue
+		case t.DueDate == nil || now.Before(t.DueDate.AddDate(0, 0, 1)):
+			continue
+		case len(t.Priority) != 1 || t.Priority <= "A" || t.Priority > "Z":
+			continue
+		case t.PriorityEscalatedAt != nil && now.Sub(*t.PriorityEscalatedAt) < escalationInterval:
+			continue
+		}
+
+		escalations = append(escalations, Escalation{
+			TodoID: t.ID,
+			UserID: t.UserID,
+			From:   t.Priority,
+			To:     string(t.Priority[0] - 1),
+		})
+	}
+
+	return escalations
+}
+
+// GetOverdue retrieves up to limit of every user's active todos that have a
+// priority and were due before now, ordered by ID. Only todos with IDs greater
+// than afterID are retrieved, so that all of them can be paged through, by
+// passing the last ID of each page as afterID for the next. It is intended to
+// provide the todos for Escalations, which makes the final decision.
+func (m TodoModel) GetOverdue(ctx context.Context, now time.Time, afterID int64, limit int) ([]*Todo, error) {
+	return m.getMatchingPage(ctx,
+		`WHERE due_date < $1 AND id > $2 AND completed = false AND archived = false AND priority > 'A'`,
+		[]any{now, afterID}, limit)
+}
+
+// Escalate applies the escalation to its todo, recording the time in the
+// todo's priority_escalated_at field, and incrementing its version. If the
+// todo's priority has changed since it was retrieved, it isn't updated, and
+// ErrEditConflict is returned.
+func (m TodoModel) Escalate(ctx context.Context, e Escalation, now time.Time) error {
+	query := `
+		UPDATE todos
+		SET priority = $1, priority_escalated_at = $2, version = version + 1
+		WHERE id = $3 AND user_id = $4 AND priority = $5`
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	result, err := m.execContext(ctx, query, e.To, now, e.TodoID, e.UserID, e.From)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestEscalations(t *testing.T) {
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	date := func(s string) *time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+
+	todos := []*Todo{
+		{ID: 1, UserID: 1, Priority: "B", DueDate: date("2024-03-09")},
+		{ID: 2, UserID: 2, Priority: "Z", DueDate: date("2024-01-01")},
+		// Due today, so not yet overdue.
+		{ID: 3, UserID: 1, Priority: "B", DueDate: date("2024-03-10")},
+		// Already the highest priority.
+		{ID: 4, UserID: 1, Priority: "A", DueDate: date("2024-03-09")},
+		// No priority or due date.
+		{ID: 5, UserID: 1, Priority: "", DueDate: date("2024-03-09")},
+		{ID: 6, UserID: 1, Priority: "C"},
+		// Completed or archived.
+		{ID: 7, UserID: 1, Priority: "C", DueDate: date("2024-03-09"), Completed: true},
+		{ID: 8, UserID: 1, Priority: "C", DueDate: date("2024-03-09"), Archived: true},
+		// Escalated less than a day ago, and more than a day ago.
+		{ID: 9, UserID: 1, Priority: "C", DueDate: date("2024-03-01"), PriorityEscalatedAt: ago(23 * time.Hour)},
+		{ID: 10, UserID: 3, Priority: "C", DueDate: date("2024-03-01"), PriorityEscalatedAt: ago(24 * time.Hour)},
+	}
+
+	got := Escalations(todos, now)
+
+	want := []Escalation{
+		{TodoID: 1, UserID: 1, From: "B", To: "A"},
+		{TodoID: 2, UserID: 2, From: "Z", To: "Y"},
+		{TodoID: 10, UserID: 3, From: "C", To: "B"},
+	}
+	assert.Equal(t, len(got), len(want))
+	for i := range want {
+		assert.Equal(t, got[i], want[i])
+	}
+
+	// The todos themselves aren't changed.
+	assert.Equal(t, todos[0].Priority, "B")
+}
@@ -3,6 +3,7 @@ package data
 import (
 	"reflect"
 	"strings"
+	"time"
 
 	validator "github.com/kvnloughead/godo/internal"
 )
@@ -47,27 +48,54 @@ type Filters struct {
 	// Completion filters
 	Done   bool
 	Undone bool
+
+	// Starred filter - if true, only starred todos are included
+	Starred bool
+
+	// Creation date filters - zero values mean no bound
+	CreatedBefore time.Time
+	CreatedAfter  time.Time
+
+	// Completion date filters - zero values mean no bound
+	CompletedBefore time.Time
+	CompletedAfter  time.Time
+
+	// Priority filter - a letter from A to Z, or PriorityNone for todos
+	// without a priority. The empty string means no filter.
+	Priority string
 }
 
-// sortColumn returns the column to sort by from the filter's Sort field.
-// It panics if the sort key is not in the safelist.
-func (f *Filters) sortColumn() string {
-	for _, safeValue := range f.SortSafelist {
-		if f.Sort == safeValue {
-			return strings.Trim(f.Sort, "-")
-		}
+// PriorityNone is the value of Filters.Priority that matches todos without a
+// priority.
+const PriorityNone = "none"
+
+// sortKeys returns the keys in the filter's Sort field. Multiple keys are
+// separated by commas, and whitespace around each key is ignored.
+func (f *Filters) sortKeys() []string {
+	keys := strings.Split(f.Sort, ",")
+	for i, key := range keys {
+		keys[i] = strings.TrimSpace(key)
 	}
-	panic("unsafe sort parameter: " + f.Sort)
+	return keys
 }
 
-// sortDirection returns the direction in which the sort should occur.
-// Possible return values: "ASC" and "DESC".
-func (f *Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
-	} else {
-		return "ASC"
+// orderBy returns the columns and directions to sort by, from the filter's
+// Sort field. For example, "-priority,text" returns "priority DESC, text ASC".
+// It panics if any sort key is not in the safelist.
+func (f *Filters) orderBy() string {
+	var columns []string
+	for _, key := range f.sortKeys() {
+		if !validator.PermittedValue(key, f.SortSafelist...) {
+			panic("unsafe sort parameter: " + key)
+		}
+
+		direction := "ASC"
+		if strings.HasPrefix(key, "-") {
+			direction = "DESC"
+		}
+		columns = append(columns, strings.TrimPrefix(key, "-")+" "+direction)
 	}
+	return strings.Join(columns, ", ")
 }
 
 // limit returns the max number of items in a page, as specified by the
@@ -82,26 +110,50 @@ func (f *Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
-func ValidateFilters(v *validator.Validator, f Filters) {
-
-	v.Check(f.Page >= 1, "page", "must be at least 1")
-	v.Check(f.Page <= 10_000_000, "page", "must be no more than least 10,000,000")
-	v.Check(f.PageSize >= 1, "page_size", "must be at least 1")
-	v.Check(f.PageSize <= 100, "page_size", "must be no more than 100")
+// ValidatePagination checks the filter's Page and PageSize fields. It is used
+// by ValidateFilters, and can be used on its own by endpoints that are paged
+// but not sorted or filtered.
+func ValidatePagination(v *validator.Validator, f Filters) {
+	v.CheckRule(f.Page >= 1, "page", validator.RuleOutOfRange, "must be at least 1")
+	v.CheckRule(f.Page <= 10_000_000, "page", validator.RuleOutOfRange, "must be no more than least 10,000,000")
+	v.CheckRule(f.PageSize >= 1, "page_size", validator.RuleOutOfRange, "must be at least 1")
+	v.CheckRule(f.PageSize <= 100, "page_size", validator.RuleOutOfRange, "must be no more than 100")
+}
 
-	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sorting key")
+func ValidateFilters(v *validator.Validator, f Filters) {
+	ValidatePagination(v, f)
+
+	// Each of the comma-separated sort keys must be in the safelist, and no
+	// column can be sorted by twice.
+	seen := make(map[string]bool)
+	for _, key := range f.sortKeys() {
+		v.CheckRule(validator.PermittedValue(key, f.SortSafelist...), "sort", validator.RuleNotAllowed, "invalid sorting key: "+key)
+		column := strings.TrimPrefix(key, "-")
+		v.CheckRule(!seen[column], "sort", validator.RuleDuplicate, "must not include the same key more than once")
+		seen[column] = true
+	}
 
 	// Validate that the boolean flags
 	v.Check(reflect.TypeOf(f.IncludeArchived).Kind() == reflect.Bool, "include-archived", "must be boolean")
 	v.Check(reflect.TypeOf(f.OnlyArchived).Kind() == reflect.Bool, "only-archived", "must be boolean")
 	v.Check(reflect.TypeOf(f.Done).Kind() == reflect.Bool, "done", "must be boolean")
 	v.Check(reflect.TypeOf(f.Undone).Kind() == reflect.Bool, "undone", "must be boolean")
+	v.Check(reflect.TypeOf(f.Starred).Kind() == reflect.Bool, "star", "must be boolean")
 
 	// Validate mutually exclusive flags
 	if f.IncludeArchived && f.OnlyArchived {
-		v.AddError("filters", "include-archived and only-archived are mutually exclusive")
+		v.AddRuleError("filters", validator.RuleConflict, "include-archived and only-archived are mutually exclusive")
 	}
 	if f.Done && f.Undone {
-		v.AddError("filters", "done and undone are mutually exclusive")
+		v.AddRuleError("filters", validator.RuleConflict, "done and undone are mutually exclusive")
+	}
+	if !f.CreatedBefore.IsZero() && !f.CreatedAfter.IsZero() {
+		v.CheckRule(f.CreatedAfter.Before(f.CreatedBefore), "created_after", validator.RuleConflict, "must be before created_before")
+	}
+	if f.Priority != "" && f.Priority != PriorityNone {
+		v.CheckRule(len(f.Priority) == 1 && f.Priority[0] >= 'A' && f.Priority[0] <= 'Z', "priority", validator.RuleFormat, "must be a capital letter (A to Z) or none")
+	}
+	if !f.CompletedBefore.IsZero() && !f.CompletedAfter.IsZero() {
+		v.CheckRule(f.CompletedAfter.Before(f.CompletedBefore), "completed_after", validator.RuleConflict, "must be before completed_before")
 	}
 }
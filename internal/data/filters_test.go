@@ -0,0 +1,64 @@
+package data
+
+import (
+	"testing"
+
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestValidateFiltersSort(t *testing.T) {
+	tests := []struct {
+		name   string
+		sort   string
+		errMsg string
+	}{
+		{name: "Single key", sort: "-text"},
+		{name: "Two keys", sort: "-priority,text"},
+		{name: "Unsafe key in list", sort: "priority,-version", errMsg: "invalid sorting key: -version"},
+		{name: "SQL in key", sort: "text,id; DROP TABLE todos", errMsg: "invalid sorting key: id; DROP TABLE todos"},
+		{name: "Empty key", sort: "text,", errMsg: "invalid sorting key: "},
+		{name: "Repeated key", sort: "text,-text", errMsg: "must not include the same key more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateFilters(v, Filters{
+				Page:         1,
+				PageSize:     20,
+				Sort:         tt.sort,
+				SortSafelist: []string{"id", "text", "priority", "-id", "-text", "-priority"},
+			})
+
+			if tt.errMsg == "" {
+				assert.Equal(t, v.Valid(), true)
+			} else {
+				assert.Equal(t, v.Errors["sort"], tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateFiltersPriority(t *testing.T) {
+	tests := []struct {
+		priority string
+		valid    bool
+	}{
+		{priority: "", valid: true},
+		{priority: "A", valid: true},
+		{priority: "Z", valid: true},
+		{priority: PriorityNone, valid: true},
+		{priority: "a", valid: false},
+		{priority: "AB", valid: false},
+		{priority: "1", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.priority, func(t *testing.T) {
+			v := validator.New()
+			ValidateFilters(v, Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}, Priority: tt.priority})
+			assert.Equal(t, v.Valid(), tt.valid)
+		})
+	}
+}
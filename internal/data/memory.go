@@ -0,0 +1,640 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	validator "github.com/kvnloughead/godo/internal"
+)
+
+// MemoryTodoModel is a TodoStore that keeps todos in memory, rather than in
+// Postgres. It is intended for tests, and for running without a database. Its
+// todos are lost when the process exits.
+//
+// Todos are filtered, sorted, and paginated exactly as they are by TodoModel,
+// with two exceptions. Text is sorted by byte order, rather than by the
+// database's collation, and the "%" and "_" characters in a text filter are
+// matched literally, rather than as wildcards.
+//
+// It is safe for concurrent use. Contexts are accepted for compatibility with
+// TodoModel, but are otherwise unused, since no method blocks.
+type MemoryTodoModel struct {
+	mu     sync.Mutex
+	todos  map[int64]*Todo
+	keys   map[idempotencyKey]idempotencyRecord
+	lastID int64
+
+	// Clock provides the creation and completion times of todos, and the
+	// expiry of idempotency keys. Defaults to SystemClock.
+	Clock Clock
+}
+
+// idempotencyKey identifies the idempotency key of a user.
+type idempotencyKey struct {
+	userID int64
+	key    string
+}
+
+// idempotencyRecord is the todo created with an idempotency key, or the
+// response to a request that imported todos with it, and the time at which the
+// key expires.
+type idempotencyRecord struct {
+	todoID   int64
+	response []byte
+	expiry   time.Time
+}
+
+// NewMemoryTodoModel returns an empty MemoryTodoModel.
+func NewMemoryTodoModel() *MemoryTodoModel {
+	return &MemoryTodoModel{
+		todos: make(map[int64]*Todo),
+		keys:  make(map[idempotencyKey]idempotencyRecord),
+		Clock: SystemClock{},
+	}
+}
+
+// cloneTodo returns a deep copy of the todo, so that todos held by the store
+// can't be changed by its callers.
+func cloneTodo(t *Todo) *Todo {
+	c := *t
+	c.Contexts = slices.Clone(t.Contexts)
+	c.Projects = slices.Clone(t.Projects)
+	if t.DueDate != nil {
+		d := *t.DueDate
+		c.DueDate = &d
+	}
+	if t.PriorityEscalatedAt != nil {
+		e := *t.PriorityEscalatedAt
+		c.PriorityEscalatedAt = &e
+	}
+	if t.CompletedAt != nil {
+		a := *t.CompletedAt
+		c.CompletedAt = &a
+	}
+	return &c
+}
+
+// cloneTodos returns deep copies of the todos.
+func cloneTodos(todos []*Todo) []*Todo {
+	clones := make([]*Todo, len(todos))
+	for i, t := range todos {
+		clones[i] = cloneTodo(t)
+	}
+	return clones
+}
+
+// matchesTodoFilter reports whether the todo matches the same conditions as
+// the WHERE clause returned by todoFilterClause.
+func matchesTodoFilter(t *Todo, text string, userID int64, contexts []string, projects []string, filters Filters) bool {
+	if t.UserID != userID || !strings.Contains(strings.ToLower(t.Text), strings.ToLower(text)) {
+		return false
+	}
+
+	for _, c := range contexts {
+		if !slices.Contains(t.Contexts, c) {
+			return false
+		}
+	}
+	for _, p := range projects {
+		if !slices.Contains(t.Projects, p) {
+			return false
+		}
+	}
+
+	switch {
+	case filters.OnlyArchived && !t.Archived:
+		return false
+	case !filters.OnlyArchived && !filters.IncludeArchived && t.Archived:
+		return false
+	case filters.Done && !t.Completed:
+		return false
+	case !filters.Done && filters.Undone && t.Completed:
+		return false
+	case filters.Starred && !t.Starred:
+		return false
+	case filters.Priority == PriorityNone && t.Priority != "":
+		return false
+	case filters.Priority != "" && filters.Priority != PriorityNone && t.Priority != filters.Priority:
+		return false
+	case !filters.CreatedAfter.IsZero() && t.CreatedAt.Before(filters.CreatedAfter):
+		return false
+	case !filters.CreatedBefore.IsZero() && !t.CreatedAt.Before(filters.CreatedBefore):
+		return false
+	case !filters.CompletedAfter.IsZero() && (t.CompletedAt == nil || t.CompletedAt.Before(filters.CompletedAfter)):
+		return false
+	case !filters.CompletedBefore.IsZero() && (t.CompletedAt == nil || !t.CompletedAt.Before(filters.CompletedBefore)):
+		return false
+	}
+
+	return true
+}
+
+// compareTodos orders todos as GetAll does. Starred todos are first, followed
+// by the filter's sort keys, and ties are broken by ID. It panics if any sort
+// key is not in the safelist.
+func compareTodos(a, b *Todo, filters Filters) int {
+	if a.Starred != b.Starred {
+		if a.Starred {
+			return -1
+		}
+		return 1
+	}
+
+	for _, key := range filters.sortKeys() {
+		if !validator.PermittedValue(key, filters.SortSafelist...) {
+			panic("unsafe sort parameter: " + key)
+		}
+
+		var c int
+		switch strings.TrimPrefix(key, "-") {
+		case "id":
+			c = cmp.Compare(a.ID, b.ID)
+		case "text":
+			c = strings.Compare(a.Text, b.Text)
+		case "priority":
+			c = strings.Compare(a.Priority, b.Priority)
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		default:
+			panic("unsupported sort parameter: " + key)
+		}
+
+		if strings.HasPrefix(key, "-") {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// paginate returns the page of items selected by the filters' Page and
+// PageSize fields, along with its pagination metadata. Like the queries of
+// TodoModel, the metadata is empty if the page is.
+func paginate[T any](items []T, filters Filters) ([]T, PaginationData) {
+	start := min(max(filters.offset(), 0), len(items))
+	end := min(start+filters.limit(), len(items))
+
+	page := slices.Clone(items[start:end])
+	if len(page) == 0 {
+		return page, PaginationData{}
+	}
+	return page, calculatePaginationData(len(items), filters.Page, filters.PageSize)
+}
+
+// matching returns the stored todos for which match returns true, ordered by
+// ID. The caller must hold m.mu.
+func (m *MemoryTodoModel) matching(match func(t *Todo) bool) []*Todo {
+	todos := []*Todo{}
+	for _, t := range m.todos {
+		if match(t) {
+			todos = append(todos, t)
+		}
+	}
+	slices.SortFunc(todos, func(a, b *Todo) int { return cmp.Compare(a.ID, b.ID) })
+	return todos
+}
+
+// insert stores a copy of the todo, assigning the generated fields to it. The
+// caller must hold m.mu.
+func (m *MemoryTodoModel) insert(todo *Todo) {
+	todo.NilToSlices()
+
+	m.lastID++
+	todo.ID = m.lastID
+	todo.CreatedAt = clockNow(m.Clock).Truncate(time.Second)
+	todo.Version = 1
+	todo.CompletedAt = nil
+	if todo.Completed {
+		todo.CompletedAt = &todo.CreatedAt
+	}
+
+	m.todos[todo.ID] = cloneTodo(todo)
+}
+
+// GetAll retrieves a page of the user's todos, filtered and sorted as they
+// are by TodoModel.GetAll.
+func (m *MemoryTodoModel) GetAll(ctx context.Context, text string, userID int64, contexts []string, projects []string, filters Filters) ([]*Todo, PaginationData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todos := m.matching(func(t *Todo) bool {
+		return matchesTodoFilter(t, text, userID, contexts, projects, filters)
+	})
+	slices.SortStableFunc(todos, func(a, b *Todo) int { return compareTodos(a, b, filters) })
+
+	page, paginationData := paginate(todos, filters)
+	return cloneTodos(page), paginationData, nil
+}
+
+// Insert adds a new todo, assigning its id, created_at, and version fields.
+func (m *MemoryTodoModel) Insert(ctx context.Context, todo *Todo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.insert(todo)
+	return nil
+}
+
+// InsertMany adds each of the todos, like Insert.
+func (m *MemoryTodoModel) InsertMany(ctx context.Context, todos []*Todo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, todo := range todos {
+		m.insert(todo)
+	}
+	return nil
+}
+
+// InsertIdempotent inserts the todo like Insert, unless the user has already
+// created a todo with the same idempotency key within the last ttl. See
+// TodoModel.InsertIdempotent.
+func (m *MemoryTodoModel) InsertIdempotent(ctx context.Context, todo *Todo, key string, ttl time.Duration) (*Todo, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := clockNow(m.Clock)
+	for k, record := range m.keys {
+		if k.userID == todo.UserID && record.expiry.Before(now) {
+			delete(m.keys, k)
+		}
+	}
+
+	k := idempotencyKey{userID: todo.UserID, key: key}
+	if record, ok := m.keys[k]; ok {
+		original, ok := m.todos[record.todoID]
+		if !ok {
+			return nil, false, ErrRecordNotFound
+		}
+		return cloneTodo(original), true, nil
+	}
+
+	m.insert(todo)
+	m.keys[k] = idempotencyRecord{todoID: todo.ID, expiry: now.Add(ttl)}
+
+	return todo, false, nil
+}
+
+// InsertManyIdempotent inserts the todos like InsertMany, unless the user has
+// already used the idempotency key within the last ttl. See
+// TodoModel.InsertManyIdempotent.
+func (m *MemoryTodoModel) InsertManyIdempotent(ctx context.Context, userID int64, todos []*Todo, key string, ttl time.Duration, respond func() ([]byte, error)) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := clockNow(m.Clock)
+	for k, record := range m.keys {
+		if k.userID == userID && record.expiry.Before(now) {
+			delete(m.keys, k)
+		}
+	}
+
+	k := idempotencyKey{userID: userID, key: key}
+	if record, ok := m.keys[k]; ok {
+		if record.response == nil {
+			return nil, false, ErrRecordNotFound
+		}
+		return record.response, true, nil
+	}
+
+	for _, todo := range todos {
+		m.insert(todo)
+	}
+	response, err := respond()
+	if err != nil {
+		return nil, false, err
+	}
+	m.keys[k] = idempotencyRecord{response: response, expiry: now.Add(ttl)}
+
+	return response, false, nil
+}
+
+// GetIdempotentResponse returns the response stored by InsertManyIdempotent
+// for the user's idempotency key, if the key hasn't expired. Otherwise, an
+// ErrRecordNotFound is returned.
+func (m *MemoryTodoModel) GetIdempotentResponse(ctx context.Context, userID int64, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.keys[idempotencyKey{userID: userID, key: key}]
+	if !ok || record.response == nil || record.expiry.Before(clockNow(m.Clock)) {
+		return nil, ErrRecordNotFound
+	}
+	return record.response, nil
+}
+
+// GetIdempotent returns the todo the user created with the idempotency key, if
+// the key hasn't expired. Otherwise, an ErrRecordNotFound is returned.
+func (m *MemoryTodoModel) GetIdempotent(ctx context.Context, userID int64, key string) (*Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.keys[idempotencyKey{userID: userID, key: key}]
+	if !ok || record.response != nil || record.expiry.Before(clockNow(m.Clock)) {
+		return nil, ErrRecordNotFound
+	}
+	original, ok := m.todos[record.todoID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return cloneTodo(original), nil
+}
+
+// GetTodoIfOwned retrieves the todo with the ID, if it is owned by the user.
+// Otherwise, an ErrRecordNotFound is returned.
+func (m *MemoryTodoModel) GetTodoIfOwned(ctx context.Context, id, userID int64) (*Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.todos[id]
+	if !ok || t.UserID != userID {
+		return nil, ErrRecordNotFound
+	}
+	return cloneTodo(t), nil
+}
+
+// FindDuplicate returns the ID of the user's oldest active todo with exactly
+// the given text. If there is none, an ErrRecordNotFound is returned.
+func (m *MemoryTodoModel) FindDuplicate(ctx context.Context, userID int64, text string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todos := m.matching(func(t *Todo) bool {
+		return t.UserID == userID && t.Text == text && !t.Completed && !t.Archived
+	})
+	if len(todos) == 0 {
+		return 0, ErrRecordNotFound
+	}
+	return todos[0].ID, nil
+}
+
+// Update replaces the stored todo with the same ID, and increments its
+// version. The todo's completed_at field is set or cleared as it is by
+// TodoModel.Update. If the todo doesn't exist, or its version has changed, an
+// ErrEditConflict is returned.
+func (m *MemoryTodoModel) Update(ctx context.Context, todo *Todo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.todos[todo.ID]
+	if !ok || t.Version != todo.Version {
+		return ErrEditConflict
+	}
+
+	updated := cloneTodo(todo)
+	updated.NilToSlices()
+	updated.UserID = t.UserID
+	updated.CreatedAt = t.CreatedAt
+	updated.PriorityEscalatedAt = t.PriorityEscalatedAt
+	updated.Version++
+
+	switch {
+	case !updated.Completed:
+		updated.CompletedAt = nil
+	case t.Completed:
+		updated.CompletedAt = t.CompletedAt
+	default:
+		now := clockNow(m.Clock).Truncate(time.Second)
+		updated.CompletedAt = &now
+	}
+
+	m.todos[todo.ID] = updated
+	todo.Version = updated.Version
+	todo.CompletedAt = nil
+	if updated.CompletedAt != nil {
+		completedAt := *updated.CompletedAt
+		todo.CompletedAt = &completedAt
+	}
+	return nil
+}
+
+// DeleteIfOwned deletes the todo with the ID, if it is owned by the user.
+// Otherwise, an ErrRecordNotFound is returned.
+func (m *MemoryTodoModel) DeleteIfOwned(ctx context.Context, id, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.todos[id]; !ok || t.UserID != userID {
+		return ErrRecordNotFound
+	}
+	delete(m.todos, id)
+	return nil
+}
+
+// DeleteManyIfOwned deletes each of the todos with the given IDs that is owned
+// by the user, and returns their IDs in ascending order.
+func (m *MemoryTodoModel) DeleteManyIfOwned(ctx context.Context, ids []int64, userID int64) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := []int64{}
+	for _, t := range m.matching(func(t *Todo) bool {
+		return t.UserID == userID && slices.Contains(ids, t.ID)
+	}) {
+		delete(m.todos, t.ID)
+		deleted = append(deleted, t.ID)
+	}
+	return deleted, nil
+}
+
+// GetManyIfOwned returns each of the todos with the given IDs that is owned by
+// the user, ordered by ID.
+func (m *MemoryTodoModel) GetManyIfOwned(ctx context.Context, ids []int64, userID int64) ([]*Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return cloneTodos(m.matching(func(t *Todo) bool {
+		return t.UserID == userID && slices.Contains(ids, t.ID)
+	})), nil
+}
+
+// CompleteMatching marks the user's matching incomplete, unarchived todos as
+// completed, and returns how many there were.
+func (m *MemoryTodoModel) CompleteMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todos := m.matching(func(t *Todo) bool {
+		return matchesTodoFilter(t, text, userID, contexts, projects, completeMatchingFilters)
+	})
+	now := clockNow(m.Clock).Truncate(time.Second)
+	for _, t := range todos {
+		t.Completed = true
+		t.CompletedAt = &now
+		t.Version++
+	}
+	return int64(len(todos)), nil
+}
+
+// PreviewCompleteMatching returns the todos that CompleteMatching would
+// complete if called with the same arguments.
+func (m *MemoryTodoModel) PreviewCompleteMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string) ([]*Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return cloneTodos(m.matching(func(t *Todo) bool {
+		return matchesTodoFilter(t, text, userID, contexts, projects, completeMatchingFilters)
+	})), nil
+}
+
+// ArchiveCompleted archives the user's completed, unarchived todos that have
+// each of the given contexts and projects, and returns how many there were.
+func (m *MemoryTodoModel) ArchiveCompleted(ctx context.Context, userID int64, contexts []string, projects []string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todos := m.matching(func(t *Todo) bool {
+		return matchesTodoFilter(t, "", userID, contexts, projects, Filters{Done: true})
+	})
+	for _, t := range todos {
+		t.Archived = true
+		t.Version++
+	}
+	return int64(len(todos)), nil
+}
+
+// applyTagChange returns the tags with the added and removed tags applied, in
+// the same order as TagMatching's query.
+func applyTagChange(tags, add, remove []string) []string {
+	changed := slices.DeleteFunc(slices.Clone(tags), func(tag string) bool {
+		return slices.Contains(remove, tag) || slices.Contains(add, tag)
+	})
+	return append(changed, add...)
+}
+
+// TagMatching adds and removes contexts and projects from the user's matching
+// unarchived todos. See TodoModel.TagMatching.
+func (m *MemoryTodoModel) TagMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string, changes TagChanges, limits TodoLimits) (updated int64, skipped int64, err error) {
+	limits = limits.withDefaults()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.matching(func(t *Todo) bool {
+		return matchesTodoFilter(t, text, userID, contexts, projects, Filters{})
+	}) {
+		newContexts := applyTagChange(t.Contexts, changes.AddContexts, changes.RemoveContexts)
+		newProjects := applyTagChange(t.Projects, changes.AddProjects, changes.RemoveProjects)
+
+		switch {
+		case len(newContexts) > limits.MaxContexts || len(newProjects) > limits.MaxProjects:
+			skipped++
+		case !slices.Equal(t.Contexts, newContexts) || !slices.Equal(t.Projects, newProjects):
+			t.Contexts, t.Projects = newContexts, newProjects
+			t.Version++
+			updated++
+		}
+	}
+
+	return updated, skipped, nil
+}
+
+// DistinctContexts returns a page of the distinct contexts of the user's
+// unarchived todos that begin with prefix. See TodoModel.DistinctContexts.
+func (m *MemoryTodoModel) DistinctContexts(ctx context.Context, userID int64, prefix string, filters Filters) ([]string, PaginationData, error) {
+	return m.distinctTags(func(t *Todo) []string { return t.Contexts }, userID, prefix, filters)
+}
+
+// DistinctProjects returns a page of the distinct projects of the user's
+// unarchived todos that begin with prefix. See TodoModel.DistinctContexts.
+func (m *MemoryTodoModel) DistinctProjects(ctx context.Context, userID int64, prefix string, filters Filters) ([]string, PaginationData, error) {
+	return m.distinctTags(func(t *Todo) []string { return t.Projects }, userID, prefix, filters)
+}
+
+// distinctTags returns a page of the distinct tags returned by tags that begin
+// with prefix, ignoring case.
+func (m *MemoryTodoModel) distinctTags(tags func(t *Todo) []string, userID int64, prefix string, filters Filters) ([]string, PaginationData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix = strings.ToLower(prefix)
+	distinct := []string{}
+	for _, t := range m.todos {
+		if t.UserID != userID || t.Archived {
+			continue
+		}
+		for _, tag := range tags(t) {
+			if strings.HasPrefix(strings.ToLower(tag), prefix) && !slices.Contains(distinct, tag) {
+				distinct = append(distinct, tag)
+			}
+		}
+	}
+	slices.Sort(distinct)
+
+	page, paginationData := paginate(distinct, filters)
+	return page, paginationData, nil
+}
+
+// GetAgenda retrieves the user's active todos, grouped by GroupAgenda.
+func (m *MemoryTodoModel) GetAgenda(ctx context.Context, userID int64, today time.Time) (Agenda, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return GroupAgenda(cloneTodos(m.matching(func(t *Todo) bool {
+		return t.UserID == userID
+	})), today), nil
+}
+
+// CompletionReport returns the number of the user's todos completed in each
+// period, oldest first. Periods are in UTC.
+func (m *MemoryTodoModel) CompletionReport(ctx context.Context, userID int64, period string) ([]ReportBucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := map[time.Time]int{}
+	for _, t := range m.todos {
+		if t.UserID == userID && t.CompletedAt != nil {
+			counts[truncateToPeriod(t.CompletedAt.UTC(), period)]++
+		}
+	}
+
+	buckets := []ReportBucket{}
+	for start, completed := range counts {
+		buckets = append(buckets, ReportBucket{Start: start, Completed: completed})
+	}
+	slices.SortFunc(buckets, func(a, b ReportBucket) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	return buckets, nil
+}
+
+// GetOverdue retrieves up to limit of every user's active todos that have a
+// priority lower than A, and were due before now, ordered by ID. Only todos
+// with IDs greater than afterID are retrieved.
+func (m *MemoryTodoModel) GetOverdue(ctx context.Context, now time.Time, afterID int64, limit int) ([]*Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todos := m.matching(func(t *Todo) bool {
+		return t.ID > afterID && t.DueDate != nil && t.DueDate.Before(now) && !t.Completed && !t.Archived && t.Priority > "A"
+	})
+	if limit > 0 && len(todos) > limit {
+		todos = todos[:limit]
+	}
+	return cloneTodos(todos), nil
+}
+
+// Escalate applies the escalation to its todo. If the todo's priority has
+// changed since it was retrieved, an ErrEditConflict is returned.
+func (m *MemoryTodoModel) Escalate(ctx context.Context, e Escalation, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.todos[e.TodoID]
+	if !ok || t.UserID != e.UserID || t.Priority != e.From {
+		return ErrEditConflict
+	}
+
+	t.Priority = e.To
+	t.PriorityEscalatedAt = &now
+	t.Version++
+	return nil
+}
@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+// clockFunc is a Clock that returns the result of calling the function.
+type clockFunc func() time.Time
+
+func (f clockFunc) Now() time.Time {
+	return f()
+}
+
+// newTestMemoryTodoModel returns a MemoryTodoModel containing the todos, which
+// are inserted in order, one second apart, starting at 2026-10-01 00:00 UTC.
+// They are assigned IDs from 1.
+func newTestMemoryTodoModel(t *testing.T, todos ...*Todo) *MemoryTodoModel {
+	t.Helper()
+
+	m := NewMemoryTodoModel()
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i, todo := range todos {
+		m.Clock = clockFunc(func() time.Time { return start.Add(time.Duration(i) * time.Second) })
+		if err := m.Insert(context.Background(), todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Clock = SystemClock{}
+
+	return m
+}
+
+// todoIDs returns the IDs of the todos, separated by commas.
+func todoIDs(todos []*Todo) string {
+	ids := make([]string, len(todos))
+	for i, t := range todos {
+		ids[i] = fmt.Sprint(t.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+// testSortSafelist is the safelist used by the todos handlers.
+var testSortSafelist = []string{"id", "text", "priority", "created_at", "-id", "-text", "-priority", "-created_at"}
+
+func TestMemoryTodoModelFilters(t *testing.T) {
+	m := newTestMemoryTodoModel(t,
+		&Todo{UserID: 1, Text: "Buy milk", Contexts: []string{"store"}, Projects: []string{"groceries"}},
+		&Todo{UserID: 1, Text: "buy bread", Contexts: []string{"store", "errands"}, Completed: true, Priority: "A"},
+		&Todo{UserID: 1, Text: "call mom", Contexts: []string{"phone"}, Starred: true},
+		&Todo{UserID: 1, Text: "file taxes", Projects: []string{"groceries", "home"}, Archived: true, Completed: true},
+		&Todo{UserID: 2, Text: "buy milk", Contexts: []string{"store"}},
+	)
+	created := func(id int) time.Time {
+		return time.Date(2026, 10, 1, 0, 0, id-1, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		contexts []string
+		projects []string
+		filters  Filters
+		ids      string
+	}{
+		{name: "No filters", ids: "3,1,2"},
+		{name: "Text ignores case", text: "BUY", ids: "1,2"},
+		{name: "Text matches substrings", text: "ll m", ids: "3"},
+		{name: "Contexts", contexts: []string{"store"}, ids: "1,2"},
+		{name: "All contexts", contexts: []string{"errands", "store"}, ids: "2"},
+		{name: "Projects", projects: []string{"groceries"}, ids: "1"},
+		{name: "Include archived", filters: Filters{IncludeArchived: true}, ids: "3,1,2,4"},
+		{name: "Only archived", filters: Filters{OnlyArchived: true}, ids: "4"},
+		{name: "Done", filters: Filters{Done: true}, ids: "2"},
+		{name: "Undone", filters: Filters{Undone: true}, ids: "3,1"},
+		{name: "Starred", filters: Filters{Starred: true}, ids: "3"},
+		{name: "Priority", filters: Filters{Priority: "A"}, ids: "2"},
+		{name: "No priority", filters: Filters{Priority: PriorityNone}, ids: "3,1"},
+		{name: "Created after is inclusive", filters: Filters{CreatedAfter: created(2)}, ids: "3,2"},
+		{name: "Created before is exclusive", filters: Filters{CreatedBefore: created(2)}, ids: "1"},
+		{name: "Combined", text: "buy", contexts: []string{"store"}, filters: Filters{Undone: true}, ids: "1"},
+		{name: "No matches", text: "zzz", ids: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := tt.filters
+			filters.Page, filters.PageSize = 1, 20
+			filters.Sort, filters.SortSafelist = "id", testSortSafelist
+
+			todos, _, err := m.GetAll(context.Background(), tt.text, 1, tt.contexts, tt.projects, filters)
+			assert.IsNil(t, err)
+			assert.Equal(t, todoIDs(todos), tt.ids)
+		})
+	}
+}
+
+func TestMemoryTodoModelSort(t *testing.T) {
+	m := newTestMemoryTodoModel(t,
+		&Todo{UserID: 1, Text: "b", Priority: "B"},
+		&Todo{UserID: 1, Text: "a", Priority: ""},
+		&Todo{UserID: 1, Text: "c", Priority: "A", Starred: true},
+		&Todo{UserID: 1, Text: "a", Priority: "B"},
+	)
+
+	tests := []struct {
+		name string
+		sort string
+		ids  string
+	}{
+		{name: "ID", sort: "id", ids: "3,1,2,4"},
+		{name: "ID descending", sort: "-id", ids: "3,4,2,1"},
+		{name: "Text, ties broken by ID", sort: "text", ids: "3,2,4,1"},
+		{name: "Priority, empty first", sort: "priority", ids: "3,2,1,4"},
+		{name: "Priority descending", sort: "-priority", ids: "3,1,4,2"},
+		{name: "Two keys", sort: "-priority, text", ids: "3,4,1,2"},
+		{name: "Created at descending", sort: "-created_at", ids: "3,4,2,1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := Filters{Page: 1, PageSize: 20, Sort: tt.sort, SortSafelist: testSortSafelist}
+
+			todos, _, err := m.GetAll(context.Background(), "", 1, nil, nil, filters)
+			assert.IsNil(t, err)
+			assert.Equal(t, todoIDs(todos), tt.ids)
+		})
+	}
+}
+
+func TestMemoryTodoModelPagination(t *testing.T) {
+	var todos []*Todo
+	for i := range 5 {
+		todos = append(todos, &Todo{UserID: 1, Text: fmt.Sprintf("todo %d", i)})
+	}
+	m := newTestMemoryTodoModel(t, todos...)
+
+	tests := []struct {
+		name     string
+		page     int
+		ids      string
+		metadata PaginationData
+	}{
+		{name: "First page", page: 1, ids: "1,2", metadata: PaginationData{CurrentPage: 1, PageSize: 2, FirstPage: 1, LastPage: 3, TotalRecords: 5}},
+		{name: "Last page", page: 3, ids: "5", metadata: PaginationData{CurrentPage: 3, PageSize: 2, FirstPage: 1, LastPage: 3, TotalRecords: 5}},
+		// Like count(*) OVER(), there is no metadata past the last page.
+		{name: "Past the last page", page: 4, ids: "", metadata: PaginationData{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := Filters{Page: tt.page, PageSize: 2, Sort: "id", SortSafelist: testSortSafelist}
+
+			todos, metadata, err := m.GetAll(context.Background(), "", 1, nil, nil, filters)
+			assert.IsNil(t, err)
+			assert.Equal(t, todoIDs(todos), tt.ids)
+			assert.Equal(t, metadata, tt.metadata)
+		})
+	}
+}
+
+func TestMemoryTodoModelUpdate(t *testing.T) {
+	m := newTestMemoryTodoModel(t, &Todo{UserID: 1, Text: "call mom"})
+	ctx := context.Background()
+
+	todo, err := m.GetTodoIfOwned(ctx, 1, 1)
+	assert.IsNil(t, err)
+	assert.Equal(t, todo.Version, int32(1))
+
+	// Changing the returned todo doesn't change the stored one.
+	todo.Text = "call dad"
+	stored, _ := m.GetTodoIfOwned(ctx, 1, 1)
+	assert.Equal(t, stored.Text, "call mom")
+
+	assert.IsNil(t, m.Update(ctx, todo))
+	assert.Equal(t, todo.Version, int32(2))
+	stored, _ = m.GetTodoIfOwned(ctx, 1, 1)
+	assert.Equal(t, stored.Text, "call dad")
+
+	// Updating an old version is an edit conflict.
+	stale := *stored
+	stale.Version = 1
+	assert.Equal(t, m.Update(ctx, &stale), ErrEditConflict)
+
+	_, err = m.GetTodoIfOwned(ctx, 1, 2)
+	assert.Equal(t, err, ErrRecordNotFound)
+
+	// Another user's todo can't be deleted.
+	assert.Equal(t, m.DeleteIfOwned(ctx, 1, 2), ErrRecordNotFound)
+	assert.IsNil(t, m.DeleteIfOwned(ctx, 1, 1))
+	assert.Equal(t, m.DeleteIfOwned(ctx, 1, 1), ErrRecordNotFound)
+}
+
+func TestMemoryTodoModelCompletedAt(t *testing.T) {
+	m := newTestMemoryTodoModel(t,
+		&Todo{UserID: 1, Text: "call mom"},
+		&Todo{UserID: 1, Text: "buy milk"},
+	)
+	ctx := context.Background()
+	completed := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+	m.Clock = clockFunc(func() time.Time { return completed })
+
+	// Completing a todo sets the timestamp.
+	todo, _ := m.GetTodoIfOwned(ctx, 1, 1)
+	todo.Completed = true
+	assert.IsNil(t, m.Update(ctx, todo))
+	assert.Equal(t, *todo.CompletedAt, completed)
+
+	// Updating a completed todo keeps it.
+	m.Clock = clockFunc(func() time.Time { return completed.Add(time.Hour) })
+	todo.Text = "call dad"
+	assert.IsNil(t, m.Update(ctx, todo))
+	assert.Equal(t, *todo.CompletedAt, completed)
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: testSortSafelist, Done: true}
+	filters.CompletedAfter = completed
+	todos, _, err := m.GetAll(ctx, "", 1, nil, nil, filters)
+	assert.IsNil(t, err)
+	assert.Equal(t, todoIDs(todos), "1")
+
+	filters.CompletedAfter = time.Time{}
+	filters.CompletedBefore = completed
+	todos, _, err = m.GetAll(ctx, "", 1, nil, nil, filters)
+	assert.IsNil(t, err)
+	assert.Equal(t, todoIDs(todos), "")
+
+	// Uncompleting a todo clears it.
+	todo.Completed = false
+	assert.IsNil(t, m.Update(ctx, todo))
+	assert.Equal(t, todo.CompletedAt == nil, true)
+}
+
+func TestMemoryTodoModelTagMatching(t *testing.T) {
+	m := newTestMemoryTodoModel(t,
+		&Todo{UserID: 1, Text: "buy milk", Contexts: []string{"store", "errands"}},
+		&Todo{UserID: 1, Text: "buy bread", Contexts: []string{"home"}},
+		&Todo{UserID: 1, Text: "buy eggs", Contexts: []string{"a", "b", "c", "d", "e"}},
+	)
+	ctx := context.Background()
+
+	changes := TagChanges{AddContexts: []string{"store"}, RemoveContexts: []string{"errands"}}
+	updated, skipped, err := m.TagMatching(ctx, "buy", 1, nil, nil, changes, TodoLimits{})
+	assert.IsNil(t, err)
+	assert.Equal(t, updated, int64(2))
+	assert.Equal(t, skipped, int64(1))
+
+	// Added tags are moved to the end, as they are by array_cat.
+	todo, _ := m.GetTodoIfOwned(ctx, 1, 1)
+	assert.Equal(t, strings.Join(todo.Contexts, ","), "store")
+	todo, _ = m.GetTodoIfOwned(ctx, 2, 1)
+	assert.Equal(t, strings.Join(todo.Contexts, ","), "home,store")
+	assert.Equal(t, todo.Version, int32(2))
+
+	contexts, _, err := m.DistinctContexts(ctx, 1, "S", Filters{Page: 1, PageSize: 20})
+	assert.IsNil(t, err)
+	assert.Equal(t, strings.Join(contexts, ","), "store")
+}
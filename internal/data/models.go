@@ -1,8 +1,10 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
@@ -14,22 +16,107 @@ var (
 	// a resource. It indicates that the resource was already changed or deleted
 	// since the current request was initiated.
 	ErrEditConflict = errors.New("edit conflict")
+
+	// ErrCircuitOpen is an error returned if a query isn't attempted, because
+	// recent queries failed with bad connection errors. See Breaker.
+	ErrCircuitOpen = errors.New("database circuit breaker is open")
+)
+
+// TodoStore is the interface implemented by TodoModel, which stores todos in
+// Postgres, and MemoryTodoModel, which stores them in memory. See TodoModel
+// for documentation of each method.
+type TodoStore interface {
+	GetAll(ctx context.Context, text string, userID int64, contexts []string, projects []string, filters Filters) ([]*Todo, PaginationData, error)
+	Insert(ctx context.Context, todo *Todo) error
+	InsertMany(ctx context.Context, todos []*Todo) error
+	InsertIdempotent(ctx context.Context, todo *Todo, key string, ttl time.Duration) (*Todo, bool, error)
+	GetIdempotent(ctx context.Context, userID int64, key string) (*Todo, error)
+	InsertManyIdempotent(ctx context.Context, userID int64, todos []*Todo, key string, ttl time.Duration, respond func() ([]byte, error)) ([]byte, bool, error)
+	GetIdempotentResponse(ctx context.Context, userID int64, key string) ([]byte, error)
+	GetTodoIfOwned(ctx context.Context, id, userID int64) (*Todo, error)
+	FindDuplicate(ctx context.Context, userID int64, text string) (int64, error)
+	Update(ctx context.Context, todo *Todo) error
+	DeleteIfOwned(ctx context.Context, id, userID int64) error
+	DeleteManyIfOwned(ctx context.Context, ids []int64, userID int64) ([]int64, error)
+	GetManyIfOwned(ctx context.Context, ids []int64, userID int64) ([]*Todo, error)
+	CompleteMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string) (int64, error)
+	PreviewCompleteMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string) ([]*Todo, error)
+	ArchiveCompleted(ctx context.Context, userID int64, contexts []string, projects []string) (int64, error)
+	TagMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string, changes TagChanges, limits TodoLimits) (int64, int64, error)
+	DistinctContexts(ctx context.Context, userID int64, prefix string, filters Filters) ([]string, PaginationData, error)
+	DistinctProjects(ctx context.Context, userID int64, prefix string, filters Filters) ([]string, PaginationData, error)
+	GetAgenda(ctx context.Context, userID int64, today time.Time) (Agenda, error)
+	CompletionReport(ctx context.Context, userID int64, period string) ([]ReportBucket, error)
+	GetOverdue(ctx context.Context, now time.Time, afterID int64, limit int) ([]*Todo, error)
+	Escalate(ctx context.Context, e Escalation, now time.Time) error
+}
+
+// UserStore is the interface implemented by UserModel. See UserModel for
+// documentation of each method.
+type UserStore interface {
+	Insert(user *User) error
+	GetByEmail(email string) (*User, error)
+	GetForToken(scope Scope, tokenPlaintext string) (*User, error)
+	Update(user *User) error
+	UpdateLastLogin(id int64) (*time.Time, error)
+	Delete(id int64) error
+}
+
+// TokenStore is the interface implemented by TokenModel. See TokenModel for
+// documentation of each method.
+type TokenStore interface {
+	New(userID int64, ttl time.Duration, scope Scope) (*Token, error)
+	Insert(token *Token) error
+	GetAllForUser(userID int64) ([]*TokenSummary, error)
+	DeleteAllForUser(scope Scope, userID int64) error
+}
+
+// PermissionStore is the interface implemented by PermissionModel. See
+// PermissionModel for documentation of each method.
+type PermissionStore interface {
+	GetAllForUser(userID int64) (Permissions, error)
+	AddForUser(userID int64, permissions ...PermissionCode) error
+	RemoveForUser(userID int64, permissions ...PermissionCode) error
+}
+
+var (
+	_ TodoStore       = TodoModel{}
+	_ TodoStore       = (*MemoryTodoModel)(nil)
+	_ UserStore       = UserModel{}
+	_ TokenStore      = TokenModel{}
+	_ PermissionStore = PermissionModel{}
 )
 
-// Models is a struct that wraps all of our models.
+// Models is a struct that wraps all of our models. Its fields are interfaces,
+// so that tests can replace any of the models with a fake.
 type Models struct {
-	Todos       TodoModel
-	Users       UserModel
-	Tokens      TokenModel
-	Permissions PermissionModel
+	Todos       TodoStore
+	Users       UserStore
+	Tokens      TokenStore
+	Permissions PermissionStore
+
+	// Clock provides the current time to the models, such as for the expiry of
+	// tokens and idempotency keys, and to date-dependent handlers.
+	Clock Clock
 }
 
-// NewModels returns an empty instance of our Model struct.
-func NewModels(db *sql.DB) Models {
+// NewModels returns a Models struct containing the Postgres implementation of
+// each model. Each query is canceled if it takes longer than queryTimeout. If
+// queryTimeout isn't positive, DefaultQueryTimeout is used. The TodoModel's
+// queries are guarded by a Breaker with the default threshold and cooldown,
+// and are retried up to retries times after bad connection errors.
+//
+// Each model gets the current time from clock. If clock is nil, SystemClock
+// is used.
+func NewModels(db *sql.DB, queryTimeout time.Duration, retries int, clock Clock) Models {
+	if clock == nil {
+		clock = SystemClock{}
+	}
 	return Models{
-		Todos:       TodoModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+		Todos:       TodoModel{DB: db, Timeout: queryTimeout, Retries: retries, Breaker: NewBreaker(0, 0), Clock: clock},
+		Users:       UserModel{DB: db, Timeout: queryTimeout, Clock: clock},
+		Tokens:      TokenModel{DB: db, Timeout: queryTimeout, Clock: clock},
+		Permissions: PermissionModel{DB: db, Timeout: queryTimeout},
+		Clock:       clock,
 	}
 }
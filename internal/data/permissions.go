@@ -2,17 +2,25 @@ package data
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/lib/pq"
 )
 
-// String type for permission codes. Current options are "todos:read" and
-// "todos:write"
+// String type for permission codes. Current options are "todos:read",
+// "todos:write", and "admin"
 type PermissionCode string
 
 var TodosRead = PermissionCode("todos:read")
 var TodosWrite = PermissionCode("todos:write")
 
+// Admin allows a user to grant and revoke other users' permissions. It is
+// never granted automatically.
+var Admin = PermissionCode("admin")
+
+// PermissionCodes contains every permission code that can be granted.
+var PermissionCodes = []PermissionCode{TodosRead, TodosWrite, Admin}
+
 // Permissions is a string slice for storing permission codes.
 type Permissions []PermissionCode
 
@@ -22,7 +30,8 @@ type Permission struct {
 }
 
 type PermissionModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration // Timeout for each query. Defaults to DefaultQueryTimeout.
 }
 
 // Permissions.Includes return a boolean indicating whether a given permission
@@ -50,7 +59,7 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 			ON users_permissions.user_id = users.id
 		WHERE users.id = $1`
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
 	defer cancel()
 
 	// Run the query, inserting the user ID as a placeholder.
@@ -86,11 +95,35 @@ func (m PermissionModel) AddForUser(userID int64, permissions ...PermissionCode)
 	// For each permission in Permissions, insert a record with userID and
 	// permissionID into users_permissions table. $2 must be a postgresql array
 	// of permission codes.
+	// Permissions that the user already has are skipped.
 	query := `
 		INSERT INTO users_permissions
 		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+		ON CONFLICT DO NOTHING
 	`
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(permissions))
+	return err
+}
+
+// PermissionModel.RemoveForUser revokes one or more permissions from a user.
+// Permissions that the user doesn't have are ignored, and if no permissions
+// are supplied, no query is run.
+func (m PermissionModel) RemoveForUser(userID int64, permissions ...PermissionCode) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	query := `
+		DELETE FROM users_permissions
+		USING permissions
+		WHERE users_permissions.permission_id = permissions.id
+		AND users_permissions.user_id = $1
+		AND permissions.code = ANY($2)`
+
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
 	defer cancel()
 
 	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(permissions))
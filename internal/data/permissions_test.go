@@ -0,0 +1,67 @@
+package data
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/lib/pq"
+)
+
+func TestRemoveForUser(t *testing.T) {
+	query := `DELETE FROM users_permissions USING permissions WHERE users_permissions.permission_id = permissions.id AND users_permissions.user_id = $1 AND permissions.code = ANY($2)`
+
+	tests := []struct {
+		name  string
+		codes []PermissionCode
+	}{
+		{name: "One code", codes: []PermissionCode{TodosWrite}},
+		{name: "Multiple codes", codes: []PermissionCode{TodosWrite, Admin}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec(regexp.QuoteMeta(query)).
+				WithArgs(int64(1), pq.Array(tt.codes)).
+				WillReturnResult(sqlmock.NewResult(0, int64(len(tt.codes))))
+
+			err = PermissionModel{DB: db}.RemoveForUser(1, tt.codes...)
+			assert.IsNil(t, err)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("No codes", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		err = PermissionModel{DB: db}.RemoveForUser(1)
+		assert.IsNil(t, err)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Database error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		dbErr := errors.New("connection refused")
+		mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnError(dbErr)
+
+		err = PermissionModel{DB: db}.RemoveForUser(1, TodosRead)
+		assert.Equal(t, errors.Is(err, dbErr), true)
+	})
+}
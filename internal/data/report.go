@@ -0,0 +1,76 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// ReportPeriods are the periods that completed todos can be grouped by in a
+// completion report. They are also valid arguments to Postgres' date_trunc.
+var ReportPeriods = []string{"week", "month"}
+
+// ReportBucket is the number of todos completed in the period that begins at
+// Start.
+type ReportBucket struct {
+	Start     time.Time `json:"start"`
+	Completed int       `json:"completed"`
+}
+
+// CompletionReport returns the number of the user's todos completed in each
+// period, which must be one of ReportPeriods, oldest first. Periods without
+// completed todos are omitted. Weeks begin on Monday. Archived todos are
+// included, since they were completed all the same.
+//
+// Periods begin at midnight UTC. completed_at is converted to UTC before it is
+// truncated, since date_trunc would otherwise use the session's time zone,
+// which depends on the server's configuration.
+func (m TodoModel) CompletionReport(ctx context.Context, userID int64, period string) ([]ReportBucket, error) {
+	query := `
+		SELECT date_trunc($2, completed_at AT TIME ZONE 'UTC') AS start, count(*)
+		FROM todos
+		WHERE user_id = $1 AND completed_at IS NOT NULL
+		GROUP BY start
+		ORDER BY start`
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	rows, err := m.queryContext(ctx, query, userID, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []ReportBucket{}
+
+	for rows.Next() {
+		var b ReportBucket
+		if err := rows.Scan(&b.Start, &b.Completed); err != nil {
+			return nil, err
+		}
+		b.Start = b.Start.UTC()
+		buckets = append(buckets, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// truncateToPeriod returns the start of the period, which must be one of
+// ReportPeriods, that t is in, like date_trunc.
+func truncateToPeriod(t time.Time, period string) time.Time {
+	year, month, day := t.Date()
+	switch period {
+	case "week":
+		// time.Weekday begins on Sunday, but ISO weeks begin on Monday.
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	default:
+		panic("unknown report period: " + period)
+	}
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestCompletionReport(t *testing.T) {
+	query := regexp.QuoteMeta(`SELECT date_trunc($2, completed_at AT TIME ZONE 'UTC') AS start, count(*) FROM todos WHERE user_id = $1 AND completed_at IS NOT NULL GROUP BY start ORDER BY start`)
+
+	t.Run("Grouped", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		april := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(query).
+			WithArgs(int64(1), "month").
+			WillReturnRows(sqlmock.NewRows([]string{"start", "count"}).
+				AddRow(march, 3).
+				AddRow(april, 5))
+
+		report, err := m.CompletionReport(context.Background(), 1, "month")
+		assert.IsNil(t, err)
+		assert.Equal(t, len(report), 2)
+		assert.Equal(t, report[0], ReportBucket{Start: march, Completed: 3})
+		assert.Equal(t, report[1], ReportBucket{Start: april, Completed: 5})
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No completed todos", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		mock.ExpectQuery(query).
+			WithArgs(int64(1), "week").
+			WillReturnRows(sqlmock.NewRows([]string{"start", "count"}))
+
+		report, err := m.CompletionReport(context.Background(), 1, "week")
+		assert.IsNil(t, err)
+		// The report is empty rather than nil, so it's encoded as [].
+		assert.Equal(t, report != nil, true)
+		assert.Equal(t, len(report), 0)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMemoryTodoModelCompletionReport(t *testing.T) {
+	m := newTestMemoryTodoModel(t,
+		&Todo{UserID: 1, Text: "a"},
+		&Todo{UserID: 1, Text: "b"},
+		&Todo{UserID: 1, Text: "c"},
+		&Todo{UserID: 2, Text: "d"},
+	)
+	ctx := context.Background()
+
+	// Sunday the 10th is in the week beginning Monday the 4th, and Monday the
+	// 11th begins the next week.
+	complete := func(id, userID int64, at time.Time) {
+		m.Clock = clockFunc(func() time.Time { return at })
+		todo, err := m.GetTodoIfOwned(ctx, id, userID)
+		assert.IsNil(t, err)
+		todo.Completed = true
+		assert.IsNil(t, m.Update(ctx, todo))
+	}
+	complete(1, 1, time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC))
+	complete(2, 1, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC))
+	complete(3, 1, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC))
+	complete(4, 2, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC))
+
+	report, err := m.CompletionReport(ctx, 1, "week")
+	assert.IsNil(t, err)
+	assert.Equal(t, len(report), 2)
+	assert.Equal(t, report[0], ReportBucket{Start: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Completed: 2})
+	assert.Equal(t, report[1], ReportBucket{Start: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), Completed: 1})
+
+	report, err = m.CompletionReport(ctx, 1, "month")
+	assert.IsNil(t, err)
+	assert.Equal(t, len(report), 1)
+	assert.Equal(t, report[0], ReportBucket{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Completed: 3})
+}
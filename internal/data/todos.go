@@ -1,12 +1,16 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	validator "github.com/kvnloughead/godo/internal"
 	"github.com/lib/pq"
@@ -20,12 +24,25 @@ type Todo struct {
 	UserID    int64     `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
 	Text      string    `json:"text"`
+	Note      string    `json:"note,omitempty"`
 	Contexts  []string  `json:"contexts,omitempty"`
 	Projects  []string  `json:"projects,omitempty"`
 	Priority  string    `json:"priority"`
 	Completed bool      `json:"completed"`
 	Version   int32     `json:"version"`
 	Archived  bool      `json:"archived"`
+	Starred   bool      `json:"starred"`
+
+	// DueDate is the date the todo is due, if any. Overdue todos may have
+	// their priority escalated. See Escalations.
+	DueDate *time.Time `json:"due_date,omitempty"`
+
+	// PriorityEscalatedAt is the time the todo's priority was last escalated.
+	PriorityEscalatedAt *time.Time `json:"priority_escalated_at,omitempty"`
+
+	// CompletedAt is the time the todo was completed. It is set when the todo
+	// is completed, and cleared when it is marked as not completed.
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
 // NilToSlices converts the calling structs Contexts and Projects fields to
@@ -40,10 +57,234 @@ func (t *Todo) NilToSlices() {
 	}
 }
 
+// FormatTodo returns the todo as a single line of todo.txt text. The line has
+// the following form, where each part but the text is optional:
+//
+//	x (A) text @context +project
+//
+// The "x " marker is included for completed todos, and the priority is
+// included if it isn't empty. Contexts and projects that aren't already
+// present in the text are appended to the end of the line.
+func FormatTodo(t *Todo) string {
+	var parts []string
+
+	if t.Completed {
+		parts = append(parts, "x")
+	}
+	if t.Priority != "" {
+		parts = append(parts, "("+t.Priority+")")
+	}
+	parts = append(parts, t.Text)
+
+	words := strings.Fields(t.Text)
+	for _, c := range t.Contexts {
+		if !slices.Contains(words, "@"+c) {
+			parts = append(parts, "@"+c)
+		}
+	}
+	for _, p := range t.Projects {
+		if !slices.Contains(words, "+"+p) {
+			parts = append(parts, "+"+p)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// NormalizeText trims the whitespace surrounding todo text, and collapses each
+// run of whitespace within it, including newlines, to a single space.
+func NormalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// maxParsedTags is the most unique contexts, and the most unique projects, that
+// ParseTodo extracts from a line. It is well above any sensible limit on the
+// number of tags, which ValidateTodo checks.
+const maxParsedTags = 100
+
+// todoDateRX matches a todo.txt completion or creation date.
+var todoDateRX = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// todoPriorityRX matches a todo.txt priority, such as "(A)".
+var todoPriorityRX = regexp.MustCompile(`^\([A-Z]\)$`)
+
+// ParseTodo parses a single line of todo.txt text into a Todo. It is the
+// inverse of FormatTodo. The line has the following form, where each part but
+// the text is optional:
+//
+//	x (A) 2024-01-01 text @context +project
+//
+// A leading "x " marks the todo as completed, and "(A) " sets its priority.
+// Completion and creation dates are skipped, since the server sets the
+// todo's creation date itself. Words beginning with "@" are added to the
+// todo's contexts, and words beginning with "+" to its projects, with the
+// prefix removed. They are also left in the text.
+//
+// An error is returned if the line has no text after these markers, or if it
+// has more than maxParsedTags unique contexts or projects, so that a
+// pathological line can't be parsed into huge arrays. Otherwise, the todo
+// isn't validated; use ValidateTodo for that, which applies the configured
+// limits to the parsed tags.
+func ParseTodo(line string) (*Todo, error) {
+	words := strings.Fields(line)
+	todo := &Todo{}
+
+	if len(words) > 0 && words[0] == "x" {
+		todo.Completed = true
+		words = words[1:]
+	}
+	if len(words) > 0 && todoPriorityRX.MatchString(words[0]) {
+		todo.Priority = words[0][1:2]
+		words = words[1:]
+	}
+	for len(words) > 0 && todoDateRX.MatchString(words[0]) {
+		words = words[1:]
+	}
+
+	if len(words) == 0 {
+		return nil, errors.New("todo has no text")
+	}
+
+	for _, word := range words {
+		switch {
+		case len(word) > 1 && strings.HasPrefix(word, "@"):
+			if !slices.Contains(todo.Contexts, word[1:]) {
+				todo.Contexts = append(todo.Contexts, word[1:])
+			}
+		case len(word) > 1 && strings.HasPrefix(word, "+"):
+			if !slices.Contains(todo.Projects, word[1:]) {
+				todo.Projects = append(todo.Projects, word[1:])
+			}
+		}
+
+		if len(todo.Contexts) > maxParsedTags {
+			return nil, fmt.Errorf("todo has more than %d contexts", maxParsedTags)
+		}
+		if len(todo.Projects) > maxParsedTags {
+			return nil, fmt.Errorf("todo has more than %d projects", maxParsedTags)
+		}
+	}
+	todo.Text = strings.Join(words, " ")
+
+	return todo, nil
+}
+
 // TodoModel struct wraps an sql.DB connection pool and implements
 // basic CRUD operations.
+//
+// Its methods accept a context, which should be the request's context. Each
+// query is aborted if the context is canceled, or if the Timeout elapses.
+//
+// Queries that fail because a connection couldn't be made, such as while
+// Postgres restarts, are retried up to Retries times. If the Breaker is open,
+// queries fail with ErrCircuitOpen without being attempted.
 type TodoModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration // Timeout for each query. Defaults to DefaultQueryTimeout.
+	Retries int           // Retries after a connection error. NewModels defaults it to DefaultQueryRetries.
+	Breaker *Breaker      // Optional.
+	Clock   Clock         // Provides the expiry of idempotency keys. Defaults to SystemClock.
+}
+
+// queryContext runs the query like m.DB.QueryContext, retrying it on a bad
+// connection error.
+func (m TodoModel) queryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	err = retryBadConn(ctx, m.Breaker, m.Retries, func() error {
+		rows, err = m.DB.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// execContext runs the query like m.DB.ExecContext, retrying it on a bad
+// connection error.
+func (m TodoModel) execContext(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	err = retryBadConn(ctx, m.Breaker, m.Retries, func() error {
+		result, err = m.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// queryRowScan runs the query like m.DB.QueryRowContext, and scans the row
+// into dest, retrying on a bad connection error.
+func (m TodoModel) queryRowScan(ctx context.Context, query string, args []any, dest ...any) error {
+	return retryBadConn(ctx, m.Breaker, m.Retries, func() error {
+		return m.DB.QueryRowContext(ctx, query, args...).Scan(dest...)
+	})
+}
+
+// todoFilterClause returns a WHERE clause, and the arguments for its
+// placeholders, that matches a user's todos by text, contexts, projects, and
+// the archive and completion filters. Every query that acts on a filtered set
+// of todos should use it, so that listing a set of todos and acting on that
+// same set can't diverge.
+//
+// Placeholders are numbered from $1, so any additional placeholders in the
+// query should be numbered from len(args) + 1.
+func todoFilterClause(text string, userID int64, contexts []string, projects []string, filters Filters) (string, []any) {
+	clause := `WHERE text ILIKE '%' || $1 || '%' AND user_id = $2`
+	args := []any{text, userID}
+
+	// Only include todos that have each of the provided contexts and projects.
+	if len(contexts) > 0 {
+		args = append(args, pq.Array(contexts))
+		clause += fmt.Sprintf(" AND contexts @> $%d", len(args))
+	}
+	if len(projects) > 0 {
+		args = append(args, pq.Array(projects))
+		clause += fmt.Sprintf(" AND projects @> $%d", len(args))
+	}
+
+	// Handle archived/unarchived filtering.
+	if filters.OnlyArchived {
+		clause += " AND archived = true" // show only archived
+	} else if !filters.IncludeArchived {
+		clause += " AND archived = false" // show only unarchived (default)
+	}
+
+	// Handle completion status filtering
+	if filters.Done {
+		clause += " AND completed = true"
+	} else if filters.Undone {
+		clause += " AND completed = false"
+	}
+
+	// Handle starred filtering.
+	if filters.Starred {
+		clause += " AND starred = true"
+	}
+
+	// Handle priority filtering.
+	if filters.Priority == PriorityNone {
+		clause += " AND priority = ''"
+	} else if filters.Priority != "" {
+		args = append(args, filters.Priority)
+		clause += fmt.Sprintf(" AND priority = $%d", len(args))
+	}
+
+	// Handle creation date filtering.
+	if !filters.CreatedAfter.IsZero() {
+		args = append(args, filters.CreatedAfter)
+		clause += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if !filters.CreatedBefore.IsZero() {
+		args = append(args, filters.CreatedBefore)
+		clause += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	// Handle completion date filtering. Todos that aren't completed have no
+	// completion date, so they never match.
+	if !filters.CompletedAfter.IsZero() {
+		args = append(args, filters.CompletedAfter)
+		clause += fmt.Sprintf(" AND completed_at >= $%d", len(args))
+	}
+	if !filters.CompletedBefore.IsZero() {
+		args = append(args, filters.CompletedBefore)
+		clause += fmt.Sprintf(" AND completed_at < $%d", len(args))
+	}
+
+	return clause, args
 }
 
 // GetAll retrieves a slice of todos from the database. The slice can be
@@ -57,43 +298,38 @@ type TodoModel struct {
 //     are included.
 //   - projects: if provided, only todos that have each of the provided projects
 //     are included.
-//   - sort: the key to sort by. Prepend with '-' for descending order. Defaults
-//     to ID, ascending.
+//   - created_after, created_before: if provided, only todos created on or
+//     after, or before, the given date are included.
+//   - completed_after, completed_before: if provided, only todos completed on
+//     or after, or before, the given date are included.
+//   - star: if true, only starred todos are included.
+//   - priority: if provided, only todos with the given priority are included.
+//     "none" matches todos without a priority.
+//   - sort: a comma-separated list of keys to sort by. Prepend a key with '-'
+//     for descending order. Defaults to ID, ascending. Starred todos are always
+//     sorted first, and ties are broken by ID.
 //   - page_size: the number of records to show per "page".
 //   - page: the page number to return.
 //
 // Pagination metadata is returned in the response, unless no records are found.
-func (m TodoModel) GetAll(text string, userID int64, contexts []string, projects []string, filters Filters) ([]*Todo, PaginationData, error) {
-	whereClause := `WHERE text ILIKE '%%' || $1 || '%%' AND user_id = $2`
-
-	// Handle archived/unarchived filtering.
-	if filters.OnlyArchived {
-		whereClause += " AND archived = true" // show only archived
-	} else if !filters.IncludeArchived {
-		whereClause += " AND archived = false" // show only unarchived (default)
-	}
-
-	// Handle completion status filtering
-	if filters.Done {
-		whereClause += " AND completed = true"
-	} else if filters.Undone {
-		whereClause += " AND completed = false"
-	}
+func (m TodoModel) GetAll(ctx context.Context, text string, userID int64, contexts []string, projects []string, filters Filters) ([]*Todo, PaginationData, error) {
+	whereClause, args := todoFilterClause(text, userID, contexts, projects, filters)
 
 	query := fmt.Sprintf(` 
 		SELECT 
 			count(*) OVER(),
-			id, created_at, text, contexts, projects, priority, completed, archived, version
+			id, created_at, text, contexts, projects, priority, completed, archived, starred, COALESCE(note, ''), version, due_date, priority_escalated_at, completed_at
 		FROM todos
 		%s
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, whereClause, filters.sortColumn(), filters.sortDirection())
+		ORDER BY starred DESC, %s, id ASC
+		LIMIT $%d OFFSET $%d`,
+		whereClause, filters.orderBy(), len(args)+1, len(args)+2)
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
 	defer cancel()
 
-	args := []any{text, userID, filters.limit(), filters.offset()}
-	rows, err := m.DB.QueryContext(ctx, query, args...)
+	args = append(args, filters.limit(), filters.offset())
+	rows, err := m.queryContext(ctx, query, args...)
 	if err != nil {
 		return nil, PaginationData{}, err
 	}
@@ -117,7 +353,12 @@ func (m TodoModel) GetAll(text string, userID int64, contexts []string, projects
 			&m.Priority,
 			&m.Completed,
 			&m.Archived,
+			&m.Starred,
+			&m.Note,
 			&m.Version,
+			&m.DueDate,
+			&m.PriorityEscalatedAt,
+			&m.CompletedAt,
 		)
 		if err != nil {
 			return nil, PaginationData{}, err
@@ -138,26 +379,306 @@ func (m TodoModel) GetAll(text string, userID int64, contexts []string, projects
 // Insert adds a new record to the todo table. It accepts a pointer to a
 // Todo struct and runs an INSERT query. The id, created_at, and version fields
 // are generated automatically.
-func (m TodoModel) Insert(todo *Todo) error {
-	// The query returns the system-generated id, created_at, and version fields
-	// so that we can assign them to the todo struct argument.
+func (m TodoModel) Insert(ctx context.Context, todo *Todo) error {
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	return retryBadConn(ctx, m.Breaker, m.Retries, func() error {
+		return insertTodo(ctx, m.DB, todo)
+	})
+}
+
+// InsertMany adds each of the todos to the todo table in a single
+// transaction, assigning the generated fields to each. If any insert fails,
+// none of the todos are added.
+func (m TodoModel) InsertMany(ctx context.Context, todos []*Todo) error {
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	var tx *sql.Tx
+	err := retryBadConn(ctx, m.Breaker, m.Retries, func() (err error) {
+		tx, err = m.DB.BeginTx(ctx, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op if the transaction has already been committed.
+	defer tx.Rollback()
+
+	for _, todo := range todos {
+		err = insertTodo(ctx, tx, todo)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx, so that a query can
+// be run either on its own or as part of a transaction.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertTodo runs the INSERT query for Insert and InsertIdempotent, assigning
+// the generated fields to the todo. If the todo is completed, its completed_at
+// field is set to the current time.
+func insertTodo(ctx context.Context, q queryRower, todo *Todo) error {
+	// The query returns the system-generated id, created_at, version, and
+	// completed_at fields so that we can assign them to the todo struct
+	// argument.
 	query := `
-		INSERT INTO todos (text, user_id, contexts, projects, priority, completed, archived)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at, version`
+		INSERT INTO todos (text, user_id, contexts, projects, priority, completed, archived, starred, note, due_date, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, CASE WHEN $6 THEN NOW() END)
+		RETURNING id, created_at, version, completed_at`
 
 	todo.NilToSlices()
 
 	// The args slice contains the fields provided in the todo struct arguement.
 	// Note that we are converting the string slice todo.Contexts to an array the
 	// is compatible with the contexts field's text[] type.
-	args := []any{todo.Text, todo.UserID, pq.Array(todo.Contexts), pq.Array(todo.Projects), todo.Priority, todo.Completed, todo.Archived}
+	args := []any{todo.Text, todo.UserID, pq.Array(todo.Contexts), pq.Array(todo.Projects), todo.Priority, todo.Completed, todo.Archived, todo.Starred, todo.Note, todo.DueDate}
+
+	return q.QueryRowContext(ctx, query, args...).Scan(
+		&todo.ID, &todo.CreatedAt, &todo.Version, &todo.CompletedAt)
+}
+
+// InsertIdempotent inserts the todo like Insert, unless the user has already
+// created a todo with the same idempotency key within the last ttl. In that
+// case nothing is inserted, and the original todo is returned instead, along
+// with replayed set to true.
+//
+// The key is claimed and the todo inserted in a single transaction. If two
+// requests with the same key are made concurrently, the second waits for the
+// first to finish, and then replays its todo. The user's expired keys are
+// deleted, so that they can be reused.
+func (m TodoModel) InsertIdempotent(ctx context.Context, todo *Todo, key string, ttl time.Duration) (result *Todo, replayed bool, err error) {
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	var tx *sql.Tx
+	err = retryBadConn(ctx, m.Breaker, m.Retries, func() error {
+		tx, err = m.DB.BeginTx(ctx, nil)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	// Rollback is a no-op if the transaction has already been committed.
+	defer tx.Rollback()
+
+	now := clockNow(m.Clock)
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = $1 AND expiry < $2`,
+		todo.UserID, now)
+	if err != nil {
+		return nil, false, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, expiry)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO NOTHING`,
+		todo.UserID, key, now.Add(ttl))
+	if err != nil {
+		return nil, false, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// If the key wasn't inserted, it has already been used. Return the todo
+	// that was created when it was.
+	if rowsAffected == 0 {
+		var todoID sql.NullInt64
+		err = tx.QueryRowContext(ctx,
+			`SELECT todo_id FROM idempotency_keys WHERE user_id = $1 AND key = $2`,
+			todo.UserID, key).Scan(&todoID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, false, ErrRecordNotFound
+			}
+			return nil, false, err
+		}
+		if !todoID.Valid {
+			return nil, false, ErrRecordNotFound
+		}
+
+		if err = tx.Commit(); err != nil {
+			return nil, false, err
+		}
+
+		original, err := m.GetTodoIfOwned(ctx, todoID.Int64, todo.UserID)
+		if err != nil {
+			return nil, false, err
+		}
+		return original, true, nil
+	}
+
+	err = insertTodo(ctx, tx, todo)
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE idempotency_keys SET todo_id = $1 WHERE user_id = $2 AND key = $3`,
+		todo.ID, todo.UserID, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	return todo, false, nil
+}
+
+// InsertManyIdempotent inserts the todos like InsertMany, unless the user has
+// already used the idempotency key within the last ttl. Once the todos are
+// inserted, respond is called to build the response to the request, which is
+// stored with the key. If the key has already been used, nothing is inserted,
+// and the stored response is returned instead, along with replayed set to
+// true. If the key was used to create a single todo, an ErrRecordNotFound is
+// returned.
+//
+// The key is claimed and the todos inserted in a single transaction, as in
+// InsertIdempotent.
+func (m TodoModel) InsertManyIdempotent(ctx context.Context, userID int64, todos []*Todo, key string, ttl time.Duration, respond func() ([]byte, error)) (response []byte, replayed bool, err error) {
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	var tx *sql.Tx
+	err = retryBadConn(ctx, m.Breaker, m.Retries, func() error {
+		tx, err = m.DB.BeginTx(ctx, nil)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	// Rollback is a no-op if the transaction has already been committed.
+	defer tx.Rollback()
+
+	now := clockNow(m.Clock)
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = $1 AND expiry < $2`,
+		userID, now)
+	if err != nil {
+		return nil, false, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, expiry)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, key) DO NOTHING`,
+		userID, key, now.Add(ttl))
+	if err != nil {
+		return nil, false, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// If the key wasn't inserted, it has already been used. Return the
+	// response that was stored when it was.
+	if rowsAffected == 0 {
+		err = tx.QueryRowContext(ctx,
+			`SELECT response FROM idempotency_keys WHERE user_id = $1 AND key = $2`,
+			userID, key).Scan(&response)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, false, ErrRecordNotFound
+			}
+			return nil, false, err
+		}
+		if response == nil {
+			return nil, false, ErrRecordNotFound
+		}
+		return response, true, tx.Commit()
+	}
+
+	for _, todo := range todos {
+		err = insertTodo(ctx, tx, todo)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	response, err = respond()
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE idempotency_keys SET response = $1 WHERE user_id = $2 AND key = $3`,
+		response, userID, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	return response, false, nil
+}
+
+// GetIdempotentResponse returns the response stored by InsertManyIdempotent
+// for the user's idempotency key, if the key hasn't expired. Otherwise, an
+// ErrRecordNotFound is returned.
+func (m TodoModel) GetIdempotentResponse(ctx context.Context, userID int64, key string) ([]byte, error) {
+	query := `
+		SELECT response
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND expiry >= $3 AND response IS NOT NULL`
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	var response []byte
+	err := m.queryRowScan(ctx, query, []any{userID, key, clockNow(m.Clock)}, &response)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// GetIdempotent returns the todo the user created with the idempotency key, if
+// the key hasn't expired. Otherwise, an ErrRecordNotFound is returned. See
+// InsertIdempotent.
+func (m TodoModel) GetIdempotent(ctx context.Context, userID int64, key string) (*Todo, error) {
+	query := `
+		SELECT todo_id
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND expiry >= $3 AND todo_id IS NOT NULL`
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(
-		&todo.ID, &todo.CreatedAt, &todo.Version)
+	var todoID int64
+	err := m.queryRowScan(ctx, query, []any{userID, key, clockNow(m.Clock)}, &todoID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.GetTodoIfOwned(ctx, todoID, userID)
 }
 
 // GetTodoIfOwned retrieves a a specific record in the todos table by its ID, but only if the current user owns the todo item.
@@ -168,21 +689,21 @@ func (m TodoModel) Insert(todo *Todo) error {
 //   - If there is no todo with matching id and userID
 //
 // If a todo is found, a pointer to the corresponding Todo struct is returned.
-func (m TodoModel) GetTodoIfOwned(id, userID int64) (*Todo, error) {
+func (m TodoModel) GetTodoIfOwned(ctx context.Context, id, userID int64) (*Todo, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT id, user_id, created_at, text, contexts, projects, priority, completed, archived, version
+		SELECT id, user_id, created_at, text, contexts, projects, priority, completed, archived, starred, COALESCE(note, ''), version, due_date, priority_escalated_at, completed_at
 		FROM todos WHERE ID = $1 AND user_id = $2`
 
 	var todo Todo
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id, userID).Scan(
+	err := m.queryRowScan(ctx, query, []any{id, userID},
 		&todo.ID,
 		&todo.UserID,
 		&todo.CreatedAt,
@@ -192,7 +713,12 @@ func (m TodoModel) GetTodoIfOwned(id, userID int64) (*Todo, error) {
 		&todo.Priority,
 		&todo.Completed,
 		&todo.Archived,
+		&todo.Starred,
+		&todo.Note,
 		&todo.Version,
+		&todo.DueDate,
+		&todo.PriorityEscalatedAt,
+		&todo.CompletedAt,
 	)
 
 	if err != nil {
@@ -207,19 +733,52 @@ func (m TodoModel) GetTodoIfOwned(id, userID int64) (*Todo, error) {
 	return &todo, nil
 }
 
+// FindDuplicate returns the ID of the user's oldest active todo with exactly
+// the given text. A todo is active if it is neither completed nor archived. If
+// there is no such todo, an ErrRecordNotFound is returned.
+func (m TodoModel) FindDuplicate(ctx context.Context, userID int64, text string) (int64, error) {
+	query := `
+		SELECT id
+		FROM todos
+		WHERE user_id = $1 AND text = $2 AND completed = false AND archived = false
+		ORDER BY id ASC
+		LIMIT 1`
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	var id int64
+	err := m.queryRowScan(ctx, query, []any{userID, text}, &id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
 // Update updates a specific record in the todos table. The caller should
 // check for the existence of the record to be updated before calling Update.
 // The record's version field is incremented by 1 after update.
 //
+// If the todo is being completed, its completed_at field is set to the current
+// time, and if it is being marked as not completed, completed_at is cleared.
+// Otherwise, completed_at is unchanged. The new value is assigned to the todo.
+//
 // Prevents edit conflicts by verifying that the version of the record in the
 // UPDATE query is the same as the version of the todo argument. In case of
 // an edit conflict, an ErrEditConflict error is returned.
-func (m TodoModel) Update(todo *Todo) error {
+func (m TodoModel) Update(ctx context.Context, todo *Todo) error {
 	query := `
 		UPDATE todos
-		SET text = $1, contexts = $2, projects = $3, priority = $4, completed = $5, archived = $6, version = version + 1
-		WHERE id = $7 AND version = $8
-		RETURNING version`
+		SET text = $1, contexts = $2, projects = $3, priority = $4, completed = $5, archived = $6, starred = $7, note = NULLIF($8, ''), due_date = $9, version = version + 1,
+			completed_at = CASE WHEN NOT $5 THEN NULL WHEN completed THEN completed_at ELSE NOW() END
+		WHERE id = $10 AND version = $11
+		RETURNING version, completed_at`
 
 	args := []any{
 		todo.Text,
@@ -228,14 +787,17 @@ func (m TodoModel) Update(todo *Todo) error {
 		todo.Priority,
 		todo.Completed,
 		todo.Archived,
+		todo.Starred,
+		todo.Note,
+		todo.DueDate,
 		todo.ID,
 		todo.Version,
 	}
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&todo.Version)
+	err := m.queryRowScan(ctx, query, args, &todo.Version, &todo.CompletedAt)
 	if err != nil {
 		switch {
 		// An sql.ErrNoRows is returned if there are no matching records. Since we
@@ -250,19 +812,20 @@ func (m TodoModel) Update(todo *Todo) error {
 	return nil
 }
 
-// Delete deletes a specific record from the todos table. Returns an
-// ErrNoRecordFound error if no record is found.
-func (m TodoModel) Delete(id int64) error {
+// DeleteIfOwned deletes a specific record from the todos table, but only if
+// the user owns it. Returns an ErrRecordNotFound error if no such record is
+// found.
+func (m TodoModel) DeleteIfOwned(ctx context.Context, id, userID int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	query := `DELETE FROM todos WHERE id = $1`
+	query := `DELETE FROM todos WHERE id = $1 AND user_id = $2`
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.execContext(ctx, query, id, userID)
 	if err != nil {
 		return err
 	}
@@ -280,38 +843,467 @@ func (m TodoModel) Delete(id int64) error {
 	return nil
 }
 
+// DeleteManyIfOwned deletes each of the todos with the given IDs that is owned
+// by the user, in a single query. The IDs of the deleted todos are returned,
+// in ascending order. IDs that don't exist, or that belong to another user,
+// are ignored.
+func (m TodoModel) DeleteManyIfOwned(ctx context.Context, ids []int64, userID int64) ([]int64, error) {
+	query := `
+		DELETE FROM todos
+		WHERE id = ANY($1) AND user_id = $2
+		RETURNING id`
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	rows, err := m.queryContext(ctx, query, pq.Array(ids), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deleted := []int64{}
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(deleted)
+	return deleted, nil
+}
+
+// GetManyIfOwned returns each of the todos with the given IDs that is owned by
+// the user, ordered by ID. It selects the same todos that DeleteManyIfOwned
+// would delete.
+func (m TodoModel) GetManyIfOwned(ctx context.Context, ids []int64, userID int64) ([]*Todo, error) {
+	return m.getMatching(ctx, `WHERE id = ANY($1) AND user_id = $2`, []any{pq.Array(ids), userID})
+}
+
+// getMatching retrieves all todos matching the given WHERE clause, ordered by
+// ID. The clause and its arguments are usually created by todoFilterClause.
+func (m TodoModel) getMatching(ctx context.Context, whereClause string, args []any) ([]*Todo, error) {
+	return m.getMatchingPage(ctx, whereClause, args, 0)
+}
+
+// getMatchingPage is like getMatching, but retrieves at most limit todos, if
+// limit is positive.
+func (m TodoModel) getMatchingPage(ctx context.Context, whereClause string, args []any, limit int) ([]*Todo, error) {
+	query := fmt.Sprintf(`
+		SELECT id, user_id, created_at, text, contexts, projects, priority, completed, archived, starred, COALESCE(note, ''), version, due_date, priority_escalated_at, completed_at
+		FROM todos
+		%s
+		ORDER BY id ASC`, whereClause)
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(`
+		LIMIT $%d`, len(args))
+	}
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	rows, err := m.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*Todo{}
+
+	for rows.Next() {
+		var todo Todo
+		err = rows.Scan(
+			&todo.ID,
+			&todo.UserID,
+			&todo.CreatedAt,
+			&todo.Text,
+			pq.Array(&todo.Contexts),
+			pq.Array(&todo.Projects),
+			&todo.Priority,
+			&todo.Completed,
+			&todo.Archived,
+			&todo.Starred,
+			&todo.Note,
+			&todo.Version,
+			&todo.DueDate,
+			&todo.PriorityEscalatedAt,
+			&todo.CompletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, &todo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// completeMatchingFilters are the filters applied by CompleteMatching and
+// PreviewCompleteMatching. Only incomplete, unarchived todos are completed.
+var completeMatchingFilters = Filters{Undone: true}
+
+// CompleteMatching marks all of a user's incomplete, unarchived todos that
+// match the given text, contexts, and projects as completed, setting their
+// completed_at fields to the current time. The matching todos are selected
+// exactly as they are in GetAll. The version of each updated record is
+// incremented by 1.
+//
+// The number of todos that were completed is returned.
+func (m TodoModel) CompleteMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string) (int64, error) {
+	whereClause, args := todoFilterClause(text, userID, contexts, projects, completeMatchingFilters)
+
+	query := fmt.Sprintf(`
+		UPDATE todos
+		SET completed = true, completed_at = NOW(), version = version + 1
+		%s`, whereClause)
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	result, err := m.execContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// PreviewCompleteMatching returns the todos that CompleteMatching would
+// complete if called with the same arguments. No records are modified.
+func (m TodoModel) PreviewCompleteMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string) ([]*Todo, error) {
+	whereClause, args := todoFilterClause(text, userID, contexts, projects, completeMatchingFilters)
+	return m.getMatching(ctx, whereClause, args)
+}
+
+// ArchiveCompleted archives all of a user's completed, unarchived todos that
+// have each of the given contexts and projects. If none are given, every
+// completed todo is archived. The version of each updated record is
+// incremented by 1.
+//
+// The number of todos that were archived is returned.
+func (m TodoModel) ArchiveCompleted(ctx context.Context, userID int64, contexts []string, projects []string) (int64, error) {
+	whereClause, args := todoFilterClause("", userID, contexts, projects, Filters{Done: true})
+
+	query := fmt.Sprintf(`
+		UPDATE todos
+		SET archived = true, version = version + 1
+		%s`, whereClause)
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	result, err := m.execContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// TagChanges describes the contexts and projects to add to, and remove from,
+// a set of todos.
+type TagChanges struct {
+	AddContexts    []string
+	RemoveContexts []string
+	AddProjects    []string
+	RemoveProjects []string
+}
+
+// ValidateTagChanges checks that at least one change is provided, that each
+// tag is valid, and that no more tags are added than a todo can have. A tag
+// can't be both added and removed.
+func ValidateTagChanges(v *validator.Validator, c TagChanges, limits TodoLimits) {
+	limits = limits.withDefaults()
+
+	v.CheckRule(len(c.AddContexts)+len(c.RemoveContexts)+len(c.AddProjects)+len(c.RemoveProjects) > 0,
+		"changes", validator.RuleRequired, "at least one context or project must be added or removed")
+
+	validateTagChange(v, "contexts", c.AddContexts, c.RemoveContexts, limits.MaxContexts)
+	validateTagChange(v, "projects", c.AddProjects, c.RemoveProjects, limits.MaxProjects)
+}
+
+// validateTagChange validates the tags added to and removed from a single
+// column. The keys of any errors are "add_" or "remove_" followed by key.
+func validateTagChange(v *validator.Validator, key string, add, remove []string, max int) {
+	addKey, removeKey := "add_"+key, "remove_"+key
+
+	v.CheckRule(len(add) <= max, addKey, validator.RuleTooMany, fmt.Sprintf("must be no more than %d %s", max, key))
+	v.CheckRule(validator.Unique(add), addKey, validator.RuleDuplicate, "must not contain duplicate values")
+	validateTags(v, addKey, add)
+
+	v.CheckRule(validator.Unique(remove), removeKey, validator.RuleDuplicate, "must not contain duplicate values")
+	validateTags(v, removeKey, remove)
+
+	for _, tag := range add {
+		v.CheckRule(!slices.Contains(remove, tag), key, validator.RuleConflict, fmt.Sprintf("%q can't be both added and removed", tag))
+	}
+}
+
+// tagChangeExpr returns an expression for the new value of the column after
+// the tags are added and removed, and appends the arguments for its
+// placeholders to args. Added tags are first removed, so that they aren't
+// duplicated, and are then appended to the end of the array.
+func tagChangeExpr(column string, add, remove []string, args *[]any) string {
+	expr := column
+
+	for _, tag := range slices.Concat(remove, add) {
+		*args = append(*args, tag)
+		expr = fmt.Sprintf("array_remove(%s, $%d)", expr, len(*args))
+	}
+
+	if len(add) > 0 {
+		*args = append(*args, pq.Array(add))
+		expr = fmt.Sprintf("array_cat(%s, $%d::text[])", expr, len(*args))
+	}
+
+	return expr
+}
+
+// TagMatching adds contexts and projects to, and removes them from, all of a
+// user's unarchived todos that match the given text, contexts, and projects.
+// The matching todos are selected exactly as they are in GetAll. The version
+// of each updated record is incremented by 1.
+//
+// Todos that would be left with more contexts or projects than the limits
+// allow are skipped. The numbers of todos that were updated and skipped are
+// returned. Todos that the changes don't affect are neither updated nor
+// skipped.
+func (m TodoModel) TagMatching(ctx context.Context, text string, userID int64, contexts []string, projects []string, changes TagChanges, limits TodoLimits) (updated int64, skipped int64, err error) {
+	limits = limits.withDefaults()
+
+	whereClause, args := todoFilterClause(text, userID, contexts, projects, Filters{})
+	contextsExpr := tagChangeExpr("contexts", changes.AddContexts, changes.RemoveContexts, &args)
+	projectsExpr := tagChangeExpr("projects", changes.AddProjects, changes.RemoveProjects, &args)
+	args = append(args, limits.MaxContexts, limits.MaxProjects)
+
+	query := fmt.Sprintf(`
+		WITH matched AS (
+			SELECT id, %s AS contexts, %s AS projects
+			FROM todos
+			%s
+		), updated AS (
+			UPDATE todos
+			SET contexts = matched.contexts, projects = matched.projects, version = version + 1
+			FROM matched
+			WHERE todos.id = matched.id
+			AND (todos.contexts <> matched.contexts OR todos.projects <> matched.projects)
+			AND cardinality(matched.contexts) <= $%[4]d AND cardinality(matched.projects) <= $%[5]d
+			RETURNING todos.id
+		)
+		SELECT
+			(SELECT count(*) FROM updated),
+			(SELECT count(*) FROM matched
+				WHERE cardinality(contexts) > $%[4]d OR cardinality(projects) > $%[5]d)`,
+		contextsExpr, projectsExpr, whereClause, len(args)-1, len(args))
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	err = m.queryRowScan(ctx, query, args, &updated, &skipped)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return updated, skipped, nil
+}
+
+// MaxDistinctTags is the maximum page size of DistinctContexts and
+// DistinctProjects, and their default page size.
+const MaxDistinctTags = 100
+
+// likeEscaper escapes the wildcard characters of LIKE patterns, so that user
+// input is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// DistinctContexts returns a page of the distinct contexts of a user's
+// unarchived todos that begin with prefix, in alphabetical order. Matching is
+// case-insensitive, and an empty prefix matches every context. Only the
+// filters' Page and PageSize fields are used.
+//
+// Pagination metadata is returned along with the contexts. Its TotalRecords
+// is the number of matching contexts across all pages, and it is empty if
+// there are none.
+func (m TodoModel) DistinctContexts(ctx context.Context, userID int64, prefix string, filters Filters) ([]string, PaginationData, error) {
+	return m.distinctTags(ctx, "contexts", userID, prefix, filters)
+}
+
+// DistinctProjects returns a page of the distinct projects of a user's
+// unarchived todos that begin with prefix. See DistinctContexts for details.
+func (m TodoModel) DistinctProjects(ctx context.Context, userID int64, prefix string, filters Filters) ([]string, PaginationData, error) {
+	return m.distinctTags(ctx, "projects", userID, prefix, filters)
+}
+
+// distinctTags returns a page of the distinct values of the column, which
+// must be either "contexts" or "projects", that begin with prefix.
+func (m TodoModel) distinctTags(ctx context.Context, column string, userID int64, prefix string, filters Filters) ([]string, PaginationData, error) {
+	// The tags are made distinct in a subquery, since count(*) OVER() would
+	// otherwise count them before duplicates are removed.
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), tag
+		FROM (
+			SELECT DISTINCT tag
+			FROM todos, unnest(%s) AS tag
+			WHERE user_id = $1 AND archived = false AND tag ILIKE $2 || '%%'
+		) AS tags
+		ORDER BY tag
+		LIMIT $3 OFFSET $4`, column)
+
+	ctx, cancel := CreateTimeoutContextFrom(ctx, queryTimeout(m.Timeout))
+	defer cancel()
+
+	rows, err := m.queryContext(ctx, query, userID, likeEscaper.Replace(prefix), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, PaginationData{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	tags := []string{}
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&totalRecords, &tag); err != nil {
+			return nil, PaginationData{}, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, PaginationData{}, err
+	}
+
+	return tags, calculatePaginationData(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// TodoLimits contains configurable limits on the fields of a todo. Zero
+// valued fields fall back to the defaults in DefaultTodoLimits.
+type TodoLimits struct {
+	MaxContexts int
+	MaxProjects int
+}
+
+// DefaultTodoLimits are the limits used for any unset fields of a TodoLimits.
+var DefaultTodoLimits = TodoLimits{MaxContexts: 5, MaxProjects: 5}
+
+// withDefaults returns a copy of the limits with any zero valued fields set
+// to their defaults.
+func (l TodoLimits) withDefaults() TodoLimits {
+	if l.MaxContexts == 0 {
+		l.MaxContexts = DefaultTodoLimits.MaxContexts
+	}
+	if l.MaxProjects == 0 {
+		l.MaxProjects = DefaultTodoLimits.MaxProjects
+	}
+	return l
+}
+
+// DefaultMaxBatchSize is the maximum number of todo IDs accepted by a batch
+// operation, if no other maximum is configured.
+const DefaultMaxBatchSize = 100
+
+// batchLimit returns max, or DefaultMaxBatchSize if max is 0.
+func batchLimit(max int) int {
+	if max == 0 {
+		return DefaultMaxBatchSize
+	}
+	return max
+}
+
+// ValidateBatchIDs checks that between 1 and max todo IDs are provided for a
+// batch operation. If max is 0, DefaultMaxBatchSize is used.
+func ValidateBatchIDs(v *validator.Validator, ids []int64, max int) {
+	max = batchLimit(max)
+
+	v.CheckRule(len(ids) > 0, "ids", validator.RuleRequired, "must be provided")
+	v.CheckRule(len(ids) <= max, "ids", validator.RuleTooMany, fmt.Sprintf("must not contain more than %d IDs", max))
+}
+
+// ValidateImportSize checks that at most max todos are imported at once. If
+// max is 0, DefaultMaxBatchSize is used.
+func ValidateImportSize(v *validator.Validator, n int, max int) {
+	max = batchLimit(max)
+
+	v.CheckRule(n <= max, "body", validator.RuleTooMany, fmt.Sprintf("must not contain more than %d todos", max))
+}
+
 // ValidateTodo validates the fields of a Todo struct. The fields must meet
 // the following requirements:
 //
 //   - Text is the only required field. It contains the full text of the todo.
 //
-//   - Text must be less than 500 bytes.
+//   - Text must be valid UTF-8, and no more than maxTextLength characters.
 //
-//   - There can be between 0 and 5 unique, string-valued contexts.
+//   - There can be between 0 and limits.MaxContexts unique, string-valued
+//     contexts. Defaults to 5.
 //
-//   - There can be between 0 and 5 unique, string-valued projects.
+//   - There can be between 0 and limits.MaxProjects unique, string-valued
+//     projects. Defaults to 5.
+//
+//   - Each context and project must be non-empty, no more than 50 bytes, and
+//     contain only letters, digits, hyphens, and underscores.
 //
 //   - There can be a priority, a single character between A and Z, or an empty
 //     string.
 //
 //   - Archived and Completed must be booleans.
-func ValidateTodo(v *validator.Validator, t *Todo) {
+func ValidateTodo(v *validator.Validator, t *Todo, limits TodoLimits) {
+	limits = limits.withDefaults()
 
-	v.Check(t.Text != "", "text", "must be provided")
-	v.Check(len(t.Text) < 500, "text", "must be less than 500 bytes")
+	v.CheckRule(t.Text != "", "text", validator.RuleRequired, "must be provided")
+	v.CheckRule(utf8.ValidString(t.Text), "text", validator.RuleFormat, "must be valid UTF-8")
+	v.CheckRule(utf8.RuneCountInString(t.Text) <= maxTextLength, "text", validator.RuleTooLong, fmt.Sprintf("must be no more than %d characters", maxTextLength))
+	v.CheckRule(len(t.Note) <= maxNoteLength, "note", validator.RuleTooLong, fmt.Sprintf("must be no more than %d bytes", maxNoteLength))
 
-	v.Check(len(t.Contexts) <= 5, "contexts", "must be no more than 5 contexts")
-	v.Check(validator.Unique(t.Contexts), "contexts", "must not contain duplicate values")
+	v.CheckRule(len(t.Contexts) <= limits.MaxContexts, "contexts", validator.RuleTooMany, fmt.Sprintf("must be no more than %d contexts", limits.MaxContexts))
+	v.CheckRule(validator.Unique(t.Contexts), "contexts", validator.RuleDuplicate, "must not contain duplicate values")
+	validateTags(v, "contexts", t.Contexts)
 
-	v.Check(len(t.Projects) <= 5, "contexts", "must be no more than 5 projects")
-	v.Check(validator.Unique(t.Projects), "projects", "must not contain duplicate values")
+	v.CheckRule(len(t.Projects) <= limits.MaxProjects, "projects", validator.RuleTooMany, fmt.Sprintf("must be no more than %d projects", limits.MaxProjects))
+	v.CheckRule(validator.Unique(t.Projects), "projects", validator.RuleDuplicate, "must not contain duplicate values")
+	validateTags(v, "projects", t.Projects)
 
-	v.Check(priorityIsValid(t), "priority", "must be a capital letter (A to Z) or empty string")
+	v.CheckRule(priorityIsValid(t), "priority", validator.RuleFormat, "must be a capital letter (A to Z) or empty string")
 
 	v.Check(reflect.TypeOf(t.Archived).Kind() == reflect.Bool, "archived", "must be boolean")
 	v.Check(reflect.TypeOf(t.Completed).Kind() == reflect.Bool, "completed", "must be boolean")
 }
 
+// maxTextLength is the maximum length of a todo's text, in characters. It is
+// counted in characters rather than bytes, so that text in scripts with
+// multibyte characters isn't given a lower limit.
+const maxTextLength = 500
+
+// maxNoteLength is the maximum length of a todo's note, in bytes.
+const maxNoteLength = 10_000
+
+// maxTagLength is the maximum length of a context or project, in bytes.
+const maxTagLength = 50
+
+// validateTags checks each of the contexts or projects in tags. Each must be
+// non-empty, no more than maxTagLength bytes, and match validator.TagRX.
+// Errors are added under keys of the form "contexts[0]".
+func validateTags(v *validator.Validator, key string, tags []string) {
+	for i, tag := range tags {
+		tagKey := fmt.Sprintf("%s[%d]", key, i)
+
+		v.CheckRule(tag != "", tagKey, validator.RuleRequired, "must not be empty")
+		v.CheckRule(len(tag) <= maxTagLength, tagKey, validator.RuleTooLong, fmt.Sprintf("must be no more than %d bytes", maxTagLength))
+		v.CheckRule(tag == "" || validator.Matches(tag, validator.TagRX), tagKey, validator.RuleFormat, "must contain only letters, digits, hyphens, and underscores")
+	}
+}
+
 // priorityIsValid returns true if the todo item's priority field is valid.
 // Valid options are letters from A to Z and the empty string.
 func priorityIsValid(t *Todo) bool {
@@ -0,0 +1,865 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/lib/pq"
+)
+
+// newTestTodoModel returns a TodoModel backed by a sqlmock database, along
+// with the mock for setting expectations.
+func newTestTodoModel(t *testing.T) (TodoModel, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return TodoModel{DB: db}, mock
+}
+
+func TestCompleteMatching(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		contexts []string
+		projects []string
+		query    string
+		args     []driver.Value
+		affected int64
+	}{
+		{
+			name:     "Text only",
+			text:     "milk",
+			query:    `UPDATE todos SET completed = true, completed_at = NOW(), version = version + 1 WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false AND completed = false`,
+			args:     []driver.Value{"milk", int64(1)},
+			affected: 2,
+		},
+		{
+			name:     "Contexts and projects",
+			contexts: []string{"store"},
+			projects: []string{"groceries", "home"},
+			query:    `UPDATE todos SET completed = true, completed_at = NOW(), version = version + 1 WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND contexts @> $3 AND projects @> $4 AND archived = false AND completed = false`,
+			args:     []driver.Value{"", int64(1), pq.Array([]string{"store"}), pq.Array([]string{"groceries", "home"})},
+			affected: 5,
+		},
+		{
+			name:     "No matches",
+			projects: []string{"work"},
+			query:    `UPDATE todos SET completed = true, completed_at = NOW(), version = version + 1 WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND projects @> $3 AND archived = false AND completed = false`,
+			args:     []driver.Value{"", int64(1), pq.Array([]string{"work"})},
+			affected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+
+			mock.ExpectExec(regexp.QuoteMeta(tt.query)).
+				WithArgs(tt.args...).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			count, err := m.CompleteMatching(context.Background(), tt.text, 1, tt.contexts, tt.projects)
+			assert.IsNil(t, err)
+			assert.Equal(t, count, tt.affected)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestArchiveCompleted(t *testing.T) {
+	tests := []struct {
+		name     string
+		contexts []string
+		projects []string
+		query    string
+		args     []driver.Value
+		affected int64
+	}{
+		{
+			name:     "All completed todos",
+			query:    `UPDATE todos SET archived = true, version = version + 1 WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false AND completed = true`,
+			args:     []driver.Value{"", int64(1)},
+			affected: 4,
+		},
+		{
+			name:     "Scoped by context and project",
+			contexts: []string{"work"},
+			projects: []string{"godo"},
+			query:    `UPDATE todos SET archived = true, version = version + 1 WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND contexts @> $3 AND projects @> $4 AND archived = false AND completed = true`,
+			args:     []driver.Value{"", int64(1), pq.Array([]string{"work"}), pq.Array([]string{"godo"})},
+			affected: 2,
+		},
+		{
+			name:     "No matches",
+			projects: []string{"garden"},
+			query:    `UPDATE todos SET archived = true, version = version + 1 WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND projects @> $3 AND archived = false AND completed = true`,
+			args:     []driver.Value{"", int64(1), pq.Array([]string{"garden"})},
+			affected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+
+			mock.ExpectExec(regexp.QuoteMeta(tt.query)).
+				WithArgs(tt.args...).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			count, err := m.ArchiveCompleted(context.Background(), 1, tt.contexts, tt.projects)
+			assert.IsNil(t, err)
+			assert.Equal(t, count, tt.affected)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteManyIfOwned(t *testing.T) {
+	m, mock := newTestTodoModel(t)
+
+	// Todo 2 doesn't exist or isn't owned by the user.
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM todos WHERE id = ANY($1) AND user_id = $2 RETURNING id`)).
+		WithArgs(pq.Array([]int64{3, 1, 2}), int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(1))
+
+	deleted, err := m.DeleteManyIfOwned(context.Background(), []int64{3, 1, 2}, 1)
+
+	assert.IsNil(t, err)
+	assert.Equal(t, len(deleted), 2)
+	assert.Equal(t, deleted[0], 1)
+	assert.Equal(t, deleted[1], 3)
+	assert.IsNil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllOrderBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    string
+		orderBy string
+	}{
+		{name: "Single key", sort: "text", orderBy: "ORDER BY starred DESC, text ASC, id ASC"},
+		{name: "Two keys", sort: "-priority,created_at", orderBy: "ORDER BY starred DESC, priority DESC, created_at ASC, id ASC"},
+		{name: "Whitespace around keys", sort: "priority, -text", orderBy: "ORDER BY starred DESC, priority ASC, text DESC, id ASC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta(tt.orderBy)).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}))
+
+			filters := Filters{
+				Page:         1,
+				PageSize:     20,
+				Sort:         tt.sort,
+				SortSafelist: []string{"text", "priority", "created_at", "-text", "-priority", "-created_at"},
+			}
+			_, _, err := m.GetAll(context.Background(), "", 1, nil, nil, filters)
+			assert.IsNil(t, err)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetAllStatusFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters Filters
+		where   string
+	}{
+		{name: "Default", filters: Filters{}, where: "AND archived = false ORDER BY"},
+		{name: "Done", filters: Filters{Done: true}, where: "AND archived = false AND completed = true ORDER BY"},
+		{name: "Undone", filters: Filters{Undone: true}, where: "AND archived = false AND completed = false ORDER BY"},
+		{name: "Include archived", filters: Filters{IncludeArchived: true}, where: "AND user_id = $2 ORDER BY"},
+		{name: "Only archived", filters: Filters{OnlyArchived: true}, where: "AND archived = true ORDER BY"},
+		{name: "Include archived and done", filters: Filters{IncludeArchived: true, Done: true}, where: "AND user_id = $2 AND completed = true ORDER BY"},
+		{name: "Include archived and undone", filters: Filters{IncludeArchived: true, Undone: true}, where: "AND user_id = $2 AND completed = false ORDER BY"},
+		{name: "Only archived and done", filters: Filters{OnlyArchived: true, Done: true}, where: "AND archived = true AND completed = true ORDER BY"},
+		{name: "Only archived and undone", filters: Filters{OnlyArchived: true, Undone: true}, where: "AND archived = true AND completed = false ORDER BY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta(tt.where)).
+				WithArgs("", int64(1), 20, 0).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}))
+
+			filters := tt.filters
+			filters.Page, filters.PageSize = 1, 20
+			filters.Sort, filters.SortSafelist = "id", []string{"id"}
+			_, _, err := m.GetAll(context.Background(), "", 1, nil, nil, filters)
+			assert.IsNil(t, err)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTagMatching(t *testing.T) {
+	tests := []struct {
+		name     string
+		changes  TagChanges
+		limits   TodoLimits
+		selected string
+		args     []driver.Value
+		updated  int64
+		skipped  int64
+	}{
+		{
+			name:     "Add and remove contexts",
+			changes:  TagChanges{AddContexts: []string{"store"}, RemoveContexts: []string{"home"}},
+			selected: `SELECT id, array_cat(array_remove(array_remove(contexts, $4), $5), $6::text[]) AS contexts, projects AS projects FROM todos`,
+			args:     []driver.Value{"", int64(1), pq.Array([]string{"groceries"}), "home", "store", pq.Array([]string{"store"}), 5, 5},
+			updated:  3,
+		},
+		{
+			name:     "Remove projects",
+			changes:  TagChanges{RemoveProjects: []string{"groceries", "home"}},
+			selected: `SELECT id, contexts AS contexts, array_remove(array_remove(projects, $4), $5) AS projects FROM todos`,
+			args:     []driver.Value{"", int64(1), pq.Array([]string{"groceries"}), "groceries", "home", 5, 5},
+			updated:  2,
+		},
+		{
+			name:     "Count cap",
+			changes:  TagChanges{AddProjects: []string{"errands"}},
+			limits:   TodoLimits{MaxProjects: 2},
+			selected: `SELECT id, contexts AS contexts, array_cat(array_remove(projects, $4), $5::text[]) AS projects FROM todos`,
+			args:     []driver.Value{"", int64(1), pq.Array([]string{"groceries"}), "errands", pq.Array([]string{"errands"}), 5, 2},
+			updated:  1,
+			skipped:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+
+			// The limits are the last two arguments, and only todos within them
+			// are updated.
+			limitsClause := fmt.Sprintf("cardinality(matched.contexts) <= $%d AND cardinality(matched.projects) <= $%d", len(tt.args)-1, len(tt.args))
+
+			mock.ExpectQuery(regexp.QuoteMeta(tt.selected) + `(.+)projects @> \$3 AND archived = false(.+)` + regexp.QuoteMeta(limitsClause)).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"updated", "skipped"}).AddRow(tt.updated, tt.skipped))
+
+			updated, skipped, err := m.TagMatching(context.Background(), "", 1, nil, []string{"groceries"}, tt.changes, tt.limits)
+			assert.IsNil(t, err)
+			assert.Equal(t, updated, tt.updated)
+			assert.Equal(t, skipped, tt.skipped)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestValidateTagChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes TagChanges
+		key     string
+		errMsg  string
+	}{
+		{name: "Valid", changes: TagChanges{AddContexts: []string{"store"}, RemoveProjects: []string{"home"}}},
+		{name: "No changes", key: "changes", errMsg: "at least one context or project must be added or removed"},
+		{
+			name:    "Too many added",
+			changes: TagChanges{AddProjects: []string{"a", "b", "c"}},
+			key:     "add_projects",
+			errMsg:  "must be no more than 2 projects",
+		},
+		{
+			name:    "Duplicates",
+			changes: TagChanges{RemoveContexts: []string{"home", "home"}},
+			key:     "remove_contexts",
+			errMsg:  "must not contain duplicate values",
+		},
+		{
+			name:    "Added and removed",
+			changes: TagChanges{AddContexts: []string{"home"}, RemoveContexts: []string{"home"}},
+			key:     "contexts",
+			errMsg:  `"home" can't be both added and removed`,
+		},
+		{
+			name:    "Invalid tag",
+			changes: TagChanges{AddContexts: []string{"a b"}},
+			key:     "add_contexts[0]",
+			errMsg:  "must contain only letters, digits, hyphens, and underscores",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateTagChanges(v, tt.changes, TodoLimits{MaxProjects: 2})
+
+			if tt.errMsg == "" {
+				assert.Equal(t, v.Valid(), true)
+			} else {
+				assert.Equal(t, v.Errors[tt.key], tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestTodoQueryCancellation(t *testing.T) {
+	m, mock := newTestTodoModel(t)
+
+	// The query takes longer than the client is willing to wait.
+	mock.ExpectQuery("SELECT (.+) FROM todos WHERE ID = \\$1 AND user_id = \\$2").
+		WithArgs(1, 1).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := m.GetTodoIfOwned(ctx, 1, 1)
+
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, errors.Is(err, context.Canceled) || errors.Is(err, sqlmock.ErrCancelled), true)
+	assert.Equal(t, time.Since(start) < time.Second, true)
+}
+
+func TestTodoFilterClause(t *testing.T) {
+	after := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		filters Filters
+		clause  string
+		args    []any
+	}{
+		{
+			name:    "No date filters",
+			filters: Filters{},
+			clause:  `WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false`,
+			args:    []any{"", int64(1)},
+		},
+		{
+			name:    "Created after",
+			filters: Filters{CreatedAfter: after},
+			clause:  `WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false AND created_at >= $3`,
+			args:    []any{"", int64(1), after},
+		},
+		{
+			name:    "Created before and after",
+			filters: Filters{CreatedAfter: after, CreatedBefore: before, Undone: true},
+			clause:  `WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false AND completed = false AND created_at >= $3 AND created_at < $4`,
+			args:    []any{"", int64(1), after, before},
+		},
+		{
+			name:    "Completed before and after",
+			filters: Filters{CompletedAfter: after, CompletedBefore: before},
+			clause:  `WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false AND completed_at >= $3 AND completed_at < $4`,
+			args:    []any{"", int64(1), after, before},
+		},
+		{
+			name:    "Starred",
+			filters: Filters{Starred: true},
+			clause:  `WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false AND starred = true`,
+			args:    []any{"", int64(1)},
+		},
+		{
+			name:    "Priority",
+			filters: Filters{Priority: "B", CreatedAfter: after},
+			clause:  `WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false AND priority = $3 AND created_at >= $4`,
+			args:    []any{"", int64(1), "B", after},
+		},
+		{
+			name:    "No priority",
+			filters: Filters{Priority: PriorityNone},
+			clause:  `WHERE text ILIKE '%' || $1 || '%' AND user_id = $2 AND archived = false AND priority = ''`,
+			args:    []any{"", int64(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clause, args := todoFilterClause("", 1, nil, nil, tt.filters)
+			assert.Equal(t, clause, tt.clause)
+			assert.Equal(t, len(args), len(tt.args))
+			for i := range args {
+				assert.Equal(t, args[i], tt.args[i])
+			}
+		})
+	}
+}
+
+// testTags returns n distinct tags, each beginning with prefix.
+func testTags(prefix string, n int) []string {
+	tags := make([]string, n)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return tags
+}
+
+func TestValidateTodoLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   TodoLimits
+		contexts int
+		valid    bool
+	}{
+		{name: "Configured limit accepts 8", limits: TodoLimits{MaxContexts: 10, MaxProjects: 10}, contexts: 8, valid: true},
+		{name: "Configured limit rejects 11", limits: TodoLimits{MaxContexts: 10, MaxProjects: 10}, contexts: 11, valid: false},
+		{name: "Default limit accepts 5", limits: TodoLimits{}, contexts: 5, valid: true},
+		{name: "Default limit rejects 6", limits: TodoLimits{}, contexts: 6, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			todo := &Todo{Text: "call mom", Contexts: testTags("tag", tt.contexts)}
+
+			ValidateTodo(v, todo, tt.limits)
+
+			assert.Equal(t, v.Valid(), tt.valid)
+			if !tt.valid {
+				assert.StringContains(t, v.Errors["contexts"], "must be no more than")
+			}
+		})
+	}
+}
+
+func TestValidateTodoTextLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		rule  string
+		valid bool
+	}{
+		{name: "ASCII at the limit", text: strings.Repeat("a", 500), valid: true},
+		{name: "ASCII over the limit", text: strings.Repeat("a", 501), rule: validator.RuleTooLong},
+		// Each character is 3 bytes, so the text is 1500 bytes.
+		{name: "Multibyte at the limit", text: strings.Repeat("日", 500), valid: true},
+		{name: "Multibyte over the limit", text: strings.Repeat("日", 501), rule: validator.RuleTooLong},
+		{name: "Emoji at the limit", text: strings.Repeat("🥛", 500), valid: true},
+		{name: "Invalid UTF-8", text: "buy milk \xff", rule: validator.RuleFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateTodo(v, &Todo{Text: tt.text}, TodoLimits{})
+
+			assert.Equal(t, v.Valid(), tt.valid)
+			if !tt.valid {
+				assert.Equal(t, v.Rules["text"], tt.rule)
+			}
+		})
+	}
+}
+
+func TestValidateTodoErrorKeys(t *testing.T) {
+	t.Run("Too many projects", func(t *testing.T) {
+		v := validator.New()
+		ValidateTodo(v, &Todo{Text: "call mom", Projects: testTags("p", 6)}, TodoLimits{})
+
+		assert.Equal(t, v.Errors["projects"], "must be no more than 5 projects")
+		_, ok := v.Errors["contexts"]
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("Too many contexts and projects", func(t *testing.T) {
+		v := validator.New()
+		ValidateTodo(v, &Todo{Text: "call mom", Contexts: testTags("c", 6), Projects: testTags("p", 6)}, TodoLimits{})
+
+		assert.Equal(t, v.Errors["contexts"], "must be no more than 5 contexts")
+		assert.Equal(t, v.Errors["projects"], "must be no more than 5 projects")
+	})
+}
+
+func TestValidateTodoTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		contexts []string
+		projects []string
+		errors   map[string]string
+	}{
+		{
+			name:     "Valid tags",
+			contexts: []string{"phone", "at_home", "errand-2"},
+			projects: []string{"Godo", "v1_release"},
+		},
+		{
+			name:     "Empty context",
+			contexts: []string{"phone", ""},
+			errors:   map[string]string{"contexts[1]": "must not be empty"},
+		},
+		{
+			name:     "Whitespace in project",
+			projects: []string{"home improvement"},
+			errors:   map[string]string{"projects[0]": "must contain only letters, digits, hyphens, and underscores"},
+		},
+		{
+			name:     "Punctuation in context",
+			contexts: []string{"@phone"},
+			errors:   map[string]string{"contexts[0]": "must contain only letters, digits, hyphens, and underscores"},
+		},
+		{
+			name:     "Maximum length",
+			projects: []string{strings.Repeat("a", 50)},
+		},
+		{
+			name:     "Too long",
+			projects: []string{strings.Repeat("a", 51)},
+			errors:   map[string]string{"projects[0]": "must be no more than 50 bytes"},
+		},
+		{
+			name:     "Invalid contexts and projects",
+			contexts: []string{"ok", "not ok"},
+			projects: []string{""},
+			errors: map[string]string{
+				"contexts[1]": "must contain only letters, digits, hyphens, and underscores",
+				"projects[0]": "must not be empty",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateTodo(v, &Todo{Text: "call mom", Contexts: tt.contexts, Projects: tt.projects}, TodoLimits{})
+
+			assert.Equal(t, len(v.Errors), len(tt.errors))
+			for key, msg := range tt.errors {
+				assert.Equal(t, v.Errors[key], msg)
+			}
+		})
+	}
+}
+
+func TestTodoQueryTimeout(t *testing.T) {
+	m, mock := newTestTodoModel(t)
+	m.Timeout = 10 * time.Millisecond
+
+	mock.ExpectExec("DELETE FROM todos WHERE id = \\$1 AND user_id = \\$2").
+		WithArgs(1, 1).
+		WillDelayFor(time.Second).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	start := time.Now()
+	err := m.DeleteIfOwned(context.Background(), 1, 1)
+
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, time.Since(start) < time.Second, true)
+}
+
+func TestDistinctTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string
+		prefix string
+		arg    string
+		tags   []string
+	}{
+		{name: "No prefix", column: "contexts", prefix: "", arg: "", tags: []string{"phone", "work"}},
+		{name: "Prefix", column: "contexts", prefix: "wo", arg: "wo", tags: []string{"work"}},
+		{name: "Projects", column: "projects", prefix: "go", arg: "go", tags: []string{"godo"}},
+		{name: "Wildcards are escaped", column: "projects", prefix: "v1_%", arg: `v1\_\%`, tags: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+
+			rows := sqlmock.NewRows([]string{"count", "tag"})
+			for _, tag := range tt.tags {
+				rows.AddRow(len(tt.tags), tag)
+			}
+			query := fmt.Sprintf(`SELECT DISTINCT tag FROM todos, unnest(%s) AS tag WHERE user_id = $1 AND archived = false AND tag ILIKE $2 || '%%' ) AS tags ORDER BY tag LIMIT $3 OFFSET $4`, tt.column)
+			mock.ExpectQuery(regexp.QuoteMeta(query)).
+				WithArgs(1, tt.arg, MaxDistinctTags, 0).
+				WillReturnRows(rows)
+
+			filters := Filters{Page: 1, PageSize: MaxDistinctTags}
+			var tags []string
+			var err error
+			if tt.column == "contexts" {
+				tags, _, err = m.DistinctContexts(context.Background(), 1, tt.prefix, filters)
+			} else {
+				tags, _, err = m.DistinctProjects(context.Background(), 1, tt.prefix, filters)
+			}
+
+			assert.IsNil(t, err)
+			assert.Equal(t, len(tags), len(tt.tags))
+			for i := range tags {
+				assert.Equal(t, tags[i], tt.tags[i])
+			}
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDistinctTagsPaging(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     int
+		pageSize int
+		offset   int
+		tags     []string // The tags on the requested page.
+		lastPage int
+	}{
+		{name: "First page", page: 1, pageSize: 2, offset: 0, tags: []string{"errands", "home"}, lastPage: 3},
+		{name: "Middle page", page: 2, pageSize: 2, offset: 2, tags: []string{"phone", "store"}, lastPage: 3},
+		{name: "Last page", page: 3, pageSize: 2, offset: 4, tags: []string{"work"}, lastPage: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+
+			// There are 5 matching contexts in total. The tags are ordered by
+			// the query, so the rows are returned in the same order.
+			rows := sqlmock.NewRows([]string{"count", "tag"})
+			for _, tag := range tt.tags {
+				rows.AddRow(5, tag)
+			}
+			mock.ExpectQuery(`SELECT count\(\*\) OVER\(\), tag FROM (.+) ORDER BY tag LIMIT \$3 OFFSET \$4`).
+				WithArgs(1, "", tt.pageSize, tt.offset).
+				WillReturnRows(rows)
+
+			tags, metadata, err := m.DistinctContexts(context.Background(), 1, "", Filters{Page: tt.page, PageSize: tt.pageSize})
+
+			assert.IsNil(t, err)
+			assert.Equal(t, strings.Join(tags, ","), strings.Join(tt.tags, ","))
+			assert.Equal(t, metadata.CurrentPage, tt.page)
+			assert.Equal(t, metadata.LastPage, tt.lastPage)
+			assert.Equal(t, metadata.TotalRecords, 5)
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	// If there are no matching tags, the metadata is empty.
+	m, mock := newTestTodoModel(t)
+	mock.ExpectQuery(`SELECT count`).WillReturnRows(sqlmock.NewRows([]string{"count", "tag"}))
+
+	tags, metadata, err := m.DistinctContexts(context.Background(), 1, "zzz", Filters{Page: 1, PageSize: 20})
+
+	assert.IsNil(t, err)
+	assert.Equal(t, len(tags), 0)
+	assert.Equal(t, metadata, PaginationData{})
+}
+
+func TestTodoStarred(t *testing.T) {
+	t.Run("Insert", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO todos (text, user_id, contexts, projects, priority, completed, archived, starred, note, due_date, completed_at)`)).
+			WithArgs("call mom", int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), "", false, false, true, "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(1, time.Now(), 1, nil))
+
+		err := m.Insert(context.Background(), &Todo{Text: "call mom", UserID: 1, Starred: true})
+		assert.IsNil(t, err)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`starred = $7, note = NULLIF($8, ''), due_date = $9, version = version + 1,`)).
+			WithArgs("call mom", sqlmock.AnyArg(), sqlmock.AnyArg(), "", false, false, true, "", nil, int64(1), int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "completed_at"}).AddRow(2, nil))
+
+		todo := &Todo{ID: 1, Text: "call mom", Starred: true, Version: 1}
+		err := m.Update(context.Background(), todo)
+		assert.IsNil(t, err)
+		assert.Equal(t, todo.Version, 2)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		columns := []string{"id", "user_id", "created_at", "text", "contexts", "projects", "priority", "completed", "archived", "starred", "note", "version", "due_date", "priority_escalated_at", "completed_at"}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, created_at, text, contexts, projects, priority, completed, archived, starred, COALESCE(note, ''), version`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, 1, time.Now(), "call mom", "{}", "{}", "", false, false, true, "", 1, nil, nil, nil))
+
+		todo, err := m.GetTodoIfOwned(context.Background(), 1, 1)
+		assert.IsNil(t, err)
+		assert.Equal(t, todo.Starred, true)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Starred todos are sorted first", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`AND starred = true ORDER BY starred DESC, text DESC, id ASC`)).
+			WithArgs("", int64(1), 20, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}))
+
+		filters := Filters{Page: 1, PageSize: 20, Sort: "-text", SortSafelist: []string{"-text"}, Starred: true}
+		_, _, err := m.GetAll(context.Background(), "", 1, nil, nil, filters)
+		assert.IsNil(t, err)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTodoArchived(t *testing.T) {
+	t.Run("Insert", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO todos (text, user_id, contexts, projects, priority, completed, archived, starred`)).
+			WithArgs("call mom", int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), "", true, true, false, "", nil).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version", "completed_at"}).AddRow(1, time.Now(), 1, time.Now()))
+
+		err := m.Insert(context.Background(), &Todo{Text: "call mom", UserID: 1, Completed: true, Archived: true})
+		assert.IsNil(t, err)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`completed = $5, archived = $6, starred = $7`)).
+			WithArgs("call mom", sqlmock.AnyArg(), sqlmock.AnyArg(), "", false, true, false, "", nil, int64(1), int32(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "completed_at"}).AddRow(2, nil))
+
+		todo := &Todo{ID: 1, Text: "call mom", Archived: true, Version: 1}
+		err := m.Update(context.Background(), todo)
+		assert.IsNil(t, err)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		columns := []string{"id", "user_id", "created_at", "text", "contexts", "projects", "priority", "completed", "archived", "starred", "note", "version", "due_date", "priority_escalated_at", "completed_at"}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, user_id, created_at, text, contexts, projects, priority, completed, archived, starred`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, 1, time.Now(), "call mom", "{}", "{}", "", true, true, false, "", 1, nil, nil, time.Now()))
+
+		todo, err := m.GetTodoIfOwned(context.Background(), 1, 1)
+		assert.IsNil(t, err)
+		assert.Equal(t, todo.Archived, true)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GetAll", func(t *testing.T) {
+		m, mock := newTestTodoModel(t)
+
+		columns := []string{"count", "id", "created_at", "text", "contexts", "projects", "priority", "completed", "archived", "starred", "note", "version", "due_date", "priority_escalated_at", "completed_at"}
+		mock.ExpectQuery(regexp.QuoteMeta(`priority, completed, archived, starred`)).
+			WithArgs("", int64(1), 20, 0).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, 1, time.Now(), "call mom", "{}", "{}", "", true, true, false, "", 1, nil, nil, time.Now()))
+
+		filters := Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}, OnlyArchived: true}
+		todos, _, err := m.GetAll(context.Background(), "", 1, nil, nil, filters)
+		assert.IsNil(t, err)
+		assert.Equal(t, len(todos), 1)
+		assert.Equal(t, todos[0].Archived, true)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateCompletedAt(t *testing.T) {
+	completedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		completed   bool
+		completedAt any // The completed_at returned by the database.
+		want        *time.Time
+	}{
+		{name: "Completed", completed: true, completedAt: completedAt, want: &completedAt},
+		{name: "Not completed", completed: false, completedAt: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, mock := newTestTodoModel(t)
+
+			mock.ExpectQuery(regexp.QuoteMeta(`completed_at = CASE WHEN NOT $5 THEN NULL WHEN completed THEN completed_at ELSE NOW() END WHERE id = $10 AND version = $11 RETURNING version, completed_at`)).
+				WillReturnRows(sqlmock.NewRows([]string{"version", "completed_at"}).AddRow(2, tt.completedAt))
+
+			// The todo's old timestamp is replaced by the one from the database.
+			stale := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			todo := &Todo{ID: 1, Text: "call mom", Completed: tt.completed, CompletedAt: &stale, Version: 1}
+			err := m.Update(context.Background(), todo)
+			assert.IsNil(t, err)
+			assert.Equal(t, todo.CompletedAt == nil, tt.want == nil)
+			if tt.want != nil {
+				assert.Equal(t, *todo.CompletedAt, *tt.want)
+			}
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestParseTodo(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   Todo
+		errMsg string
+	}{
+		{name: "Text only", line: "call mom", want: Todo{Text: "call mom"}},
+		{
+			name: "Completed with priority and tags",
+			line: "x (A) call mom @phone +family @phone",
+			want: Todo{Text: "call mom @phone +family @phone", Completed: true, Priority: "A", Contexts: []string{"phone"}, Projects: []string{"family"}},
+		},
+		{name: "Dates are skipped", line: "x 2024-01-02 2024-01-01 call mom", want: Todo{Text: "call mom", Completed: true}},
+		{name: "Lowercase priority is text", line: "(a) call mom", want: Todo{Text: "(a) call mom"}},
+		{name: "Bare prefixes are text", line: "call mom @ +", want: Todo{Text: "call mom @ +"}},
+		{name: "No text", line: "x (A)", errMsg: "todo has no text"},
+		{name: "Too many contexts", line: "call mom @" + strings.Join(testTags("tag", maxParsedTags+1), " @"), errMsg: "todo has more than 100 contexts"},
+		{name: "Too many projects", line: "call mom +" + strings.Join(testTags("tag", maxParsedTags+1), " +"), errMsg: "todo has more than 100 projects"},
+		{name: "Repeated tags are counted once", line: "call mom " + strings.Repeat("@phone ", maxParsedTags+1), want: Todo{Text: "call mom" + strings.Repeat(" @phone", maxParsedTags+1), Contexts: []string{"phone"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo, err := ParseTodo(tt.line)
+
+			if tt.errMsg != "" {
+				assert.Equal(t, err.Error(), tt.errMsg)
+				return
+			}
+			assert.IsNil(t, err)
+			assert.Equal(t, todo.Text, tt.want.Text)
+			assert.Equal(t, todo.Completed, tt.want.Completed)
+			assert.Equal(t, todo.Priority, tt.want.Priority)
+			assert.Equal(t, strings.Join(todo.Contexts, ","), strings.Join(tt.want.Contexts, ","))
+			assert.Equal(t, strings.Join(todo.Projects, ","), strings.Join(tt.want.Projects, ","))
+		})
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "  buy   milk  ", want: "buy milk"},
+		{text: "call\tmom\n@phone", want: "call mom @phone"},
+		{text: "buy milk", want: "buy milk"},
+		{text: " \n\t ", want: ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, NormalizeText(tt.text), tt.want)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"encoding/hex"
+	"fmt"
 	"time"
 
 	validator "github.com/kvnloughead/godo/internal"
@@ -61,23 +63,31 @@ type Token struct {
 	Scope     Scope     `json:"-"`
 }
 
-// The generateToken function accepts a user ID, an expiry duration, and a
-// scope, and returns a Token struct.
+// tokenRandomBytes is the number of random bytes in a token.
+const tokenRandomBytes = 16
+
+// TokenPlaintextLength is the length of a plaintext token, in bytes. It is the
+// length of tokenRandomBytes bytes encoded to base-32 without padding, which
+// uses 8 characters for every 5 bytes, rounded up.
+const TokenPlaintextLength = (tokenRandomBytes*8 + 4) / 5
+
+// The generateToken function accepts a user ID, an expiry duration, a scope,
+// and the current time, and returns a Token struct that expires ttl after now.
 //
 // The plaintext token is generated via cryptographically-secure pseudo-random
-// generation (CSPRNG) and encoded to a base-32 string. The resulting plaintex
-// string will be 26 bytes long.
+// generation (CSPRNG) and encoded to a base-32 string. The resulting plaintext
+// string will be TokenPlaintextLength bytes long.
 //
 // The hash is generated from the plaintext token using SHA-256.
-func generateToken(userID int64, ttl time.Duration, scope Scope) (*Token, error) {
+func generateToken(userID int64, ttl time.Duration, scope Scope, now time.Time) (*Token, error) {
 	token := Token{
 		UserID: userID,
-		Expiry: time.Now().Add(ttl),
+		Expiry: now.Add(ttl),
 		Scope:  scope,
 	}
 
 	// Fill a slice of bytes with random bytes from CSPRNG.
-	randomBytes := make([]byte, 16)
+	randomBytes := make([]byte, tokenRandomBytes)
 	_, err := rand.Read(randomBytes)
 	if err != nil {
 		return nil, err
@@ -98,17 +108,19 @@ func generateToken(userID int64, ttl time.Duration, scope Scope) (*Token, error)
 }
 
 // ValidateTokenPlaintext uses validator.Validator to check if the plaintext
-// string provided is exactly 26 bytes long. This is the number of bytes
-// generated with 16 bytes of randomness are encoded into base-32.
+// string provided is exactly TokenPlaintextLength bytes long, the length of
+// the tokens created by generateToken.
 func ValidateTokenPlaintext(v *validator.Validator, plaintext string) {
-	v.Check(plaintext != "", "token", "must be provided")
-	v.Check(len(plaintext) == 26, "token", "must be 26 bytes long")
+	v.CheckRule(plaintext != "", "token", validator.RuleRequired, "must be provided")
+	v.CheckRule(len(plaintext) == TokenPlaintextLength, "token", validator.RuleFormat, fmt.Sprintf("must be %d bytes long", TokenPlaintextLength))
 }
 
 // The TokenModel struct encapsulates database interactions with the tokens
 // table.
 type TokenModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration // Timeout for each query. Defaults to DefaultQueryTimeout.
+	Clock   Clock         // Provides the expiry of tokens. Defaults to SystemClock.
 }
 
 // The TokenModel's New method creates a new token struct, inserts the
@@ -117,7 +129,7 @@ type TokenModel struct {
 // It calls generateToken to generate the random plaintext string and its hash,
 // and calls TokenModel.Insert to insert the record.
 func (m TokenModel) New(userID int64, ttl time.Duration, scope Scope) (*Token, error) {
-	token, err := generateToken(userID, ttl, scope)
+	token, err := generateToken(userID, ttl, scope, clockNow(m.Clock))
 	if err != nil {
 		return nil, err
 	}
@@ -135,20 +147,76 @@ func (m TokenModel) Insert(token *Token) error {
 
 	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
 	defer cancel()
 
 	_, err := m.DB.ExecContext(ctx, query, args...)
 	return err
 }
 
+// TokenSummary describes an active token without exposing it. Only the first
+// few characters of the token's hash are included, so that tokens can be told
+// apart.
+type TokenSummary struct {
+	HashPrefix string    `json:"hash_prefix"`
+	Scope      Scope     `json:"scope"`
+	Expiry     time.Time `json:"expiry"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// tokenHashPrefixLength is the number of hex characters of a token's hash that
+// are included in a TokenSummary.
+const tokenHashPrefixLength = 8
+
+// The TokenModel's GetAllForUser method returns summaries of all of the user's
+// unexpired tokens, ordered from newest to oldest.
+func (m TokenModel) GetAllForUser(userID int64) ([]*TokenSummary, error) {
+	query := `
+		SELECT hash, scope, expiry, created_at
+		FROM tokens
+		WHERE user_id = $1 AND expiry > $2
+		ORDER BY created_at DESC`
+
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, clockNow(m.Clock))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*TokenSummary{}
+	for rows.Next() {
+		var token TokenSummary
+		var hash []byte
+
+		err := rows.Scan(&hash, &token.Scope, &token.Expiry, &token.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		token.HashPrefix = hex.EncodeToString(hash)
+		if len(token.HashPrefix) > tokenHashPrefixLength {
+			token.HashPrefix = token.HashPrefix[:tokenHashPrefixLength]
+		}
+		tokens = append(tokens, &token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
 // The TokenModel's DeleteAllForUser method deletes all tokens that match
 // the given scope and user ID.
 func (m TokenModel) DeleteAllForUser(scope Scope, userID int64) error {
 
 	query := `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
 	defer cancel()
 
 	_, err := m.DB.ExecContext(ctx, query, scope, userID)
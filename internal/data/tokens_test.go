@@ -0,0 +1,40 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	validator "github.com/kvnloughead/godo/internal"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestTokenPlaintextLength(t *testing.T) {
+	// Generated tokens must always pass validation.
+	token, err := generateToken(1, time.Hour, Authentication, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(token.Plaintext), TokenPlaintextLength)
+	assert.Equal(t, TokenPlaintextLength, 26)
+
+	tests := []struct {
+		name      string
+		plaintext string
+		valid     bool
+	}{
+		{name: "Generated", plaintext: token.Plaintext, valid: true},
+		{name: "Correct length", plaintext: strings.Repeat("A", TokenPlaintextLength), valid: true},
+		{name: "Too short", plaintext: strings.Repeat("A", TokenPlaintextLength-1)},
+		{name: "Too long", plaintext: strings.Repeat("A", TokenPlaintextLength+1)},
+		{name: "Empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateTokenPlaintext(v, tt.plaintext)
+			assert.Equal(t, v.Valid(), tt.valid)
+		})
+	}
+}
@@ -39,7 +39,9 @@ func (u *User) IsAnonymous() bool {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration // Timeout for each query. Defaults to DefaultQueryTimeout.
+	Clock   Clock         // Provides the time of logins and token checks. Defaults to SystemClock.
 }
 
 // Insert adds a new record to the users table. It accepts a pointer to a
@@ -56,7 +58,7 @@ func (m UserModel) Insert(user *User) error {
 
 	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
@@ -86,7 +88,7 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 	// An empty struct to store the document returned by the query.
 	var user User
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
 	defer cancel()
 
 	// Run the query and populate the empty user struct. Since we have a unique
@@ -127,10 +129,10 @@ func (m UserModel) GetForToken(scope Scope, tokenPlaintext string) (*User, error
 		AND tokens.scope = $2
 		AND tokens.expiry > $3`
 
-	args := []any{tokenHash[:], scope, time.Now()}
+	args := []any{tokenHash[:], scope, clockNow(m.Clock)}
 	var user User
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
@@ -178,7 +180,7 @@ func (m UserModel) Update(user *User) error {
 		user.Version,
 	}
 
-	ctx, cancel := CreateTimeoutContext(QueryTimeout)
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
@@ -197,6 +199,84 @@ func (m UserModel) Update(user *User) error {
 	return nil
 }
 
+// UpdateLastLogin sets the user's last_login_at to the current time, and
+// returns its previous value. The previous value is nil if the user has never
+// logged in. If there is no user with the given ID, an ErrRecordNotFound is
+// returned.
+func (m UserModel) UpdateLastLogin(id int64) (*time.Time, error) {
+	// The subquery reads the previous value, since RETURNING only has access to
+	// the updated row. FOR UPDATE prevents concurrent logins from both reading
+	// the same value.
+	query := `
+		UPDATE users
+		SET last_login_at = $2
+		FROM (SELECT id, last_login_at FROM users WHERE id = $1 FOR UPDATE) AS previous
+		WHERE users.id = previous.id
+		RETURNING previous.last_login_at`
+
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
+	defer cancel()
+
+	var previous sql.NullTime
+	err := m.DB.QueryRowContext(ctx, query, id, clockNow(m.Clock)).Scan(&previous)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if !previous.Valid {
+		return nil, nil
+	}
+	return &previous.Time, nil
+}
+
+// Delete removes a user and all of their data in a single transaction. The
+// user's todos, tokens, and permissions are deleted before the user record.
+// If there is no user with the given ID, an ErrRecordNotFound is returned and
+// the transaction is rolled back.
+func (m UserModel) Delete(id int64) error {
+	ctx, cancel := CreateTimeoutContext(queryTimeout(m.Timeout))
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op if the transaction has already been committed.
+	defer tx.Rollback()
+
+	queries := []string{
+		`DELETE FROM todos WHERE user_id = $1`,
+		`DELETE FROM tokens WHERE user_id = $1`,
+		`DELETE FROM users_permissions WHERE user_id = $1`,
+	}
+	for _, query := range queries {
+		_, err = tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
+
 // The password struct stores a password's plaintext representation and the
 // computed hash. The plaintext field is a pointer to a string, so we can
 // distinguish between non-existent passwords and empty string passwords.
@@ -242,11 +322,12 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 // using validator.EmailRX to determine validity. If any checks fail, errors
 // are added to the validator's Errors map.
 func ValidateEmail(v *validator.Validator, email string) {
-	v.Check(email != "", "email", "must be provided")
-	v.Check(validator.Matches(
+	v.CheckRule(email != "", "email", validator.RuleRequired, "must be provided")
+	v.CheckRule(validator.Matches(
 		email,
 		validator.EmailRX),
 		"email",
+		validator.RuleFormat,
 		"must be a valid email adress",
 	)
 }
@@ -255,9 +336,9 @@ func ValidateEmail(v *validator.Validator, email string) {
 // and between 8 and 72 bytes long. If any checks fail, errors
 // are added to the validator's Errors map.
 func ValidatePasswordPlaintext(v *validator.Validator, password string) {
-	v.Check(password != "", "password", "must be provided")
-	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
-	v.Check(len(password) <= 72, "password", "must be no more than 72 bytes long")
+	v.CheckRule(password != "", "password", validator.RuleRequired, "must be provided")
+	v.CheckRule(len(password) >= 8, "password", validator.RuleTooShort, "must be at least 8 bytes long")
+	v.CheckRule(len(password) <= 72, "password", validator.RuleTooLong, "must be no more than 72 bytes long")
 }
 
 // ValidateUser checks various aspects of a user object. If any checks fail,
@@ -269,8 +350,8 @@ func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 //
 // A panic occurs if Password.hash is nil.
 func ValidateUser(v *validator.Validator, u *User) {
-	v.Check(u.Name != "", "name", "must be provided")
-	v.Check(len(u.Name) < 500, "name", "must be no more than 500 bytes long")
+	v.CheckRule(u.Name != "", "name", validator.RuleRequired, "must be provided")
+	v.CheckRule(len(u.Name) < 500, "name", validator.RuleTooLong, "must be no more than 500 bytes long")
 	ValidateEmail(v, u.Email)
 
 	// The plaintext password will be nil in some circumstances, so we omit the
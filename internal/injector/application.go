@@ -2,6 +2,7 @@ package injector
 
 import (
 	"database/sql"
+	"fmt"
 	"log/slog"
 	"sync"
 
@@ -16,17 +17,59 @@ type Application struct {
 	Models data.Models
 	Mailer mailer.Mailer
 
+	// DB is the database connection pool used by Models. It is kept for health
+	// checks, and may be nil.
+	DB *sql.DB
+
 	// The WaitGroup instance allows us to track goroutines in progress, to
 	// prevent shutdown until they are all completed. No need for initialization,
 	// the zero-valued sync.WaitGroup is useable, with counter set to 0.
 	WG sync.WaitGroup
 }
 
-func NewApplication(cfg Config, logger *slog.Logger, db *sql.DB) *Application {
+// MailerOptions returns the options for connecting to the SMTP server.
+func (cfg Config) MailerOptions() mailer.Options {
+	return mailer.Options{
+		Host:              cfg.SMTP.Host,
+		Port:              cfg.SMTP.Port,
+		Username:          cfg.SMTP.Username,
+		Password:          cfg.SMTP.Password,
+		Sender:            cfg.SMTP.Sender,
+		TLS:               mailer.TLSMode(cfg.SMTP.TLS),
+		AllowInsecureAuth: cfg.SMTP.AllowInsecureAuth,
+		Timeout:           cfg.SMTP.Timeout,
+	}
+}
+
+// NewApplication returns an Application with the provided dependencies. An
+// error is returned if the mailer's email templates are invalid, or if
+// cfg.TodoStore is unknown. If cfg.DisableEmails is set, the mailer logs emails
+// instead of sending them. The Models' Clock is a data.SystemClock.
+func NewApplication(cfg Config, logger *slog.Logger, db *sql.DB) (*Application, error) {
+	m, err := mailer.New(cfg.MailerOptions())
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DisableEmails {
+		m = m.Disable(logger)
+	}
+
+	models := data.NewModels(db, cfg.DB.QueryTimeout, cfg.DB.Retries, data.SystemClock{})
+	switch cfg.TodoStore {
+	case "", "postgres":
+	case "memory":
+		todos := data.NewMemoryTodoModel()
+		todos.Clock = models.Clock
+		models.Todos = todos
+	default:
+		return nil, fmt.Errorf("unknown todo store %q", cfg.TodoStore)
+	}
+
 	return &Application{
 		Config: cfg,
 		Logger: logger,
-		Models: data.NewModels(db),
-		Mailer: mailer.New(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.Sender),
-	}
+		DB:     db,
+		Models: models,
+		Mailer: m,
+	}, nil
 }
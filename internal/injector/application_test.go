@@ -0,0 +1,36 @@
+package injector
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+	"github.com/kvnloughead/godo/internal/data"
+)
+
+func TestNewApplicationTodoStore(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		store  string
+		memory bool
+	}{
+		{store: "", memory: false},
+		{store: "postgres", memory: false},
+		{store: "memory", memory: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.store, func(t *testing.T) {
+			app, err := NewApplication(Config{TodoStore: tt.store}, logger, nil)
+			assert.IsNil(t, err)
+
+			_, ok := app.Models.Todos.(*data.MemoryTodoModel)
+			assert.Equal(t, ok, tt.memory)
+		})
+	}
+
+	_, err := NewApplication(Config{TodoStore: "sqlite"}, logger, nil)
+	assert.Equal(t, err.Error(), `unknown todo store "sqlite"`)
+}
@@ -1,26 +1,40 @@
 package logger
 
 import (
-	"log"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 )
 
-func NewLogger() *slog.Logger {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Fatalf("Failed to get home directory: %v", err)
-	}
-	logDir := filepath.Join(homeDir, ".config/godo/logs")
-	os.MkdirAll(logDir, 0755)
-	logFile := filepath.Join(logDir, "app.log")
-	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.FileMode(0644))
-	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
+// NewLogger returns a logger that appends to app.log in a "logs" directory in
+// configDir, which is created if it doesn't exist.
+//
+// If the log file can't be opened, such as when the directory isn't writable,
+// a warning is printed to stderr, and the returned logger writes errors to
+// stderr instead, so that the CLI can still be used.
+func NewLogger(configDir string) *slog.Logger {
+	return newLogger(configDir, os.Stderr)
+}
+
+// LogFile returns the path to the log file that NewLogger writes to.
+func LogFile(configDir string) string {
+	return filepath.Join(configDir, "logs", "app.log")
+}
+
+func newLogger(configDir string, stderr io.Writer) *slog.Logger {
+	logFile := LogFile(configDir)
+
+	err := os.MkdirAll(filepath.Dir(logFile), 0755)
+	if err == nil {
+		var file *os.File
+		file, err = os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.FileMode(0644))
+		if err == nil {
+			return slog.New(slog.NewTextHandler(file, nil))
+		}
 	}
 
-	handler := slog.NewTextHandler(file, nil)
-	logger := slog.New(handler)
-	return logger
+	fmt.Fprintf(stderr, "Warning: failed to open log file, logging errors to stderr instead: %v\n", err)
+	return slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 }
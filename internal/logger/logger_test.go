@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	dir := t.TempDir()
+	var stderr bytes.Buffer
+
+	logger := newLogger(dir, &stderr)
+	logger.Info("executing command", "command", "list")
+
+	data, err := os.ReadFile(filepath.Join(dir, "logs", "app.log"))
+	assert.IsNil(t, err)
+	assert.StringContains(t, string(data), "executing command")
+	assert.Equal(t, stderr.String(), "")
+}
+
+func TestNewLoggerFallback(t *testing.T) {
+	// The config directory is a file, so the log directory can't be created.
+	dir := filepath.Join(t.TempDir(), "godo")
+	err := os.WriteFile(dir, nil, 0644)
+	assert.IsNil(t, err)
+
+	var stderr bytes.Buffer
+	logger := newLogger(dir, &stderr)
+	assert.StringContains(t, stderr.String(), "Warning: failed to open log file")
+
+	// Only errors are written to stderr.
+	stderr.Reset()
+	logger.Info("executing command", "command", "list")
+	assert.Equal(t, stderr.String(), "")
+
+	logger.Error("failed to send request", "error", errors.New("connection refused"))
+	assert.StringContains(t, stderr.String(), "failed to send request")
+	assert.Equal(t, strings.Count(stderr.String(), "\n"), 1)
+}
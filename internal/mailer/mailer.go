@@ -4,8 +4,17 @@ package mailer
 
 import (
 	"bytes"
+	"crypto/tls"
 	"embed"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"path"
 	"time"
 
 	"github.com/go-mail/mail/v2"
@@ -14,42 +23,234 @@ import (
 //go:embed "templates"
 var templateFS embed.FS
 
-// Type Mailer is a struct containing a mail.Dialer instance (to connect to an
-// SMTP server) and sender information for use in sent emails.
+// Dialer connects to an SMTP server. It is satisfied by *mail.Dialer, and can
+// be replaced by a fake in tests.
+type Dialer interface {
+	Dial() (mail.SendCloser, error)
+	DialAndSend(m ...*mail.Message) error
+}
+
+// Type Mailer is a struct containing a Dialer instance (to connect to an
+// SMTP server), sender information for use in sent emails, and the parsed
+// email templates, keyed by file name.
 //
 // The sender field should be a string of the format "Name <email>".
 type Mailer struct {
-	dialer *mail.Dialer
-	sender string
+	dialer    Dialer
+	sender    string
+	templates map[string]*template.Template
+
+	// attempts is the maximum number of times Send tries to send an email, and
+	// backoff is the delay before the first retry. The delay doubles after each
+	// subsequent attempt.
+	attempts int
+	backoff  time.Duration
+
+	// If suppressLogger isn't nil, emails aren't sent, and are logged to it
+	// instead. See Disable.
+	suppressLogger *slog.Logger
+}
+
+// Default retry settings used by New and NewWithDialer.
+const (
+	defaultAttempts = 3
+	defaultBackoff  = 500 * time.Millisecond
+)
+
+// requiredTemplates are the sub-templates that each email template must
+// define. Every email has both a plain-text and an HTML body, so that it can
+// be read by clients that don't render HTML.
+var requiredTemplates = []string{"subject", "plainBody", "htmlBody"}
+
+// TLSMode is how the connection to the SMTP server is encrypted.
+type TLSMode string
+
+const (
+	// TLSNone sends everything in plain text, including credentials if
+	// Options.AllowInsecureAuth is set.
+	TLSNone TLSMode = "none"
+	// TLSStartTLS connects in plain text, usually to port 587, and upgrades the
+	// connection with STARTTLS before authenticating. The connection fails if
+	// the server doesn't support STARTTLS.
+	TLSStartTLS TLSMode = "starttls"
+	// TLSImplicit encrypts the connection from the start, usually on port 465.
+	TLSImplicit TLSMode = "tls"
+)
+
+// Options are the settings for connecting to the SMTP server. See New.
+type Options struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+
+	// TLS is the TLS mode. If it is empty, TLSImplicit is used for port 465,
+	// and TLSStartTLS otherwise.
+	TLS TLSMode
+
+	// AllowInsecureAuth allows the username and password to be sent when TLS
+	// is TLSNone. Otherwise, New returns an error in that case.
+	AllowInsecureAuth bool
+
+	// Timeout limits connecting to the server, and each read and write.
+	// Defaults to 5 seconds.
+	Timeout time.Duration
+
+	// TLSConfig is the TLS configuration for the connection. If it is nil,
+	// the server's certificate is verified against the system's roots.
+	TLSConfig *tls.Config
+}
+
+// defaultTimeout is used by New if Options.Timeout isn't set.
+const defaultTimeout = 5 * time.Second
+
+// New returns an instance of a Mailer struct that connects to the SMTP server
+// with the provided options.
+//
+// An error is returned if the TLS mode isn't valid, or if credentials would be
+// sent over an unencrypted connection without opts.AllowInsecureAuth. The
+// email templates are also parsed once, here. An error is returned if any of
+// them can't be parsed, or doesn't define each of the requiredTemplates.
+func New(opts Options) (Mailer, error) {
+	mode := opts.TLS
+	if mode == "" {
+		mode = TLSStartTLS
+		if opts.Port == 465 {
+			mode = TLSImplicit
+		}
+	}
+
+	dialer := mail.NewDialer(opts.Host, opts.Port, opts.Username, opts.Password)
+	dialer.TLSConfig = opts.TLSConfig
+	dialer.Timeout = opts.Timeout
+	if dialer.Timeout == 0 {
+		dialer.Timeout = defaultTimeout
+	}
+
+	switch mode {
+	case TLSNone:
+		if opts.Username != "" && !opts.AllowInsecureAuth {
+			return Mailer{}, errors.New("mailer: credentials can't be sent without TLS unless insecure authentication is allowed")
+		}
+		dialer.SSL = false
+		dialer.StartTLSPolicy = mail.NoStartTLS
+	case TLSStartTLS:
+		dialer.SSL = false
+		dialer.StartTLSPolicy = mail.MandatoryStartTLS
+	case TLSImplicit:
+		dialer.SSL = true
+	default:
+		return Mailer{}, fmt.Errorf("mailer: invalid TLS mode %q, must be none, starttls or tls", mode)
+	}
+
+	return NewWithDialer(dialer, opts.Sender)
+}
+
+// NewWithDialer returns an instance of a Mailer struct that uses the provided
+// Dialer to connect to the SMTP server. See New for details.
+func NewWithDialer(dialer Dialer, sender string) (Mailer, error) {
+	return newMailer(dialer, sender, templateFS)
 }
 
-// New returns an instance of a Mailer struct with the provided SMTP server
-// settings. The dialer is configured to have a 5-second timeout when an email
-// is sent.
-func New(host string, port int, username, password, sender string) Mailer {
-	dialer := mail.NewDialer(host, port, username, password)
-	dialer.Timeout = 5 * time.Second
+// newMailer returns a Mailer using the templates in the "templates" directory
+// of fsys.
+func newMailer(dialer Dialer, sender string, fsys fs.FS) (Mailer, error) {
+	templates, err := parseTemplates(fsys)
+	if err != nil {
+		return Mailer{}, err
+	}
+
 	return Mailer{
-		dialer: dialer,
-		sender: sender,
+		dialer:    dialer,
+		sender:    sender,
+		templates: templates,
+		attempts:  defaultAttempts,
+		backoff:   defaultBackoff,
+	}, nil
+}
+
+// parseTemplates parses each template file in the "templates" directory of
+// fsys, and checks that it defines each of the requiredTemplates. The parsed
+// templates are returned in a map keyed by file name.
+func parseTemplates(fsys fs.FS) (map[string]*template.Template, error) {
+	files, err := fs.Glob(fsys, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
 	}
+
+	templates := make(map[string]*template.Template, len(files))
+
+	for _, file := range files {
+		tmpl, err := template.New("email").ParseFS(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("mailer: %w", err)
+		}
+
+		for _, name := range requiredTemplates {
+			if tmpl.Lookup(name) == nil {
+				return nil, fmt.Errorf("mailer: template %s doesn't define %q", file, name)
+			}
+		}
+
+		templates[path.Base(file)] = tmpl
+	}
+
+	return templates, nil
 }
 
-// The Send method uses the calling Mailer to send an email to the provided
-// recipient. Errors are returned if the template file, or its "subject"
-// sub-template, can't be parsed. The data object is used to provide data for
-// interpolation in the templates.
-func (m Mailer) Send(recipient, tmplFile string, data any) error {
-	// Parse the provided template file.
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+tmplFile)
+// Disable returns a copy of the Mailer that doesn't send emails. Instead, Send
+// logs "email suppressed" to the logger, along with the recipient and
+// subject. Templates are still executed, so template errors are returned as
+// usual. This is intended for test and development environments that
+// shouldn't depend on an SMTP server.
+func (m Mailer) Disable(logger *slog.Logger) Mailer {
+	m.suppressLogger = logger
+	return m
+}
+
+// Disabled reports whether the Mailer was returned by Disable.
+func (m Mailer) Disabled() bool {
+	return m.suppressLogger != nil
+}
+
+// Check verifies that the SMTP server is reachable by connecting to it. If a
+// username is configured, the connection is also authenticated. The connection
+// is closed without sending anything. If the Mailer is disabled, no connection
+// is made, and nil is returned.
+func (m Mailer) Check() error {
+	if m.Disabled() {
+		return nil
+	}
+
+	conn, err := m.dialer.Dial()
 	if err != nil {
 		return err
 	}
+	return conn.Close()
+}
+
+// The Send method uses the calling Mailer to send an email to the provided
+// recipient. An error is returned if the template file doesn't exist, or if
+// any of its sub-templates can't be executed. The data object is used to
+// provide data for interpolation in the templates.
+//
+// Transient failures, such as network errors and 4xx SMTP replies, are retried
+// with exponential backoff. Permanent failures, such as a 5xx reply rejecting
+// the recipient, are returned immediately.
+//
+// If the Mailer is disabled, the email is logged instead of being sent.
+func (m Mailer) Send(recipient, tmplFile string, data any) error {
+	// Look up the provided template file, which was parsed by New.
+	tmpl, ok := m.templates[tmplFile]
+	if !ok {
+		return fmt.Errorf("mailer: unknown template %s", tmplFile)
+	}
 
 	// Execute the "plainbody" template from the provided template file, passing
 	// in the dynamic data argument, and storing the result in a bytes.Buffer.
 	subject := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	err := tmpl.ExecuteTemplate(subject, "subject", data)
 	if err != nil {
 		return err
 	}
@@ -80,17 +281,56 @@ func (m Mailer) Send(recipient, tmplFile string, data any) error {
 	msg.SetBody("text/plain", plainBody.String())
 	msg.AddAlternative("text/html", htmlBody.String()) // Must call after SetBody
 
-	// Try to send email three times before admitting failure. A 500ms timeout
-	// is set between each attempt.
-	for i := 1; i <= 3; i++ {
+	if m.Disabled() {
+		m.suppressLogger.Info("email suppressed",
+			"recipient", recipient,
+			"template", tmplFile,
+			"subject", subject.String())
+		return nil
+	}
+
+	// Try to send the email up to m.attempts times before admitting failure,
+	// doubling the delay between each attempt.
+	delay := m.backoff
+	for i := 1; i <= m.attempts; i++ {
 		err = m.dialer.DialAndSend(msg)
 		if nil == err {
 			return nil
 		}
 
-		time.Sleep(500 * time.Millisecond)
+		if !isTransient(err) {
+			return err
+		}
+
+		if i < m.attempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	// If every attempt fails, return the last error.
+	return fmt.Errorf("failed after %d attempts: %w", m.attempts, err)
+}
+
+// isTransient reports whether a failure to send an email is likely to be
+// temporary. Network errors and 4xx SMTP replies are transient. 5xx SMTP
+// replies, and any other errors, are permanent.
+func isTransient(err error) bool {
+	// mail.SendError doesn't implement Unwrap, so its cause is unwrapped here.
+	var sendErr *mail.SendError
+	if errors.As(err, &sendErr) {
+		err = sendErr.Cause
+	}
+
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
 	}
 
-	// If sending fails three times, return the error.
-	return err
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
 }
@@ -0,0 +1,400 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+// fakeSMTPServer is a minimal SMTP server for testing. It accepts every
+// message, unless reply returns a non-empty reply for a command. The reply
+// function is passed the number of the connection, starting from 1, and the
+// command's verb.
+//
+// If the server has a TLS configuration, it offers STARTTLS, or, if it was
+// started with implicit TLS, encrypts connections from the start.
+type fakeSMTPServer struct {
+	ln        net.Listener
+	reply     func(conn int, verb string) string
+	tlsConfig *tls.Config
+
+	mu    sync.Mutex
+	conns int
+	auths []bool // Whether each AUTH command was sent over TLS.
+}
+
+// newFakeSMTPServer starts a fakeSMTPServer on a random local port. It is
+// closed when the test finishes.
+func newFakeSMTPServer(t *testing.T, reply func(conn int, verb string) string) *fakeSMTPServer {
+	return startFakeSMTPServer(t, reply, nil, false)
+}
+
+// startFakeSMTPServer starts a fakeSMTPServer that uses the TLS
+// configuration, if it isn't nil. If implicit is true, connections are
+// encrypted from the start. Otherwise, they are upgraded with STARTTLS.
+func startFakeSMTPServer(t *testing.T, reply func(conn int, verb string) string, tlsConfig *tls.Config, implicit bool) *fakeSMTPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeSMTPServer{ln: ln, reply: reply, tlsConfig: tlsConfig}
+	if implicit {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			n := s.conns
+			s.mu.Unlock()
+			go s.handle(conn, n)
+		}
+	}()
+
+	return s
+}
+
+// connections returns the number of connections the server has accepted.
+func (s *fakeSMTPServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// authentications returns whether each AUTH command the server received was
+// sent over TLS.
+func (s *fakeSMTPServer) authentications() []bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.auths)
+}
+
+// options returns the Options for a Mailer that sends to the server.
+func (s *fakeSMTPServer) options() Options {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return Options{Host: addr.IP.String(), Port: addr.Port, Sender: "Godo <no-reply@example.com>", TLS: TLSNone}
+}
+
+// mailer returns a Mailer that sends to the server, with a short backoff.
+func (s *fakeSMTPServer) mailer(t *testing.T) Mailer {
+	t.Helper()
+
+	m, err := New(s.options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.backoff = time.Millisecond
+	return m
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn, n int) {
+	defer func() { conn.Close() }()
+
+	_, encrypted := conn.(*tls.Conn)
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(strings.ToUpper(line), " ")
+
+		if reply := s.reply(n, verb); reply != "" {
+			tp.PrintfLine(reply)
+			continue
+		}
+
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			if s.tlsConfig != nil && !encrypted {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, encrypted = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			s.mu.Lock()
+			s.auths = append(s.auths, encrypted)
+			s.mu.Unlock()
+			tp.PrintfLine("235 Authenticated")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			tp.ReadDotLines()
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+// testTLSConfigs returns the TLS configurations for a server with a
+// self-signed certificate for 127.0.0.1, and for a client that trusts it.
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client = &tls.Config{RootCAs: roots, ServerName: "127.0.0.1"}
+	return server, client
+}
+
+// testData is the data for the token_activation.tmpl template.
+var testData = map[string]any{"Token": map[string]any{"Plaintext": "ABCDEFGHIJKLMNOPQRSTUVWXYZ"}}
+
+func TestSendRetriesTransientFailures(t *testing.T) {
+	// The first two connections are rejected with a temporary failure.
+	s := newFakeSMTPServer(t, func(conn int, verb string) string {
+		if verb == "MAIL" && conn < 3 {
+			return "421 4.7.0 Try again later"
+		}
+		return ""
+	})
+
+	err := s.mailer(t).Send("test@example.com", "token_activation.tmpl", testData)
+
+	assert.IsNil(t, err)
+	assert.Equal(t, s.connections(), 3)
+}
+
+func TestSendGivesUpAfterAttempts(t *testing.T) {
+	s := newFakeSMTPServer(t, func(conn int, verb string) string {
+		if verb == "MAIL" {
+			return "421 4.7.0 Try again later"
+		}
+		return ""
+	})
+
+	err := s.mailer(t).Send("test@example.com", "token_activation.tmpl", testData)
+
+	assert.Equal(t, err != nil, true)
+	assert.StringContains(t, err.Error(), "failed after 3 attempts")
+	assert.Equal(t, s.connections(), 3)
+}
+
+func TestSendDoesNotRetryPermanentFailures(t *testing.T) {
+	s := newFakeSMTPServer(t, func(conn int, verb string) string {
+		if verb == "RCPT" {
+			return "550 5.1.1 No such user"
+		}
+		return ""
+	})
+
+	err := s.mailer(t).Send("nobody@example.com", "token_activation.tmpl", testData)
+
+	assert.Equal(t, err != nil, true)
+	assert.StringContains(t, err.Error(), "No such user")
+	assert.Equal(t, s.connections(), 1)
+}
+
+func TestNewValidatesTemplates(t *testing.T) {
+	valid := `{{define "subject"}}Hi{{end}}{{define "plainBody"}}Hi{{end}}{{define "htmlBody"}}<p>Hi</p>{{end}}`
+
+	tests := []struct {
+		name   string
+		files  fstest.MapFS
+		errMsg string
+	}{
+		{
+			name:  "Valid",
+			files: fstest.MapFS{"templates/welcome.tmpl": {Data: []byte(valid)}},
+		},
+		{
+			name:   "Malformed",
+			files:  fstest.MapFS{"templates/welcome.tmpl": {Data: []byte(`{{define "subject"}}Hi{{.User.}}{{end}}`)}},
+			errMsg: "welcome.tmpl",
+		},
+		{
+			name:   "Missing plain-text body",
+			files:  fstest.MapFS{"templates/welcome.tmpl": {Data: []byte(`{{define "subject"}}Hi{{end}}{{define "htmlBody"}}<p>Hi</p>{{end}}`)}},
+			errMsg: `doesn't define "plainBody"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newMailer(nil, "Godo <no-reply@example.com>", tt.files)
+
+			if tt.errMsg == "" {
+				assert.IsNil(t, err)
+			} else {
+				assert.Equal(t, err != nil, true)
+				assert.StringContains(t, err.Error(), tt.errMsg)
+			}
+		})
+	}
+
+	// The embedded templates are valid.
+	_, err := NewWithDialer(nil, "Godo <no-reply@example.com>")
+	assert.IsNil(t, err)
+}
+
+func TestSendUnknownTemplate(t *testing.T) {
+	s := newFakeSMTPServer(t, func(conn int, verb string) string { return "" })
+
+	err := s.mailer(t).Send("test@example.com", "missing.tmpl", testData)
+
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, s.connections(), 0)
+}
+
+func TestSendDisabled(t *testing.T) {
+	s := newFakeSMTPServer(t, func(conn int, verb string) string { return "" })
+
+	var logs bytes.Buffer
+	m := s.mailer(t).Disable(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	err := m.Send("test@example.com", "token_activation.tmpl", testData)
+
+	assert.IsNil(t, err)
+	assert.IsNil(t, m.Check())
+	assert.Equal(t, s.connections(), 0)
+	assert.StringContains(t, logs.String(), "email suppressed")
+	assert.StringContains(t, logs.String(), "recipient=test@example.com")
+
+	// Template errors are still returned.
+	err = m.Send("test@example.com", "missing.tmpl", testData)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestNewTLSModes(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+
+	tests := []struct {
+		name      string
+		tlsConfig *tls.Config // The server's TLS configuration.
+		implicit  bool
+		mode      TLSMode
+		insecure  bool
+		auths     []bool
+	}{
+		{name: "STARTTLS", tlsConfig: serverTLS, mode: TLSStartTLS, auths: []bool{true}},
+		{name: "Implicit TLS", tlsConfig: serverTLS, implicit: true, mode: TLSImplicit, auths: []bool{true}},
+		{name: "Insecure authentication", mode: TLSNone, insecure: true, auths: []bool{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startFakeSMTPServer(t, func(int, string) string { return "" }, tt.tlsConfig, tt.implicit)
+
+			opts := s.options()
+			opts.Username = "user"
+			opts.Password = "pa55word"
+			opts.TLS = tt.mode
+			opts.AllowInsecureAuth = tt.insecure
+			opts.TLSConfig = clientTLS
+			m, err := New(opts)
+			assert.IsNil(t, err)
+
+			err = m.Send("test@example.com", "token_activation.tmpl", testData)
+			assert.IsNil(t, err)
+			assert.Equal(t, slices.Equal(s.authentications(), tt.auths), true)
+		})
+	}
+
+	t.Run("STARTTLS not supported", func(t *testing.T) {
+		s := newFakeSMTPServer(t, func(int, string) string { return "" })
+
+		opts := s.options()
+		opts.Username = "user"
+		opts.TLS = TLSStartTLS
+		m, err := New(opts)
+		assert.IsNil(t, err)
+
+		// The connection fails rather than falling back to plain text.
+		var unsupported mail.StartTLSUnsupportedError
+		err = m.Check()
+		assert.Equal(t, errors.As(err, &unsupported), true)
+		assert.Equal(t, len(s.authentications()), 0)
+	})
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		errMsg string
+	}{
+		{name: "STARTTLS with credentials", opts: Options{Username: "user", TLS: TLSStartTLS}},
+		{name: "No TLS without credentials", opts: Options{TLS: TLSNone}},
+		{name: "No TLS with credentials", opts: Options{Username: "user", TLS: TLSNone}, errMsg: "credentials can't be sent without TLS"},
+		{name: "Insecure authentication allowed", opts: Options{Username: "user", TLS: TLSNone, AllowInsecureAuth: true}},
+		{name: "Invalid mode", opts: Options{TLS: "ssl"}, errMsg: `invalid TLS mode "ssl"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts)
+
+			if tt.errMsg == "" {
+				assert.IsNil(t, err)
+			} else {
+				assert.Equal(t, err != nil, true)
+				assert.StringContains(t, err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
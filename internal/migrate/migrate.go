@@ -0,0 +1,259 @@
+// Package migrate applies versioned SQL migrations to a Postgres database.
+//
+// The version is stored in the schema_migrations table in the same way as the
+// migrate CLI (github.com/golang-migrate/migrate), so that databases migrated
+// with either can be migrated with the other.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// lockID is the key of the advisory lock that is held while migrations are
+// applied, so that instances of the API that start at the same time don't
+// apply the same migration twice.
+const lockID = 7_310_352_418
+
+var (
+	// ErrDirty is returned if a migration applied by the migrate CLI failed
+	// part way through. The database must be fixed by hand, and the version
+	// forced with the migrate CLI.
+	ErrDirty = errors.New("migrate: database is dirty")
+
+	// ErrUnknownVersion is returned if the database's version doesn't match any
+	// of the migrations, such as when it was migrated by a newer release.
+	ErrUnknownVersion = errors.New("migrate: unknown database version")
+)
+
+// filenameRX matches the names of migration files, such as
+// 000001_create_users_table.up.sql.
+var filenameRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change to the database schema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string // The SQL that applies the migration.
+	Down    string // The SQL that reverts it.
+}
+
+// Load reads the migrations in the root directory of fsys, ordered by version.
+// Files that aren't named like migrations are ignored. Each migration must
+// have both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := filenameRX.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migrate: invalid version in %s", entry.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by %s and %s", version, m.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: migration %d_%s must have an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies and reverts Migrations. The migrations must be ordered by
+// version, as they are by Load.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// Version returns the version of the last migration applied to the database,
+// or 0 if none have been applied, and whether the database is dirty.
+func (m Migrator) Version(ctx context.Context) (int64, bool, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	if err := createVersionTable(ctx, conn); err != nil {
+		return 0, false, err
+	}
+	return version(ctx, conn)
+}
+
+// Up applies all of the migrations that are newer than the database's version,
+// in order. Each migration is applied in a transaction with the change to the
+// version, so a migration that fails leaves the database at the previous
+// version. The migrations that were applied are returned, even if there is an
+// error.
+func (m Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn, current int64) error {
+		for _, migration := range m.Migrations {
+			if migration.Version <= current {
+				continue
+			}
+			if err := apply(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migrate: applying %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts up to steps migrations, newest first. The migrations that were
+// reverted are returned, even if there is an error.
+func (m Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn, current int64) error {
+		if current == 0 {
+			return nil
+		}
+
+		i := slices.IndexFunc(m.Migrations, func(migration Migration) bool {
+			return migration.Version == current
+		})
+		if i == -1 {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, current)
+		}
+
+		for ; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.Migrations[i]
+			var previous int64
+			if i > 0 {
+				previous = m.Migrations[i-1].Version
+			}
+			if err := apply(ctx, conn, migration.Down, previous); err != nil {
+				return fmt.Errorf("migrate: reverting %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// withLock calls fn with a connection that holds the advisory lock, and the
+// database's version. An ErrDirty is returned if the database is dirty.
+func (m Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, current int64) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return err
+	}
+	// The lock is released with a fresh context, in case ctx was canceled.
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if err := createVersionTable(ctx, conn); err != nil {
+		return err
+	}
+
+	current, dirty, err := version(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d", ErrDirty, current)
+	}
+
+	return fn(conn, current)
+}
+
+// createVersionTable creates the schema_migrations table, if it doesn't exist.
+func createVersionTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`)
+	return err
+}
+
+// version reads the database's version from the schema_migrations table. The
+// table is empty if no migrations have been applied.
+func version(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var version int64
+	var dirty bool
+
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// apply runs the SQL of a migration and sets the database's version to
+// version, in a single transaction. If version is 0, the version is removed.
+func apply(ctx context.Context, conn *sql.Conn, query string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `TRUNCATE schema_migrations`); err != nil {
+		return err
+	}
+	if version > 0 {
+		_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
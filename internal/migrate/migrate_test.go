@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/kvnloughead/godo/db/migrations"
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+// testMigrations are three migrations, with versions 1 to 3.
+var testMigrations = fstest.MapFS{
+	"000001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id int);")},
+	"000001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	"000002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id int);")},
+	"000002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	"000003_create_c.up.sql":   {Data: []byte("CREATE TABLE c (id int);")},
+	"000003_create_c.down.sql": {Data: []byte("DROP TABLE c;")},
+	"README.md":                {Data: []byte("Not a migration.")},
+}
+
+// newTestMigrator returns a Migrator for testMigrations, using a mock
+// database.
+func newTestMigrator(t *testing.T) (Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := Load(testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Migrator{DB: db, Migrations: m}, mock
+}
+
+// expectLock expects the lock to be taken, the version table to be created,
+// and the version to be read. A version of 0 means that the table is empty.
+func expectLock(mock sqlmock.Sqlmock, version int64, dirty bool) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).
+		WithArgs(lockID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rows := sqlmock.NewRows([]string{"version", "dirty"})
+	if version > 0 {
+		rows.AddRow(version, dirty)
+	}
+	mock.ExpectQuery(`SELECT version, dirty FROM schema_migrations`).WillReturnRows(rows)
+}
+
+// expectApply expects the query to be run in a transaction that sets the
+// version.
+func expectApply(mock sqlmock.Sqlmock, query string, version int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`TRUNCATE schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	if version > 0 {
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`)).
+			WithArgs(version).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+// expectUnlock expects the lock to be released.
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).
+		WithArgs(lockID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// versions returns the versions of the migrations.
+func versions(migrations []Migration) []int64 {
+	v := []int64{}
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func TestLoad(t *testing.T) {
+	m, err := Load(testMigrations)
+	assert.IsNil(t, err)
+	assert.Equal(t, len(m), 3)
+	assert.Equal(t, m[1], Migration{Version: 2, Name: "create_b", Up: "CREATE TABLE b (id int);", Down: "DROP TABLE b;"})
+
+	// Each migration needs a down file.
+	_, err = Load(fstest.MapFS{"000001_create_a.up.sql": {Data: []byte("CREATE TABLE a (id int);")}})
+	assert.StringContains(t, err.Error(), "must have an up and a down file")
+
+	// The embedded migrations are numbered from 1, without gaps.
+	m, err = Load(migrations.FS)
+	assert.IsNil(t, err)
+	for i, migration := range m {
+		assert.Equal(t, migration.Version, int64(i+1))
+	}
+}
+
+func TestUp(t *testing.T) {
+	t.Run("Pending migrations", func(t *testing.T) {
+		migrator, mock := newTestMigrator(t)
+
+		expectLock(mock, 1, false)
+		expectApply(mock, "CREATE TABLE b (id int);", 2)
+		expectApply(mock, "CREATE TABLE c (id int);", 3)
+		expectUnlock(mock)
+
+		applied, err := migrator.Up(context.Background())
+		assert.IsNil(t, err)
+		assert.Equal(t, fmt.Sprint(versions(applied)), "[2 3]")
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Up to date", func(t *testing.T) {
+		migrator, mock := newTestMigrator(t)
+
+		expectLock(mock, 3, false)
+		expectUnlock(mock)
+
+		applied, err := migrator.Up(context.Background())
+		assert.IsNil(t, err)
+		assert.Equal(t, len(applied), 0)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Failed migration", func(t *testing.T) {
+		migrator, mock := newTestMigrator(t)
+
+		expectLock(mock, 0, false)
+		expectApply(mock, "CREATE TABLE a (id int);", 1)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE b (id int);")).WillReturnError(errors.New("syntax error"))
+		mock.ExpectRollback()
+		expectUnlock(mock)
+
+		applied, err := migrator.Up(context.Background())
+		assert.StringContains(t, err.Error(), "applying 2_create_b: syntax error")
+		assert.Equal(t, len(applied), 1)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Dirty", func(t *testing.T) {
+		migrator, mock := newTestMigrator(t)
+
+		expectLock(mock, 2, true)
+		expectUnlock(mock)
+
+		_, err := migrator.Up(context.Background())
+		assert.Equal(t, errors.Is(err, ErrDirty), true)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDown(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  int64
+		steps    int
+		reverted []int64
+		setup    func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name:     "One step",
+			version:  3,
+			steps:    1,
+			reverted: []int64{3},
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApply(mock, "DROP TABLE c;", 2)
+			},
+		},
+		{
+			name:     "More steps than migrations",
+			version:  2,
+			steps:    5,
+			reverted: []int64{2, 1},
+			setup: func(mock sqlmock.Sqlmock) {
+				expectApply(mock, "DROP TABLE b;", 1)
+				// Reverting the first migration removes the version.
+				expectApply(mock, "DROP TABLE a;", 0)
+			},
+		},
+		{
+			name:     "Nothing applied",
+			version:  0,
+			steps:    1,
+			reverted: []int64{},
+			setup:    func(mock sqlmock.Sqlmock) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator, mock := newTestMigrator(t)
+
+			expectLock(mock, tt.version, false)
+			tt.setup(mock)
+			expectUnlock(mock)
+
+			reverted, err := migrator.Down(context.Background(), tt.steps)
+			assert.IsNil(t, err)
+			assert.Equal(t, fmt.Sprint(versions(reverted)), fmt.Sprint(tt.reverted))
+			assert.IsNil(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("Unknown version", func(t *testing.T) {
+		migrator, mock := newTestMigrator(t)
+
+		expectLock(mock, 9, false)
+		expectUnlock(mock)
+
+		_, err := migrator.Down(context.Background(), 1)
+		assert.Equal(t, errors.Is(err, ErrUnknownVersion), true)
+		assert.IsNil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -9,15 +9,37 @@ import (
 // https://html.spec.whatwg.org/multipage/input.html#valid-e-mail-address
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// TagRX is a regex pattern matching a valid context or project. Tags may only
+// contain letters, digits, hyphens, and underscores.
+var TagRX = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
+
+// Rule codes identify which rule a value broke, so that clients can handle
+// validation errors without parsing their messages.
+const (
+	RuleRequired   = "required"     // A value must be provided.
+	RuleTooShort   = "too_short"    // The value is too short.
+	RuleTooLong    = "too_long"     // The value is too long.
+	RuleTooMany    = "too_many"     // A list has too many values.
+	RuleDuplicate  = "duplicate"    // A value is repeated, or already exists.
+	RuleFormat     = "format"       // The value isn't in the required format.
+	RuleNotAllowed = "not_allowed"  // The value isn't one of the permitted values.
+	RuleOutOfRange = "out_of_range" // A number is too small or too large.
+	RuleConflict   = "conflict"     // The value conflicts with another value.
+	RuleNotFound   = "not_found"    // The value doesn't match an existing record.
+	RuleInvalid    = "invalid"      // Any other error.
+)
+
 // Validator is a struct for validating JSON responses. It contains several
-// validation methods and an Error map to store error messages.
+// validation methods and an Error map to store error messages. The Rules map
+// stores the rule code of each error, with the same keys.
 type Validator struct {
 	Errors map[string]string
+	Rules  map[string]string
 }
 
-// New returns a Validator instance with an empty Errors map.
+// New returns a Validator instance with empty Errors and Rules maps.
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{Errors: make(map[string]string), Rules: make(map[string]string)}
 }
 
 // Validator.Valid returns true if the validator's Errors map is empty.
@@ -26,10 +48,17 @@ func (v *Validator) Valid() bool {
 }
 
 // Validator.AddError adds an error to the validator's Errors map (as long as
-// if it doesn't already exist.
+// if it doesn't already exist), with the rule code RuleInvalid.
 func (v *Validator) AddError(key, message string) {
+	v.AddRuleError(key, RuleInvalid, message)
+}
+
+// Validator.AddRuleError adds an error with a rule code to the validator's
+// Errors and Rules maps, as long as there isn't already an error for the key.
+func (v *Validator) AddRuleError(key, rule, message string) {
 	if _, exists := v.Errors[key]; !exists {
 		v.Errors[key] = message
+		v.Rules[key] = rule
 	}
 }
 
@@ -40,6 +69,13 @@ func (v *Validator) Check(ok bool, key, message string) {
 	}
 }
 
+// Validator.CheckRule adds an error with a rule code if ok is false.
+func (v *Validator) CheckRule(ok bool, key, rule, message string) {
+	if !ok {
+		v.AddRuleError(key, rule, message)
+	}
+}
+
 // Returns true if the string matches the regex.
 func Matches(s string, rx *regexp.Regexp) bool {
 	return rx.MatchString(s)
@@ -5,6 +5,46 @@ import (
 	"runtime/debug"
 )
 
+// Info contains the version control information embedded in the binary.
+type Info struct {
+	Revision string // The revision number of the build.
+	Time     string // The time the revision was made.
+	Modified bool   // True if the build has changes not in the revision.
+}
+
+// ReadInfo returns the version control information embedded in the binary. It
+// is obtained via debug.ReadBuildInfo(), which provides at runtime the same
+// information that is provided by `go version -m <binary>`. Fields are empty
+// if the information isn't available.
+func ReadInfo() Info {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Info{}
+	}
+	return infoFrom(info)
+}
+
+// infoFrom returns the version control information in the build settings of
+// info.
+func infoFrom(info *debug.BuildInfo) Info {
+	var vcsInfo Info
+
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			vcsInfo.Revision = s.Value
+		case "vcs.time":
+			vcsInfo.Time = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				vcsInfo.Modified = true
+			}
+		}
+	}
+
+	return vcsInfo
+}
+
 // Version returns a version number of the form
 //
 //	`<time>-<revision-number>[-dirty]`
@@ -14,34 +54,16 @@ import (
 // the code in the binary has modifications that aren't included in the
 // revision.
 //
-// This information is obtained via debug.ReadBuildInfo(), which provides at
-// runtime the same information that is provided by `go version -m <binary>`.
+// This information is obtained via ReadInfo.
 func Version() string {
-	var (
-		revision string
-		time     string
-		modified bool
-	)
-
-	info, ok := debug.ReadBuildInfo()
-	if ok {
-		for _, s := range info.Settings {
-			switch s.Key {
-			case "vcs.revision":
-				revision = s.Value
-			case "vcs.time":
-				time = s.Value
-			case "vcs.modified":
-				if s.Value == "true" {
-					modified = true
-				}
-			}
-		}
-	}
+	return ReadInfo().Version()
+}
 
-	if modified {
-		return fmt.Sprintf("%s-%s-dirty", time, revision)
+// Version returns the version number made from i. See the Version function.
+func (i Info) Version() string {
+	if i.Modified {
+		return fmt.Sprintf("%s-%s-dirty", i.Time, i.Revision)
 	}
 
-	return fmt.Sprintf("%s-%s", time, revision)
+	return fmt.Sprintf("%s-%s", i.Time, i.Revision)
 }
@@ -0,0 +1,54 @@
+package vcs
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/kvnloughead/godo/internal/assert"
+)
+
+func TestInfoFrom(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings []debug.BuildSetting
+		want     Info
+		version  string
+	}{
+		{
+			name: "Clean",
+			settings: []debug.BuildSetting{
+				{Key: "GOOS", Value: "linux"},
+				{Key: "vcs", Value: "git"},
+				{Key: "vcs.revision", Value: "c663c2e"},
+				{Key: "vcs.time", Value: "2024-05-26T23:49:46Z"},
+				{Key: "vcs.modified", Value: "false"},
+			},
+			want:    Info{Revision: "c663c2e", Time: "2024-05-26T23:49:46Z"},
+			version: "2024-05-26T23:49:46Z-c663c2e",
+		},
+		{
+			name: "Dirty",
+			settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "c663c2e"},
+				{Key: "vcs.time", Value: "2024-05-26T23:49:46Z"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+			want:    Info{Revision: "c663c2e", Time: "2024-05-26T23:49:46Z", Modified: true},
+			version: "2024-05-26T23:49:46Z-c663c2e-dirty",
+		},
+		{
+			name:     "No VCS settings",
+			settings: []debug.BuildSetting{{Key: "GOARCH", Value: "amd64"}},
+			want:     Info{},
+			version:  "-",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := infoFrom(&debug.BuildInfo{Settings: tt.settings})
+			assert.Equal(t, info, tt.want)
+			assert.Equal(t, info.Version(), tt.version)
+		})
+	}
+}